@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// configAlias maps a deprecated config key to the key that replaced it.
+type configAlias struct {
+	Old string
+	New string
+	// Removal is the plugin version that stops accepting Old.
+	Removal string
+}
+
+// configAliases lists every deprecated key. parseConfig and Validate read
+// deprecated keys through it, and the schema documents them from it. No key
+// has been renamed yet; add an entry here when one is.
+var configAliases = []configAlias{}
+
+// aliasUse records a deprecated key found in a configuration. Conflict is set
+// when the replacement was set as well.
+type aliasUse struct {
+	configAlias
+	Conflict bool
+}
+
+// resolveConfigAliases returns raw with every deprecated key moved to its
+// replacement, and the deprecated keys that were used. When both keys are set
+// the replacement wins. raw itself is not modified.
+func resolveConfigAliases(raw map[string]any) (map[string]any, []aliasUse) {
+	var uses []aliasUse
+	resolved := raw
+	for _, alias := range configAliases {
+		value, ok := raw[alias.Old]
+		if !ok {
+			continue
+		}
+		if len(uses) == 0 {
+			resolved = make(map[string]any, len(raw))
+			for k, v := range raw {
+				resolved[k] = v
+			}
+		}
+		_, conflict := raw[alias.New]
+		uses = append(uses, aliasUse{configAlias: alias, Conflict: conflict})
+		delete(resolved, alias.Old)
+		if !conflict {
+			resolved[alias.New] = value
+		}
+	}
+	return resolved, uses
+}
+
+// message describes the deprecated key and its replacement.
+func (u aliasUse) message() string {
+	return fmt.Sprintf("%s is deprecated and will be removed in %s; use %s instead", u.Old, u.Removal, u.New)
+}
+
+// err reports a deprecated key that cannot be accepted: one set next to its
+// replacement, or any deprecated key under strict_config.
+func (u aliasUse) err(strict bool) error {
+	switch {
+	case u.Conflict:
+		return fmt.Errorf("%s and %s are both set; remove the deprecated %s", u.Old, u.New, u.Old)
+	case strict:
+		return fmt.Errorf("%s (strict_config)", u.message())
+	}
+	return nil
+}
+
+// validateAliasUses returns the first deprecated key that cannot be accepted.
+func validateAliasUses(uses []aliasUse, strict bool) error {
+	for _, use := range uses {
+		if err := use.err(strict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOutputs records which deprecated key supplied each option, as
+// config_aliases mapping the option to the key that was used.
+func addAliasOutputs(uses []aliasUse, outputs map[string]any) {
+	if len(uses) == 0 {
+		return
+	}
+	used := make(map[string]string, len(uses))
+	for _, use := range uses {
+		used[use.New] = use.Old
+	}
+	outputs["config_aliases"] = used
+}
+
+// withDeprecatedAliases adds a property for every deprecated key to schema,
+// copied from its replacement and marked deprecated, so the schema documents
+// every key that still parses. schema is returned unchanged when it cannot be
+// extended; checkConfigSchema then reports why.
+func withDeprecatedAliases(schema string) string {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return schema
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(root["properties"], &properties); err != nil {
+		return schema
+	}
+
+	for _, alias := range configAliases {
+		var property map[string]any
+		if err := json.Unmarshal(properties[alias.New], &property); err != nil || property == nil {
+			return schema
+		}
+		property["description"] = fmt.Sprintf("Deprecated alias of %s, removed in %s", alias.New, alias.Removal)
+		property["deprecated"] = true
+		encoded, err := marshalSchema(property, "")
+		if err != nil {
+			return schema
+		}
+		properties[alias.Old] = json.RawMessage(encoded)
+	}
+
+	encoded, err := marshalSchema(properties, "")
+	if err != nil {
+		return schema
+	}
+	root["properties"] = json.RawMessage(encoded)
+	out, err := marshalSchema(root, "\t")
+	if err != nil {
+		return schema
+	}
+	return out
+}
+
+// marshalSchema encodes v without escaping <, > and &, which descriptions such
+// as CARGO_REGISTRIES_<NAME>_TOKEN contain.
+func marshalSchema(v any, indent string) (string, error) {
+	var out strings.Builder
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
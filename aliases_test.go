@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// testAlias is a rename used to exercise the alias mechanism, since no
+// shipped key has been renamed yet.
+var testAlias = configAlias{Old: "verify_before_publish", New: "prepublish_verify", Removal: "3.0.0"}
+
+// withTestAliases swaps configAliases for aliases for the rest of the test.
+func withTestAliases(t *testing.T, aliases ...configAlias) {
+	t.Helper()
+	saved := configAliases
+	configAliases = aliases
+	t.Cleanup(func() { configAliases = saved })
+}
+
+func TestShippedConfigAliases(t *testing.T) {
+	var root struct {
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(configSchema), &root); err != nil {
+		t.Fatalf("config schema is not valid JSON: %v", err)
+	}
+	for _, alias := range configAliases {
+		if _, ok := root.Properties[alias.New]; !ok {
+			t.Errorf("%s is an alias of %s, which the schema does not list", alias.Old, alias.New)
+		}
+		if alias.Removal == "" {
+			t.Errorf("%s has no removal version", alias.Old)
+		}
+	}
+}
+
+func TestConfigAliases(t *testing.T) {
+	withTestAliases(t, testAlias)
+	var root struct {
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(withDeprecatedAliases(baseConfigSchema)), &root); err != nil {
+		t.Fatalf("config schema is not valid JSON: %v", err)
+	}
+
+	for _, alias := range configAliases {
+		t.Run(alias.Old, func(t *testing.T) {
+			replacement, ok := root.Properties[alias.New]
+			if !ok {
+				t.Fatalf("schema is missing the replacement %s", alias.New)
+			}
+			property, ok := root.Properties[alias.Old]
+			if !ok {
+				t.Fatalf("schema is missing the deprecated %s", alias.Old)
+			}
+			if property["deprecated"] != true {
+				t.Errorf("%s should be marked deprecated, got %v", alias.Old, property["deprecated"])
+			}
+			if property["type"] != replacement["type"] {
+				t.Errorf("%s type = %v, want %v", alias.Old, property["type"], replacement["type"])
+			}
+			if desc, _ := property["description"].(string); !strings.Contains(desc, alias.New) || !strings.Contains(desc, alias.Removal) {
+				t.Errorf("%s description %q should name %s and %s", alias.Old, desc, alias.New, alias.Removal)
+			}
+
+			// The deprecated key parses as its replacement, whose default is true
+			p := &CratesPlugin{}
+			raw := map[string]any{alias.Old: false}
+			cfg := p.parseConfig(raw)
+			if len(cfg.AliasesUsed) != 1 || cfg.AliasesUsed[0].Old != alias.Old {
+				t.Errorf("aliases used = %+v, want %s", cfg.AliasesUsed, alias.Old)
+			}
+			if _, ok := raw[alias.New]; ok {
+				t.Error("resolving aliases should not modify the raw config")
+			}
+
+			resp, err := p.Validate(context.Background(), raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var warning string
+			for _, e := range resp.Errors {
+				if e.Field == alias.Old {
+					warning = e.Message
+				}
+			}
+			for _, want := range []string{alias.New, alias.Removal} {
+				if !strings.Contains(warning, want) {
+					t.Errorf("warning %q should contain %q", warning, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveConfigAliases(t *testing.T) {
+	withTestAliases(t, testAlias)
+	tests := []struct {
+		name         string
+		raw          map[string]any
+		want         any
+		wantUses     int
+		wantConflict bool
+	}{
+		{name: "replacement only", raw: map[string]any{"prepublish_verify": false}, want: false},
+		{name: "deprecated only", raw: map[string]any{"verify_before_publish": false}, want: false, wantUses: 1},
+		{
+			name:         "both set",
+			raw:          map[string]any{"verify_before_publish": false, "prepublish_verify": true},
+			want:         true,
+			wantUses:     1,
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, uses := resolveConfigAliases(tt.raw)
+			if got := resolved["prepublish_verify"]; got != tt.want {
+				t.Errorf("prepublish_verify = %v, want %v", got, tt.want)
+			}
+			if _, ok := resolved["verify_before_publish"]; ok {
+				t.Error("deprecated key should be removed after resolving")
+			}
+			if len(uses) != tt.wantUses {
+				t.Fatalf("uses = %+v, want %d", uses, tt.wantUses)
+			}
+			if tt.wantUses > 0 && uses[0].Conflict != tt.wantConflict {
+				t.Errorf("conflict = %v, want %v", uses[0].Conflict, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestExecuteDeprecatedAlias(t *testing.T) {
+	withTestAliases(t, testAlias)
+	tests := []struct {
+		name         string
+		hook         plugin.Hook
+		config       map[string]any
+		wantErrorHas string
+	}{
+		{
+			name:   "deprecated key applies",
+			hook:   plugin.HookPrePublish,
+			config: map[string]any{"token": "secret", "verify_before_publish": false},
+		},
+		{
+			name:         "strict_config rejects it",
+			hook:         plugin.HookPostPublish,
+			config:       map[string]any{"token": "secret", "verify_before_publish": false, "strict_config": true},
+			wantErrorHas: "verify_before_publish is deprecated and will be removed in 3.0.0; use prepublish_verify instead",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    tt.hook,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantErrorHas != "" {
+				if resp.Success || !strings.Contains(resp.Error, tt.wantErrorHas) {
+					t.Errorf("error = %q, want it to contain %q", resp.Error, tt.wantErrorHas)
+				}
+				return
+			}
+			if !resp.Success {
+				t.Fatalf("unexpected failure: %s", resp.Error)
+			}
+			for _, call := range mock.GetCalls() {
+				if call.Args[0] == "publish" {
+					t.Errorf("expected verification to be skipped, got %+v", call)
+				}
+			}
+			aliases, _ := resp.Outputs["config_aliases"].(map[string]string)
+			if aliases["prepublish_verify"] != "verify_before_publish" {
+				t.Errorf("config_aliases = %v, want prepublish_verify from verify_before_publish", resp.Outputs["config_aliases"])
+			}
+		})
+	}
+}
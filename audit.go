@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditRecord is one audit_log line, written for every command the plugin
+// runs. Prev is the SHA-256 of the previous line, so removing or editing a
+// line breaks the chain from that point on.
+type auditRecord struct {
+	Time          string   `json:"time"`
+	CorrelationID string   `json:"correlation_id"`
+	Dir           string   `json:"dir,omitempty"`
+	Command       string   `json:"command"`
+	Args          []string `json:"args"`
+	ExitStatus    int      `json:"exit_status"`
+	Error         string   `json:"error,omitempty"`
+	DurationMS    int64    `json:"duration_ms"`
+	Prev          string   `json:"prev"`
+}
+
+// maxAuditTail bounds how much of an existing audit log is read to find the
+// last line for the hash chain.
+const maxAuditTail = 64 << 10
+
+// auditExecutor records every command run through the wrapped executor to
+// audit_log. Arguments are redacted like formatCommand and scrubbed of the
+// secrets known when the command runs, since token sources resolve late.
+type auditExecutor struct {
+	CommandExecutor
+
+	cfg           *Config
+	correlationID string
+	now           func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	prev     string
+	writeErr error
+}
+
+// openAuditLog opens cfg.AuditLog for appending and wraps executor. Without
+// audit_log it returns executor unchanged.
+func openAuditLog(executor CommandExecutor, cfg *Config, correlationID string, now func() time.Time) (CommandExecutor, *auditExecutor, error) {
+	if cfg.AuditLog == "" {
+		return executor, nil, nil
+	}
+	file, err := os.OpenFile(cfg.AuditLog, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return executor, nil, fmt.Errorf("audit_log disabled: failed to open %s: %v", cfg.AuditLog, err)
+	}
+	prev, err := lastLineHash(file)
+	if err != nil {
+		file.Close()
+		return executor, nil, fmt.Errorf("audit_log disabled: failed to read %s: %v", cfg.AuditLog, err)
+	}
+	audit := &auditExecutor{CommandExecutor: executor, cfg: cfg, correlationID: correlationID, now: now, file: file, prev: prev}
+	return audit, audit, nil
+}
+
+// lastLineHash returns the hash of the last line of the log, or "" when it
+// is empty.
+func lastLineHash(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	offset := max(info.Size()-maxAuditTail, 0)
+	scanner := bufio.NewScanner(io.NewSectionReader(file, offset, info.Size()-offset))
+	scanner.Buffer(make([]byte, 0, maxAuditTail), maxAuditTail)
+	last := ""
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			last = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if last == "" {
+		return "", nil
+	}
+	return lineHash(last), nil
+}
+
+// lineHash is the chain hash of an audit line, without its newline.
+func lineHash(line string) string {
+	sum := sha256.Sum256([]byte(line))
+	return hex.EncodeToString(sum[:])
+}
+
+// close closes the log and returns the warning for a failed write, if any.
+func (a *auditExecutor) close() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.file.Close()
+	if a.writeErr != nil {
+		return fmt.Sprintf("audit_log %s is incomplete: %v", a.cfg.AuditLog, a.writeErr)
+	}
+	return ""
+}
+
+// record appends the audit line for a finished command. Failures are kept
+// for close to report and never fail the command itself.
+func (a *auditExecutor) record(start time.Time, dir, name string, args []string, err error) {
+	scrub := newScrubber(knownSecrets(a.cfg))
+	redacted := redactArgs(args)
+	for i, arg := range redacted {
+		redacted[i] = scrub.scrub(arg)
+	}
+	entry := auditRecord{
+		Time:          start.UTC().Format(time.RFC3339Nano),
+		CorrelationID: a.correlationID,
+		Dir:           dir,
+		Command:       name,
+		Args:          redacted,
+		DurationMS:    a.now().Sub(start).Milliseconds(),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		entry.ExitStatus = exitErr.ExitCode()
+	default:
+		// The command did not run, or its status is unknown
+		entry.ExitStatus = -1
+		entry.Error = scrub.scrub(err.Error())
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry.Prev = a.prev
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		a.writeErr = marshalErr
+		return
+	}
+	if _, writeErr := a.file.Write(append(line, '\n')); writeErr != nil {
+		if a.writeErr == nil {
+			a.writeErr = writeErr
+		}
+		return
+	}
+	a.prev = lineHash(string(line))
+}
+
+// Run implements CommandExecutor.Run.
+func (a *auditExecutor) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	start := a.now()
+	output, err := a.CommandExecutor.Run(ctx, name, args...)
+	a.record(start, "", name, args, err)
+	return output, err
+}
+
+// RunInDir implements CommandExecutor.RunInDir.
+func (a *auditExecutor) RunInDir(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	start := a.now()
+	output, err := a.CommandExecutor.RunInDir(ctx, dir, name, args...)
+	a.record(start, dir, name, args, err)
+	return output, err
+}
+
+// RunWithEnv implements CommandExecutor.RunWithEnv. The environment is not
+// logged, as it carries the token.
+func (a *auditExecutor) RunWithEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	start := a.now()
+	output, err := a.CommandExecutor.RunWithEnv(ctx, dir, env, name, args...)
+	a.record(start, dir, name, args, err)
+	return output, err
+}
+
+// RunInEnv implements CommandExecutor.RunInEnv.
+func (a *auditExecutor) RunInEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	start := a.now()
+	output, err := a.CommandExecutor.RunInEnv(ctx, dir, env, name, args...)
+	a.record(start, dir, name, args, err)
+	return output, err
+}
+
+// Output implements CommandExecutor.Output.
+func (a *auditExecutor) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	start := a.now()
+	output, err := a.CommandExecutor.Output(ctx, name, args...)
+	a.record(start, "", name, args, err)
+	return output, err
+}
+
+// validateAuditLog checks the audit_log path. It may be absolute, since logs
+// usually live outside the checkout.
+func validateAuditLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	if strings.TrimSpace(path) != path || strings.ContainsFunc(path, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return fmt.Errorf("audit_log must not contain surrounding whitespace or control characters")
+	}
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, `\`) {
+		return fmt.Errorf("audit_log must name a file, not a directory")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// readAuditLog returns the raw lines and decoded records of an audit log.
+func readAuditLog(t *testing.T, path string) ([]string, []auditRecord) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	records := make([]auditRecord, len(lines))
+	for i, line := range lines {
+		if err := json.Unmarshal([]byte(line), &records[i]); err != nil {
+			t.Fatalf("audit line %d is not JSON: %v\n%s", i, err, line)
+		}
+	}
+	return lines, records
+}
+
+func TestExecuteAuditLog(t *testing.T) {
+	const token = "cioAuditSecret42"
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	run := func(_ context.Context, name string, args ...string) ([]byte, error) {
+		now = now.Add(1500 * time.Millisecond)
+		switch {
+		case len(args) > 0 && args[0] == "--version":
+			return []byte("cargo 1.80.0 (376290515 2024-07-16)"), nil
+		case len(args) > 0 && args[0] == "publish":
+			// Cargo may echo the token in its errors
+			return []byte("error: rejected " + token), errors.New("upload rejected for " + token)
+		}
+		return nil, nil
+	}
+	mock := &MockCommandExecutor{
+		RunFunc: run,
+		RunInDirFunc: func(ctx context.Context, _ string, name string, args ...string) ([]byte, error) {
+			return run(ctx, name, args...)
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: mock, now: func() time.Time { return now }}
+	execute := func(hook plugin.Hook, dryRun bool) *plugin.ExecuteResponse {
+		t.Helper()
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    hook,
+			Config:  map[string]any{"token": token, "audit_log": logPath, "min_cargo_version": "1.70.0"},
+			Context: plugin.ReleaseContext{Version: "v1.0.0", Environment: map[string]string{"RELICTA_CORRELATION_ID": "release-7"}},
+			DryRun:  dryRun,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Outputs["audit_log"] != logPath {
+			t.Errorf("audit_log output = %v, want %s", resp.Outputs["audit_log"], logPath)
+		}
+		return resp
+	}
+
+	resp := execute(plugin.HookPostPublish, false)
+	if resp.Success {
+		t.Fatal("expected the publish to fail")
+	}
+
+	lines, records := readAuditLog(t, logPath)
+	if strings.Contains(strings.Join(lines, "\n"), token) {
+		t.Fatalf("audit log contains the token:\n%s", strings.Join(lines, "\n"))
+	}
+	var publish *auditRecord
+	for i := range records {
+		if records[i].Command != "cargo" {
+			t.Errorf("unexpected command %q", records[i].Command)
+		}
+		if records[i].CorrelationID != "release-7" {
+			t.Errorf("correlation_id = %q, want release-7", records[i].CorrelationID)
+		}
+		if len(records[i].Args) > 0 && records[i].Args[0] == "publish" {
+			publish = &records[i]
+		}
+	}
+	if publish == nil {
+		t.Fatalf("expected a cargo publish record, got %+v", records)
+	}
+	if !strings.Contains(strings.Join(publish.Args, " "), "--token "+redactedValue) {
+		t.Errorf("expected the redacted token in the arguments, got %v", publish.Args)
+	}
+	if publish.ExitStatus != -1 || publish.Error != "upload rejected for "+redactedValue {
+		t.Errorf("exit_status = %d, error = %q; want -1 and the scrubbed error", publish.ExitStatus, publish.Error)
+	}
+	if publish.DurationMS != 1500 || publish.Time == "" {
+		t.Errorf("duration_ms = %d, time = %q", publish.DurationMS, publish.Time)
+	}
+	if records[0].Args[0] != "--version" || records[0].ExitStatus != 0 {
+		t.Errorf("expected the cargo version check first, got %+v", records[0])
+	}
+
+	// A later execution appends and continues the hash chain
+	execute(plugin.HookPrePublish, true)
+	lines, records = readAuditLog(t, logPath)
+	if records[0].Prev != "" {
+		t.Errorf("first record prev = %q, want empty", records[0].Prev)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].Prev != lineHash(lines[i-1]) {
+			t.Errorf("record %d does not chain to the previous line", i)
+		}
+	}
+}
+
+func TestExecuteAuditLogUnavailable(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "missing", "audit.jsonl")
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "audit_log": logPath},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1], "audit_log disabled: failed to open "+logPath) {
+		t.Errorf("expected an audit_log warning, got %v", warnings)
+	}
+	if _, ok := resp.Outputs["audit_log"]; ok {
+		t.Error("audit_log output must not be set when the log is not written")
+	}
+}
+
+func TestValidateAuditLog(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: ""},
+		{path: "audit.jsonl"},
+		{path: "/var/log/relicta/audit.jsonl"},
+		{path: " audit.jsonl", wantErr: true},
+		{path: "audit\n.jsonl", wantErr: true},
+		{path: "logs/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if err := validateAuditLog(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("validateAuditLog(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cargoCAInfoKey is the cargo setting registry_ca_cert is passed as.
+const cargoCAInfoKey = "http.cainfo"
+
+// validateRegistryCACertPath checks the registry_ca_cert path can be passed
+// to cargo as a TOML literal string and does not clash with cargo_config.
+func validateRegistryCACertPath(path string, cargoConfig map[string]string) error {
+	if path == "" {
+		return nil
+	}
+	if strings.TrimSpace(path) != path || strings.ContainsFunc(path, func(r rune) bool { return r < 0x20 || r == 0x7f || r == '\'' }) {
+		return fmt.Errorf("registry_ca_cert must not contain surrounding whitespace, control characters or single quotes")
+	}
+	if _, ok := cargoConfig[cargoCAInfoKey]; ok {
+		return fmt.Errorf("registry_ca_cert and cargo_config %s are mutually exclusive", cargoCAInfoKey)
+	}
+	return nil
+}
+
+// loadRegistryCACert reads the PEM certificates in registry_ca_cert and
+// returns the system roots plus those certificates, or nil without it. The
+// file must hold at least one certificate, and every certificate must parse.
+func loadRegistryCACert(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("registry_ca_cert %s does not exist", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry_ca_cert %s: %w", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	count := 0
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		count++
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("registry_ca_cert %s: certificate %d does not parse: %v", path, count, err)
+		}
+		pool.AddCert(cert)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("registry_ca_cert %s holds no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// registryCACertPath returns registry_ca_cert as an absolute path, as cargo
+// may run in the manifest directory.
+func registryCACertPath(cfg *Config) string {
+	if abs, err := filepath.Abs(cfg.RegistryCACert); err == nil {
+		return abs
+	}
+	return cfg.RegistryCACert
+}
+
+// getRegistryExecutor returns the executor for the plugin's own requests to
+// the registry. With registry_ca_cert the real executor trusts its
+// certificates, with client_cert it presents the client certificate and with
+// proxy it goes through the proxy unless no_proxy matches; an injected
+// executor is returned as is.
+func (p *CratesPlugin) getRegistryExecutor(cfg *Config) CommandExecutor {
+	if p.cmdExecutor != nil || cfg.RegistryCAs == nil && cfg.ClientCertificate == nil && cfg.Proxy == "" {
+		return p.getExecutor()
+	}
+	tlsConfig := &tls.Config{RootCAs: cfg.RegistryCAs, MinVersion: tls.VersionTLS12}
+	if cfg.ClientCertificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cfg.ClientCertificate}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.Proxy != "" {
+		transport.Proxy = proxyFunc(cfg)
+	}
+	return &RealCommandExecutor{client: &http.Client{Transport: transport}}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeCACert writes the certificate of a TLS test server as PEM.
+func writeCACert(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRegistryCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	valid := writeCACert(t, server)
+
+	dir := t.TempDir()
+	noCerts := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(noCerts, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.pem")
+	if err := os.WriteFile(corrupt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("not der")}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantPool bool
+		wantErr  string
+	}{
+		{name: "unset"},
+		{name: "certificate", path: valid, wantPool: true},
+		{name: "missing file", path: filepath.Join(dir, "missing.pem"), wantErr: "does not exist"},
+		{name: "no certificates", path: noCerts, wantErr: "holds no PEM certificates"},
+		{name: "corrupt certificate", path: corrupt, wantErr: "certificate 1 does not parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := loadRegistryCACert(tt.path)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if (pool != nil) != tt.wantPool {
+				t.Errorf("pool = %v, want one: %v", pool, tt.wantPool)
+			}
+		})
+	}
+}
+
+func TestValidateRegistryCACertPath(t *testing.T) {
+	if err := validateRegistryCACertPath("/etc/ssl/corp.pem", nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateRegistryCACertPath("/etc/ssl/corp's.pem", nil); err == nil {
+		t.Error("expected an error for a single quote")
+	}
+	err := validateRegistryCACertPath("/etc/ssl/corp.pem", map[string]string{"http.cainfo": "'/other.pem'"})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("error = %v, want a cargo_config conflict", err)
+	}
+}
+
+func TestCheckConnectivityRegistryCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	pool, err := loadRegistryCACert(writeCACert(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := (&CratesPlugin{}).checkConnectivity(context.Background(), &Config{RegistryCAs: pool}, server.URL+"/index/config.json")
+	if result.err != nil || result.httpStatus != http.StatusOK {
+		t.Errorf("expected the registry CA to be trusted, got %d: %v", result.httpStatus, result.err)
+	}
+}
+
+func TestExecuteRegistryCACert(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	path := writeCACert(t, server)
+
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "registry_ca_cert": path},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("dry run failed: %s", resp.Error)
+	}
+	command, _ := resp.Outputs["command"].(string)
+	if !strings.Contains(command, "--config http.cainfo='"+path+"'") {
+		t.Errorf("expected http.cainfo in the command, got %q", command)
+	}
+	if resp.Outputs["registry_ca_cert"] != path {
+		t.Errorf("registry_ca_cert = %v, want %s", resp.Outputs["registry_ca_cert"], path)
+	}
+
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "registry_ca_cert": filepath.Join(t.TempDir(), "missing.pem")},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.HasPrefix(resp.Error, errConfigValidation) || !strings.Contains(resp.Error, "does not exist") {
+		t.Errorf("expected a configuration error, got success=%v error=%q", resp.Success, resp.Error)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+// Cargo network settings set by net_retry and git_fetch_with_cli.
+const (
+	cargoNetRetryKey        = "net.retry"
+	cargoGitFetchWithCLIKey = "net.git-fetch-with-cli"
+)
+
+// maxNetRetry bounds net_retry; cargo backs off between retries, so more
+// would keep a failing publish hanging for a long time.
+const maxNetRetry = 20
+
+// parseNetRetry reads net_retry, nil when unset leaves net.retry to cargo's
+// configuration. A value that is not a whole number becomes -1 so validation
+// rejects it.
+func parseNetRetry(raw any) *int {
+	retry := -1
+	switch v := raw.(type) {
+	case nil:
+		return nil
+	case float64:
+		if v == float64(int(v)) {
+			retry = int(v)
+		}
+	case int:
+		retry = v
+	}
+	return &retry
+}
+
+// validateCargoNet checks net_retry and that neither setting is also given
+// through cargo_config.
+func validateCargoNet(netRetry *int, gitFetchWithCLI bool, cargoConfig map[string]string) error {
+	if netRetry != nil && (*netRetry < 0 || *netRetry > maxNetRetry) {
+		return fmt.Errorf("net_retry must be a whole number between 0 and %d", maxNetRetry)
+	}
+	if _, ok := cargoConfig[cargoNetRetryKey]; ok && netRetry != nil {
+		return fmt.Errorf("net_retry and cargo_config %s are mutually exclusive", cargoNetRetryKey)
+	}
+	if _, ok := cargoConfig[cargoGitFetchWithCLIKey]; ok && gitFetchWithCLI {
+		return fmt.Errorf("git_fetch_with_cli and cargo_config %s are mutually exclusive", cargoGitFetchWithCLIKey)
+	}
+	return nil
+}
+
+// cargoNetArgs returns the --config arguments for net_retry and
+// git_fetch_with_cli.
+func cargoNetArgs(cfg *Config) []string {
+	var args []string
+	if cfg.NetRetry != nil {
+		args = append(args, "--config", fmt.Sprintf("%s=%d", cargoNetRetryKey, *cfg.NetRetry))
+	}
+	if cfg.GitFetchWithCLI {
+		args = append(args, "--config", cargoGitFetchWithCLIKey+"=true")
+	}
+	return args
+}
+
+// addCargoNetOutputs reports the network settings the plugin passed to cargo.
+func addCargoNetOutputs(cfg *Config, outputs map[string]any) {
+	if cfg.NetRetry != nil {
+		outputs["net_retry"] = *cfg.NetRetry
+	}
+	if cfg.GitFetchWithCLI {
+		outputs["git_fetch_with_cli"] = true
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateCargoNet(t *testing.T) {
+	retry := func(n int) *int { return &n }
+
+	tests := []struct {
+		name            string
+		netRetry        *int
+		gitFetchWithCLI bool
+		cargoConfig     map[string]string
+		wantErr         string
+	}{
+		{name: "unset"},
+		{name: "no retries", netRetry: retry(0)},
+		{name: "maximum", netRetry: retry(maxNetRetry), gitFetchWithCLI: true},
+		{name: "negative", netRetry: retry(-1), wantErr: "net_retry must be a whole number between 0 and 20"},
+		{name: "too many", netRetry: retry(maxNetRetry + 1), wantErr: "net_retry must be a whole number between 0 and 20"},
+		{name: "net.retry in cargo_config", netRetry: retry(3), cargoConfig: map[string]string{"net.retry": "5"}, wantErr: "net_retry and cargo_config net.retry are mutually exclusive"},
+		{name: "git-fetch-with-cli in cargo_config", gitFetchWithCLI: true, cargoConfig: map[string]string{"net.git-fetch-with-cli": "true"}, wantErr: "git_fetch_with_cli and cargo_config"},
+		{name: "cargo_config alone", cargoConfig: map[string]string{"net.retry": "5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCargoNet(tt.netRetry, tt.gitFetchWithCLI, tt.cargoConfig)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseNetRetry(t *testing.T) {
+	if got := parseNetRetry(nil); got != nil {
+		t.Errorf("parseNetRetry(nil) = %d, want nil", *got)
+	}
+	for raw, want := range map[any]int{float64(0): 0, float64(5): 5, 7: 7, float64(2.5): -1, "3": -1} {
+		if got := parseNetRetry(raw); got == nil || *got != want {
+			t.Errorf("parseNetRetry(%v) = %v, want %d", raw, got, want)
+		}
+	}
+}
+
+func TestExecuteCargoNet(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "net_retry": float64(0), "git_fetch_with_cli": true},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("dry run failed: %s", resp.Error)
+	}
+	command, _ := resp.Outputs["command"].(string)
+	if !strings.Contains(command, "--config net.retry=0 --config net.git-fetch-with-cli=true") {
+		t.Errorf("expected the network settings in the command, got %q", command)
+	}
+	if resp.Outputs["net_retry"] != 0 || resp.Outputs["git_fetch_with_cli"] != true {
+		t.Errorf("unexpected outputs: net_retry=%v git_fetch_with_cli=%v", resp.Outputs["net_retry"], resp.Outputs["git_fetch_with_cli"])
+	}
+}
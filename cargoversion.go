@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// cargoVersionPattern matches the version in `cargo --version` output, such as
+	// "cargo 1.74.0 (ecb9851af 2023-10-18)" or "cargo 1.76.0-nightly (71cd3a926 2023-11-20)".
+	cargoVersionPattern = regexp.MustCompile(`cargo (\d+)\.(\d+)\.(\d+)(-[0-9A-Za-z.-]+)?`)
+	// minCargoVersionPattern matches the accepted min_cargo_version forms.
+	minCargoVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(\.(\d+))?$`)
+)
+
+// cargoVersion is a cargo release version. Pre-release suffixes such as
+// -nightly are ignored, since a nightly carries the features of its release.
+type cargoVersion struct {
+	major, minor, patch int
+}
+
+func (v cargoVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+// less reports whether v is older than other.
+func (v cargoVersion) less(other cargoVersion) bool {
+	if v.major != other.major {
+		return v.major < other.major
+	}
+	if v.minor != other.minor {
+		return v.minor < other.minor
+	}
+	return v.patch < other.patch
+}
+
+// parseCargoVersion extracts the version from `cargo --version` output.
+func parseCargoVersion(output string) (cargoVersion, error) {
+	m := cargoVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return cargoVersion{}, fmt.Errorf("could not parse cargo version from %q", output)
+	}
+	return cargoVersion{major: atoi(m[1]), minor: atoi(m[2]), patch: atoi(m[3])}, nil
+}
+
+// parseMinCargoVersion parses a min_cargo_version value such as 1.74 or 1.74.0.
+func parseMinCargoVersion(value string) (cargoVersion, error) {
+	m := minCargoVersionPattern.FindStringSubmatch(value)
+	if m == nil {
+		return cargoVersion{}, fmt.Errorf("%q is not a version like 1.74 or 1.74.0", value)
+	}
+	v := cargoVersion{major: atoi(m[1]), minor: atoi(m[2])}
+	if m[4] != "" {
+		v.patch = atoi(m[4])
+	}
+	return v, nil
+}
+
+// checkCargoVersion runs `cargo --version` and fails when cargo is older than
+// min_cargo_version. It returns the detected version.
+func (p *CratesPlugin) checkCargoVersion(ctx context.Context, cfg *Config, workDir string) (cargoVersion, error) {
+	required, err := parseMinCargoVersion(cfg.MinCargoVersion)
+	if err != nil {
+		return cargoVersion{}, err
+	}
+
+	var args []string
+	if cfg.Toolchain != "" {
+		args = append(args, "+"+cfg.Toolchain)
+	}
+	args = append(args, "--version")
+
+	output, err := p.runCargo(ctx, cfg, workDir, args...)
+	if errors.Is(err, errCargoNotFound) {
+		return cargoVersion{}, err
+	}
+	if err != nil {
+		return cargoVersion{}, fmt.Errorf("failed to run cargo --version: %v\nOutput: %s", err, string(output))
+	}
+
+	found, err := parseCargoVersion(string(output))
+	if err != nil {
+		return cargoVersion{}, err
+	}
+	if found.less(required) {
+		return found, fmt.Errorf("cargo %s found, %s required", found, required)
+	}
+	return found, nil
+}
+
+// atoi converts a string of digits matched by a pattern above.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestParseCargoVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "stable", output: "cargo 1.74.0 (ecb9851af 2023-10-18)\n", want: "1.74.0"},
+		{name: "nightly", output: "cargo 1.76.0-nightly (71cd3a926 2023-11-20)", want: "1.76.0"},
+		{name: "beta", output: "cargo 1.75.0-beta.5 (fe6e9e0b4 2023-12-01)", want: "1.75.0"},
+		{name: "without commit info", output: "cargo 1.62.0", want: "1.62.0"},
+		{name: "rustup warning before version", output: "info: syncing channel updates\ncargo 1.80.1 (376290515 2024-07-16)", want: "1.80.1"},
+		{name: "garbage", output: "command not found", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCargoVersion(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCargoVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("parseCargoVersion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMinCargoVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "1.74", want: "1.74.0"},
+		{value: "1.74.1", want: "1.74.1"},
+		{value: "1", wantErr: true},
+		{value: "v1.74.0", wantErr: true},
+		{value: "1.74.0-nightly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMinCargoVersion(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMinCargoVersion(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("parseMinCargoVersion(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteMinCargoVersion(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]any
+		versionOutput     string
+		wantSuccess       bool
+		wantErrorContains string
+		wantCargoVersion  string
+		wantVersionArgs   string
+		wantPublish       bool
+	}{
+		{
+			name:             "new enough",
+			config:           map[string]any{"min_cargo_version": "1.74.0"},
+			versionOutput:    "cargo 1.74.0 (ecb9851af 2023-10-18)",
+			wantSuccess:      true,
+			wantCargoVersion: "1.74.0",
+			wantVersionArgs:  "--version",
+			wantPublish:      true,
+		},
+		{
+			name:             "nightly of the required release is accepted",
+			config:           map[string]any{"min_cargo_version": "1.76", "toolchain": "nightly"},
+			versionOutput:    "cargo 1.76.0-nightly (71cd3a926 2023-11-20)",
+			wantSuccess:      true,
+			wantCargoVersion: "1.76.0",
+			wantVersionArgs:  "+nightly --version",
+			wantPublish:      true,
+		},
+		{
+			name:              "too old",
+			config:            map[string]any{"min_cargo_version": "1.74.0"},
+			versionOutput:     "cargo 1.62.0 (a748cf5a3 2022-06-08)",
+			wantErrorContains: "cargo 1.62.0 found, 1.74.0 required",
+			wantVersionArgs:   "--version",
+		},
+		{
+			name:              "unparseable version",
+			config:            map[string]any{"min_cargo_version": "1.74.0"},
+			versionOutput:     "something else",
+			wantErrorContains: "could not parse cargo version",
+			wantVersionArgs:   "--version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					if args[len(args)-1] == "--version" {
+						return []byte(tt.versionOutput), nil
+					}
+					return []byte("Uploaded successfully"), nil
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			config := map[string]any{"token": "test-token"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain %q, got %q", tt.wantErrorContains, resp.Error)
+			}
+			if tt.wantCargoVersion != "" && resp.Outputs["cargo_version"] != tt.wantCargoVersion {
+				t.Errorf("cargo_version = %v, want %s", resp.Outputs["cargo_version"], tt.wantCargoVersion)
+			}
+
+			calls := mock.GetCalls()
+			if len(calls) == 0 {
+				t.Fatal("expected cargo --version to run")
+			}
+			if got := strings.Join(calls[0].Args, " "); got != tt.wantVersionArgs {
+				t.Errorf("version check args = %q, want %q", got, tt.wantVersionArgs)
+			}
+			if published := len(withoutSizeCheck(calls)) == 2; published != tt.wantPublish {
+				t.Errorf("publish attempted = %v, want %v", published, tt.wantPublish)
+			}
+		})
+	}
+}
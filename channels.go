@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// stableChannel is the channel of a release version without a pre-release.
+const stableChannel = "stable"
+
+// channelEnvKeys lists the release context environment keys that may carry
+// the host's release channel, in order of preference.
+var channelEnvKeys = []string{"RELICTA_RELEASE_CHANNEL", "RELICTA_CHANNEL"}
+
+// channelSelection records the registry channel_registries selected for a
+// release and why, for the registry_selection output.
+type channelSelection struct {
+	Channel string
+	// Registry is the registry mapped to the channel; empty when the channel
+	// is not in channel_registries and the default registry is used.
+	Registry string
+	Reason   string
+}
+
+// releaseChannel returns the channel of the release and where it came from:
+// the host's channel from the release context environment, else the first
+// pre-release identifier of the version without trailing digits (rc for both
+// 1.0.0-rc.1 and 1.0.0-rc1), else stable.
+func releaseChannel(releaseCtx plugin.ReleaseContext) (channel, source string) {
+	for _, key := range channelEnvKeys {
+		if channel := strings.TrimSpace(releaseCtx.Environment[key]); channel != "" {
+			return channel, "release context " + key
+		}
+	}
+	version, err := parseReleaseVersion(releaseCtx.Version)
+	if err != nil {
+		return "", "release version is not valid"
+	}
+	if version.pre == "" {
+		return stableChannel, fmt.Sprintf("release version %s has no pre-release", version)
+	}
+	id, _, _ := strings.Cut(version.pre, ".")
+	if trimmed := strings.TrimRight(id, "0123456789"); trimmed != "" {
+		id = trimmed
+	}
+	return id, fmt.Sprintf("pre-release identifier of %s", version)
+}
+
+// selectChannelRegistry maps the release channel through channels, naming
+// defaultRegistry in the reason when the channel is not mapped.
+func selectChannelRegistry(channels map[string]string, releaseCtx plugin.ReleaseContext, defaultRegistry string) *channelSelection {
+	channel, source := releaseChannel(releaseCtx)
+	if registry, ok := channels[channel]; ok && channel != "" {
+		return &channelSelection{
+			Channel:  channel,
+			Registry: registry,
+			Reason:   fmt.Sprintf("channel %s (%s) maps to %s in channel_registries", channel, source, registry),
+		}
+	}
+	if channel == "" {
+		return &channelSelection{Reason: fmt.Sprintf("no release channel (%s); using the default registry %s", source, defaultRegistry)}
+	}
+	return &channelSelection{
+		Channel: channel,
+		Reason:  fmt.Sprintf("channel %s (%s) is not in channel_registries; using the default registry %s", channel, source, defaultRegistry),
+	}
+}
+
+// validateChannelRegistries checks channel_registries: every channel maps to
+// a registry valid for registry, and the registry is not pinned by index or
+// registry_index, which would not follow the channel.
+func validateChannelRegistries(channels map[string]string, policy registryPolicy, explicit map[string]bool) error {
+	if len(channels) == 0 {
+		return nil
+	}
+	for _, key := range []string{"index", "registry_index"} {
+		if explicit[key] {
+			return fmt.Errorf("channel_registries and %s are mutually exclusive; declare the index of each channel's registry in the cargo configuration", key)
+		}
+	}
+	names := make([]string, 0, len(channels))
+	for channel := range channels {
+		names = append(names, channel)
+	}
+	sort.Strings(names)
+	for _, channel := range names {
+		registry := channels[channel]
+		switch {
+		case strings.TrimSpace(channel) == "":
+			return fmt.Errorf("channel_registries has an empty channel")
+		case registry == "":
+			return fmt.Errorf("channel_registries[%q] must name a registry", channel)
+		}
+		if err := validateRegistryURL(registry, policy); err != nil {
+			return fmt.Errorf("channel_registries[%q]: invalid registry: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+// applyChannelRegistry selects the registry for the release from
+// channel_registries, returning the configuration parsed again with the
+// mapped registry in place of registry so tokens and token environment
+// variables follow it. The configuration is returned unchanged when
+// channel_registries is unset or invalid, or registries is set; validation
+// reports those.
+func (p *CratesPlugin) applyChannelRegistry(raw map[string]any, cfg *Config, releaseCtx plugin.ReleaseContext) *Config {
+	if len(cfg.ChannelRegistries) == 0 || len(cfg.Registries) > 0 ||
+		validateChannelRegistries(cfg.ChannelRegistries, configRegistryPolicy(cfg), cfg.ExplicitKeys) != nil {
+		return cfg
+	}
+	selection := selectChannelRegistry(cfg.ChannelRegistries, releaseCtx, p.getRegistryName(cfg))
+	if selection.Registry != "" {
+		config := make(map[string]any, len(raw))
+		for key, value := range raw {
+			config[key] = value
+		}
+		config["registry"] = selection.Registry
+		cfg = p.parseConfig(config)
+	}
+	cfg.ChannelSelection = selection
+	return cfg
+}
+
+// addChannelOutputs reports the release channel, the registry it resolved to
+// and the reason in outputs.
+func (p *CratesPlugin) addChannelOutputs(cfg *Config, outputs map[string]any) {
+	if cfg.ChannelSelection == nil {
+		return
+	}
+	outputs["channel"] = cfg.ChannelSelection.Channel
+	outputs["channel_registry"] = p.getRegistryName(cfg)
+	outputs["registry_selection"] = cfg.ChannelSelection.Reason
+}
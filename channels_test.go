@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestReleaseChannel(t *testing.T) {
+	tests := []struct {
+		name        string
+		releaseCtx  plugin.ReleaseContext
+		wantChannel string
+		wantSource  string
+	}{
+		{name: "stable", releaseCtx: plugin.ReleaseContext{Version: "v1.2.0"}, wantChannel: stableChannel, wantSource: "release version 1.2.0 has no pre-release"},
+		{name: "release candidate", releaseCtx: plugin.ReleaseContext{Version: "1.2.0-rc.1"}, wantChannel: "rc", wantSource: "pre-release identifier of 1.2.0-rc.1"},
+		{name: "trailing digits", releaseCtx: plugin.ReleaseContext{Version: "1.2.0-beta2"}, wantChannel: "beta"},
+		{name: "numeric pre-release", releaseCtx: plugin.ReleaseContext{Version: "1.2.0-1"}, wantChannel: "1"},
+		{name: "host channel", releaseCtx: plugin.ReleaseContext{Version: "1.2.0", Environment: map[string]string{"RELICTA_RELEASE_CHANNEL": "nightly"}}, wantChannel: "nightly", wantSource: "release context RELICTA_RELEASE_CHANNEL"},
+		{name: "invalid version", releaseCtx: plugin.ReleaseContext{Version: "latest"}, wantSource: "release version is not valid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, source := releaseChannel(tt.releaseCtx)
+			if channel != tt.wantChannel {
+				t.Errorf("channel = %q, want %q", channel, tt.wantChannel)
+			}
+			if tt.wantSource != "" && source != tt.wantSource {
+				t.Errorf("source = %q, want %q", source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestValidateChannelRegistries(t *testing.T) {
+	policy := newRegistryPolicy(nil, false, nil)
+
+	tests := []struct {
+		name     string
+		channels map[string]string
+		explicit map[string]bool
+		wantErr  string
+	}{
+		{name: "unset"},
+		{name: "names and URLs", channels: map[string]string{"rc": "staging", "beta": "sparse+https://beta.example.com/index/"}},
+		{name: "empty channel", channels: map[string]string{" ": "staging"}, wantErr: "channel_registries has an empty channel"},
+		{name: "empty registry", channels: map[string]string{"rc": ""}, wantErr: `channel_registries["rc"] must name a registry`},
+		{name: "invalid registry", channels: map[string]string{"rc": "http://staging.example.com/"}, wantErr: `channel_registries["rc"]: invalid registry`},
+		{name: "with index", channels: map[string]string{"rc": "staging"}, explicit: map[string]bool{"index": true}, wantErr: "channel_registries and index are mutually exclusive"},
+		{name: "with registry_index", channels: map[string]string{"rc": "staging"}, explicit: map[string]bool{"registry_index": true}, wantErr: "channel_registries and registry_index are mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChannelRegistries(tt.channels, policy, tt.explicit)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteChannelRegistries(t *testing.T) {
+	tests := []struct {
+		version      string
+		wantRegistry string
+		wantChannel  string
+		wantReason   string
+	}{
+		{version: "v1.2.0-rc.1", wantRegistry: "--registry staging", wantChannel: "rc", wantReason: "channel rc (pre-release identifier of 1.2.0-rc.1) maps to staging in channel_registries"},
+		{version: "v1.2.0", wantChannel: stableChannel, wantReason: "channel stable (release version 1.2.0 has no pre-release) is not in channel_registries; using the default registry crates.io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "crates-io-token")
+			chdir(t, t.TempDir())
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}, resolver: &fakeResolver{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook: plugin.HookPostPublish,
+				Config: map[string]any{
+					"channel_registries": map[string]any{"rc": "staging"},
+					"tokens":             map[string]any{"staging": "staging-token"},
+				},
+				Context: plugin.ReleaseContext{Version: tt.version},
+				DryRun:  true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("dry run failed: %s", resp.Error)
+			}
+
+			command, _ := resp.Outputs["command"].(string)
+			if tt.wantRegistry != "" && !strings.Contains(command, tt.wantRegistry) {
+				t.Errorf("expected %q in the command, got %q", tt.wantRegistry, command)
+			}
+			if tt.wantRegistry == "" && strings.Contains(command, "--registry") {
+				t.Errorf("expected the default registry, got %q", command)
+			}
+			if resp.Outputs["channel"] != tt.wantChannel {
+				t.Errorf("channel = %v, want %s", resp.Outputs["channel"], tt.wantChannel)
+			}
+			if resp.Outputs["registry_selection"] != tt.wantReason {
+				t.Errorf("registry_selection = %v, want %q", resp.Outputs["registry_selection"], tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyChannelRegistry(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	p := &CratesPlugin{}
+	raw := map[string]any{
+		"channel_registries": map[string]any{"rc": "staging"},
+		"tokens":             map[string]any{"staging": "staging-token", "crates-io": "crates-io-token"},
+	}
+
+	// The token follows the selected registry
+	cfg := p.applyChannelRegistry(raw, p.parseConfig(raw), plugin.ReleaseContext{Version: "1.0.0-rc.2"})
+	if cfg.Registry != "staging" || cfg.Token != "staging-token" || cfg.TokenSource != "tokens[staging]" {
+		t.Errorf("got registry %q token source %q, want staging from tokens[staging]", cfg.Registry, cfg.TokenSource)
+	}
+	if cfg.ChannelSelection == nil || cfg.ChannelSelection.Registry != "staging" {
+		t.Errorf("unexpected selection %+v", cfg.ChannelSelection)
+	}
+
+	cfg = p.applyChannelRegistry(raw, p.parseConfig(raw), plugin.ReleaseContext{Version: "1.0.0"})
+	if cfg.Registry != "" || cfg.TokenSource != "tokens[crates-io]" {
+		t.Errorf("got registry %q token source %q, want the default registry", cfg.Registry, cfg.TokenSource)
+	}
+
+	// Without channel_registries nothing is selected
+	plain := map[string]any{"registry": "internal"}
+	if cfg := p.applyChannelRegistry(plain, p.parseConfig(plain), plugin.ReleaseContext{Version: "1.0.0-rc.1"}); cfg.ChannelSelection != nil || cfg.Registry != "internal" {
+		t.Errorf("unexpected selection %+v for registry %q", cfg.ChannelSelection, cfg.Registry)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Environment modes for the cargo child process, selected by env_mode.
+const (
+	envModeInherit   = "inherit"
+	envModeClean     = "clean"
+	envModeAllowlist = "allowlist"
+)
+
+// baseEnvNames are passed to cargo in every env_mode; cargo and rustup do not
+// work without them.
+var baseEnvNames = []string{"PATH", "HOME"}
+
+// windowsBaseEnvNames are also needed on Windows, where processes fail to
+// start or find their profile without them.
+var windowsBaseEnvNames = []string{"SYSTEMROOT", "USERPROFILE", "TEMP", "TMP"}
+
+// baseEnvPrefixes select the cargo and rustup configuration variables, which
+// include the token variables the plugin sets itself.
+var baseEnvPrefixes = []string{"CARGO_", "RUSTUP_"}
+
+// validateEnvConfig checks env_mode, the env additions and env_allowlist.
+func validateEnvConfig(mode string, env map[string]string, allowlist []string) error {
+	switch mode {
+	case "", envModeInherit, envModeClean, envModeAllowlist:
+	default:
+		return fmt.Errorf("env_mode must be one of: inherit, clean, allowlist")
+	}
+	for _, name := range sortedKeys(env) {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("env key %q is not an environment variable name", name)
+		}
+		if strings.ContainsRune(env[name], 0) {
+			return fmt.Errorf("env %s must not contain NUL characters", name)
+		}
+	}
+	if len(allowlist) > 0 && mode != envModeAllowlist {
+		return fmt.Errorf("env_allowlist needs env_mode allowlist")
+	}
+	for i, name := range allowlist {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("env_allowlist[%d] %q is not an environment variable name", i, name)
+		}
+	}
+	return nil
+}
+
+// childEnv filters the parent environment for env_mode clean or allowlist:
+// only baseEnvNames, the baseEnvPrefixes variables and, with allowlist, the
+// env_allowlist names are kept. The env entries are added by the caller.
+// Windows compares names case-insensitively, as its environment does.
+func childEnv(cfg *Config, parent []string, windows bool) []string {
+	names := baseEnvNames
+	if windows {
+		names = append(slices.Clone(names), windowsBaseEnvNames...)
+	}
+	if cfg.EnvMode == envModeAllowlist {
+		names = append(slices.Clone(names), cfg.EnvAllowlist...)
+	}
+	normalize := func(s string) string { return s }
+	if windows {
+		normalize = strings.ToUpper
+	}
+
+	env := []string{}
+	for _, entry := range parent {
+		name, _, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			continue
+		}
+		key := normalize(name)
+		keep := slices.ContainsFunc(names, func(n string) bool { return normalize(n) == key }) ||
+			slices.ContainsFunc(baseEnvPrefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) })
+		if keep {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+// envEntries renders the env config as NAME=value entries in name order.
+func envEntries(env map[string]string) []string {
+	entries := make([]string, 0, len(env))
+	for _, name := range sortedKeys(env) {
+		entries = append(entries, name+"="+env[name])
+	}
+	return entries
+}
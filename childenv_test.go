@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestChildEnv(t *testing.T) {
+	parent := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/ci",
+		"CARGO_HOME=/home/ci/.cargo",
+		"CARGO_REGISTRY_TOKEN=secret",
+		"RUSTUP_TOOLCHAIN=stable",
+		"AWS_SECRET_ACCESS_KEY=aws-secret",
+		"HTTPS_PROXY=http://proxy:3128",
+		"SSH_AUTH_SOCK=/tmp/agent.sock",
+		"RUSTFLAGS=-Dwarnings",
+		"CARGOX=not-cargo",
+		"SystemRoot=C:\\Windows",
+		"Path=C:\\Windows\\system32",
+		"cargo_target_dir=C:\\target",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		windows bool
+		want    []string
+	}{
+		{
+			name: "clean",
+			cfg:  Config{EnvMode: envModeClean},
+			want: []string{
+				"PATH=/usr/bin",
+				"HOME=/home/ci",
+				"CARGO_HOME=/home/ci/.cargo",
+				"CARGO_REGISTRY_TOKEN=secret",
+				"RUSTUP_TOOLCHAIN=stable",
+			},
+		},
+		{
+			name: "allowlist",
+			cfg:  Config{EnvMode: envModeAllowlist, EnvAllowlist: []string{"SSH_AUTH_SOCK", "RUSTFLAGS"}},
+			want: []string{
+				"PATH=/usr/bin",
+				"HOME=/home/ci",
+				"CARGO_HOME=/home/ci/.cargo",
+				"CARGO_REGISTRY_TOKEN=secret",
+				"RUSTUP_TOOLCHAIN=stable",
+				"SSH_AUTH_SOCK=/tmp/agent.sock",
+				"RUSTFLAGS=-Dwarnings",
+			},
+		},
+		{
+			name:    "clean on windows",
+			cfg:     Config{EnvMode: envModeClean},
+			windows: true,
+			want: []string{
+				"PATH=/usr/bin",
+				"HOME=/home/ci",
+				"CARGO_HOME=/home/ci/.cargo",
+				"CARGO_REGISTRY_TOKEN=secret",
+				"RUSTUP_TOOLCHAIN=stable",
+				"SystemRoot=C:\\Windows",
+				"Path=C:\\Windows\\system32",
+				"cargo_target_dir=C:\\target",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := childEnv(&tt.cfg, parent, tt.windows)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("childEnv() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateEnvConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		env       map[string]string
+		allowlist []string
+		wantErr   string
+	}{
+		{name: "default"},
+		{name: "inherit with additions", mode: envModeInherit, env: map[string]string{"RUSTFLAGS": "-Dwarnings"}},
+		{name: "allowlist", mode: envModeAllowlist, allowlist: []string{"SSH_AUTH_SOCK"}},
+		{name: "unknown mode", mode: "none", wantErr: "env_mode must be one of"},
+		{name: "bad env name", mode: envModeClean, env: map[string]string{"MY VAR": "x"}, wantErr: `env key "MY VAR" is not an environment variable name`},
+		{name: "NUL in value", env: map[string]string{"RUSTFLAGS": "a\x00b"}, wantErr: "must not contain NUL"},
+		{name: "allowlist outside allowlist mode", mode: envModeClean, allowlist: []string{"SSH_AUTH_SOCK"}, wantErr: "env_allowlist needs env_mode allowlist"},
+		{name: "bad allowlist name", mode: envModeAllowlist, allowlist: []string{"SSH-AUTH"}, wantErr: "env_allowlist[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEnvConfig(tt.mode, tt.env, tt.allowlist)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteEnvMode(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "aws-secret")
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/agent.sock")
+
+	tests := []struct {
+		name       string
+		config     map[string]any
+		wantMethod string
+		wantEnv    []string
+		notEnv     []string
+	}{
+		{
+			name:       "inherit appends the env additions",
+			config:     map[string]any{"env": map[string]any{"RUSTFLAGS": "-Dwarnings"}},
+			wantMethod: "RunWithEnv",
+			wantEnv:    []string{"RUSTFLAGS=-Dwarnings", "CARGO_REGISTRY_TOKEN=secret-token"},
+		},
+		{
+			name:       "clean",
+			config:     map[string]any{"env_mode": "clean", "env": map[string]any{"RUSTFLAGS": "-Dwarnings"}},
+			wantMethod: "RunInEnv",
+			wantEnv:    []string{"PATH=" + os.Getenv("PATH"), "RUSTFLAGS=-Dwarnings", "CARGO_REGISTRY_TOKEN=secret-token"},
+			notEnv:     []string{"AWS_SECRET_ACCESS_KEY", "HTTPS_PROXY", "SSH_AUTH_SOCK"},
+		},
+		{
+			name:       "allowlist",
+			config:     map[string]any{"env_mode": "allowlist", "env_allowlist": []any{"SSH_AUTH_SOCK"}},
+			wantMethod: "RunInEnv",
+			wantEnv:    []string{"SSH_AUTH_SOCK=/tmp/agent.sock", "CARGO_REGISTRY_TOKEN=secret-token"},
+			notEnv:     []string{"AWS_SECRET_ACCESS_KEY", "HTTPS_PROXY"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"token": "secret-token", "token_via_env": true}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+
+			var publish *ExecutorCall
+			for _, call := range mock.GetCalls() {
+				if len(call.Args) > 0 && call.Args[0] == "publish" {
+					publish = &call
+				}
+			}
+			if publish == nil {
+				t.Fatal("expected cargo publish to run")
+			}
+			if publish.Method != tt.wantMethod {
+				t.Errorf("cargo ran with %s, want %s", publish.Method, tt.wantMethod)
+			}
+			for _, want := range tt.wantEnv {
+				found := false
+				for _, entry := range publish.Env {
+					if entry == want {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("expected %s in the cargo environment, got %v", want, publish.Env)
+				}
+			}
+			for _, entry := range publish.Env {
+				name, _, _ := strings.Cut(entry, "=")
+				for _, forbidden := range tt.notEnv {
+					if name == forbidden {
+						t.Errorf("%s must not reach cargo", forbidden)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// clientCertCargoWarning explains that client_cert does not reach cargo.
+const clientCertCargoWarning = "cargo has no client certificate setting, so client_cert and client_key are only used by the plugin's own registry requests; cargo reaches a registry requiring mutual TLS only through a proxy that presents the certificate"
+
+// validateClientCertPair checks that client_cert and client_key are set
+// together.
+func validateClientCertPair(cert, key string) error {
+	switch {
+	case cert != "" && key == "":
+		return fmt.Errorf("client_cert needs client_key")
+	case cert == "" && key != "":
+		return fmt.Errorf("client_key needs client_cert")
+	}
+	return nil
+}
+
+// loadClientCert loads the client_cert and client_key pair for mutual TLS,
+// or nil without it. Errors name the files but never their contents.
+func loadClientCert(certPath, keyPath string) (*tls.Certificate, error) {
+	if err := validateClientCertPair(certPath, keyPath); err != nil || certPath == "" {
+		return nil, err
+	}
+	for _, file := range []struct{ key, path string }{{"client_cert", certPath}, {"client_key", keyPath}} {
+		if _, err := os.Stat(file.path); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s %s does not exist", file.key, file.path)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s %s: %w", file.key, file.path, err)
+		}
+	}
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("client_cert %s and client_key %s do not form a key pair: %v", certPath, keyPath, err)
+	}
+	return &cert, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key as PEM
+// files and returns the certificate and the paths.
+func writeClientCert(t *testing.T, dir, name string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath := filepath.Join(dir, name+".pem")
+	keyPath := filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cert, certPath, keyPath
+}
+
+func TestLoadClientCert(t *testing.T) {
+	dir := t.TempDir()
+	_, certPath, keyPath := writeClientCert(t, dir, "client")
+	_, _, otherKey := writeClientCert(t, dir, "other")
+
+	tests := []struct {
+		name     string
+		cert     string
+		key      string
+		wantCert bool
+		wantErr  string
+	}{
+		{name: "unset"},
+		{name: "key pair", cert: certPath, key: keyPath, wantCert: true},
+		{name: "certificate only", cert: certPath, wantErr: "client_cert needs client_key"},
+		{name: "key only", key: keyPath, wantErr: "client_key needs client_cert"},
+		{name: "missing key", cert: certPath, key: filepath.Join(dir, "missing.pem"), wantErr: "client_key " + filepath.Join(dir, "missing.pem") + " does not exist"},
+		{name: "mismatched key", cert: certPath, key: otherKey, wantErr: "do not form a key pair"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := loadClientCert(tt.cert, tt.key)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if (cert != nil) != tt.wantCert {
+				t.Errorf("certificate = %v, want one: %v", cert != nil, tt.wantCert)
+			}
+		})
+	}
+}
+
+func TestCheckConnectivityClientCert(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certPath, keyPath := writeClientCert(t, dir, "client")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	// The rejected handshake is expected; keep it out of the test output
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	certificate, err := loadClientCert(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := server.URL + "/index/config.json"
+
+	result := (&CratesPlugin{}).checkConnectivity(context.Background(), &Config{RegistryCAs: serverCAs, ClientCertificate: certificate}, target)
+	if result.err != nil || result.httpStatus != http.StatusOK {
+		t.Errorf("expected the client certificate to be accepted, got %d: %v", result.httpStatus, result.err)
+	}
+
+	result = (&CratesPlugin{}).checkConnectivity(context.Background(), &Config{RegistryCAs: serverCAs}, target)
+	if result.err == nil {
+		t.Error("expected the registry to reject a request without the client certificate")
+	}
+}
+
+func TestValidateClientCert(t *testing.T) {
+	dir := t.TempDir()
+	_, certPath, keyPath := writeClientCert(t, dir, "client")
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &CratesPlugin{}
+	resp, err := p.Validate(context.Background(), map[string]any{"client_cert": certPath, "client_key": keyPath})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Valid || len(resp.Errors) != 1 || resp.Errors[0].Field != "client_cert" || resp.Errors[0].Code != warningCode {
+		t.Fatalf("expected one client_cert warning, got valid=%v %+v", resp.Valid, resp.Errors)
+	}
+	if strings.Contains(fmt.Sprint(resp), strings.TrimSpace(string(key))) {
+		t.Error("Validate echoed the client key")
+	}
+}
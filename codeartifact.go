@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// codeArtifactConfig names the AWS CodeArtifact domain whose short-lived
+// authorization token is used as the registry token.
+type codeArtifactConfig struct {
+	Domain      string
+	DomainOwner string
+	Region      string
+}
+
+// parseCodeArtifactConfig reads the codeartifact object; it is nil when unset.
+func parseCodeArtifactConfig(raw map[string]any) *codeArtifactConfig {
+	if raw == nil {
+		return nil
+	}
+	ca := &codeArtifactConfig{}
+	ca.Domain, _ = raw["domain"].(string)
+	ca.DomainOwner, _ = raw["domain_owner"].(string)
+	ca.Region, _ = raw["region"].(string)
+	return ca
+}
+
+var (
+	// codeArtifactDomainPattern matches CodeArtifact domain names.
+	codeArtifactDomainPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,48}[a-z0-9]$`)
+	// awsAccountPattern matches a 12-digit AWS account ID.
+	awsAccountPattern = regexp.MustCompile(`^[0-9]{12}$`)
+	// awsRegionPattern matches AWS region names such as eu-west-1.
+	awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+)
+
+// validateCodeArtifact checks the codeartifact block and that the token can
+// be handed to cargo through CARGO_REGISTRIES_<NAME>_TOKEN. domain_owner and
+// region are optional and fall back to the AWS CLI's account and profile.
+func validateCodeArtifact(cfg *Config) error {
+	ca := cfg.CodeArtifact
+	if ca == nil {
+		return nil
+	}
+	if !codeArtifactDomainPattern.MatchString(ca.Domain) {
+		return fmt.Errorf("codeartifact.domain %q is not a CodeArtifact domain name", ca.Domain)
+	}
+	if ca.DomainOwner != "" && !awsAccountPattern.MatchString(ca.DomainOwner) {
+		return fmt.Errorf("codeartifact.domain_owner %q is not a 12-digit AWS account ID", ca.DomainOwner)
+	}
+	if ca.Region != "" && !awsRegionPattern.MatchString(ca.Region) {
+		return fmt.Errorf("codeartifact.region %q is not an AWS region", ca.Region)
+	}
+	if cfg.Registry == "" || cfg.Registry == cratesIORegistry || strings.Contains(cfg.Registry, "://") {
+		return fmt.Errorf("codeartifact needs the name of the CodeArtifact registry as registry, to derive CARGO_REGISTRIES_<NAME>_TOKEN")
+	}
+	if cfg.Index != "" {
+		return fmt.Errorf("codeartifact cannot be combined with index: cargo only reads --index tokens from --token")
+	}
+
+	var conflicts []string
+	if cfg.Token != "" {
+		conflicts = append(conflicts, "token")
+	}
+	if len(cfg.Tokens) > 0 {
+		conflicts = append(conflicts, "tokens")
+	}
+	if cfg.TokenFile != "" {
+		conflicts = append(conflicts, "token_file")
+	}
+	if len(cfg.TokenCommand) > 0 {
+		conflicts = append(conflicts, "token_command")
+	}
+	if cfg.TokenKeyring != nil {
+		conflicts = append(conflicts, "token_keyring")
+	}
+	if cfg.CredentialProvider != "" {
+		conflicts = append(conflicts, "credential_provider")
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("codeartifact generates the registry token and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// codeArtifactArgs returns the aws CLI arguments that print an authorization
+// token for the domain as JSON.
+func codeArtifactArgs(ca *codeArtifactConfig) []string {
+	args := []string{"codeartifact", "get-authorization-token", "--domain", ca.Domain}
+	if ca.DomainOwner != "" {
+		args = append(args, "--domain-owner", ca.DomainOwner)
+	}
+	if ca.Region != "" {
+		args = append(args, "--region", ca.Region)
+	}
+	return append(args, "--output", "json")
+}
+
+// awsCredentialsExpired reports whether aws CLI error output says the caller's
+// own AWS credentials, rather than the CodeArtifact token, have expired.
+func awsCredentialsExpired(stderr string) bool {
+	for _, marker := range []string{"ExpiredToken", "RequestExpired", "security token included in the request is expired"} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCodeArtifactExpiration reads the expiration of a token. The aws CLI
+// prints ISO 8601 timestamps by default and epoch seconds when
+// cli_timestamp_format is none, as AWS CLI v1 does. A missing expiration
+// yields the zero time.
+func parseCodeArtifactExpiration(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return time.Parse(time.RFC3339, text)
+	}
+	seconds, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognised expiration %s", raw)
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// resolveTokenCodeArtifact fetches a CodeArtifact authorization token with
+// the aws CLI, bounded by token_command_timeout, when codeartifact is set. The
+// token reaches cargo through CARGO_REGISTRIES_<NAME>_TOKEN and its expiry is
+// kept so a publish outliving it can be reported as such.
+func (p *CratesPlugin) resolveTokenCodeArtifact(ctx context.Context, cfg *Config) error {
+	if cfg.CodeArtifact == nil || cfg.Token != "" {
+		return nil
+	}
+
+	executor := p.getExecutor()
+	if _, err := executor.LookPath("aws"); err != nil {
+		return fmt.Errorf("codeartifact needs the aws CLI to fetch an authorization token, but aws was not found on PATH; install AWS CLI v2 or fetch the token with token_command")
+	}
+
+	timeout := cfg.TokenCmdTimeout
+	if timeout <= 0 {
+		timeout = defaultTokenCmdTimeout
+	}
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	domain := cfg.CodeArtifact.Domain
+	output, err := executor.Output(cmdCtx, "aws", codeArtifactArgs(cfg.CodeArtifact)...)
+	if err != nil {
+		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("aws codeartifact get-authorization-token for domain %s timed out after %s", domain, timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			stderr := strings.TrimSpace(string(exitErr.Stderr))
+			if awsCredentialsExpired(stderr) {
+				return fmt.Errorf("the AWS credentials used for codeartifact domain %s have expired; refresh them (e.g. aws sso login) and rerun the release\nStderr: %s", domain, stderr)
+			}
+			return fmt.Errorf("aws codeartifact get-authorization-token for domain %s failed: %v\nStderr: %s", domain, err, stderr)
+		}
+		return fmt.Errorf("aws codeartifact get-authorization-token for domain %s failed: %v", domain, err)
+	}
+
+	var result struct {
+		AuthorizationToken string          `json:"authorizationToken"`
+		Expiration         json.RawMessage `json:"expiration"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("aws codeartifact get-authorization-token for domain %s printed unexpected output: %v", domain, err)
+	}
+	token := strings.TrimSpace(result.AuthorizationToken)
+	if token == "" {
+		return fmt.Errorf("aws codeartifact get-authorization-token for domain %s returned no token", domain)
+	}
+	expiresAt, err := parseCodeArtifactExpiration(result.Expiration)
+	if err != nil {
+		return fmt.Errorf("aws codeartifact get-authorization-token for domain %s: %v", domain, err)
+	}
+	if !expiresAt.IsZero() && !p.getNow().Before(expiresAt) {
+		return fmt.Errorf("the codeartifact authorization token for domain %s expired at %s as it was issued; check the system clock", domain, expiresAt.UTC().Format(time.RFC3339))
+	}
+
+	cfg.Token = token
+	cfg.TokenSource = "codeartifact"
+	cfg.TokenViaEnv = true
+	cfg.TokenExpiresAt = expiresAt
+	return nil
+}
+
+// tokenExpiredError reports a publish that failed after the CodeArtifact
+// token expired, or nil when the token is still valid or never expires.
+func (p *CratesPlugin) tokenExpiredError(cfg *Config) error {
+	if cfg.CodeArtifact == nil || cfg.TokenExpiresAt.IsZero() || p.getNow().Before(cfg.TokenExpiresAt) {
+		return nil
+	}
+	return fmt.Errorf("the codeartifact authorization token for domain %s expired at %s before cargo publish finished; rerun the release to fetch a new token", cfg.CodeArtifact.Domain, cfg.TokenExpiresAt.UTC().Format(time.RFC3339))
+}
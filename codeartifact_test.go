@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateCodeArtifact(t *testing.T) {
+	domain := &codeArtifactConfig{Domain: "acme", DomainOwner: "123456789012", Region: "eu-west-1"}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "unset", cfg: Config{Token: "secret"}},
+		{name: "domain only", cfg: Config{CodeArtifact: &codeArtifactConfig{Domain: "acme"}, Registry: "codeartifact"}},
+		{name: "fully specified", cfg: Config{CodeArtifact: domain, Registry: "codeartifact"}},
+		{name: "missing domain", cfg: Config{CodeArtifact: &codeArtifactConfig{}, Registry: "codeartifact"}, wantErr: "is not a CodeArtifact domain name"},
+		{name: "bad owner", cfg: Config{CodeArtifact: &codeArtifactConfig{Domain: "acme", DomainOwner: "acme-corp"}, Registry: "codeartifact"}, wantErr: "12-digit AWS account ID"},
+		{name: "bad region", cfg: Config{CodeArtifact: &codeArtifactConfig{Domain: "acme", Region: "Europe"}, Registry: "codeartifact"}, wantErr: "is not an AWS region"},
+		{name: "no registry", cfg: Config{CodeArtifact: domain}, wantErr: "needs the name of the CodeArtifact registry"},
+		{name: "registry URL", cfg: Config{CodeArtifact: domain, Registry: "sparse+https://acme.d.codeartifact.eu-west-1.amazonaws.com/cargo/crates/"}, wantErr: "needs the name of the CodeArtifact registry"},
+		{name: "index", cfg: Config{CodeArtifact: domain, Registry: "codeartifact", Index: "sparse+https://example.com/"}, wantErr: "cannot be combined with index"},
+		{
+			name:    "token sources",
+			cfg:     Config{CodeArtifact: domain, Registry: "codeartifact", Token: "secret", CredentialProvider: "cargo:token"},
+			wantErr: "cannot be combined with token, credential_provider",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCodeArtifact(&tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCodeArtifactExpiration(t *testing.T) {
+	want := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "missing", raw: ""},
+		{name: "null", raw: "null"},
+		{name: "ISO 8601", raw: `"2026-10-14T14:00:00+02:00"`, want: want},
+		{name: "epoch seconds", raw: fmt.Sprintf("%d.0", want.Unix()), want: want},
+		{name: "garbage", raw: `"tomorrow"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCodeArtifactExpiration(json.RawMessage(tt.raw))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("expiration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteCodeArtifact(t *testing.T) {
+	const caToken = "eyJ2ZXIiOjEsImlzdSI6MTcwMDAwMDAwMH0.codeartifact-secret"
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	t.Setenv("CARGO_REGISTRIES_CODEARTIFACT_TOKEN", "")
+
+	issued := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tokenJSON := fmt.Sprintf(`{"authorizationToken": %q, "expiration": %q}`, caToken, issued.Add(12*time.Hour).Format(time.RFC3339))
+	config := map[string]any{
+		"registry":     "codeartifact",
+		"codeartifact": map[string]any{"domain": "acme", "domain_owner": "123456789012", "region": "eu-west-1"},
+	}
+
+	tests := []struct {
+		name         string
+		noAWS        bool
+		tokenOutput  func() ([]byte, error)
+		publishAfter time.Duration
+		publishErr   error
+		wantErrorHas []string
+	}{
+		{
+			name:        "token passed through the environment",
+			tokenOutput: func() ([]byte, error) { return []byte(tokenJSON), nil },
+		},
+		{
+			name:         "aws CLI missing",
+			noAWS:        true,
+			wantErrorHas: []string{"codeartifact needs the aws CLI", "install AWS CLI v2"},
+		},
+		{
+			name: "expired AWS credentials",
+			tokenOutput: func() ([]byte, error) {
+				return nil, &exec.ExitError{Stderr: []byte("An error occurred (ExpiredTokenException) when calling the GetAuthorizationToken operation\n")}
+			},
+			wantErrorHas: []string{"AWS credentials used for codeartifact domain acme have expired"},
+		},
+		{
+			name:         "token expired during the publish",
+			tokenOutput:  func() ([]byte, error) { return []byte(tokenJSON), nil },
+			publishAfter: 13 * time.Hour,
+			publishErr:   errors.New("exit status 101"),
+			wantErrorHas: []string{"codeartifact authorization token for domain acme expired at 2026-10-15T00:00:00Z before cargo publish finished"},
+		},
+		{
+			name: "already expired",
+			tokenOutput: func() ([]byte, error) {
+				return []byte(`{"authorizationToken": "x", "expiration": "2026-10-14T11:00:00Z"}`), nil
+			},
+			wantErrorHas: []string{"expired at 2026-10-14T11:00:00Z as it was issued"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := issued
+			mock := &MockCommandExecutor{
+				LookPathFunc: func(name string) (string, error) {
+					if name == "aws" && tt.noAWS {
+						return "", exec.ErrNotFound
+					}
+					return "/usr/bin/" + name, nil
+				},
+				OutputFunc: func(_ context.Context, name string, args ...string) ([]byte, error) {
+					want := "codeartifact get-authorization-token --domain acme --domain-owner 123456789012 --region eu-west-1 --output json"
+					if name != "aws" || strings.Join(args, " ") != want {
+						t.Errorf("unexpected token command %s %v", name, args)
+					}
+					return tt.tokenOutput()
+				},
+				RunWithEnvFunc: func(context.Context, string, []string, string, ...string) ([]byte, error) {
+					now = now.Add(tt.publishAfter)
+					// Cargo may echo the token, which must not reach the outputs
+					return []byte("Uploading fixture with " + caToken), tt.publishErr
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock, now: func() time.Time { return now }}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Contains(fmt.Sprint(resp), caToken) {
+				t.Errorf("token leaked into the response: %+v", resp)
+			}
+
+			if len(tt.wantErrorHas) > 0 {
+				if resp.Success {
+					t.Fatal("expected failure")
+				}
+				for _, want := range tt.wantErrorHas {
+					if !strings.Contains(resp.Error, want) {
+						t.Errorf("error %q should contain %q", resp.Error, want)
+					}
+				}
+				return
+			}
+
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+			var publish *ExecutorCall
+			for _, call := range mock.GetCalls() {
+				if call.Method == "RunWithEnv" {
+					publish = &call
+				}
+			}
+			if publish == nil {
+				t.Fatal("expected cargo publish to run")
+			}
+			if strings.Contains(strings.Join(publish.Args, " "), "--token") {
+				t.Errorf("expected no --token, got %v", publish.Args)
+			}
+			found := false
+			for _, entry := range publish.Env {
+				if entry == "CARGO_REGISTRIES_CODEARTIFACT_TOKEN="+caToken {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected CARGO_REGISTRIES_CODEARTIFACT_TOKEN in the environment, got %v", publish.Env)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// currentCompatLevel is the compat_level whose defaults this build applies
+// when compat_level is unset.
+const currentCompatLevel = "2.1"
+
+// oldestCompatLevel is the oldest compat_level that can be pinned.
+const oldestCompatLevel = "2.0"
+
+// Compat features are the default behaviors gated by compat_level.
+const (
+	compatManifestVersionCheck = "manifest_version_check"
+	compatPrePublishVerify     = "prepublish_verify"
+	compatRegistryTokenEnv     = "registry_token_env"
+	compatMetadataCheck        = "metadata_check"
+	compatPackageSizeCheck     = "package_size_check"
+	compatPatchCheck           = "patch_check"
+	compatDependencyCheck      = "dependency_check"
+	compatNewCrateCheck        = "new_crate_check"
+)
+
+// compatFeature is a default behavior introduced at a compat level. Pinning
+// an older level turns it off unless it is listed in compat_features.
+type compatFeature struct {
+	name  string
+	since string
+	// description says what the feature does, for Validate warnings.
+	description string
+}
+
+// compatFeatures lists every default that changed after the oldest level.
+var compatFeatures = []compatFeature{
+	{compatManifestVersionCheck, "2.1", "publishing requires the manifest version to equal the release version"},
+	{compatPrePublishVerify, "2.1", "the pre-publish hook runs cargo publish --dry-run unless prepublish_verify is set"},
+	{compatRegistryTokenEnv, "2.1", "a named registry's token is read from CARGO_REGISTRIES_<NAME>_TOKEN"},
+	{compatMetadataCheck, "2.1", "publishing requires the description and license metadata crates.io enforces"},
+	{compatPackageSizeCheck, "2.1", "publishing fails when the packaged crate is larger than max_package_size"},
+	{compatPatchCheck, "2.1", "publishing fails when the manifest has [patch] or [replace] overrides"},
+	{compatDependencyCheck, "2.1", "publishing fails when a dependency has a wildcard version requirement and warns when a stable release depends on a pre-release"},
+	{compatNewCrateCheck, "2.1", "publishing fails when the registry's sparse index does not know the crate unless allow_new_crate is set"},
+}
+
+// compatLevelPattern matches compat levels such as "2.0".
+var compatLevelPattern = regexp.MustCompile(`^(\d+)\.(\d+)$`)
+
+// compatLevelOlder reports whether level a is older than level b. Both must
+// match compatLevelPattern.
+func compatLevelOlder(a, b string) bool {
+	return parseCompatLevel(a).less(parseCompatLevel(b))
+}
+
+// parseCompatLevel converts a compat level into a version for comparison.
+func parseCompatLevel(level string) cargoVersion {
+	m := compatLevelPattern.FindStringSubmatch(level)
+	if m == nil {
+		return cargoVersion{}
+	}
+	return cargoVersion{major: atoi(m[1]), minor: atoi(m[2])}
+}
+
+// compatEnabled reports whether the named feature is on at the pinned level,
+// either because the level includes it or because it is listed in optIn. An
+// empty level is the current one.
+func compatEnabled(level string, optIn []string, name string) bool {
+	for _, feature := range optIn {
+		if feature == name {
+			return true
+		}
+	}
+	if level == "" || !compatLevelPattern.MatchString(level) {
+		return true
+	}
+	for _, feature := range compatFeatures {
+		if feature.name == name {
+			return !compatLevelOlder(level, feature.since)
+		}
+	}
+	return true
+}
+
+// compatDisabled returns the features turned off by the pinned level and not
+// opted back into, in table order.
+func compatDisabled(level string, optIn []string) []compatFeature {
+	var disabled []compatFeature
+	for _, feature := range compatFeatures {
+		if !compatEnabled(level, optIn, feature.name) {
+			disabled = append(disabled, feature)
+		}
+	}
+	return disabled
+}
+
+// validateCompat checks compat_level and the compat_features names.
+func validateCompat(level string, optIn []string) error {
+	if level != "" {
+		if !compatLevelPattern.MatchString(level) {
+			return fmt.Errorf("compat_level must look like %s, got %q", currentCompatLevel, level)
+		}
+		if compatLevelOlder(level, oldestCompatLevel) || compatLevelOlder(currentCompatLevel, level) {
+			return fmt.Errorf("compat_level %s is not supported; use a level from %s to %s", level, oldestCompatLevel, currentCompatLevel)
+		}
+	}
+	for i, name := range optIn {
+		known := false
+		for _, feature := range compatFeatures {
+			if feature.name == name {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("compat_features[%d] %q is not a known feature", i, name)
+		}
+	}
+	return nil
+}
+
+// compatWarning describes a pinned level older than the current one and the
+// features it turns off, or returns "" when the level is current.
+func compatWarning(level string, optIn []string) string {
+	if level == "" || !compatLevelPattern.MatchString(level) || !compatLevelOlder(level, currentCompatLevel) {
+		return ""
+	}
+	message := fmt.Sprintf("compat_level %s is older than the current level %s", level, currentCompatLevel)
+	disabled := compatDisabled(level, optIn)
+	if len(disabled) == 0 {
+		return message
+	}
+	var parts []string
+	for _, feature := range disabled {
+		parts = append(parts, fmt.Sprintf("%s (%s)", feature.name, feature.description))
+	}
+	return fmt.Sprintf("%s and turns off: %s; list features in compat_features to opt in", message, strings.Join(parts, ", "))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCompatEnabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		optIn []string
+		want  bool
+	}{
+		{name: "unset level is current", level: "", want: true},
+		{name: "current level", level: currentCompatLevel, want: true},
+		{name: "older level", level: "2.0", want: false},
+		{name: "older level with opt-in", level: "2.0", optIn: []string{compatManifestVersionCheck}, want: true},
+		{name: "opt-in for another feature", level: "2.0", optIn: []string{compatPrePublishVerify}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compatEnabled(tt.level, tt.optIn, compatManifestVersionCheck); got != tt.want {
+				t.Errorf("compatEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCompat(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		optIn   []string
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "oldest", level: oldestCompatLevel},
+		{name: "current", level: currentCompatLevel},
+		{name: "known feature", level: "2.0", optIn: []string{compatRegistryTokenEnv}},
+		{name: "not a level", level: "v2", wantErr: "must look like"},
+		{name: "patch level", level: "2.0.1", wantErr: "must look like"},
+		{name: "older than supported", level: "1.9", wantErr: "not supported"},
+		{name: "newer than this build", level: "2.2", wantErr: "not supported"},
+		{name: "unknown feature", optIn: []string{"strict_mode"}, wantErr: `compat_features[0] "strict_mode"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCompat(tt.level, tt.optIn)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompatWarning(t *testing.T) {
+	if w := compatWarning("", nil); w != "" {
+		t.Errorf("unset level should not warn, got %q", w)
+	}
+	if w := compatWarning(currentCompatLevel, nil); w != "" {
+		t.Errorf("current level should not warn, got %q", w)
+	}
+
+	w := compatWarning("2.0", []string{compatPrePublishVerify})
+	if !strings.Contains(w, "older than the current level "+currentCompatLevel) {
+		t.Errorf("warning should name the current level, got %q", w)
+	}
+	if !strings.Contains(w, compatManifestVersionCheck) || !strings.Contains(w, compatRegistryTokenEnv) {
+		t.Errorf("warning should list the features turned off, got %q", w)
+	}
+	if strings.Contains(w, compatPrePublishVerify) {
+		t.Errorf("warning should not list opted-in features, got %q", w)
+	}
+}
+
+func TestExecuteCompatLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]any
+		// Expected gated behaviors
+		wantVersionCheck bool
+		wantPrePublish   bool
+		wantRegistryEnv  bool
+	}{
+		{
+			name:             "current level",
+			config:           map[string]any{},
+			wantVersionCheck: true,
+			wantPrePublish:   true,
+			wantRegistryEnv:  true,
+		},
+		{
+			name:   "pinned to 2.0",
+			config: map[string]any{"compat_level": "2.0"},
+		},
+		{
+			name:             "pinned to 2.0 with every feature opted in",
+			config:           map[string]any{"compat_level": "2.0", "compat_features": []any{"manifest_version_check", "prepublish_verify", "registry_token_env", "metadata_check", "package_size_check", "patch_check", "dependency_check", "new_crate_check"}},
+			wantVersionCheck: true,
+			wantPrePublish:   true,
+			wantRegistryEnv:  true,
+		},
+		{
+			name:           "pinned to 2.0 with prepublish_verify set",
+			config:         map[string]any{"compat_level": "2.0", "prepublish_verify": true},
+			wantPrePublish: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "default-secret")
+			t.Setenv("CARGO_REGISTRIES_MY_REGISTRY_TOKEN", "named-secret")
+
+			// A release version that differs from the fixture manifest
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v9.9.9"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := !resp.Success && strings.Contains(resp.Error, "release version is 9.9.9"); got != tt.wantVersionCheck {
+				t.Errorf("version check applied = %v, want %v (error: %s)", got, tt.wantVersionCheck, resp.Error)
+			}
+
+			mock = &MockCommandExecutor{}
+			p = &CratesPlugin{cmdExecutor: mock}
+			resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPrePublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(mock.calls) > 0; got != tt.wantPrePublish {
+				t.Errorf("pre-publish verification ran = %v, want %v (message: %s)", got, tt.wantPrePublish, resp.Message)
+			}
+
+			config := map[string]any{"registry": "my-registry"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			cfg := p.parseConfig(config)
+			want := "default-secret"
+			if tt.wantRegistryEnv {
+				want = "named-secret"
+			}
+			if cfg.Token != want {
+				t.Errorf("token = %q, want %q", cfg.Token, want)
+			}
+		})
+	}
+}
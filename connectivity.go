@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// registryConnectivityTimeout bounds the check_registry_connectivity request.
+const registryConnectivityTimeout = 5 * time.Second
+
+// connectivityTarget returns the URL check_registry_connectivity requests:
+// the config.json of a sparse index or the root of an index served over
+// HTTP. When there is none it returns the reason instead.
+func connectivityTarget(cfg *Config) (target, reason string) {
+	indexURL, reason := registryIndexURL(cfg)
+	if reason != "" {
+		return "", reason
+	}
+	if base, ok := sparseIndexBase(indexURL); ok {
+		return base + "config.json", ""
+	}
+	u, err := url.Parse(indexURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", "the index is not served over HTTP"
+	}
+	return indexURL, ""
+}
+
+// checkConnectivity requests target without credentials and classifies the
+// outcome. TLS failures, timeouts, unreachable endpoints, missing indexes and
+// server errors fail the check; an authentication challenge, which
+// registries with authenticated downloads answer with, only warns.
+func (p *CratesPlugin) checkConnectivity(ctx context.Context, cfg *Config, target string) *registryPreflight {
+	checkCtx, cancel := context.WithTimeout(ctx, registryConnectivityTimeout)
+	defer cancel()
+	start := time.Now()
+	status, err := p.getRegistryExecutor(cfg).FetchStatus(checkCtx, target)
+	result := &registryPreflight{status: registryReachable, endpoint: target, latency: time.Since(start), httpStatus: status}
+
+	var netErr net.Error
+	switch {
+	case err != nil && isTLSError(err):
+		result.err = fmt.Errorf("TLS error: registry endpoint %s failed the TLS handshake: %v; check the registry certificate and the trusted CAs", target, err)
+	case err != nil && (errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()):
+		result.err = fmt.Errorf("network error: registry endpoint %s did not respond within %s; check connectivity to the registry or set skip_preflight to bypass this check", target, registryConnectivityTimeout)
+	case err != nil:
+		result.err = fmt.Errorf("network error: registry endpoint %s is unreachable: %v; check connectivity to the registry or set skip_preflight to bypass this check", target, err)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		result.warning = fmt.Sprintf("registry endpoint %s answered %d %s: it requires authentication, as registries with authenticated downloads do; the check does not send the token", target, status, http.StatusText(status))
+	case status == http.StatusNotFound || status == http.StatusGone:
+		result.err = fmt.Errorf("registry endpoint %s answered %d %s; check the registry or index URL", target, status, http.StatusText(status))
+	case status >= 500:
+		result.err = fmt.Errorf("registry endpoint %s answered %d %s; the registry is failing", target, status, http.StatusText(status))
+	}
+	if result.err != nil {
+		result.status = registryUnreachable
+	}
+	return result
+}
+
+// isTLSError reports whether err comes from the TLS handshake or
+// certificate verification.
+func isTLSError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
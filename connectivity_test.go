@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestConnectivityTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		wantTarget string
+		wantReason string
+	}{
+		{name: "crates.io", wantTarget: "https://index.crates.io/config.json"},
+		{name: "sparse index", cfg: Config{Index: "sparse+https://registry.example.com/index"}, wantTarget: "https://registry.example.com/index/config.json"},
+		{name: "git index over https", cfg: Config{Index: "https://git.example.com/index.git"}, wantTarget: "https://git.example.com/index.git"},
+		{name: "git index over ssh", cfg: Config{Index: "ssh://git@git.example.com/index.git"}, wantReason: "not served over HTTP"},
+		{name: "unknown named registry", cfg: Config{Registry: "my-registry"}, wantReason: "is not known to the plugin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, reason := connectivityTarget(&tt.cfg)
+			if target != tt.wantTarget {
+				t.Errorf("target = %q, want %q", target, tt.wantTarget)
+			}
+			if tt.wantReason != "" && !strings.Contains(reason, tt.wantReason) {
+				t.Errorf("reason = %q, want it to contain %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	const target = "https://registry.example.com/index/config.json"
+
+	tests := []struct {
+		name        string
+		status      int
+		err         error
+		wantErr     string
+		wantWarning string
+	}{
+		{name: "reachable", status: http.StatusOK},
+		{name: "authentication required", status: http.StatusUnauthorized, wantWarning: "answered 401 Unauthorized: it requires authentication"},
+		{name: "forbidden", status: http.StatusForbidden, wantWarning: "answered 403 Forbidden"},
+		{name: "missing index", status: http.StatusNotFound, wantErr: "answered 404 Not Found; check the registry or index URL"},
+		{name: "server error", status: http.StatusBadGateway, wantErr: "answered 502 Bad Gateway; the registry is failing"},
+		{name: "TLS error", err: &certificateError{x509.UnknownAuthorityError{}}, wantErr: "TLS error: registry endpoint " + target + " failed the TLS handshake"},
+		{name: "timeout", err: context.DeadlineExceeded, wantErr: "did not respond within 5s"},
+		{name: "unreachable", err: errors.New("connect: connection refused"), wantErr: "is unreachable: connect: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				FetchFunc: func(ctx context.Context, url string) (int, error) {
+					if url != target {
+						t.Errorf("fetched %s, want %s", url, target)
+					}
+					return tt.status, tt.err
+				},
+			}
+			result := (&CratesPlugin{cmdExecutor: mock}).checkConnectivity(context.Background(), &Config{}, target)
+			if tt.wantErr == "" && result.err != nil {
+				t.Errorf("unexpected error: %v", result.err)
+			}
+			if tt.wantErr != "" && (result.err == nil || !strings.Contains(result.err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want it to contain %q", result.err, tt.wantErr)
+			}
+			if !strings.Contains(result.warning, tt.wantWarning) || (tt.wantWarning == "") != (result.warning == "") {
+				t.Errorf("warning = %q, want %q", result.warning, tt.wantWarning)
+			}
+			wantStatus := registryReachable
+			if tt.wantErr != "" {
+				wantStatus = registryUnreachable
+			}
+			if result.status != wantStatus || result.httpStatus != tt.status {
+				t.Errorf("status = %s/%d, want %s/%d", result.status, result.httpStatus, wantStatus, tt.status)
+			}
+		})
+	}
+}
+
+// certificateError wraps a certificate error the way net/http does.
+type certificateError struct{ err error }
+
+func (e *certificateError) Error() string { return "Get: tls: " + e.err.Error() }
+func (e *certificateError) Unwrap() error { return e.err }
+
+func TestCheckConnectivityRealTLS(t *testing.T) {
+	var authorization []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	// The test server's certificate is not trusted by the default client
+	result := (&CratesPlugin{}).checkConnectivity(context.Background(), &Config{}, server.URL+"/index/config.json")
+	if result.err == nil || !strings.HasPrefix(result.err.Error(), "TLS error:") {
+		t.Errorf("error = %v, want a TLS error", result.err)
+	}
+	for _, header := range authorization {
+		if header != "" {
+			t.Errorf("the check sent credentials: %q", header)
+		}
+	}
+}
+
+func TestValidateRegistryConnectivity(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantValid  bool
+		wantField  string
+		wantCode   string
+		wantPrefix string
+	}{
+		{name: "reachable", status: http.StatusOK, wantValid: true},
+		{name: "missing index", status: http.StatusNotFound, wantField: "index", wantPrefix: "registry endpoint"},
+		{name: "authentication required", status: http.StatusUnauthorized, wantValid: true, wantField: "index", wantCode: warningCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched []string
+			mock := &MockCommandExecutor{
+				FetchFunc: func(ctx context.Context, url string) (int, error) {
+					fetched = append(fetched, url)
+					return tt.status, nil
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+			resp, err := p.Validate(context.Background(), map[string]any{
+				"index":                       "sparse+https://registry.example.com/index/",
+				"check_registry_connectivity": true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Valid != tt.wantValid {
+				t.Errorf("Valid = %v, want %v: %+v", resp.Valid, tt.wantValid, resp.Errors)
+			}
+			if len(fetched) != 1 || fetched[0] != "https://registry.example.com/index/config.json" {
+				t.Errorf("fetched %v, want the sparse config.json", fetched)
+			}
+			if tt.wantField == "" {
+				if len(resp.Errors) != 0 {
+					t.Errorf("unexpected errors: %+v", resp.Errors)
+				}
+				return
+			}
+			if len(resp.Errors) != 1 || resp.Errors[0].Field != tt.wantField || !strings.HasPrefix(resp.Errors[0].Message, tt.wantPrefix) {
+				t.Fatalf("expected one %s entry, got %+v", tt.wantField, resp.Errors)
+			}
+			if tt.wantCode != "" && resp.Errors[0].Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Errors[0].Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestExecuteRegistryConnectivity(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	probed := false
+	mock := &MockCommandExecutor{
+		ProbeFunc: func(ctx context.Context, network, address string) error {
+			probed = true
+			return nil
+		},
+		FetchFunc: func(ctx context.Context, url string) (int, error) {
+			return http.StatusNotFound, nil
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "index": "sparse+https://registry.example.com/index/", "check_registry_connectivity": true},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "answered 404 Not Found") {
+		t.Fatalf("expected a 404 preflight failure, got success=%v error=%q", resp.Success, resp.Error)
+	}
+	if probed {
+		t.Error("the connectivity check replaces the reachability probe")
+	}
+	if resp.Outputs["registry_preflight_status"] != http.StatusNotFound {
+		t.Errorf("registry_preflight_status = %v, want 404", resp.Outputs["registry_preflight_status"])
+	}
+	if calls := mock.GetCalls(); len(calls) != 0 {
+		t.Errorf("expected no commands after a failed preflight, got %+v", calls)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Auth mechanisms reported as the auth output.
+const (
+	authCredentialProvider = "credential_provider"
+	authTrustedPublishing  = "trusted_publishing"
+	authTokenEnv           = "token_env"
+	authTokenFlag          = "token_flag"
+	authNone               = "none"
+)
+
+// credentialProviderEnvVar returns the variable that selects cargo's
+// credential provider for the registry: CARGO_REGISTRY_CREDENTIAL_PROVIDER for
+// crates.io, CARGO_REGISTRIES_<NAME>_CREDENTIAL_PROVIDER for a named registry.
+func credentialProviderEnvVar(registry string) string {
+	if prefix := registryEnvPrefix(registry); prefix != "" {
+		return prefix + "CREDENTIAL_PROVIDER"
+	}
+	return ""
+}
+
+// credentialProviderEnv returns the environment entry that hands
+// authentication to the configured credential provider.
+func credentialProviderEnv(cfg *Config) []string {
+	if cfg.CredentialProvider == "" {
+		return nil
+	}
+	return []string{credentialProviderEnvVar(cfg.Registry) + "=" + cfg.CredentialProvider}
+}
+
+// validateCredentialProvider checks the credential_provider value and that no
+// token source is configured next to it, since cargo then authenticates
+// through the provider alone.
+func validateCredentialProvider(cfg *Config) error {
+	if cfg.CredentialProvider == "" {
+		return nil
+	}
+	if strings.TrimSpace(cfg.CredentialProvider) == "" {
+		return fmt.Errorf("credential_provider must not be blank")
+	}
+	for _, r := range cfg.CredentialProvider {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("credential_provider must not contain control characters")
+		}
+	}
+	if strings.Contains(cfg.Registry, "://") {
+		return fmt.Errorf("credential_provider needs a registry name, not a URL, to derive CARGO_REGISTRIES_<NAME>_CREDENTIAL_PROVIDER")
+	}
+	if cfg.Index != "" {
+		return fmt.Errorf("credential_provider cannot be combined with index: cargo only selects providers for named registries")
+	}
+
+	var conflicts []string
+	if cfg.Token != "" {
+		conflicts = append(conflicts, "token")
+	}
+	if len(cfg.Tokens) > 0 {
+		conflicts = append(conflicts, "tokens")
+	}
+	if cfg.TokenFile != "" {
+		conflicts = append(conflicts, "token_file")
+	}
+	if len(cfg.TokenCommand) > 0 {
+		conflicts = append(conflicts, "token_command")
+	}
+	if cfg.TokenKeyring != nil {
+		conflicts = append(conflicts, "token_keyring")
+	}
+	if cfg.TokenViaEnv {
+		conflicts = append(conflicts, "token_via_env")
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("credential_provider replaces the API token and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// authMechanism names how cargo will authenticate the upload.
+func authMechanism(cfg *Config) string {
+	switch {
+	case cfg.CredentialProvider != "":
+		return authCredentialProvider
+	case cfg.TrustedPublishing:
+		return authTrustedPublishing
+	case cfg.Token == "":
+		return authNone
+	case cfg.TokenViaEnv:
+		return authTokenEnv
+	default:
+		return authTokenFlag
+	}
+}
+
+// authDescription describes the auth mechanism for messages. Only the
+// provider name is shown, since its arguments may reference secrets.
+func authDescription(cfg *Config) string {
+	switch authMechanism(cfg) {
+	case authCredentialProvider:
+		name, _, _ := strings.Cut(strings.TrimSpace(cfg.CredentialProvider), " ")
+		return fmt.Sprintf("credential provider %s via %s", name, credentialProviderEnvVar(cfg.Registry))
+	case authTrustedPublishing:
+		return "trusted publishing token via " + tokenEnvVar(cfg)
+	case authTokenEnv:
+		return "token via " + tokenEnvVar(cfg)
+	case authTokenFlag:
+		return "token via --token"
+	default:
+		return "no API token"
+	}
+}
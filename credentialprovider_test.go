@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCredentialProviderEnvVar(t *testing.T) {
+	tests := map[string]string{
+		"":                          "CARGO_REGISTRY_CREDENTIAL_PROVIDER",
+		"crates-io":                 "CARGO_REGISTRY_CREDENTIAL_PROVIDER",
+		"my-registry":               "CARGO_REGISTRIES_MY_REGISTRY_CREDENTIAL_PROVIDER",
+		"https://registry.example/": "",
+	}
+	for registry, want := range tests {
+		if got := credentialProviderEnvVar(registry); got != want {
+			t.Errorf("credentialProviderEnvVar(%q) = %q, want %q", registry, got, want)
+		}
+	}
+}
+
+func TestValidateCredentialProvider(t *testing.T) {
+	const provider = "cargo:token-from-stdout vault read -field=token secret/crates"
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "unset", cfg: Config{Token: "secret"}},
+		{name: "crates.io", cfg: Config{CredentialProvider: provider}},
+		{name: "named registry", cfg: Config{CredentialProvider: "cargo:libsecret", Registry: "my-registry"}},
+		{name: "blank", cfg: Config{CredentialProvider: "  "}, wantErr: "must not be blank"},
+		{name: "newline", cfg: Config{CredentialProvider: "cargo:token\nrm -rf /"}, wantErr: "control characters"},
+		{name: "registry URL", cfg: Config{CredentialProvider: provider, Registry: "sparse+https://registry.example.com/"}, wantErr: "needs a registry name"},
+		{name: "index", cfg: Config{CredentialProvider: provider, Index: "sparse+https://registry.example.com/"}, wantErr: "cannot be combined with index"},
+		{
+			name:    "token sources",
+			cfg:     Config{CredentialProvider: provider, Token: "secret", TokenFile: "token.txt"},
+			wantErr: "cannot be combined with token, token_file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCredentialProvider(&tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteCredentialProvider(t *testing.T) {
+	// An environment token must not be passed to cargo next to the provider
+	t.Setenv("CARGO_REGISTRY_TOKEN", "env-secret")
+	t.Setenv("CARGO_REGISTRIES_MY_REGISTRY_TOKEN", "")
+
+	tests := []struct {
+		name    string
+		config  map[string]any
+		wantEnv string
+	}{
+		{
+			name:    "crates.io",
+			config:  map[string]any{"credential_provider": "cargo:token-from-stdout op read op://ci/crates/token"},
+			wantEnv: "CARGO_REGISTRY_CREDENTIAL_PROVIDER=cargo:token-from-stdout op read op://ci/crates/token",
+		},
+		{
+			name:    "named registry",
+			config:  map[string]any{"credential_provider": "cargo:libsecret", "registry": "my-registry"},
+			wantEnv: "CARGO_REGISTRIES_MY_REGISTRY_CREDENTIAL_PROVIDER=cargo:libsecret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("publish without a token should succeed with a credential provider, got: %s", resp.Error)
+			}
+			if resp.Outputs["token_present"] != false {
+				t.Errorf("token_present = %v, want false", resp.Outputs["token_present"])
+			}
+
+			// cargo package --no-verify measures the crate, then it is published
+			calls := mock.GetCalls()
+			if len(calls) != 2 {
+				t.Fatalf("expected 2 calls, got %d", len(calls))
+			}
+			for _, call := range calls {
+				if args := strings.Join(call.Args, " "); strings.Contains(args, "--token") {
+					t.Errorf("expected no --token with a credential provider, got %s", args)
+				}
+				for _, entry := range call.Env {
+					if strings.Contains(entry, "env-secret") {
+						t.Errorf("token leaked into the environment: %s", entry)
+					}
+				}
+			}
+			publish := calls[1]
+			if !slices.Contains(publish.Env, tt.wantEnv) {
+				t.Errorf("expected %s in the environment, got %v", tt.wantEnv, publish.Env)
+			}
+		})
+	}
+}
+
+func TestDryRunReportsAuthMechanism(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+
+	tests := []struct {
+		name            string
+		config          map[string]any
+		wantAuth        string
+		wantMsgContains string
+	}{
+		{
+			name:            "credential provider",
+			config:          map[string]any{"credential_provider": "cargo:token-from-stdout op read op://ci/crates/token"},
+			wantAuth:        authCredentialProvider,
+			wantMsgContains: "credential provider cargo:token-from-stdout via CARGO_REGISTRY_CREDENTIAL_PROVIDER",
+		},
+		{
+			name:            "token flag",
+			config:          map[string]any{"token": "secret"},
+			wantAuth:        authTokenFlag,
+			wantMsgContains: "token via --token",
+		},
+		{
+			name:            "token via environment",
+			config:          map[string]any{"token": "secret", "token_via_env": true, "registry": "my-registry"},
+			wantAuth:        authTokenEnv,
+			wantMsgContains: "token via CARGO_REGISTRIES_MY_REGISTRY_TOKEN",
+		},
+		{
+			name:            "no token",
+			config:          map[string]any{},
+			wantAuth:        authNone,
+			wantMsgContains: "no API token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Outputs["auth"] != tt.wantAuth {
+				t.Errorf("auth = %v, want %s", resp.Outputs["auth"], tt.wantAuth)
+			}
+			if !strings.Contains(resp.Message, tt.wantMsgContains) {
+				t.Errorf("message %q should contain %q", resp.Message, tt.wantMsgContains)
+			}
+			if strings.Contains(resp.Message, "op://") {
+				t.Errorf("message should name only the provider, got %q", resp.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+// Decision kinds recorded in the decision log.
+const (
+	decisionSkip  = "skip"
+	decisionBlock = "block"
+	decisionDefer = "defer"
+)
+
+// decision records one point where the plugin skipped, blocked, or deferred
+// part of the release, so the outcome can be explained after the fact.
+type decision struct {
+	// Subject is what the decision applies to, such as a crate version or a step.
+	Subject  string `json:"subject"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	// Feature names the plugin feature that made the decision.
+	Feature string `json:"feature"`
+	// ConfigKey is the option that controls the decision, if any.
+	ConfigKey string `json:"config_key,omitempty"`
+}
+
+// decisionLog collects the decisions made during one execution.
+type decisionLog []decision
+
+// add appends a decision.
+func (l *decisionLog) add(subject, kind, reason, feature, configKey string) {
+	*l = append(*l, decision{
+		Subject:   subject,
+		Decision:  kind,
+		Reason:    reason,
+		Feature:   feature,
+		ConfigKey: configKey,
+	})
+}
+
+// addOutputs records the decisions as Outputs["decisions"].
+func (l decisionLog) addOutputs(outputs map[string]any) {
+	if len(l) > 0 {
+		outputs["decisions"] = []decision(l)
+	}
+}
+
+// releaseSubject names the crate version being released, using the package
+// name from the manifest when it can be read.
+func releaseSubject(cfg *Config, version string) string {
+	if name := crateName(cfg); name != "" {
+		return name + "@" + version
+	}
+	return "version " + version
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestExecuteDecisionLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    plugin.Hook
+		config  map[string]any
+		dryRun  bool
+		version string
+		runFunc func(calls *int) func(ctx context.Context, name string, args ...string) ([]byte, error)
+		want    decision
+	}{
+		{
+			name: "unhandled hook",
+			hook: plugin.HookPreInit,
+			want: decision{Subject: "hook pre-init", Decision: decisionSkip, Feature: "hooks"},
+		},
+		{
+			name:   "pre-publish verification disabled",
+			hook:   plugin.HookPrePublish,
+			config: map[string]any{"prepublish_verify": false},
+			want:   decision{Subject: "pre-publish verification", Decision: decisionSkip, Feature: "prepublish_verify", ConfigKey: "prepublish_verify"},
+		},
+		{
+			name:   "simulated pre-publish dry run",
+			hook:   plugin.HookPrePublish,
+			dryRun: true,
+			want:   decision{Subject: "pre-publish verification", Decision: decisionSkip, Feature: "dry_run", ConfigKey: "execute_dry_run"},
+		},
+		{
+			name:   "simulated publish dry run",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token"},
+			dryRun: true,
+			want:   decision{Subject: "fixture@1.0.0", Decision: decisionSkip, Feature: "dry_run", ConfigKey: "execute_dry_run"},
+		},
+		{
+			name:    "manifest version mismatch",
+			hook:    plugin.HookPostPublish,
+			config:  map[string]any{"token": "test-token"},
+			version: "v2.0.0",
+			want:    decision{Subject: "fixture@2.0.0", Decision: decisionBlock, Feature: "manifest_version_check", ConfigKey: "manifest_path"},
+		},
+		{
+			name: "preflight missing token",
+			hook: plugin.HookPostPublish,
+			want: decision{Subject: "fixture@1.0.0", Decision: decisionBlock, Feature: "preflight", ConfigKey: "token"},
+		},
+		{
+			name:   "cargo too old",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token", "min_cargo_version": "1.80"},
+			runFunc: func(*int) func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					return []byte("cargo 1.70.0 (ec8a8a0ca 2023-04-25)"), nil
+				}
+			},
+			want: decision{Subject: "fixture@1.0.0", Decision: decisionBlock, Feature: "min_cargo_version", ConfigKey: "min_cargo_version"},
+		},
+		{
+			name:   "forbidden dependency license",
+			hook:   plugin.HookPrePublish,
+			config: map[string]any{"forbidden_licenses": []any{"GPL-3.0-only"}},
+			runFunc: func(*int) func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					return []byte(licenseMetadata), nil
+				}
+			},
+			want: decision{Subject: "fixture@1.0.0", Decision: decisionBlock, Feature: "license_audit", ConfigKey: "forbidden_licenses"},
+		},
+		{
+			name:   "rate limited upload",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token", "max_package_size": 0},
+			runFunc: func(calls *int) func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					*calls++
+					if *calls == 1 {
+						return []byte("status 429 Too Many Requests: retry after 30 seconds"), errors.New("exit status 101")
+					}
+					return []byte("Uploaded fixture v1.0.0"), nil
+				}
+			},
+			want: decision{Subject: "fixture@1.0.0", Decision: decisionDefer, Feature: "rate_limit_retry", ConfigKey: "rate_limit_max_wait"},
+		},
+		{
+			name:   "post publish wait",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token", "post_publish_wait": "1s"},
+			want:   decision{Subject: "fixture@1.0.0", Decision: decisionDefer, Feature: "post_publish_wait", ConfigKey: "post_publish_wait"},
+		},
+		{
+			name:   "soft failure",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"failure_policy": "soft"},
+			want:   decision{Subject: "hook post-publish", Decision: decisionSkip, Feature: "failure_policy", ConfigKey: "failure_policy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "")
+
+			var calls int
+			mock := &MockCommandExecutor{}
+			if tt.runFunc != nil {
+				mock.RunFunc = tt.runFunc(&calls)
+			}
+			p := &CratesPlugin{
+				cmdExecutor: mock,
+				sleep:       func(ctx context.Context, d time.Duration) error { return nil },
+			}
+
+			version := tt.version
+			if version == "" {
+				version = "v1.0.0"
+			}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    tt.hook,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: version},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			decisions, _ := resp.Outputs["decisions"].([]decision)
+			for _, d := range decisions {
+				if d.Subject == tt.want.Subject && d.Decision == tt.want.Decision && d.Feature == tt.want.Feature && d.ConfigKey == tt.want.ConfigKey {
+					if d.Reason == "" {
+						t.Errorf("decision %+v has no reason", d)
+					}
+					return
+				}
+			}
+			t.Errorf("decisions = %+v, want an entry matching %+v", decisions, tt.want)
+		})
+	}
+}
+
+func TestExecuteNoDecisionsOnPlainPublish(t *testing.T) {
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := resp.Outputs["decisions"]; ok {
+		t.Errorf("expected no decisions, got %v", resp.Outputs["decisions"])
+	}
+}
+
+func TestDecisionJSON(t *testing.T) {
+	data, err := json.Marshal(decision{Subject: "fixture@1.0.0", Decision: decisionSkip, Reason: "disabled", Feature: "dry_run"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"subject":"fixture@1.0.0","decision":"skip","reason":"disabled","feature":"dry_run"}`
+	if string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+}
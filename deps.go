@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// manifestDependency is a dependency declared in a manifest, with the version
+// requirement taken from [workspace.dependencies] when it is inherited.
+type manifestDependency struct {
+	name string
+	// table is the dependency table, such as dependencies or
+	// target.'cfg(unix)'.build-dependencies.
+	table string
+	// requirement is the version requirement; empty for path and git
+	// dependencies without a version.
+	requirement string
+	// source names where the requirement is declared, as "[dependencies] of
+	// Cargo.toml" or "[workspace.dependencies] of ../Cargo.toml".
+	source string
+	dev    bool
+}
+
+// normal reports whether the dependency is linked into the crate, rather
+// than used by the build script or tests.
+func (d manifestDependency) normal() bool {
+	return !d.dev && !strings.HasSuffix(d.table, "build-dependencies")
+}
+
+// readDependencies lists the dependencies of a manifest, resolving
+// `workspace = true` to the workspace root's [workspace.dependencies].
+// Manifests that cannot be read are left for cargo to report, so they yield
+// no dependencies.
+func readDependencies(path string) []manifestDependency {
+	manifest, err := loadManifest(path)
+	if err != nil || manifest.Package == nil {
+		return nil
+	}
+
+	// The workspace root is only read when a dependency inherits from it
+	var root string
+	var workspace *cargoManifest
+	inherited := func() (string, map[string]any) {
+		if workspace == nil {
+			var err error
+			if root, workspace, err = findWorkspaceRoot(path); err != nil {
+				root, workspace = "", &cargoManifest{}
+			}
+			// Name the root relative to the working directory like the manifest
+			if wd, err := filepath.Abs("."); err == nil && root != "" && !filepath.IsAbs(path) {
+				if rel, err := filepath.Rel(wd, root); err == nil {
+					root = rel
+				}
+			}
+		}
+		if workspace.Workspace == nil {
+			return root, nil
+		}
+		return root, workspace.Workspace.Dependencies
+	}
+
+	var deps []manifestDependency
+	add := func(table string, entries map[string]any, dev bool) {
+		for _, name := range sortedKeys(entries) {
+			dep := manifestDependency{name: name, table: table, source: fmt.Sprintf("[%s] of %s", table, path), dev: dev}
+			entry := entries[name]
+			if spec, ok := entry.(map[string]any); ok {
+				if inherit, _ := spec["workspace"].(bool); inherit {
+					rootPath, rootDeps := inherited()
+					entry = rootDeps[name]
+					dep.source = fmt.Sprintf("[workspace.dependencies] of %s", rootPath)
+				}
+			}
+			dep.requirement = dependencyRequirement(entry)
+			deps = append(deps, dep)
+		}
+	}
+	addTables := func(prefix string, tables dependencyTables) {
+		add(prefix+"dependencies", tables.Dependencies, false)
+		add(prefix+"build-dependencies", tables.BuildDependencies, false)
+		add(prefix+"dev-dependencies", tables.DevDependencies, true)
+	}
+	addTables("", manifest.dependencyTables)
+	for _, target := range sortedKeys(manifest.Target) {
+		addTables(fmt.Sprintf("target.'%s'.", target), manifest.Target[target])
+	}
+	return deps
+}
+
+// dependencyRequirement returns the version requirement of a dependency
+// entry: the string itself or the version key of a table.
+func dependencyRequirement(entry any) string {
+	switch v := entry.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		version, _ := v["version"].(string)
+		return strings.TrimSpace(version)
+	}
+	return ""
+}
+
+// isWildcardRequirement reports whether a version requirement has a bare *
+// comparator, such as "*" or ">=1.0, *", which crates.io rejects.
+func isWildcardRequirement(requirement string) bool {
+	for _, comparator := range strings.Split(requirement, ",") {
+		if strings.TrimLeft(strings.TrimSpace(comparator), "=^~<> ") == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrereleaseRequirement reports whether a version requirement only admits
+// pre-release versions at its lower bound: an exact, caret, tilde or
+// greater-than comparator names a version with a pre-release, as in
+// "2.0.0-beta.3" or "=2.0.0-rc.1". Upper bounds such as "<2.0.0-0" are the
+// usual way to exclude pre-releases and are not counted.
+func isPrereleaseRequirement(requirement string) bool {
+	for _, comparator := range strings.Split(requirement, ",") {
+		comparator = strings.TrimSpace(comparator)
+		if strings.HasPrefix(comparator, "<") {
+			continue
+		}
+		version, err := parseReleaseVersion(strings.TrimSpace(strings.TrimLeft(comparator, "=^~>")))
+		if err == nil && version.pre != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyFindings are what the dependency check reports besides its error.
+type dependencyFindings struct {
+	warnings []string
+	// prerelease lists the normal dependencies of a stable release that
+	// require a pre-release version.
+	prerelease []string
+}
+
+// addOutputs reports the pre-release dependencies for release reviewers.
+func (f *dependencyFindings) addOutputs(outputs map[string]any) {
+	if f != nil && len(f.prerelease) > 0 {
+		outputs["prerelease_dependencies"] = f.prerelease
+	}
+}
+
+// dependencyCheckEnabled reports whether publishing checks the version
+// requirements of the manifest's dependencies: on at the current
+// compat_level.
+func dependencyCheckEnabled(cfg *Config) bool {
+	return compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatDependencyCheck)
+}
+
+// dependencyCheck runs checkDependencies when it is enabled, recording why it
+// was skipped or why it blocked the release. The findings are returned even
+// when the check fails.
+func (p *CratesPlugin) dependencyCheck(cfg *Config, release releaseVersion, subject string, decisions *decisionLog) (*dependencyFindings, error) {
+	if !dependencyCheckEnabled(cfg) {
+		decisions.add(subject, decisionSkip, "compat_level "+cfg.CompatLevel+" predates the dependency check", "dependency_check", "compat_level")
+		return nil, nil
+	}
+	findings, err := checkDependencies(cfg, release)
+	var prereleaseErr *prereleaseDependencyError
+	switch {
+	case errors.As(err, &prereleaseErr):
+		decisions.add(subject, decisionBlock, "stable release depends on pre-release versions", "dependency_check", "deny_prerelease_deps")
+	case err != nil:
+		decisions.add(subject, decisionBlock, "dependency requirements would be rejected by crates.io", "dependency_check", "compat_level")
+	}
+	return findings, err
+}
+
+// prereleaseDependencyError reports the pre-release dependencies of a stable
+// release when deny_prerelease_deps is set.
+type prereleaseDependencyError struct {
+	message string
+}
+
+func (e *prereleaseDependencyError) Error() string {
+	return e.message
+}
+
+// checkDependencies fails when a normal or build dependency has a wildcard
+// version requirement, which crates.io rejects after cargo has built the
+// crate. Wildcard dev-dependencies are allowed and only reported as
+// warnings, as are the normal dependencies of a stable release that require
+// a pre-release version, unless deny_prerelease_deps is set.
+func checkDependencies(cfg *Config, release releaseVersion) (*dependencyFindings, error) {
+	findings := &dependencyFindings{}
+	var wildcards []string
+	for _, dep := range readDependencies(cfg.ManifestPath) {
+		entry := fmt.Sprintf("%s = %q in %s", dep.name, dep.requirement, dep.source)
+		switch {
+		case isWildcardRequirement(dep.requirement) && dep.dev:
+			findings.warnings = append(findings.warnings, fmt.Sprintf("dev-dependency %s has a wildcard version requirement", entry))
+		case isWildcardRequirement(dep.requirement):
+			wildcards = append(wildcards, entry)
+		case release.pre == "" && dep.normal() && isPrereleaseRequirement(dep.requirement):
+			findings.prerelease = append(findings.prerelease, entry)
+		}
+	}
+	if len(wildcards) > 0 {
+		return findings, fmt.Errorf("%s has wildcard dependency requirements crates.io rejects: %s; require a version such as \"1\" instead",
+			cfg.ManifestPath, strings.Join(wildcards, ", "))
+	}
+	if len(findings.prerelease) > 0 {
+		message := fmt.Sprintf("stable release %s depends on pre-release versions: %s", release, strings.Join(findings.prerelease, ", "))
+		if cfg.DenyPrereleaseDeps {
+			return findings, &prereleaseDependencyError{message: message + "; require a stable version, or publish a pre-release, or unset deny_prerelease_deps"}
+		}
+		findings.warnings = append(findings.warnings, message+"; set deny_prerelease_deps to fail the release instead")
+	}
+	return findings, nil
+}
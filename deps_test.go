@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestIsWildcardRequirement(t *testing.T) {
+	for requirement, want := range map[string]bool{
+		"*":         true,
+		" * ":       true,
+		">=1.0, *":  true,
+		"=*":        true,
+		"1":         false,
+		"1.*":       false,
+		"^1.2":      false,
+		"":          false,
+		">=1, <2.0": false,
+	} {
+		if got := isWildcardRequirement(requirement); got != want {
+			t.Errorf("isWildcardRequirement(%q) = %v, want %v", requirement, got, want)
+		}
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	pkg := "[package]\nname = \"x\"\n"
+	root := write("workspace/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n\n[workspace.dependencies]\nlog = \"*\"\nserde = { version = \"1\" }\n")
+
+	tests := []struct {
+		name         string
+		manifest     string
+		wantErr      string
+		wantWarnings []string
+	}{
+		{name: "pinned", manifest: write("pinned/Cargo.toml", pkg+"\n[dependencies]\nserde = \"1\"\nlocal = { path = \"local\" }\n")},
+		{
+			name:     "normal and build dependencies",
+			manifest: write("wild/Cargo.toml", pkg+"\n[dependencies]\nserde = \"*\"\n\n[build-dependencies]\ncc = { version = \"*\" }\n\n[target.'cfg(unix)'.dependencies]\nlibc = \"*\"\n"),
+			wantErr: `wild/Cargo.toml has wildcard dependency requirements crates.io rejects: serde = "*" in [dependencies] of ` + filepath.Join(dir, "wild", "Cargo.toml") +
+				`, cc = "*" in [build-dependencies] of ` + filepath.Join(dir, "wild", "Cargo.toml") +
+				`, libc = "*" in [target.'cfg(unix)'.dependencies] of ` + filepath.Join(dir, "wild", "Cargo.toml"),
+		},
+		{
+			name:         "dev-dependencies only warn",
+			manifest:     write("dev/Cargo.toml", pkg+"\n[dev-dependencies]\nproptest = \"*\"\n"),
+			wantWarnings: []string{`dev-dependency proptest = "*" in [dev-dependencies] of ` + filepath.Join(dir, "dev", "Cargo.toml") + " has a wildcard version requirement"},
+		},
+		{
+			name:     "inherited from the workspace",
+			manifest: write("workspace/member/Cargo.toml", pkg+"\n[dependencies]\nlog.workspace = true\nserde = { workspace = true, features = [\"derive\"] }\n"),
+			wantErr:  `log = "*" in [workspace.dependencies] of ` + root + ";",
+		},
+		{name: "unparseable manifest", manifest: write("invalid/Cargo.toml", "[package\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := checkDependencies(&Config{ManifestPath: tt.manifest}, releaseVersion{major: 1})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(findings.warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", findings.warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestIsPrereleaseRequirement(t *testing.T) {
+	for requirement, want := range map[string]bool{
+		"2.0.0-beta.3":          true,
+		"^2.0.0-beta.3":         true,
+		"~1.2.3-rc.1":           true,
+		"=2.0.0-rc.1":           true,
+		">=2.0.0-alpha, <3":     true,
+		"2.0.0":                 false,
+		"2":                     false,
+		">=1.0, <2.0.0-0":       false,
+		"1.2.3+build":           false,
+		"git-only":              false,
+		"2.0.0-beta.3 || 1.0.0": false,
+	} {
+		if got := isPrereleaseRequirement(requirement); got != want {
+			t.Errorf("isPrereleaseRequirement(%q) = %v, want %v", requirement, got, want)
+		}
+	}
+}
+
+func TestCheckPrereleaseDependencies(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "Cargo.toml")
+	content := "[package]\nname = \"x\"\n\n[dependencies]\nfoo = \"2.0.0-beta.3\"\nbar = { version = \"=1.0.0-rc.1\" }\nstable = \"1\"\n\n[build-dependencies]\ncc = \"1.0.0-alpha\"\n\n[dev-dependencies]\nmock = \"0.1.0-pre\"\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`bar = "=1.0.0-rc.1" in [dependencies] of ` + manifest, `foo = "2.0.0-beta.3" in [dependencies] of ` + manifest}
+
+	stable := releaseVersion{major: 1}
+	findings, err := checkDependencies(&Config{ManifestPath: manifest}, stable)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(findings.prerelease, want) {
+		t.Errorf("prerelease = %q, want %q", findings.prerelease, want)
+	}
+	if len(findings.warnings) != 1 || !strings.HasPrefix(findings.warnings[0], "stable release 1.0.0 depends on pre-release versions: bar") {
+		t.Errorf("unexpected warnings %q", findings.warnings)
+	}
+
+	// deny_prerelease_deps turns the warning into an error
+	findings, err = checkDependencies(&Config{ManifestPath: manifest, DenyPrereleaseDeps: true}, stable)
+	if err == nil || !strings.Contains(err.Error(), "stable release 1.0.0 depends on pre-release versions") || len(findings.warnings) != 0 {
+		t.Errorf("expected a pre-release dependency error, got %v with warnings %q", err, findings.warnings)
+	}
+
+	// A pre-release may depend on pre-releases
+	findings, err = checkDependencies(&Config{ManifestPath: manifest, DenyPrereleaseDeps: true}, releaseVersion{major: 1, pre: "rc.1"})
+	if err != nil || len(findings.prerelease) != 0 {
+		t.Errorf("expected a pre-release to pass, got %v with %q", err, findings.prerelease)
+	}
+}
+
+func TestExecuteWildcardDependency(t *testing.T) {
+	dir := t.TempDir()
+	manifest := "[package]\nname = \"wild\"\nversion = \"1.0.0\"\ndescription = \"x\"\nlicense = \"MIT\"\n\n[dependencies]\nserde = \"*\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	for _, hook := range []plugin.Hook{plugin.HookPrePublish, plugin.HookPostPublish} {
+		mock := &MockCommandExecutor{}
+		p := &CratesPlugin{cmdExecutor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    hook,
+			Config:  map[string]any{"token": "test-token"},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.HasPrefix(resp.Error, `Cargo.toml has wildcard dependency requirements crates.io rejects: serde = "*" in [dependencies] of Cargo.toml;`) {
+			t.Errorf("%s: expected a wildcard error, got success=%v error=%q", hook, resp.Success, resp.Error)
+		}
+		if calls := mock.GetCalls(); len(calls) != 0 {
+			t.Errorf("%s: expected no commands, got %+v", hook, calls)
+		}
+	}
+
+	// Pre-release dependencies are reported even when they only warn
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(strings.Replace(manifest, `serde = "*"`, `serde = "2.0.0-beta.3"`, 1))
+	for _, deny := range []bool{false, true} {
+		p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"token": "test-token", "deny_prerelease_deps": deny},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			DryRun:  true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success == deny {
+			t.Errorf("deny_prerelease_deps %v: Success = %v (error: %s)", deny, resp.Success, resp.Error)
+		}
+		deps, _ := resp.Outputs["prerelease_dependencies"].([]string)
+		if len(deps) != 1 || deps[0] != `serde = "2.0.0-beta.3" in [dependencies] of Cargo.toml` {
+			t.Errorf("deny_prerelease_deps %v: prerelease_dependencies = %v", deny, resp.Outputs["prerelease_dependencies"])
+		}
+	}
+	write(manifest)
+
+	// Pinned to 2.0 the check is skipped
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token", "compat_level": "2.0"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Errorf("expected compat_level 2.0 to skip the check, got %q", resp.Error)
+	}
+}
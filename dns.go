@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultDNSTimeout bounds the DNS lookup of a registry host.
+const defaultDNSTimeout = 3 * time.Second
+
+// dnsCheckSkipped is the dns_check output when skip_dns_check is set.
+const dnsCheckSkipped = "skipped"
+
+// ipResolver resolves host names for the private network check.
+// *net.Resolver implements it.
+type ipResolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// getResolver returns the DNS resolver, defaulting to net.DefaultResolver.
+func (p *CratesPlugin) getResolver() ipResolver {
+	if p.resolver != nil {
+		return p.resolver
+	}
+	return net.DefaultResolver
+}
+
+// dnsCheckHost returns the host of a registry URL that needs a DNS lookup
+// for the private network check, in punycode form. Registry names, localhost
+// and IP literals need none, and "" is returned. The URL must have passed
+// validateRegistryURL.
+func dnsCheckHost(registryURL string) string {
+	inner, _ := strings.CutPrefix(registryURL, "sparse+")
+	if !strings.Contains(inner, "://") {
+		return ""
+	}
+	u, err := url.Parse(inner)
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	if host == "localhost" || net.ParseIP(host) != nil {
+		return ""
+	}
+	if host, err = asciiHost(host); err != nil {
+		return ""
+	}
+	return host
+}
+
+// checkRegistryDNS resolves the host of a registry URL within timeout and
+// rejects it when it resolves to a private address the policy does not
+// allow. A lookup that times out returns a warning instead of blocking;
+// other lookup failures pass, as DNS may not work where the plugin runs.
+func checkRegistryDNS(ctx context.Context, resolver ipResolver, registryURL string, policy registryPolicy, timeout time.Duration) (warning string, err error) {
+	host := dnsCheckHost(registryURL)
+	if host == "" {
+		return "", nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ips, err := resolver.LookupIP(lookupCtx, "ip", host)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || lookupCtx.Err() != nil {
+			return fmt.Sprintf("DNS lookup of %s did not finish within %s, so its addresses were not checked for private networks; raise dns_timeout if DNS is slow", host, timeout), nil
+		}
+		return "", nil
+	}
+	return "", checkRegistryIPs(host, ips, policy)
+}
+
+// checkRegistryHosts runs checkRegistryDNS for the configured registry and
+// index URLs, unless skip_dns_check is set.
+func (p *CratesPlugin) checkRegistryHosts(ctx context.Context, cfg *Config) ([]string, error) {
+	if cfg.SkipDNSCheck {
+		return nil, nil
+	}
+	policy := configRegistryPolicy(cfg)
+	var warnings []string
+	for _, target := range []struct{ key, url string }{{"registry", cfg.Registry}, {"index", cfg.Index}, {"registry_index", cfg.RegistryIndex}} {
+		if target.url == "" {
+			continue
+		}
+		warning, err := checkRegistryDNS(ctx, p.getResolver(), target.url, policy, cfg.DNSTimeout)
+		if err != nil {
+			return warnings, fmt.Errorf("invalid %s: %w", target.key, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// fakeResolver answers lookups from a fixed table. With hang set it blocks
+// until the lookup context is done, like a black-holing DNS server.
+type fakeResolver struct {
+	addrs   map[string][]string
+	hang    bool
+	lookups []string
+}
+
+func (r *fakeResolver) LookupIP(ctx context.Context, _, host string) ([]net.IP, error) {
+	r.lookups = append(r.lookups, host)
+	if r.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = net.ParseIP(addr)
+	}
+	return ips, nil
+}
+
+func TestCheckRegistryDNS(t *testing.T) {
+	resolver := &fakeResolver{addrs: map[string][]string{
+		"registry.example.com":     {"93.184.216.34"},
+		"internal.example.com":     {"93.184.216.34", "10.20.0.5"},
+		"xn--bcher-kva.example":    {"10.20.0.6"},
+		"metadata.example.com":     {"169.254.169.254"},
+		"allowed.internal.example": {"10.20.0.7"},
+	}}
+	networks, err := parseNetworks([]string{"10.20.0.0/16"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		policy     registryPolicy
+		wantErr    string
+		wantLookup string
+	}{
+		{name: "public host", url: "sparse+https://registry.example.com/index/", wantLookup: "registry.example.com"},
+		{name: "any private address rejects", url: "https://internal.example.com/git/index", wantErr: "private networks", wantLookup: "internal.example.com"},
+		{name: "resolved in punycode form", url: "sparse+https://bücher.example/index/", wantErr: "private networks", wantLookup: "xn--bcher-kva.example"},
+		{name: "allowed network", url: "sparse+https://allowed.internal.example/index/", policy: registryPolicy{networks: networks}, wantLookup: "allowed.internal.example"},
+		{name: "metadata endpoint", url: "https://metadata.example.com/index", policy: registryPolicy{networks: networks}, wantErr: "cloud metadata", wantLookup: "metadata.example.com"},
+		{name: "unknown host passes", url: "https://missing.example.com/index", wantLookup: "missing.example.com"},
+		{name: "registry name", url: "my-registry"},
+		{name: "localhost", url: "sparse+http://localhost:8000/index/"},
+		{name: "IP literal", url: "https://[2001:db8::10]/index"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver.lookups = nil
+			warning, err := checkRegistryDNS(context.Background(), resolver, tt.url, tt.policy, time.Second)
+			if warning != "" {
+				t.Errorf("unexpected warning: %s", warning)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := strings.Join(resolver.lookups, ","); got != tt.wantLookup {
+				t.Errorf("lookups = %q, want %q", got, tt.wantLookup)
+			}
+		})
+	}
+}
+
+func TestCheckRegistryDNSTimeout(t *testing.T) {
+	start := time.Now()
+	warning, err := checkRegistryDNS(context.Background(), &fakeResolver{hang: true}, "https://registry.example.com/index", registryPolicy{}, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(warning, "DNS lookup of registry.example.com did not finish within 20ms") {
+		t.Errorf("warning = %q", warning)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("lookup blocked for %s", elapsed)
+	}
+
+	// A cancelled request context stops the lookup as well
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	warning, err = checkRegistryDNS(ctx, &fakeResolver{hang: true}, "https://registry.example.com/index", registryPolicy{}, time.Minute)
+	if err != nil || warning == "" {
+		t.Errorf("warning = %q, error = %v; want a warning", warning, err)
+	}
+}
+
+func TestValidateRegistryDNS(t *testing.T) {
+	p := &CratesPlugin{resolver: &fakeResolver{addrs: map[string][]string{"internal.example.com": {"10.20.0.5"}}}}
+	resp, err := p.Validate(context.Background(), map[string]any{"index": "sparse+https://internal.example.com/index/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid || len(resp.Errors) != 1 || resp.Errors[0].Field != "index" || !strings.Contains(resp.Errors[0].Message, "private networks") {
+		t.Errorf("expected one index error, got %+v", resp.Errors)
+	}
+
+	p = &CratesPlugin{resolver: &fakeResolver{hang: true}}
+	resp, err = p.Validate(context.Background(), map[string]any{"registry": "https://registry.example.com/index", "dns_timeout": "10ms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Valid {
+		t.Fatalf("expected a valid config, got %+v", resp.Errors)
+	}
+	found := false
+	for _, e := range resp.Errors {
+		if e.Code == warningCode && e.Field == "registry" && strings.Contains(e.Message, "did not finish within 10ms") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a registry DNS warning, got %+v", resp.Errors)
+	}
+}
+
+func TestExecuteRegistryDNS(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	execute := func(resolver ipResolver, extra ...string) *plugin.ExecuteResponse {
+		t.Helper()
+		config := map[string]any{"token": "secret", "index": "sparse+https://internal.example.com/index/", "skip_preflight": true, "allow_new_crate": true, "dns_timeout": "10ms"}
+		for _, key := range extra {
+			config[key] = true
+		}
+		p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}, resolver: resolver}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  config,
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return resp
+	}
+
+	resp := execute(&fakeResolver{addrs: map[string][]string{"internal.example.com": {"10.20.0.5"}}})
+	if resp.Success || !strings.HasPrefix(resp.Error, errConfigValidation) || !strings.Contains(resp.Error, "private networks") {
+		t.Errorf("expected a private network error, got %q", resp.Error)
+	}
+
+	resp = execute(&fakeResolver{hang: true})
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "did not finish within 10ms") {
+		t.Errorf("expected a DNS warning, got %v", warnings)
+	}
+
+	if _, ok := resp.Outputs["dns_check"]; ok {
+		t.Error("dns_check must only be reported when skipped")
+	}
+
+	// skip_dns_check resolves nothing and says so
+	resolver := &fakeResolver{addrs: map[string][]string{"internal.example.com": {"10.20.0.5"}}}
+	resp = execute(resolver, "skip_dns_check")
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if len(resolver.lookups) != 0 {
+		t.Errorf("expected no lookups, got %v", resolver.lookups)
+	}
+	if resp.Outputs["dns_check"] != dnsCheckSkipped {
+		t.Errorf("dns_check = %v, want skipped", resp.Outputs["dns_check"])
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+const (
+	// dryRunSimulated marks a dry run that only rendered the cargo command.
+	dryRunSimulated = "simulated"
+	// dryRunVerified marks a dry run verified by cargo publish --dry-run.
+	dryRunVerified = "cargo_dry_run"
+)
+
+// buildDryRunArgs constructs the cargo publish --dry-run arguments. The token
+// is omitted since a dry run never uploads.
+func (p *CratesPlugin) buildDryRunArgs(cfg *Config) []string {
+	dryRunCfg := *cfg
+	dryRunCfg.Token = ""
+	return append(p.buildPublishArgs(&dryRunCfg), "--dry-run")
+}
+
+// runPublishDryRun runs cargo publish --dry-run, packaging and verifying the
+// crate without uploading it. It returns the arguments cargo ran with.
+func (p *CratesPlugin) runPublishDryRun(ctx context.Context, cfg *Config) ([]string, []byte, error) {
+	runCfg := *cfg
+
+	targetDir, cleanupTargetDir, err := p.resolveTargetDir(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanupTargetDir()
+	runCfg.TargetDir = targetDir
+
+	args := p.buildDryRunArgs(&runCfg)
+	workDir := manifestWorkDir(&runCfg)
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
+	}
+
+	if cfg.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.PublishTimeout)
+		defer cancel()
+	}
+
+	output, err := p.runCargo(ctx, &runCfg, workDir, args...)
+	return args, output, err
+}
+
+// prePublish verifies the crate with cargo publish --dry-run before the
+// release is published, so packaging problems abort the release early. It also
+// audits dependency licenses when report_licenses or forbidden_licenses is set,
+// and checks the packaged file list when package_check, package_must_include
+// or package_must_not_include is set.
+func (p *CratesPlugin) prePublish(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool, decisions *decisionLog) (*plugin.ExecuteResponse, error) {
+	if err := p.validateConfig(cfg); err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
+		}, nil
+	}
+
+	release, err := parseReleaseVersion(releaseCtx.Version)
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid release version: %v", err),
+		}, nil
+	}
+	version := release.String()
+	crate, crateWarning := publishCrateName(cfg)
+	label := crateLabel(crate, version)
+
+	// Abort the release before building when crates.io would reject the crate
+	manifestWarnings, err := p.metadataCheck(cfg, releaseSubject(cfg, version), decisions)
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	dependencies, err := p.dependencyCheck(cfg, release, releaseSubject(cfg, version), decisions)
+	if err != nil {
+		outputs := map[string]any{}
+		dependencies.addOutputs(outputs)
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+			Outputs: outputs,
+		}, nil
+	}
+	if dependencies != nil {
+		manifestWarnings = append(manifestWarnings, dependencies.warnings...)
+	}
+
+	outputs := map[string]any{"version": version}
+	addCrateNameOutputs(crate, crateWarning, outputs)
+	dependencies.addOutputs(outputs)
+	if len(manifestWarnings) > 0 {
+		warnings, _ := outputs["warnings"].([]string)
+		outputs["warnings"] = append(warnings, manifestWarnings...)
+	}
+
+	// Measure the crate before any build so an oversized package fails fast,
+	// whether or not the later cargo checks run; host dry runs only execute
+	// cargo when execute_dry_run asks for it
+	if packageSizeCheckEnabled(cfg) && (!dryRun || cfg.ExecuteDryRun) {
+		packaged, err := p.measurePackage(ctx, cfg, manifestWorkDir(cfg))
+		if errors.Is(err, errCargoNotFound) {
+			return cargoNotFoundResponse(outputs), nil
+		}
+		if err != nil {
+			// cargo publish reports the packaging failure itself
+			warnings, _ := outputs["warnings"].([]string)
+			outputs["warnings"] = append(warnings, fmt.Sprintf("package size not checked: %v", err))
+		} else {
+			outputs["package_size_bytes"] = packaged.size
+			if err := checkPackageSize(label, packaged.size, cfg.MaxPackageSize); err != nil {
+				decisions.add(releaseSubject(cfg, version), decisionBlock, "packaged crate is over max_package_size", "package_size_check", "max_package_size")
+				return &plugin.ExecuteResponse{
+					Success: false,
+					Error:   err.Error(),
+					Outputs: outputs,
+				}, nil
+			}
+		}
+	}
+
+	// The checks above apply even when every later cargo check is off
+	if !cfg.PrePublishVerify && !licenseAuditEnabled(cfg) && !packageListEnabled(cfg) {
+		decisions.add("pre-publish verification", decisionSkip, "disabled by configuration", "prepublish_verify", "prepublish_verify")
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: "Pre-publish verification disabled (prepublish_verify: false)",
+			Outputs: outputs,
+		}, nil
+	}
+
+	// Host dry runs only execute cargo when execute_dry_run asks for it
+	if dryRun && !cfg.ExecuteDryRun {
+		decisions.add("pre-publish verification", decisionSkip, "host dry run; cargo was not run", "dry_run", "execute_dry_run")
+		outputs["dry_run_mode"] = dryRunSimulated
+		outputs["effective_config"] = p.explainConfig(cfg)
+		message := fmt.Sprintf("Would run pre-publish checks for %s", label)
+		if !packageListEnabled(cfg) {
+			message = fmt.Sprintf("Would audit dependency licenses of %s", label)
+		}
+		if cfg.PrePublishVerify {
+			outputs["command"] = formatCommand(cfg, p.buildDryRunArgs(cfg))
+			message = fmt.Sprintf("Would verify %s with cargo publish --dry-run", label)
+		}
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: message,
+			Outputs: outputs,
+		}, nil
+	}
+
+	if licenseAuditEnabled(cfg) {
+		report, err := p.auditLicenses(ctx, cfg)
+		if errors.Is(err, errCargoNotFound) {
+			return cargoNotFoundResponse(outputs), nil
+		}
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s: %v", crateFailure("dependency license audit", crate, version), err),
+				Outputs: outputs,
+			}, nil
+		}
+		report.addOutputs(outputs)
+		if len(report.forbidden) > 0 {
+			decisions.add(releaseSubject(cfg, version), decisionBlock, "dependencies are only available under forbidden licenses: "+strings.Join(report.forbidden, ", "), "license_audit", "forbidden_licenses")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("dependencies are only available under forbidden licenses: %s", strings.Join(report.forbidden, ", ")),
+				Outputs: outputs,
+			}, nil
+		}
+	}
+
+	// Catch include/exclude rules that leave the crate empty or incomplete,
+	// or let unwanted files in
+	if packageListEnabled(cfg) {
+		files, err := p.listPackageFiles(ctx, cfg)
+		if errors.Is(err, errCargoNotFound) {
+			return cargoNotFoundResponse(outputs), nil
+		}
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s: %v", crateFailure("packaging check", crate, version), err),
+				Outputs: outputs,
+			}, nil
+		}
+		outputs["package_files"] = files
+		outputs["package_file_count"] = len(files)
+		if problems := checkPackageGlobs(files, cfg.MustInclude, cfg.MustNotInclude); len(problems) > 0 {
+			summary := strings.Join(problems, "; ")
+			decisions.add(releaseSubject(cfg, version), decisionBlock, "packaged files: "+summary, "package_files", "package_must_not_include")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s: %s", crateFailure("packaged file check", crate, version), summary),
+				Outputs: outputs,
+			}, nil
+		}
+		if problems := checkPackageFiles(files, cfg.RequiredPaths, cfg.MinPackageFiles); packageCheckEnabled(cfg) && len(problems) > 0 {
+			summary := strings.Join(problems, "; ")
+			if cfg.PackageCheck == packageCheckError {
+				decisions.add(releaseSubject(cfg, version), decisionBlock, "packaging check: "+summary, "package_check", "package_check")
+				return &plugin.ExecuteResponse{
+					Success: false,
+					Error:   fmt.Sprintf("%s: %s\ncargo would package:\n%s", crateFailure("packaging check", crate, version), summary, strings.Join(files, "\n")),
+					Outputs: outputs,
+				}, nil
+			}
+			warnings, _ := outputs["warnings"].([]string)
+			outputs["warnings"] = append(warnings, fmt.Sprintf("packaging check: %s (cargo would package: %s)", summary, strings.Join(files, ", ")))
+		}
+	}
+
+	if !cfg.PrePublishVerify {
+		reason := "disabled by configuration; only the license audit ran"
+		message := fmt.Sprintf("Audited dependency licenses of %s (prepublish_verify: false)", label)
+		if packageListEnabled(cfg) {
+			reason = "disabled by configuration; only the pre-publish checks ran"
+			message = fmt.Sprintf("Ran pre-publish checks for %s (prepublish_verify: false)", label)
+		}
+		decisions.add("pre-publish verification", decisionSkip, reason, "prepublish_verify", "prepublish_verify")
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: message,
+			Outputs: outputs,
+		}, nil
+	}
+
+	args, output, err := p.runPublishDryRun(ctx, cfg)
+	outputs["command"] = formatCommand(cfg, args)
+	outputs["output"] = string(output)
+	if errors.Is(err, errCargoNotFound) {
+		return cargoNotFoundResponse(outputs), nil
+	}
+	if err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: cargo publish --dry-run: %v\nOutput: %s", crateFailure("pre-publish verification", crate, version), err, string(output)),
+			Outputs: outputs,
+		}, nil
+	}
+
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Verified %s with cargo publish --dry-run", label),
+		Outputs: outputs,
+	}, nil
+}
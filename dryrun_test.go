@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestBuildDryRunArgs(t *testing.T) {
+	p := &CratesPlugin{}
+	cfg := &Config{Token: "secret-token", Registry: "my-registry", Locked: true}
+
+	got := strings.Join(p.buildDryRunArgs(cfg), " ")
+	want := "publish --registry my-registry --locked --dry-run"
+	if got != want {
+		t.Errorf("buildDryRunArgs() = %q, want %q", got, want)
+	}
+	if cfg.Token != "secret-token" {
+		t.Error("buildDryRunArgs must not modify the config")
+	}
+}
+
+func TestExecuteExecuteDryRun(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]any
+		runErr            error
+		wantSuccess       bool
+		wantMode          string
+		wantCalls         int
+		wantMethod        string
+		wantMsgContains   string
+		wantErrorContains string
+	}{
+		{
+			name:            "default only simulates",
+			config:          map[string]any{"token": "test-token"},
+			wantSuccess:     true,
+			wantMode:        dryRunSimulated,
+			wantCalls:       0,
+			wantMsgContains: "Would publish fixture 1.0.0",
+		},
+		{
+			name:            "execute_dry_run runs cargo",
+			config:          map[string]any{"token": "test-token", "execute_dry_run": true},
+			wantSuccess:     true,
+			wantMode:        dryRunVerified,
+			wantCalls:       1,
+			wantMethod:      "Run",
+			wantMsgContains: "Verified fixture 1.0.0",
+		},
+		{
+			name:            "execute_dry_run runs in the manifest directory",
+			config:          map[string]any{"execute_dry_run": true, "manifest_path": "crates/lib/Cargo.toml"},
+			wantSuccess:     true,
+			wantMode:        dryRunVerified,
+			wantCalls:       1,
+			wantMethod:      "RunInDir",
+			wantMsgContains: "Verified lib 1.0.0",
+		},
+		{
+			name:              "execute_dry_run fails when cargo rejects the crate",
+			config:            map[string]any{"execute_dry_run": true},
+			runErr:            errors.New("exit status 101"),
+			wantSuccess:       false,
+			wantMode:          dryRunVerified,
+			wantCalls:         1,
+			wantMethod:        "Run",
+			wantErrorContains: "cargo publish --dry-run failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				return []byte("Packaged 4 files\nwarning: aborting upload due to dry run"), tt.runErr
+			}
+			mock := &MockCommandExecutor{
+				RunFunc: run,
+				RunInDirFunc: func(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+					return run(ctx, name, args...)
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if tt.wantMsgContains != "" && !strings.Contains(resp.Message, tt.wantMsgContains) {
+				t.Errorf("expected message to contain %q, got %q", tt.wantMsgContains, resp.Message)
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain %q, got %q", tt.wantErrorContains, resp.Error)
+			}
+			if resp.Outputs["dry_run_mode"] != tt.wantMode {
+				t.Errorf("dry_run_mode = %v, want %s", resp.Outputs["dry_run_mode"], tt.wantMode)
+			}
+
+			calls := mock.GetCalls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, len(calls))
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+
+			if calls[0].Method != tt.wantMethod {
+				t.Errorf("expected %s, got %s", tt.wantMethod, calls[0].Method)
+			}
+			argsStr := strings.Join(calls[0].Args, " ")
+			if !strings.HasSuffix(argsStr, "--dry-run") {
+				t.Errorf("expected --dry-run, got %s", argsStr)
+			}
+			if strings.Contains(argsStr, "--token") {
+				t.Errorf("dry run must not pass the token, got %s", argsStr)
+			}
+			if command, _ := resp.Outputs["command"].(string); !strings.HasSuffix(command, "--dry-run") {
+				t.Errorf("command output should be the executed command, got %q", command)
+			}
+		})
+	}
+}
+
+func TestExecutePrePublish(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]any
+		dryRun            bool
+		runErr            error
+		wantSuccess       bool
+		wantCalls         int
+		wantMsgContains   string
+		wantErrorContains string
+	}{
+		{
+			name:            "verifies with cargo publish --dry-run",
+			config:          map[string]any{"token": "test-token"},
+			wantSuccess:     true,
+			wantCalls:       2,
+			wantMsgContains: "Verified fixture 1.0.0",
+		},
+		{
+			name:            "no token required",
+			config:          map[string]any{},
+			wantSuccess:     true,
+			wantCalls:       2,
+			wantMsgContains: "Verified fixture 1.0.0",
+		},
+		{
+			name:              "cargo rejects the crate",
+			config:            map[string]any{},
+			runErr:            errors.New("exit status 101"),
+			wantSuccess:       false,
+			wantCalls:         2,
+			wantErrorContains: "pre-publish verification failed",
+		},
+		{
+			name:            "disabled with prepublish_verify",
+			config:          map[string]any{"prepublish_verify": false},
+			wantSuccess:     true,
+			wantCalls:       1,
+			wantMsgContains: "Pre-publish verification disabled",
+		},
+		{
+			name:              "invalid configuration",
+			config:            map[string]any{"manifest_path": "../Cargo.toml"},
+			wantSuccess:       false,
+			wantCalls:         0,
+			wantErrorContains: "configuration validation failed",
+		},
+		{
+			name:            "host dry run only simulates",
+			config:          map[string]any{},
+			dryRun:          true,
+			wantSuccess:     true,
+			wantCalls:       0,
+			wantMsgContains: "Would verify fixture 1.0.0",
+		},
+		{
+			name:            "host dry run with execute_dry_run verifies",
+			config:          map[string]any{"execute_dry_run": true},
+			dryRun:          true,
+			wantSuccess:     true,
+			wantCalls:       2,
+			wantMsgContains: "Verified fixture 1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					return []byte("warning: aborting upload due to dry run"), tt.runErr
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPrePublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if tt.wantMsgContains != "" && !strings.Contains(resp.Message, tt.wantMsgContains) {
+				t.Errorf("expected message to contain %q, got %q", tt.wantMsgContains, resp.Message)
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain %q, got %q", tt.wantErrorContains, resp.Error)
+			}
+
+			calls := mock.GetCalls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, len(calls))
+			}
+			for _, call := range calls {
+				argsStr := strings.Join(call.Args, " ")
+				if argsStr == "package --no-verify" {
+					// The package size check runs before verification
+					continue
+				}
+				if !strings.HasPrefix(argsStr, "publish") || !strings.HasSuffix(argsStr, "--dry-run") {
+					t.Errorf("expected cargo publish --dry-run, got %s", argsStr)
+				}
+				if strings.Contains(argsStr, "--token") {
+					t.Errorf("pre-publish verification must not pass the token, got %s", argsStr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+//go:build e2e
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRegistry is a minimal in-memory sparse registry. It serves the index
+// layout cargo expects and implements the publish and download endpoints.
+type testRegistry struct {
+	server *httptest.Server
+	token  string
+
+	mu      sync.Mutex
+	entries map[string][]testIndexEntry
+	crates  map[string][]byte
+}
+
+// testIndexEntry is a single version line in a sparse index file.
+type testIndexEntry struct {
+	Name     string              `json:"name"`
+	Vers     string              `json:"vers"`
+	Deps     []testIndexDep      `json:"deps"`
+	Cksum    string              `json:"cksum"`
+	Features map[string][]string `json:"features"`
+	Yanked   bool                `json:"yanked"`
+}
+
+// testIndexDep is a dependency as recorded in the index.
+type testIndexDep struct {
+	Name            string   `json:"name"`
+	Req             string   `json:"req"`
+	Features        []string `json:"features"`
+	Optional        bool     `json:"optional"`
+	DefaultFeatures bool     `json:"default_features"`
+	Target          *string  `json:"target"`
+	Kind            string   `json:"kind"`
+	Registry        *string  `json:"registry,omitempty"`
+	Package         *string  `json:"package,omitempty"`
+}
+
+// testPublishMetadata is the JSON metadata cargo sends with a publish request.
+type testPublishMetadata struct {
+	Name     string              `json:"name"`
+	Vers     string              `json:"vers"`
+	Features map[string][]string `json:"features"`
+	Deps     []struct {
+		Name               string   `json:"name"`
+		VersionReq         string   `json:"version_req"`
+		Features           []string `json:"features"`
+		Optional           bool     `json:"optional"`
+		DefaultFeatures    bool     `json:"default_features"`
+		Target             *string  `json:"target"`
+		Kind               string   `json:"kind"`
+		Registry           *string  `json:"registry"`
+		ExplicitNameInToml *string  `json:"explicit_name_in_toml"`
+	} `json:"deps"`
+}
+
+// newTestRegistry starts a registry that accepts publishes with the given token.
+func newTestRegistry(t *testing.T, token string) *testRegistry {
+	t.Helper()
+
+	r := &testRegistry{
+		token:   token,
+		entries: map[string][]testIndexEntry{},
+		crates:  map[string][]byte{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index/config.json", r.handleConfig)
+	mux.HandleFunc("/index/", r.handleIndex)
+	mux.HandleFunc("/api/v1/crates/new", r.handlePublish)
+	mux.HandleFunc("/api/v1/crates/", r.handleDownload)
+
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// indexURL returns the sparse index URL to pass to cargo.
+func (r *testRegistry) indexURL() string {
+	return "sparse+" + r.server.URL + "/index/"
+}
+
+// published reports whether the given crate version was published.
+func (r *testRegistry) published(name, vers string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.entries[name] {
+		if e.Vers == vers {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *testRegistry) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"dl":  r.server.URL + "/api/v1/crates",
+		"api": r.server.URL,
+	})
+}
+
+func (r *testRegistry) handleIndex(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/index/")
+	name := path[strings.LastIndex(path, "/")+1:]
+	if path != indexPath(name) {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.mu.Lock()
+	entries := r.entries[name]
+	r.mu.Unlock()
+
+	if len(entries) == 0 {
+		http.NotFound(w, req)
+		return
+	}
+	for _, e := range entries {
+		line, _ := json.Marshal(e)
+		_, _ = w.Write(append(line, '\n'))
+	}
+}
+
+func (r *testRegistry) handlePublish(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Header.Get("Authorization") != r.token {
+		writeRegistryError(w, http.StatusForbidden, "invalid token")
+		return
+	}
+
+	meta, crate, err := readPublishBody(req.Body)
+	if err != nil {
+		writeRegistryError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sum := sha256.Sum256(crate)
+	entry := testIndexEntry{
+		Name:     meta.Name,
+		Vers:     meta.Vers,
+		Deps:     []testIndexDep{},
+		Cksum:    hex.EncodeToString(sum[:]),
+		Features: meta.Features,
+	}
+	for _, d := range meta.Deps {
+		dep := testIndexDep{
+			Name:            d.Name,
+			Req:             d.VersionReq,
+			Features:        d.Features,
+			Optional:        d.Optional,
+			DefaultFeatures: d.DefaultFeatures,
+			Target:          d.Target,
+			Kind:            d.Kind,
+			Registry:        d.Registry,
+		}
+		if d.ExplicitNameInToml != nil {
+			dep.Name, dep.Package = *d.ExplicitNameInToml, &d.Name
+		}
+		entry.Deps = append(entry.Deps, dep)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries[meta.Name] {
+		if e.Vers == meta.Vers {
+			writeRegistryError(w, http.StatusBadRequest, fmt.Sprintf("crate version `%s` is already uploaded", meta.Vers))
+			return
+		}
+	}
+	r.entries[meta.Name] = append(r.entries[meta.Name], entry)
+	r.crates[meta.Name+"/"+meta.Vers] = crate
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"warnings":{"invalid_categories":[],"invalid_badges":[],"other":[]}}`)
+}
+
+func (r *testRegistry) handleDownload(w http.ResponseWriter, req *http.Request) {
+	// /api/v1/crates/{name}/{version}/download
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/crates/"), "/")
+	if len(parts) != 3 || parts[2] != "download" {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.mu.Lock()
+	crate, ok := r.crates[parts[0]+"/"+parts[1]]
+	r.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	_, _ = w.Write(crate)
+}
+
+// readPublishBody decodes cargo's publish payload: a little-endian u32 length
+// and JSON metadata, followed by a u32 length and the .crate file.
+func readPublishBody(body io.Reader) (*testPublishMetadata, []byte, error) {
+	readChunk := func() ([]byte, error) {
+		var n uint32
+		if err := binary.Read(body, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(body, buf); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	metaJSON, err := readChunk()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	var meta testPublishMetadata
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	crate, err := readChunk()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read crate file: %w", err)
+	}
+	return &meta, crate, nil
+}
+
+// indexPath returns the index file path for a crate name.
+func indexPath(name string) string {
+	lower := strings.ToLower(name)
+	switch len(lower) {
+	case 0:
+		return ""
+	case 1:
+		return "1/" + lower
+	case 2:
+		return "2/" + lower
+	case 3:
+		return "3/" + lower[:1] + "/" + lower
+	default:
+		return lower[:2] + "/" + lower[2:4] + "/" + lower
+	}
+}
+
+// writeRegistryError writes an error in the registry web API format.
+func writeRegistryError(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"detail": detail}},
+	})
+}
@@ -0,0 +1,274 @@
+//go:build e2e
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// requireCargo skips the test when cargo is not installed.
+func requireCargo(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("cargo"); err != nil {
+		t.Skip("cargo not installed, skipping end-to-end test")
+	}
+}
+
+// newScratchCrate generates a minimal library crate and returns its manifest
+// path relative to the working directory, since manifest_path must be relative.
+func newScratchCrate(t *testing.T, name, version string) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp(".", "e2e-scratch-")
+	if err != nil {
+		t.Fatalf("failed to create scratch directory: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	manifest := fmt.Sprintf(`[package]
+name = %q
+version = %q
+edition = "2021"
+description = "Scratch crate for plugin end-to-end tests"
+license = "MIT"
+
+[dependencies]
+
+# Keep cargo from treating the test fixture manifests above as a workspace
+[workspace]
+`, name, version)
+
+	files := map[string]string{
+		"Cargo.toml": manifest,
+		"src/lib.rs": "pub fn answer() -> u32 {\n    42\n}\n",
+	}
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("failed to create %s: %v", filepath.Dir(full), err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", full, err)
+		}
+	}
+
+	return filepath.Join(dir, "Cargo.toml")
+}
+
+func TestE2EPublishToLocalRegistry(t *testing.T) {
+	requireCargo(t)
+
+	const token = "e2e-token"
+	registry := newTestRegistry(t, token)
+	manifest := newScratchCrate(t, "relicta-e2e-scratch", "0.1.0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"token":           token,
+			"allow_new_crate": true,
+			"index":           registry.indexURL(),
+			"manifest_path":   manifest,
+			"allow_dirty":     true,
+			"target_dir":      "temp",
+		},
+		Context: plugin.ReleaseContext{Version: "v0.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+
+	if !registry.published("relicta-e2e-scratch", "0.1.0") {
+		t.Error("crate was not published to the local registry")
+	}
+
+	if resp.Outputs["version"] != "0.1.0" {
+		t.Errorf("version output = %v, want 0.1.0", resp.Outputs["version"])
+	}
+	host := strings.TrimPrefix(registry.server.URL, "http://")
+	if !strings.Contains(resp.Message, host) {
+		t.Errorf("message %q should name the registry host %s", resp.Message, host)
+	}
+
+	// cargo waits for the new version to appear in the index before reporting
+	// it as published, so this confirms the availability wait succeeded.
+	output, _ := resp.Outputs["output"].(string)
+	if !strings.Contains(output, "Published relicta-e2e-scratch v0.1.0") {
+		t.Errorf("output should report the crate as available, got:\n%s", output)
+	}
+}
+
+func TestE2EPublishTokenViaEnv(t *testing.T) {
+	requireCargo(t)
+
+	const token = "e2e-token"
+	registry := newTestRegistry(t, token)
+	manifest := newScratchCrate(t, "relicta-e2e-env-token", "0.1.0")
+
+	// Define the named registry for cargo; the plugin supplies only its token
+	t.Setenv("CARGO_REGISTRIES_E2E_LOCAL_INDEX", registry.indexURL())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"token":           token,
+			"allow_new_crate": true,
+			"token_via_env":   true,
+			"env_mode":        "clean",
+			"registry":        "e2e-local",
+			"manifest_path":   manifest,
+			"allow_dirty":     true,
+			"target_dir":      "temp",
+		},
+		Context: plugin.ReleaseContext{Version: "v0.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if !registry.published("relicta-e2e-env-token", "0.1.0") {
+		t.Error("crate was not published to the local registry")
+	}
+}
+
+func TestE2EPackageThenPublish(t *testing.T) {
+	requireCargo(t)
+
+	const token = "e2e-token"
+	registry := newTestRegistry(t, token)
+	manifest := newScratchCrate(t, "relicta-e2e-two-phase", "0.2.0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"token":                token,
+			"allow_new_crate":      true,
+			"index":                registry.indexURL(),
+			"manifest_path":        manifest,
+			"allow_dirty":          true,
+			"package_then_publish": true,
+		},
+		Context: plugin.ReleaseContext{Version: "v0.2.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+
+	if !registry.published("relicta-e2e-two-phase", "0.2.0") {
+		t.Error("crate was not published to the local registry")
+	}
+	crateFile, _ := resp.Outputs["crate_file"].(string)
+	if filepath.Base(crateFile) != "relicta-e2e-two-phase-0.2.0.crate" {
+		t.Errorf("crate_file = %q, want the packaged .crate path", crateFile)
+	}
+	if size, _ := resp.Outputs["crate_size"].(int64); size <= 0 {
+		t.Errorf("crate_size = %v, want a positive size", resp.Outputs["crate_size"])
+	}
+}
+
+func TestE2EPackageCheckCatchesExcludedSources(t *testing.T) {
+	requireCargo(t)
+
+	manifest := newScratchCrate(t, "relicta-e2e-empty", "0.1.0")
+	content, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", manifest, err)
+	}
+	content = []byte(strings.Replace(string(content), "license = \"MIT\"\n", "license = \"MIT\"\nexclude = [\"src/\"]\n", 1))
+	if err := os.WriteFile(manifest, content, 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", manifest, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook: plugin.HookPrePublish,
+		Config: map[string]any{
+			"manifest_path":     manifest,
+			"package_check":     "error",
+			"prepublish_verify": false,
+		},
+		Context: plugin.ReleaseContext{Version: "v0.1.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected the packaging check to fail for a crate without sources")
+	}
+	if !strings.Contains(resp.Error, "no packaged file under src/") || !strings.Contains(resp.Error, "Cargo.toml") {
+		t.Errorf("error should name the problem and the packaged files, got: %s", resp.Error)
+	}
+}
+
+func TestE2EPublishRejectsDuplicateVersion(t *testing.T) {
+	requireCargo(t)
+
+	const token = "e2e-token"
+	registry := newTestRegistry(t, token)
+	manifest := newScratchCrate(t, "relicta-e2e-dup", "0.1.0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+	req := plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"token":           token,
+			"allow_new_crate": true,
+			"index":           registry.indexURL(),
+			"manifest_path":   manifest,
+			"allow_dirty":     true,
+			"target_dir":      "temp",
+		},
+		Context: plugin.ReleaseContext{Version: "v0.1.0"},
+	}
+
+	first, err := p.Execute(ctx, req)
+	if err != nil || !first.Success {
+		t.Fatalf("first publish failed: err=%v, error=%s", err, first.Error)
+	}
+
+	second, err := p.Execute(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Success {
+		t.Fatal("expected republishing the same version to fail")
+	}
+	if !strings.Contains(second.Error, "already") {
+		t.Errorf("error should explain the version exists, got: %s", second.Error)
+	}
+}
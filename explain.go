@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Option sources reported in the effective configuration.
+const (
+	sourceConfig  = "config"
+	sourceDefault = "default"
+	sourceUnset   = "unset"
+)
+
+// Check severities reported in the effective configuration.
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// effectiveConfig describes what a configuration resolved to: every option's
+// final value and where it came from, the publish plan and the checks that
+// will run. It is rendered from the same Config the publish path uses.
+type effectiveConfig struct {
+	Options []effectiveOption `json:"options"`
+	Plan    publishPlan       `json:"plan"`
+	Checks  []effectiveCheck  `json:"checks"`
+}
+
+// effectiveOption is one option's resolved value. Secrets are masked.
+type effectiveOption struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// publishPlan is the upload the configuration leads to.
+type publishPlan struct {
+	Registry       string `json:"registry"`
+	Index          string `json:"index,omitempty"`
+	CrateName      string `json:"crate_name,omitempty"`
+	ManifestPath   string `json:"manifest_path"`
+	WorkDir        string `json:"work_dir,omitempty"`
+	Auth           string `json:"auth"`
+	AuthDetail     string `json:"auth_detail"`
+	PackageCommand string `json:"package_command,omitempty"`
+	Command        string `json:"command"`
+	Preflight      string `json:"registry_preflight,omitempty"`
+}
+
+// effectiveCheck is a check and the severity its failure has. Under
+// failure_policy soft every error is reported as a warning.
+type effectiveCheck struct {
+	Name      string `json:"name"`
+	Enabled   bool   `json:"enabled"`
+	Severity  string `json:"severity,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ConfigKey string `json:"config_key"`
+}
+
+// effectiveOptions renders each option from the resolved Config, in schema
+// order. Every schema property must be listed.
+var effectiveOptions = []struct {
+	key   string
+	value func(cfg *Config) any
+}{
+	{"token", func(cfg *Config) any { return maskSecret(cfg.Token) }},
+	{"tokens", func(cfg *Config) any { return maskSecretMap(cfg.Tokens) }},
+	{"token_env", func(cfg *Config) any { return cfg.TokenEnv }},
+	{"token_file", func(cfg *Config) any { return cfg.TokenFile }},
+	{"token_file_root", func(cfg *Config) any { return cfg.TokenFileRoot }},
+	{"token_command", func(cfg *Config) any { return cfg.TokenCommand }},
+	{"token_command_timeout", func(cfg *Config) any { return cfg.TokenCmdTimeout.String() }},
+	{"token_keyring", func(cfg *Config) any {
+		if cfg.TokenKeyring == nil {
+			return nil
+		}
+		return map[string]string{"service": cfg.TokenKeyring.Service, "account": cfg.TokenKeyring.Account}
+	}},
+	{"token_via_env", func(cfg *Config) any { return cfg.TokenViaEnv }},
+	{"codeartifact", func(cfg *Config) any {
+		if cfg.CodeArtifact == nil {
+			return nil
+		}
+		return map[string]string{"domain": cfg.CodeArtifact.Domain, "domain_owner": cfg.CodeArtifact.DomainOwner, "region": cfg.CodeArtifact.Region}
+	}},
+	{"trusted_publishing", func(cfg *Config) any { return cfg.TrustedPublishing }},
+	{"credential_provider", func(cfg *Config) any { return maskProviderArgs(cfg.CredentialProvider) }},
+	{"registry", func(cfg *Config) any { return cfg.Registry }},
+	{"index", func(cfg *Config) any { return cfg.Index }},
+	{"registry_index", func(cfg *Config) any { return cfg.RegistryIndex }},
+	{"registries", func(cfg *Config) any { return maskRegistryTargets(cfg.Registries) }},
+	{"registries_policy", func(cfg *Config) any {
+		if cfg.RegistriesPolicy == "" {
+			return registriesRequireAll
+		}
+		return cfg.RegistriesPolicy
+	}},
+	{"channel_registries", func(cfg *Config) any { return cfg.ChannelRegistries }},
+	{"allowed_registry_ports", func(cfg *Config) any {
+		if cfg.RegistryPorts == nil {
+			return defaultRegistryPorts
+		}
+		return cfg.RegistryPorts
+	}},
+	{"allow_insecure_registry", func(cfg *Config) any { return cfg.InsecureRegistry }},
+	{"insecure_hosts", func(cfg *Config) any { return cfg.InsecureHosts }},
+	{"allowed_networks", func(cfg *Config) any { return cfg.AllowedNetworks }},
+	{"dns_timeout", func(cfg *Config) any { return cfg.DNSTimeout.String() }},
+	{"skip_dns_check", func(cfg *Config) any { return cfg.SkipDNSCheck }},
+	{"check_registry_connectivity", func(cfg *Config) any { return cfg.CheckConnectivity }},
+	{"check_registry_defined", func(cfg *Config) any { return cfg.RegistryDefined }},
+	{"registry_ca_cert", func(cfg *Config) any { return cfg.RegistryCACert }},
+	{"client_cert", func(cfg *Config) any { return cfg.ClientCert }},
+	{"client_key", func(cfg *Config) any { return cfg.ClientKey }},
+	{"proxy", func(cfg *Config) any {
+		if cfg.Proxy == "" {
+			return ""
+		}
+		return redactProxy(cfg.Proxy)
+	}},
+	{"no_proxy", func(cfg *Config) any { return cfg.NoProxy }},
+	{"allow_dirty", func(cfg *Config) any { return cfg.AllowDirty }},
+	{"no_verify", func(cfg *Config) any { return cfg.NoVerify }},
+	{"allow_new_crate", func(cfg *Config) any { return cfg.AllowNewCrate }},
+	{"crate_name", func(cfg *Config) any { return crateName(cfg) }},
+	{"manifest_path", func(cfg *Config) any { return cfg.ManifestPath }},
+	{"features", func(cfg *Config) any { return cfg.Features }},
+	{"all_features", func(cfg *Config) any { return cfg.AllFeatures }},
+	{"no_default_features", func(cfg *Config) any { return cfg.NoDefaultFeatures }},
+	{"jobs", func(cfg *Config) any { return cfg.Jobs }},
+	{"net_retry", func(cfg *Config) any {
+		if cfg.NetRetry == nil {
+			return nil
+		}
+		return *cfg.NetRetry
+	}},
+	{"git_fetch_with_cli", func(cfg *Config) any { return cfg.GitFetchWithCLI }},
+	{"locked", func(cfg *Config) any { return cfg.Locked }},
+	{"offline", func(cfg *Config) any { return cfg.Offline }},
+	{"frozen", func(cfg *Config) any { return cfg.Frozen }},
+	{"target", func(cfg *Config) any { return cfg.Target }},
+	{"target_dir", func(cfg *Config) any { return cfg.TargetDir }},
+	{"target_dir_root", func(cfg *Config) any { return cfg.TargetDirRoot }},
+	{"temp_dir", func(cfg *Config) any { return cfg.TempDir }},
+	{"env_mode", func(cfg *Config) any { return cfg.EnvMode }},
+	{"env", func(cfg *Config) any { return cfg.Env }},
+	{"env_allowlist", func(cfg *Config) any { return cfg.EnvAllowlist }},
+	{"cargo_config", func(cfg *Config) any { return cfg.CargoConfig }},
+	{"extra_args", func(cfg *Config) any { return cfg.ExtraArgs }},
+	{"unstable_flags", func(cfg *Config) any { return cfg.UnstableFlags }},
+	{"quiet", func(cfg *Config) any { return cfg.Quiet }},
+	{"verbose", func(cfg *Config) any { return cfg.Verbose }},
+	{"toolchain", func(cfg *Config) any { return cfg.Toolchain }},
+	{"cargo_path", func(cfg *Config) any { return cfg.CargoPath }},
+	{"min_cargo_version", func(cfg *Config) any { return cfg.MinCargoVersion }},
+	{"package_then_publish", func(cfg *Config) any { return cfg.PackageThenPublish }},
+	{"execute_dry_run", func(cfg *Config) any { return cfg.ExecuteDryRun }},
+	{"prepublish_verify", func(cfg *Config) any { return cfg.PrePublishVerify }},
+	{"skip_metadata_check", func(cfg *Config) any { return cfg.SkipMetadataCheck }},
+	{"allow_patched", func(cfg *Config) any { return cfg.AllowPatched }},
+	{"deny_prerelease_deps", func(cfg *Config) any { return cfg.DenyPrereleaseDeps }},
+	{"skip_preflight", func(cfg *Config) any { return cfg.SkipPreflight }},
+	{"report_licenses", func(cfg *Config) any { return cfg.ReportLicenses }},
+	{"forbidden_licenses", func(cfg *Config) any { return cfg.ForbiddenLicenses }},
+	{"package_check", func(cfg *Config) any { return cfg.PackageCheck }},
+	{"required_paths", func(cfg *Config) any { return cfg.RequiredPaths }},
+	{"min_package_files", func(cfg *Config) any { return cfg.MinPackageFiles }},
+	{"package_must_include", func(cfg *Config) any { return cfg.MustInclude }},
+	{"package_must_not_include", func(cfg *Config) any { return cfg.MustNotInclude }},
+	{"max_package_size", func(cfg *Config) any { return cfg.MaxPackageSize }},
+	{"rate_limit_max_wait", func(cfg *Config) any { return cfg.RateLimitMaxWait.String() }},
+	{"quota_warn_threshold", func(cfg *Config) any { return cfg.QuotaWarnThreshold }},
+	{"quota_state_file", func(cfg *Config) any { return cfg.QuotaStateFile }},
+	{"publish_timeout", func(cfg *Config) any { return cfg.PublishTimeout.String() }},
+	{"post_publish_wait", func(cfg *Config) any { return cfg.PostPublishWait.String() }},
+	{"failure_policy", func(cfg *Config) any { return cfg.FailurePolicy }},
+	{"compat_level", func(cfg *Config) any {
+		if cfg.CompatLevel == "" {
+			return currentCompatLevel
+		}
+		return cfg.CompatLevel
+	}},
+	{"compat_features", func(cfg *Config) any { return cfg.CompatFeatures }},
+	{"forbid_inline_token", func(cfg *Config) any { return cfg.ForbidInlineToken }},
+	{"strict_config", func(cfg *Config) any { return cfg.StrictConfig }},
+	{"audit_log", func(cfg *Config) any { return cfg.AuditLog }},
+	{"metrics", func(cfg *Config) any {
+		return map[string]any{
+			"host":   cfg.Metrics.Host,
+			"port":   cfg.Metrics.Port,
+			"prefix": cfg.Metrics.Prefix,
+			"format": cfg.Metrics.Format,
+			"tags":   cfg.Metrics.Tags,
+		}
+	}},
+}
+
+// compatDefaults maps options whose default depends on compat_level to the
+// feature that sets it.
+var compatDefaults = map[string]string{
+	"prepublish_verify": compatPrePublishVerify,
+}
+
+// maskSecret replaces a set secret with redactedValue.
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// maskSecretMap masks every value of a secrets map, keeping the keys.
+func maskSecretMap(secrets map[string]string) map[string]string {
+	if secrets == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(secrets))
+	for key, secret := range secrets {
+		masked[key] = maskSecret(secret)
+	}
+	return masked
+}
+
+// maskProviderArgs keeps the credential provider name and masks its
+// arguments, which may reference secrets.
+func maskProviderArgs(provider string) string {
+	name, args, _ := strings.Cut(strings.TrimSpace(provider), " ")
+	if strings.TrimSpace(args) != "" {
+		return name + " " + redactedValue
+	}
+	return name
+}
+
+// optionSource reports where an option's value came from.
+func optionSource(cfg *Config, key string) string {
+	for _, use := range cfg.AliasesUsed {
+		if use.New == key && !use.Conflict {
+			return sourceConfig + " (deprecated " + use.Old + ")"
+		}
+	}
+	switch {
+	case key == "token":
+		if cfg.TokenSource == "" {
+			return sourceUnset
+		}
+		return cfg.TokenSource
+	case cfg.ExplicitKeys[key]:
+		return sourceConfig
+	case key == "forbid_inline_token" && cfg.ForbidInlineToken:
+		return "env " + forbidInlineTokenEnv
+	case key == "token_via_env" && cfg.CodeArtifact != nil:
+		return "codeartifact"
+	case key == "token_via_env" && cfg.TrustedPublishing:
+		return tokenSourceTrustedPublishing
+	case key == "crate_name" && crateName(cfg) != "":
+		return "manifest"
+	case compatDefaults[key] != "" && cfg.CompatLevel != "":
+		return sourceDefault + " (compat_level " + cfg.CompatLevel + ")"
+	}
+	return sourceDefault
+}
+
+// explainConfig describes the resolved configuration. It only renders cfg
+// and never runs commands, so it is safe to call before or after the token
+// is resolved.
+func (p *CratesPlugin) explainConfig(cfg *Config) *effectiveConfig {
+	explained := &effectiveConfig{}
+	for _, option := range effectiveOptions {
+		explained.Options = append(explained.Options, effectiveOption{
+			Key:    option.key,
+			Value:  option.value(cfg),
+			Source: optionSource(cfg, option.key),
+		})
+	}
+
+	// The same arguments publish builds, relative to the same work directory
+	workDir := manifestWorkDir(cfg)
+	rebase := func(args []string) []string {
+		if workDir != "" {
+			return rebaseManifestPath(args, workDir)
+		}
+		return args
+	}
+	plan := publishPlan{
+		Registry:     p.getRegistryName(cfg),
+		Index:        cfg.Index,
+		CrateName:    crateName(cfg),
+		ManifestPath: cfg.ManifestPath,
+		WorkDir:      workDir,
+		Auth:         authMechanism(cfg),
+		AuthDetail:   authDescription(cfg),
+		Command:      formatCommand(cfg, rebase(p.buildPublishArgs(cfg))),
+	}
+	if cfg.PackageThenPublish {
+		publishCfg := *cfg
+		publishCfg.NoVerify = true
+		plan.PackageCommand = formatCommand(cfg, rebase(p.buildPackageArgs(cfg)))
+		plan.Command = formatCommand(cfg, rebase(p.buildPublishArgs(&publishCfg)))
+	}
+	if network, address, reason := registryPreflightTarget(cfg); reason == "" {
+		plan.Preflight = network + " " + address
+	}
+	explained.Plan = plan
+	explained.Checks = explainChecks(cfg)
+	return explained
+}
+
+// explainChecks lists the checks and the severity of their failures.
+func explainChecks(cfg *Config) []effectiveCheck {
+	blocking := severityError
+	if cfg.FailurePolicy == failurePolicySoft {
+		blocking = severityWarning
+	}
+	check := func(name string, enabled bool, severity, configKey string) effectiveCheck {
+		if !enabled {
+			severity = ""
+		}
+		return effectiveCheck{Name: name, Enabled: enabled, Severity: severity, ConfigKey: configKey}
+	}
+
+	licenseSeverity := severityInfo
+	if len(cfg.ForbiddenLicenses) > 0 {
+		licenseSeverity = blocking
+	}
+	packageSeverity := blocking
+	if cfg.PackageCheck == packageCheckWarn {
+		packageSeverity = severityWarning
+	}
+	aliasSeverity := severityWarning
+	if cfg.StrictConfig {
+		aliasSeverity = severityError
+	}
+	_, _, preflightSkip := registryPreflightTarget(cfg)
+	preflight := check("registry_preflight", preflightSkip == "", blocking, "skip_preflight")
+	preflight.Reason = preflightSkip
+
+	return []effectiveCheck{
+		check("deprecated_keys", true, aliasSeverity, "strict_config"),
+		check("manifest_version_check", compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatManifestVersionCheck), blocking, "compat_level"),
+		check("metadata_check", metadataCheckEnabled(cfg), blocking, "skip_metadata_check"),
+		check("patch_check", patchCheckEnabled(cfg), blocking, "allow_patched"),
+		check("dependency_check", dependencyCheckEnabled(cfg), blocking, "compat_level"),
+		check("prepublish_verify", cfg.PrePublishVerify, blocking, "prepublish_verify"),
+		check("license_audit", licenseAuditEnabled(cfg), licenseSeverity, "forbidden_licenses"),
+		check("package_check", packageCheckEnabled(cfg), packageSeverity, "package_check"),
+		check("package_files", len(cfg.MustInclude) > 0 || len(cfg.MustNotInclude) > 0, blocking, "package_must_not_include"),
+		check("package_size_check", packageSizeCheckEnabled(cfg), blocking, "max_package_size"),
+		preflight,
+		check("dns_check", !cfg.SkipDNSCheck, blocking, "skip_dns_check"),
+		check("min_cargo_version", cfg.MinCargoVersion != "", blocking, "min_cargo_version"),
+		check("new_crate_check", newCrateCheckEnabled(cfg), blocking, "allow_new_crate"),
+		check("crate_name_mismatch", cfg.CrateName != "", severityWarning, "crate_name"),
+		check("publish_quota", cfg.QuotaWarnThreshold > 0, severityWarning, "quota_warn_threshold"),
+	}
+}
+
+// runExplain implements --explain: it reads a plugin configuration from a
+// JSON file and prints the effective configuration without running cargo or
+// any token source. It returns the process exit code.
+func runExplain(path string, stdout, stderr io.Writer) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read %s: %v\n", path, err)
+		return 1
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		fmt.Fprintf(stderr, "%s is not a JSON object: %v\n", path, err)
+		return 1
+	}
+
+	p := &CratesPlugin{}
+	explained := p.explainConfig(p.parseConfig(raw))
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(explained); err != nil {
+		fmt.Fprintf(stderr, "failed to render the effective configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
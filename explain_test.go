@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/explain")
+
+func TestEffectiveOptionsMatchSchema(t *testing.T) {
+	var root struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(baseConfigSchema), &root); err != nil {
+		t.Fatalf("config schema is not valid JSON: %v", err)
+	}
+
+	explained := map[string]bool{}
+	for _, option := range effectiveOptions {
+		if explained[option.key] {
+			t.Errorf("option %s is explained twice", option.key)
+		}
+		explained[option.key] = true
+		if _, ok := root.Properties[option.key]; !ok {
+			t.Errorf("explained option %s is not in the schema", option.key)
+		}
+	}
+	for key := range root.Properties {
+		if !explained[key] {
+			t.Errorf("schema option %s is missing from the effective configuration", key)
+		}
+	}
+}
+
+func TestExplainGolden(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "env-secret")
+	t.Setenv("CARGO_REGISTRIES_MY_REGISTRY_TOKEN", "")
+	t.Setenv("CARGO_REGISTRIES_MY_REGISTRY_INDEX", "")
+	t.Setenv("CARGO_REGISTRIES_INTERNAL_INDEX", "")
+
+	inputs, err := filepath.Glob("../explain/*.json")
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no explain inputs found: %v", err)
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runExplain(input, &stdout, &stderr); code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr.String())
+			}
+			if strings.Contains(stdout.String(), "secret") {
+				t.Errorf("effective configuration leaks a secret:\n%s", stdout.String())
+			}
+
+			golden := strings.TrimSuffix(input, ".json") + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, stdout.Bytes(), 0o644); err != nil {
+					t.Fatalf("failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read %s (run go test -update to create it): %v", golden, err)
+			}
+			if stdout.String() != string(want) {
+				t.Errorf("effective configuration differs from %s (run go test -update after intended changes):\n%s", golden, stdout.String())
+			}
+		})
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	dir := t.TempDir()
+	notObject := filepath.Join(dir, "list.json")
+	if err := os.WriteFile(notObject, []byte(`["token"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		filepath.Join(dir, "missing.json"): "failed to read",
+		notObject:                          "is not a JSON object",
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := runExplain(path, &stdout, &stderr); code != 1 {
+			t.Errorf("%s: exit code = %d, want 1", path, code)
+		}
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("%s: stderr %q should contain %q", path, stderr.String(), want)
+		}
+	}
+}
+
+func TestDryRunEffectiveConfig(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "dry-run-secret", "locked": true, "compat_level": "2.0"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("dry run failed: %s", resp.Error)
+	}
+
+	explained, ok := resp.Outputs["effective_config"].(*effectiveConfig)
+	if !ok {
+		t.Fatalf("effective_config = %T, want *effectiveConfig", resp.Outputs["effective_config"])
+	}
+	// The plan is the command the dry run rendered, not a second rendering of its own
+	if explained.Plan.Command != resp.Outputs["command"] {
+		t.Errorf("plan command %q differs from the dry-run command %q", explained.Plan.Command, resp.Outputs["command"])
+	}
+
+	options := map[string]effectiveOption{}
+	for _, option := range explained.Options {
+		options[option.Key] = option
+	}
+	if got := options["token"]; got.Value != redactedValue || got.Source != "token" {
+		t.Errorf("token = %+v, want a masked value from token", got)
+	}
+	if got := options["locked"]; got.Value != true || got.Source != sourceConfig {
+		t.Errorf("locked = %+v, want true from config", got)
+	}
+	if got := options["offline"]; got.Value != false || got.Source != sourceDefault {
+		t.Errorf("offline = %+v, want false by default", got)
+	}
+	if got := options["prepublish_verify"]; got.Value != false || got.Source != "default (compat_level 2.0)" {
+		t.Errorf("prepublish_verify = %+v, want false from compat_level 2.0", got)
+	}
+
+	data, err := json.Marshal(resp.Outputs)
+	if err != nil {
+		t.Fatalf("outputs do not encode: %v", err)
+	}
+	if strings.Contains(string(data), "dry-run-secret") {
+		t.Errorf("outputs leak the token: %s", data)
+	}
+}
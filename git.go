@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepository is the git work tree that owns a directory. When the
+// directory sits in a submodule, root is the submodule's work tree and
+// superproject the repository that contains it.
+type gitRepository struct {
+	root         string
+	superproject string
+}
+
+// findGitRepository resolves the work tree that owns dir with git rev-parse
+// run from dir itself, so a manifest in a submodule is attributed to the
+// submodule rather than the superproject.
+func (p *CratesPlugin) findGitRepository(ctx context.Context, dir string) (*gitRepository, error) {
+	executor := p.getExecutor()
+	root, err := executor.RunInDir(ctx, dir, "git", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	repo := &gitRepository{root: strings.TrimSpace(string(root))}
+	if repo.root == "" {
+		return nil, fmt.Errorf("git rev-parse --show-toplevel printed no work tree")
+	}
+
+	// Prints nothing outside a submodule; older git versions may not know the flag
+	if super, err := executor.RunInDir(ctx, dir, "git", "rev-parse", "--show-superproject-working-tree"); err == nil {
+		repo.superproject = strings.TrimSpace(string(super))
+	}
+	return repo, nil
+}
+
+// relPath returns path relative to the work tree root for git pathspecs,
+// or "" when path is not inside it.
+func (r *gitRepository) relPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(r.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// describe names the repository for messages.
+func (r *gitRepository) describe() string {
+	if r.superproject != "" {
+		return fmt.Sprintf("submodule repository %s (inside %s)", r.root, r.superproject)
+	}
+	return "repository " + r.root
+}
+
+// addOutputs records which repository git-derived facts came from.
+func (r *gitRepository) addOutputs(outputs map[string]any) {
+	outputs["git_repository"] = r.root
+	if r.superproject != "" {
+		outputs["git_superproject"] = r.superproject
+	}
+}
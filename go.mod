@@ -2,10 +2,19 @@ module github.com/relicta-tech/plugin-crates
 
 go 1.22.7
 
-require github.com/relicta-tech/relicta-plugin-sdk v1.0.0
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/relicta-tech/relicta-plugin-sdk v1.0.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/zalando/go-keyring v0.2.5
+	golang.org/x/net v0.29.0
+)
 
 require (
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
 	github.com/fatih/color v1.7.0 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/hashicorp/go-hclog v0.14.1 // indirect
 	github.com/hashicorp/go-plugin v1.6.2 // indirect
@@ -13,7 +22,6 @@ require (
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/oklog/run v1.0.0 // indirect
-	golang.org/x/net v0.29.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
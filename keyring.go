@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Keyring reads secrets from the OS keyring: the macOS Keychain, the Secret
+// Service on Linux, or the Windows Credential Manager.
+type Keyring interface {
+	Get(service, account string) (string, error)
+}
+
+// systemKeyring reads from the OS keyring through go-keyring.
+type systemKeyring struct{}
+
+// Get returns the secret stored for service and account.
+func (systemKeyring) Get(service, account string) (string, error) {
+	return keyring.Get(service, account)
+}
+
+// errKeyringNotFound is returned by a Keyring when the entry does not exist.
+var errKeyringNotFound = keyring.ErrNotFound
+
+// keyringEntry names the keyring entry holding the API token.
+type keyringEntry struct {
+	Service string
+	Account string
+}
+
+// String renders the entry as service/account for messages.
+func (e keyringEntry) String() string {
+	return e.Service + "/" + e.Account
+}
+
+// getKeyring returns the keyring, defaulting to the OS keyring.
+func (p *CratesPlugin) getKeyring() Keyring {
+	if p.keyring != nil {
+		return p.keyring
+	}
+	return systemKeyring{}
+}
+
+// parseKeyringEntry reads the token_keyring object; it is nil when unset.
+func parseKeyringEntry(raw map[string]any) *keyringEntry {
+	if raw == nil {
+		return nil
+	}
+	entry := &keyringEntry{}
+	entry.Service, _ = raw["service"].(string)
+	entry.Account, _ = raw["account"].(string)
+	return entry
+}
+
+// validateKeyringEntry checks that the token_keyring entry names a service and
+// an account and is not combined with token_file or token_command.
+func validateKeyringEntry(entry *keyringEntry, tokenFile string, tokenCommand []string) error {
+	if entry == nil {
+		return nil
+	}
+	if strings.TrimSpace(entry.Service) == "" || strings.TrimSpace(entry.Account) == "" {
+		return fmt.Errorf("token_keyring needs both service and account")
+	}
+	if tokenFile != "" || len(tokenCommand) > 0 {
+		return fmt.Errorf("token_keyring cannot be combined with token_file or token_command")
+	}
+	return nil
+}
+
+// resolveTokenKeyring reads the token from the OS keyring when token_keyring
+// is set and no token was configured directly.
+func (p *CratesPlugin) resolveTokenKeyring(cfg *Config) error {
+	if cfg.TokenKeyring == nil || cfg.Token != "" {
+		return nil
+	}
+
+	secret, err := p.getKeyring().Get(cfg.TokenKeyring.Service, cfg.TokenKeyring.Account)
+	if errors.Is(err, errKeyringNotFound) {
+		return fmt.Errorf("token_keyring entry %s was not found in the OS keyring", cfg.TokenKeyring)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read token_keyring entry %s: %v; check that the keyring is unlocked and this process may access it", cfg.TokenKeyring, err)
+	}
+	token := strings.TrimSpace(secret)
+	if token == "" {
+		return fmt.Errorf("token_keyring entry %s is empty", cfg.TokenKeyring)
+	}
+	cfg.Token = token
+	cfg.TokenSource = "token_keyring"
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// fakeKeyring serves entries from a map keyed by service/account, or fails
+// every read with err.
+type fakeKeyring struct {
+	entries map[string]string
+	err     error
+}
+
+// Get implements Keyring.Get.
+func (k *fakeKeyring) Get(service, account string) (string, error) {
+	if k.err != nil {
+		return "", k.err
+	}
+	secret, ok := k.entries[service+"/"+account]
+	if !ok {
+		return "", errKeyringNotFound
+	}
+	return secret, nil
+}
+
+func TestParseConfigTokenKeyring(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "env-secret")
+	entry := map[string]any{"service": "crates.io", "account": "release-bot"}
+	keyring := &fakeKeyring{entries: map[string]string{"crates.io/release-bot": " keyring-secret\n"}}
+
+	tests := []struct {
+		name   string
+		config map[string]any
+		want   string
+	}{
+		{
+			name:   "explicit token beats the keyring",
+			config: map[string]any{"token": "explicit-token", "token_keyring": entry},
+			want:   "explicit-token",
+		},
+		{
+			name:   "keyring beats the environment",
+			config: map[string]any{"token_keyring": entry},
+			want:   "keyring-secret",
+		},
+		{
+			name:   "environment without a keyring entry",
+			config: map[string]any{},
+			want:   "env-secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &CratesPlugin{keyring: keyring}
+			cfg := p.parseConfig(tt.config)
+			if err := p.resolveToken(context.Background(), cfg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Token != tt.want {
+				t.Errorf("token = %q, want %q", cfg.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateKeyringEntry(t *testing.T) {
+	tests := []struct {
+		name         string
+		entry        *keyringEntry
+		tokenFile    string
+		tokenCommand []string
+		wantErr      string
+	}{
+		{name: "unset"},
+		{name: "service and account", entry: &keyringEntry{Service: "crates.io", Account: "release-bot"}},
+		{name: "missing account", entry: &keyringEntry{Service: "crates.io"}, wantErr: "needs both service and account"},
+		{name: "with token_file", entry: &keyringEntry{Service: "crates.io", Account: "release-bot"}, tokenFile: "token", wantErr: "cannot be combined"},
+		{name: "with token_command", entry: &keyringEntry{Service: "crates.io", Account: "release-bot"}, tokenCommand: []string{"pass"}, wantErr: "cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeyringEntry(tt.entry, tt.tokenFile, tt.tokenCommand)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecuteTokenKeyring(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	entry := map[string]any{"service": "crates.io", "account": "release-bot"}
+
+	tests := []struct {
+		name         string
+		keyring      *fakeKeyring
+		wantErrorHas []string
+	}{
+		{
+			name:    "token from the keyring",
+			keyring: &fakeKeyring{entries: map[string]string{"crates.io/release-bot": "keyring-secret"}},
+		},
+		{
+			name:         "missing entry",
+			keyring:      &fakeKeyring{},
+			wantErrorHas: []string{"token_keyring entry crates.io/release-bot was not found"},
+		},
+		{
+			name:         "empty entry",
+			keyring:      &fakeKeyring{entries: map[string]string{"crates.io/release-bot": " "}},
+			wantErrorHas: []string{"token_keyring entry crates.io/release-bot is empty"},
+		},
+		{
+			name:         "locked keyring",
+			keyring:      &fakeKeyring{err: errors.New("prompt dismissed")},
+			wantErrorHas: []string{"failed to read token_keyring entry crates.io/release-bot", "prompt dismissed", "unlocked"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock, keyring: tt.keyring}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  map[string]any{"token_keyring": entry, "max_package_size": 0},
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(tt.wantErrorHas) > 0 {
+				if resp.Success {
+					t.Fatal("expected failure")
+				}
+				for _, want := range tt.wantErrorHas {
+					if !strings.Contains(resp.Error, want) {
+						t.Errorf("error %q should contain %q", resp.Error, want)
+					}
+				}
+				if calls := mock.GetCalls(); len(calls) != 0 {
+					t.Errorf("expected cargo not to run, got %+v", calls)
+				}
+				return
+			}
+
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+			calls := mock.GetCalls()
+			if len(calls) != 1 || !strings.Contains(strings.Join(calls[0].Args, " "), "--token keyring-secret") {
+				t.Errorf("expected the keyring token in args, got %+v", calls)
+			}
+		})
+	}
+}
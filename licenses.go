@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// licenseIDPattern matches an SPDX license or exception identifier, including
+// LicenseRef- references and the "+" (or later) suffix.
+var licenseIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.-]*\+?$`)
+
+// cargoMetadata is the subset of `cargo metadata --format-version 1` output the
+// license audit reads.
+type cargoMetadata struct {
+	Packages []struct {
+		ID           string  `json:"id"`
+		Name         string  `json:"name"`
+		Version      string  `json:"version"`
+		License      *string `json:"license"`
+		LicenseFile  *string `json:"license_file"`
+		ManifestPath string  `json:"manifest_path"`
+	} `json:"packages"`
+	Resolve *struct {
+		Root  *string `json:"root"`
+		Nodes []struct {
+			ID   string `json:"id"`
+			Deps []struct {
+				Pkg      string `json:"pkg"`
+				DepKinds []struct {
+					Kind *string `json:"kind"`
+				} `json:"dep_kinds"`
+			} `json:"deps"`
+		} `json:"nodes"`
+	} `json:"resolve"`
+}
+
+// licenseReport is the aggregated license set of a crate's dependencies.
+type licenseReport struct {
+	// licenses are the distinct license expressions, sorted.
+	licenses []string
+	// flagged lists dependencies without a license expression or with one that
+	// is not valid SPDX.
+	flagged []string
+	// forbidden lists dependencies whose license requires a forbidden license.
+	forbidden []string
+}
+
+// addOutputs records the license set for the release record.
+func (r *licenseReport) addOutputs(outputs map[string]any) {
+	outputs["dependency_licenses"] = r.licenses
+	if len(r.flagged) > 0 {
+		outputs["dependency_licenses_flagged"] = r.flagged
+	}
+	if len(r.forbidden) > 0 {
+		outputs["forbidden_dependency_licenses"] = r.forbidden
+	}
+}
+
+// licenseAuditEnabled reports whether the dependency licenses are collected.
+func licenseAuditEnabled(cfg *Config) bool {
+	return cfg.ReportLicenses || len(cfg.ForbiddenLicenses) > 0
+}
+
+// buildMetadataArgs returns the cargo metadata arguments resolving the
+// dependency tree for the feature set that is published.
+func buildMetadataArgs(cfg *Config) []string {
+	var args []string
+	if cfg.Toolchain != "" {
+		args = append(args, "+"+cfg.Toolchain)
+	}
+	args = append(args, "metadata", "--format-version", "1")
+	if cfg.ManifestPath != "" {
+		args = append(args, "--manifest-path", cfg.ManifestPath)
+	}
+	if len(cfg.Features) > 0 {
+		args = append(args, "--features", strings.Join(cfg.Features, ","))
+	}
+	if cfg.AllFeatures {
+		args = append(args, "--all-features")
+	}
+	if cfg.NoDefaultFeatures {
+		args = append(args, "--no-default-features")
+	}
+	if cfg.Locked {
+		args = append(args, "--locked")
+	}
+	if cfg.Offline {
+		args = append(args, "--offline")
+	}
+	if cfg.Frozen {
+		args = append(args, "--frozen")
+	}
+	return append(args, cargoConfigArgs(cfg)...)
+}
+
+// auditLicenses runs cargo metadata and aggregates the licenses of every
+// package the crate links, that is its normal dependencies followed
+// transitively. Dev and build dependencies are not part of the published
+// artifact and are skipped.
+func (p *CratesPlugin) auditLicenses(ctx context.Context, cfg *Config) (*licenseReport, error) {
+	args := buildMetadataArgs(cfg)
+	workDir := manifestWorkDir(cfg)
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
+	}
+
+	output, err := p.runCargo(ctx, cfg, workDir, args...)
+	if errors.Is(err, errCargoNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run cargo metadata: %v\nOutput: %s", err, string(output))
+	}
+
+	var metadata cargoMetadata
+	if err := decodeMetadata(output, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata.licenseReport(cfg.ManifestPath, cfg.ForbiddenLicenses)
+}
+
+// decodeMetadata decodes the JSON document from cargo metadata output, which
+// is combined with stderr.
+func decodeMetadata(output []byte, v any) error {
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), v) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to parse cargo metadata output")
+}
+
+// rootID returns the package ID of the manifest being published.
+func (m *cargoMetadata) rootID(manifestPath string) (string, error) {
+	if m.Resolve != nil && m.Resolve.Root != nil {
+		return *m.Resolve.Root, nil
+	}
+
+	// Workspace manifests have no resolve root; match the member by path
+	want, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(want); err == nil {
+		want = resolved
+	}
+	for _, pkg := range m.Packages {
+		got := pkg.ManifestPath
+		if resolved, err := filepath.EvalSymlinks(got); err == nil {
+			got = resolved
+		}
+		if got == want {
+			return pkg.ID, nil
+		}
+	}
+	return "", fmt.Errorf("cargo metadata has no package for %s", manifestPath)
+}
+
+// licenseReport walks the resolved normal dependencies of the root package.
+func (m *cargoMetadata) licenseReport(manifestPath string, forbidden []string) (*licenseReport, error) {
+	if m.Resolve == nil {
+		return nil, fmt.Errorf("cargo metadata did not include the resolved dependency graph")
+	}
+	root, err := m.rootID(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string, len(m.Resolve.Nodes))
+	for _, node := range m.Resolve.Nodes {
+		for _, dep := range node.Deps {
+			for _, kind := range dep.DepKinds {
+				if kind.Kind == nil {
+					deps[node.ID] = append(deps[node.ID], dep.Pkg)
+					break
+				}
+			}
+		}
+	}
+
+	linked := map[string]bool{}
+	queue := append([]string(nil), deps[root]...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if linked[id] || id == root {
+			continue
+		}
+		linked[id] = true
+		queue = append(queue, deps[id]...)
+	}
+
+	report := &licenseReport{licenses: []string{}}
+	seen := map[string]bool{}
+	for _, pkg := range m.Packages {
+		if !linked[pkg.ID] {
+			continue
+		}
+		crate := pkg.Name + " " + pkg.Version
+
+		if pkg.License == nil || strings.TrimSpace(*pkg.License) == "" {
+			reason := "no license expression"
+			if pkg.LicenseFile != nil {
+				reason += fmt.Sprintf(" (license-file %s)", *pkg.LicenseFile)
+			}
+			report.flagged = append(report.flagged, fmt.Sprintf("%s: %s", crate, reason))
+			continue
+		}
+
+		license := *pkg.License
+		if !seen[license] {
+			seen[license] = true
+			report.licenses = append(report.licenses, license)
+		}
+
+		expr, err := parseLicenseExpression(license)
+		if err != nil {
+			report.flagged = append(report.flagged, fmt.Sprintf("%s: %q is not an SPDX expression: %v", crate, license, err))
+			continue
+		}
+		if expr.legacy {
+			report.flagged = append(report.flagged, fmt.Sprintf("%s: %q uses the deprecated / separator instead of OR", crate, license))
+		}
+		if len(forbidden) > 0 && !expr.satisfiable(forbidden) {
+			report.forbidden = append(report.forbidden, fmt.Sprintf("%s (%s)", crate, license))
+		}
+	}
+
+	sort.Strings(report.licenses)
+	sort.Strings(report.flagged)
+	sort.Strings(report.forbidden)
+	return report, nil
+}
+
+// licenseExpression is a parsed SPDX license expression.
+type licenseExpression struct {
+	// op is "AND" or "OR" for compound expressions, empty for a license.
+	op       string
+	operands []*licenseExpression
+	// id is the license identifier; exception is the WITH exception, if any.
+	id        string
+	exception string
+	// legacy is set on the root when the deprecated "/" separator was used.
+	legacy bool
+}
+
+// satisfiable reports whether the expression can be complied with without
+// any of the forbidden licenses: every AND operand must be allowed, and at
+// least one OR alternative. Matching is case-insensitive and ignores "+".
+func (e *licenseExpression) satisfiable(forbidden []string) bool {
+	switch e.op {
+	case "AND":
+		for _, operand := range e.operands {
+			if !operand.satisfiable(forbidden) {
+				return false
+			}
+		}
+		return true
+	case "OR":
+		for _, operand := range e.operands {
+			if operand.satisfiable(forbidden) {
+				return true
+			}
+		}
+		return false
+	}
+
+	id := strings.TrimSuffix(e.id, "+")
+	for _, f := range forbidden {
+		f = strings.TrimSuffix(f, "+")
+		if strings.EqualFold(id, f) || (e.exception != "" && strings.EqualFold(e.id+" WITH "+e.exception, f)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLicenseExpression parses an SPDX license expression. AND binds tighter
+// than OR, and the "/" separator of older crates is accepted as OR.
+func parseLicenseExpression(s string) (*licenseExpression, error) {
+	s = strings.NewReplacer("(", " ( ", ")", " ) ", "/", " / ").Replace(s)
+	parser := &licenseParser{tokens: strings.Fields(s)}
+	if len(parser.tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	expr, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected %q", parser.tokens[parser.pos])
+	}
+	expr.legacy = parser.legacy
+	return expr, nil
+}
+
+// licenseParser is a recursive descent parser over SPDX expression tokens.
+type licenseParser struct {
+	tokens []string
+	pos    int
+	legacy bool
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseParser) or() (*licenseExpression, error) {
+	return p.compound("OR", p.and)
+}
+
+func (p *licenseParser) and() (*licenseExpression, error) {
+	return p.compound("AND", p.with)
+}
+
+// compound parses operands joined by op.
+func (p *licenseParser) compound(op string, operand func() (*licenseExpression, error)) (*licenseExpression, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*licenseExpression{first}
+	for {
+		tok := p.peek()
+		if tok == "/" && op == "OR" {
+			p.legacy = true
+		} else if tok != op {
+			break
+		}
+		p.pos++
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &licenseExpression{op: op, operands: operands}, nil
+}
+
+func (p *licenseParser) with() (*licenseExpression, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "WITH" {
+		return expr, nil
+	}
+	if expr.op != "" || expr.exception != "" {
+		return nil, fmt.Errorf("WITH must follow a license identifier")
+	}
+	p.pos++
+	exception := p.peek()
+	if !licenseIDPattern.MatchString(exception) || isLicenseOperator(exception) {
+		return nil, fmt.Errorf("WITH must be followed by an exception identifier")
+	}
+	p.pos++
+	expr.exception = exception
+	return expr, nil
+}
+
+func (p *licenseParser) primary() (*licenseExpression, error) {
+	tok := p.peek()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	case isLicenseOperator(tok) || !licenseIDPattern.MatchString(tok):
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	p.pos++
+	return &licenseExpression{id: tok}, nil
+}
+
+// validateLicenseID checks a forbidden_licenses entry.
+func validateLicenseID(id string) error {
+	if !licenseIDPattern.MatchString(id) || isLicenseOperator(id) {
+		return fmt.Errorf("%q is not an SPDX license identifier", id)
+	}
+	return nil
+}
+
+// isLicenseOperator reports whether tok is an SPDX operator keyword.
+func isLicenseOperator(tok string) bool {
+	return tok == "AND" || tok == "OR" || tok == "WITH"
+}
+
+// deprecatedLicenses maps deprecated SPDX license identifiers, lowercased,
+// to their replacements.
+var deprecatedLicenses = map[string]string{
+	"agpl-1.0":             "AGPL-1.0-only or AGPL-1.0-or-later",
+	"agpl-3.0":             "AGPL-3.0-only or AGPL-3.0-or-later",
+	"bsd-2-clause-freebsd": "BSD-2-Clause",
+	"bsd-2-clause-netbsd":  "BSD-2-Clause",
+	"ecos-2.0":             "GPL-2.0-or-later WITH eCos-exception-2.0",
+	"gfdl-1.1":             "GFDL-1.1-only or GFDL-1.1-or-later",
+	"gfdl-1.2":             "GFDL-1.2-only or GFDL-1.2-or-later",
+	"gfdl-1.3":             "GFDL-1.3-only or GFDL-1.3-or-later",
+	"gpl-1.0":              "GPL-1.0-only",
+	"gpl-1.0+":             "GPL-1.0-or-later",
+	"gpl-2.0":              "GPL-2.0-only",
+	"gpl-2.0+":             "GPL-2.0-or-later",
+	"gpl-3.0":              "GPL-3.0-only",
+	"gpl-3.0+":             "GPL-3.0-or-later",
+	"lgpl-2.0":             "LGPL-2.0-only",
+	"lgpl-2.0+":            "LGPL-2.0-or-later",
+	"lgpl-2.1":             "LGPL-2.1-only",
+	"lgpl-2.1+":            "LGPL-2.1-or-later",
+	"lgpl-3.0":             "LGPL-3.0-only",
+	"lgpl-3.0+":            "LGPL-3.0-or-later",
+	"nunit":                "Zlib-acknowledgement",
+	"standardml-nj":        "SMLNJ",
+	"wxwindows":            "LGPL-2.0-or-later WITH WxWindows-exception-3.1",
+}
+
+// commonLicenses lists, lowercased, the SPDX license and exception
+// identifiers crates commonly use. Identifiers outside it are reported as
+// warnings rather than errors, since the SPDX list is much longer.
+var commonLicenses = map[string]bool{
+	"0bsd": true, "afl-3.0": true, "agpl-3.0-only": true, "agpl-3.0-or-later": true,
+	"apache-1.1": true, "apache-2.0": true, "artistic-2.0": true, "blueoak-1.0.0": true,
+	"bsd-1-clause": true, "bsd-2-clause": true, "bsd-2-clause-patent": true, "bsd-3-clause": true,
+	"bsd-3-clause-clear": true, "bsl-1.0": true, "cc-by-4.0": true, "cc-by-sa-4.0": true,
+	"cc0-1.0": true, "cddl-1.0": true, "cecill-2.1": true, "epl-1.0": true, "epl-2.0": true,
+	"eupl-1.2": true, "gpl-2.0-only": true, "gpl-2.0-or-later": true, "gpl-3.0-only": true,
+	"gpl-3.0-or-later": true, "isc": true, "lgpl-2.0-only": true, "lgpl-2.0-or-later": true,
+	"lgpl-2.1-only": true, "lgpl-2.1-or-later": true, "lgpl-3.0-only": true, "lgpl-3.0-or-later": true,
+	"mit": true, "mit-0": true, "mpl-1.1": true, "mpl-2.0": true, "ms-pl": true, "ncsa": true,
+	"ofl-1.1": true, "openssl": true, "postgresql": true, "python-2.0": true, "unicode-3.0": true,
+	"unicode-dfs-2016": true, "unlicense": true, "upl-1.0": true, "wtfpl": true, "x11": true,
+	"zlib": true, "zlib-acknowledgement": true,
+	// Exceptions used with WITH
+	"autoconf-exception-3.0": true, "bison-exception-2.2": true, "classpath-exception-2.0": true,
+	"font-exception-2.0": true, "gcc-exception-3.1": true, "linux-syscall-note": true,
+	"llvm-exception": true, "swift-exception": true, "wxwindows-exception-3.1": true,
+}
+
+// licenseWarnings reports what crates.io accepts in a crate's own license
+// expression but should be changed: the "/" separator, deprecated
+// identifiers, and identifiers outside commonLicenses.
+func licenseWarnings(license string, expr *licenseExpression) []string {
+	var warnings []string
+	if expr.legacy {
+		warnings = append(warnings, fmt.Sprintf("license %q uses the deprecated / separator; use OR", license))
+	}
+	seen := map[string]bool{}
+	var walk func(e *licenseExpression)
+	walk = func(e *licenseExpression) {
+		for _, operand := range e.operands {
+			walk(operand)
+		}
+		for _, id := range []string{e.id, e.exception} {
+			key := strings.ToLower(id)
+			if id == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if replacement, ok := deprecatedLicenses[key]; ok {
+				warnings = append(warnings, fmt.Sprintf("license identifier %s is deprecated by SPDX; use %s", id, replacement))
+			} else if !commonLicenses[strings.TrimSuffix(key, "+")] {
+				warnings = append(warnings, fmt.Sprintf("license identifier %s is not a common SPDX identifier; crates.io rejects identifiers outside the SPDX license list", id))
+			}
+		}
+	}
+	walk(expr)
+	return warnings
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// licenseMetadata is cargo metadata output for a crate with one normal
+// dependency tree, a dev dependency, and a build dependency:
+//
+//	fixture -> serde (MIT OR Apache-2.0) -> itoa (MIT/Apache-2.0)
+//	fixture -> gpl-lib (GPL-3.0-only)
+//	fixture -> custom (license-file only)
+//	fixture -> proptest (dev, Apache-2.0)
+//	fixture -> cc (build, BSD-3-Clause)
+const licenseMetadata = `{"packages":[` +
+	`{"id":"fixture 1.0.0","name":"fixture","version":"1.0.0","license":"MIT","license_file":null,"manifest_path":"/work/Cargo.toml"},` +
+	`{"id":"serde 1.0.200","name":"serde","version":"1.0.200","license":"MIT OR Apache-2.0","license_file":null,"manifest_path":"/registry/serde/Cargo.toml"},` +
+	`{"id":"itoa 1.0.11","name":"itoa","version":"1.0.11","license":"MIT/Apache-2.0","license_file":null,"manifest_path":"/registry/itoa/Cargo.toml"},` +
+	`{"id":"gpl-lib 0.3.0","name":"gpl-lib","version":"0.3.0","license":"GPL-3.0-only","license_file":null,"manifest_path":"/registry/gpl-lib/Cargo.toml"},` +
+	`{"id":"custom 0.1.0","name":"custom","version":"0.1.0","license":null,"license_file":"LICENSE.txt","manifest_path":"/registry/custom/Cargo.toml"},` +
+	`{"id":"proptest 1.4.0","name":"proptest","version":"1.4.0","license":"Apache-2.0","license_file":null,"manifest_path":"/registry/proptest/Cargo.toml"},` +
+	`{"id":"cc 1.0.90","name":"cc","version":"1.0.90","license":"BSD-3-Clause","license_file":null,"manifest_path":"/registry/cc/Cargo.toml"}` +
+	`],"resolve":{"root":"fixture 1.0.0","nodes":[` +
+	`{"id":"fixture 1.0.0","deps":[` +
+	`{"pkg":"serde 1.0.200","dep_kinds":[{"kind":null}]},` +
+	`{"pkg":"gpl-lib 0.3.0","dep_kinds":[{"kind":null}]},` +
+	`{"pkg":"custom 0.1.0","dep_kinds":[{"kind":null}]},` +
+	`{"pkg":"proptest 1.4.0","dep_kinds":[{"kind":"dev"}]},` +
+	`{"pkg":"cc 1.0.90","dep_kinds":[{"kind":"build"}]}]},` +
+	`{"id":"serde 1.0.200","deps":[{"pkg":"itoa 1.0.11","dep_kinds":[{"kind":null}]}]},` +
+	`{"id":"itoa 1.0.11","deps":[]},` +
+	`{"id":"gpl-lib 0.3.0","deps":[]},` +
+	`{"id":"custom 0.1.0","deps":[]},` +
+	`{"id":"proptest 1.4.0","deps":[]},` +
+	`{"id":"cc 1.0.90","deps":[]}` +
+	`]},"target_directory":"/work/target"}`
+
+func TestParseLicenseExpression(t *testing.T) {
+	tests := []struct {
+		expr       string
+		wantErr    bool
+		wantLegacy bool
+	}{
+		{expr: "MIT"},
+		{expr: "MIT OR Apache-2.0"},
+		{expr: "(MIT OR Apache-2.0) AND BSD-3-Clause"},
+		{expr: "Apache-2.0 WITH LLVM-exception"},
+		{expr: "GPL-2.0+"},
+		{expr: "LicenseRef-Proprietary"},
+		{expr: "MIT/Apache-2.0", wantLegacy: true},
+		{expr: "", wantErr: true},
+		{expr: "MIT OR", wantErr: true},
+		{expr: "(MIT OR Apache-2.0", wantErr: true},
+		{expr: "MIT Apache-2.0", wantErr: true},
+		{expr: "(MIT OR Apache-2.0) WITH LLVM-exception", wantErr: true},
+		{expr: "Public Domain", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parseLicenseExpression(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLicenseExpression(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if err == nil && expr.legacy != tt.wantLegacy {
+				t.Errorf("legacy = %v, want %v", expr.legacy, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestLicenseExpressionSatisfiable(t *testing.T) {
+	tests := []struct {
+		expr      string
+		forbidden []string
+		want      bool
+	}{
+		{expr: "MIT", forbidden: []string{"GPL-3.0-only"}, want: true},
+		{expr: "GPL-3.0-only", forbidden: []string{"GPL-3.0-only"}, want: false},
+		{expr: "gpl-3.0-only", forbidden: []string{"GPL-3.0-only"}, want: false},
+		{expr: "MIT OR GPL-3.0-only", forbidden: []string{"GPL-3.0-only"}, want: true},
+		{expr: "MIT AND GPL-3.0-only", forbidden: []string{"GPL-3.0-only"}, want: false},
+		{expr: "(MIT OR GPL-3.0-only) AND (Apache-2.0 OR GPL-3.0-only)", forbidden: []string{"GPL-3.0-only"}, want: true},
+		{expr: "GPL-2.0+", forbidden: []string{"GPL-2.0"}, want: false},
+		{expr: "GPL-2.0 WITH Classpath-exception-2.0", forbidden: []string{"GPL-2.0"}, want: false},
+		{expr: "GPL-2.0 WITH Classpath-exception-2.0", forbidden: []string{"GPL-2.0 WITH Classpath-exception-2.0"}, want: false},
+		{expr: "GPL-2.0", forbidden: []string{"GPL-2.0 WITH Classpath-exception-2.0"}, want: true},
+		{expr: "MIT/GPL-3.0-only", forbidden: []string{"GPL-3.0-only"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parseLicenseExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if got := expr.satisfiable(tt.forbidden); got != tt.want {
+				t.Errorf("satisfiable(%v) = %v, want %v", tt.forbidden, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLicenseReport(t *testing.T) {
+	var metadata cargoMetadata
+	if err := decodeMetadata([]byte("warning: some cargo noise\n"+licenseMetadata+"\n"), &metadata); err != nil {
+		t.Fatalf("failed to decode metadata: %v", err)
+	}
+
+	report, err := metadata.licenseReport("Cargo.toml", []string{"GPL-3.0-only", "Apache-2.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantLicenses := []string{"GPL-3.0-only", "MIT OR Apache-2.0", "MIT/Apache-2.0"}
+	if !reflect.DeepEqual(report.licenses, wantLicenses) {
+		t.Errorf("licenses = %v, want %v", report.licenses, wantLicenses)
+	}
+
+	wantFlagged := []string{
+		"custom 0.1.0: no license expression (license-file LICENSE.txt)",
+		`itoa 1.0.11: "MIT/Apache-2.0" uses the deprecated / separator instead of OR`,
+	}
+	if !reflect.DeepEqual(report.flagged, wantFlagged) {
+		t.Errorf("flagged = %v, want %v", report.flagged, wantFlagged)
+	}
+
+	wantForbidden := []string{"gpl-lib 0.3.0 (GPL-3.0-only)"}
+	if !reflect.DeepEqual(report.forbidden, wantForbidden) {
+		t.Errorf("forbidden = %v, want %v", report.forbidden, wantForbidden)
+	}
+}
+
+func TestBuildMetadataArgs(t *testing.T) {
+	cfg := &Config{
+		Toolchain:         "stable",
+		ManifestPath:      "Cargo.toml",
+		Features:          []string{"serde", "std"},
+		NoDefaultFeatures: true,
+		Locked:            true,
+		CargoConfig:       map[string]string{"net.retry": "5"},
+	}
+	got := strings.Join(buildMetadataArgs(cfg), " ")
+	want := "+stable metadata --format-version 1 --manifest-path Cargo.toml --features serde,std --no-default-features --locked --config net.retry=5"
+	if got != want {
+		t.Errorf("buildMetadataArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestExecutePrePublishLicenses(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]any
+		wantSuccess       bool
+		wantErrorContains string
+		wantDryRun        bool
+	}{
+		{
+			name:        "report only",
+			config:      map[string]any{"report_licenses": true},
+			wantSuccess: true,
+			wantDryRun:  true,
+		},
+		{
+			name:              "forbidden license fails the release",
+			config:            map[string]any{"forbidden_licenses": []any{"GPL-3.0-only"}},
+			wantErrorContains: "dependencies are only available under forbidden licenses: gpl-lib 0.3.0 (GPL-3.0-only)",
+		},
+		{
+			name:        "license with an allowed alternative passes",
+			config:      map[string]any{"forbidden_licenses": []any{"Apache-2.0"}},
+			wantSuccess: true,
+			wantDryRun:  true,
+		},
+		{
+			name:        "audit runs with prepublish_verify disabled",
+			config:      map[string]any{"forbidden_licenses": []any{"AGPL-3.0-only"}, "prepublish_verify": false},
+			wantSuccess: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					if len(args) > 0 && args[0] == "metadata" {
+						return []byte(licenseMetadata), nil
+					}
+					return []byte("warning: aborting upload due to dry run"), nil
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPrePublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain %q, got %q", tt.wantErrorContains, resp.Error)
+			}
+
+			licenses, _ := resp.Outputs["dependency_licenses"].([]string)
+			if len(licenses) != 3 {
+				t.Errorf("dependency_licenses = %v, want the 3 linked license expressions", resp.Outputs["dependency_licenses"])
+			}
+			if _, ok := resp.Outputs["dependency_licenses_flagged"]; !ok {
+				t.Error("expected dependency_licenses_flagged in outputs")
+			}
+
+			ranDryRun := false
+			for _, call := range mock.GetCalls() {
+				if call.Args[0] == "publish" {
+					ranDryRun = true
+				}
+			}
+			if ranDryRun != tt.wantDryRun {
+				t.Errorf("ran cargo publish --dry-run = %v, want %v", ranDryRun, tt.wantDryRun)
+			}
+		})
+	}
+}
+
+func TestExecuteReportLicensesOnPublish(t *testing.T) {
+	mock := &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			if len(args) > 0 && args[0] == "metadata" {
+				return []byte(licenseMetadata), nil
+			}
+			return []byte("Uploading fixture v1.0.0"), nil
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token", "report_licenses": true},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	want := []string{"GPL-3.0-only", "MIT OR Apache-2.0", "MIT/Apache-2.0"}
+	if !reflect.DeepEqual(resp.Outputs["dependency_licenses"], want) {
+		t.Errorf("dependency_licenses = %v, want %v", resp.Outputs["dependency_licenses"], want)
+	}
+}
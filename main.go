@@ -2,9 +2,18 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
 )
 
 func main() {
+	explain := flag.String("explain", "", "print the effective configuration for a JSON plugin config file and exit")
+	flag.Parse()
+	if *explain != "" {
+		os.Exit(runExplain(*explain, os.Stdout, os.Stderr))
+	}
+
 	plugin.Serve(&CratesPlugin{})
 }
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// errNoPackageVersion is returned for manifests without a [package] version,
+// such as virtual workspace manifests.
+var errNoPackageVersion = errors.New("manifest has no package version")
+
+// cargoManifest is the subset of Cargo.toml the plugin reads.
+type cargoManifest struct {
+	Package *struct {
+		Name        string `toml:"name"`
+		Version     any    `toml:"version"`
+		Description any    `toml:"description"`
+		License     any    `toml:"license"`
+		LicenseFile any    `toml:"license-file"`
+		Readme      any    `toml:"readme"`
+	} `toml:"package"`
+	Workspace *struct {
+		Package struct {
+			Version     string `toml:"version"`
+			Description string `toml:"description"`
+			License     string `toml:"license"`
+			LicenseFile string `toml:"license-file"`
+			Readme      any    `toml:"readme"`
+		} `toml:"package"`
+		Dependencies map[string]any `toml:"dependencies"`
+	} `toml:"workspace"`
+	dependencyTables
+	Target  map[string]dependencyTables `toml:"target"`
+	Patch   map[string]map[string]any   `toml:"patch"`
+	Replace map[string]any              `toml:"replace"`
+}
+
+// dependencyTables are the dependency tables of a manifest or of one of its
+// [target.<cfg>] tables.
+type dependencyTables struct {
+	Dependencies      map[string]any `toml:"dependencies"`
+	BuildDependencies map[string]any `toml:"build-dependencies"`
+	DevDependencies   map[string]any `toml:"dev-dependencies"`
+}
+
+// loadManifest parses a Cargo.toml file.
+func loadManifest(path string) (*cargoManifest, error) {
+	var manifest cargoManifest
+	if _, err := toml.DecodeFile(path, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// manifestPackageName returns the manifest's package.name, or "" when the
+// manifest cannot be read or has no [package] table.
+func manifestPackageName(path string) string {
+	manifest, err := loadManifest(path)
+	if err != nil || manifest.Package == nil {
+		return ""
+	}
+	return manifest.Package.Name
+}
+
+// crateName returns the crate name used for registry-facing lookups and
+// outputs: crate_name when configured, otherwise the manifest's package.name.
+func crateName(cfg *Config) string {
+	if cfg.CrateName != "" {
+		return cfg.CrateName
+	}
+	return manifestPackageName(cfg.ManifestPath)
+}
+
+// publishCrateName returns the crate name for publish messages and outputs,
+// like crateName, with a warning when the manifest exists but cannot be
+// parsed. package.name is never inherited from the workspace, so the member
+// manifest alone names the crate. A missing manifest or one without a
+// [package] table is left for cargo to report.
+func publishCrateName(cfg *Config) (name, warning string) {
+	if cfg.CrateName != "" {
+		return cfg.CrateName, ""
+	}
+	manifest, err := loadManifest(cfg.ManifestPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ""
+		}
+		return "", fmt.Sprintf("crate name unavailable, omitted from messages and outputs: %v", err)
+	}
+	if manifest.Package == nil {
+		return "", ""
+	}
+	return manifest.Package.Name, ""
+}
+
+// crateLabel names the crate version in messages: "foo 1.2.3", or "crate
+// version 1.2.3" when the name is not known.
+func crateLabel(name, version string) string {
+	if name == "" {
+		return "crate version " + version
+	}
+	return name + " " + version
+}
+
+// crateFailure names the failed step and, when known, the crate version it
+// failed for: "cargo publish failed for foo 1.2.3".
+func crateFailure(step, name, version string) string {
+	if name == "" {
+		return step + " failed"
+	}
+	return fmt.Sprintf("%s failed for %s %s", step, name, version)
+}
+
+// addCrateNameOutputs records the crate name in outputs, or the warning
+// explaining why it is missing.
+func addCrateNameOutputs(name, warning string, outputs map[string]any) {
+	if name != "" {
+		outputs["crate_name"] = name
+	}
+	if warning != "" {
+		warnings, _ := outputs["warnings"].([]string)
+		outputs["warnings"] = append(warnings, warning)
+	}
+}
+
+// crateNameMismatch returns a warning when crate_name differs from the
+// manifest's package.name, since cargo always publishes under the latter.
+func crateNameMismatch(cfg *Config) string {
+	if cfg.CrateName == "" {
+		return ""
+	}
+	name := manifestPackageName(cfg.ManifestPath)
+	if name == "" || name == cfg.CrateName {
+		return ""
+	}
+	return fmt.Sprintf("crate_name %q differs from package.name %q in %s; registry lookups and outputs use crate_name, but cargo publishes %q", cfg.CrateName, name, cfg.ManifestPath, name)
+}
+
+// readManifestVersion returns the package version declared in the manifest,
+// following `version.workspace = true` to the workspace root.
+func readManifestVersion(path string) (string, error) {
+	manifest, err := loadManifest(path)
+	if err != nil {
+		return "", err
+	}
+	if manifest.Package == nil || manifest.Package.Version == nil {
+		return "", errNoPackageVersion
+	}
+
+	switch v := manifest.Package.Version.(type) {
+	case string:
+		return v, nil
+	case map[string]any:
+		if inherit, _ := v["workspace"].(bool); inherit {
+			return readWorkspaceVersion(path)
+		}
+	}
+	return "", fmt.Errorf("unsupported package version in %s", path)
+}
+
+// readWorkspaceVersion finds the workspace root above a member manifest and
+// returns its [workspace.package] version.
+func readWorkspaceVersion(memberPath string) (string, error) {
+	root, manifest, err := findWorkspaceRoot(memberPath)
+	if err != nil {
+		return "", err
+	}
+	if manifest.Workspace.Package.Version == "" {
+		return "", fmt.Errorf("workspace %s does not set [workspace.package] version", root)
+	}
+	return manifest.Workspace.Package.Version, nil
+}
+
+// findWorkspaceRoot returns the path and contents of the first manifest with
+// a [workspace] table above a member manifest.
+func findWorkspaceRoot(memberPath string) (string, *cargoManifest, error) {
+	abs, err := filepath.Abs(memberPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for dir := filepath.Dir(abs); ; {
+		candidate := filepath.Join(dir, "Cargo.toml")
+		if candidate != abs {
+			if manifest, err := loadManifest(candidate); err == nil && manifest.Workspace != nil {
+				return candidate, manifest, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil, fmt.Errorf("no workspace root found for %s", memberPath)
+		}
+		dir = parent
+	}
+}
+
+// manifestVersionError reports a manifest version that differs from the
+// release version, with the repository the checkout details came from.
+type manifestVersionError struct {
+	message string
+	repo    *gitRepository
+}
+
+func (e *manifestVersionError) Error() string {
+	return e.message
+}
+
+// checkManifestVersion requires the manifest version to equal the release
+// version, pre-release and build metadata included. A mismatch usually means the publish runs from a checkout made
+// before the version bump was committed, so the error includes the HEAD
+// commit and whether the manifest has uncommitted changes.
+func (p *CratesPlugin) checkManifestVersion(ctx context.Context, cfg *Config, version releaseVersion) error {
+	manifestVersion, err := readManifestVersion(cfg.ManifestPath)
+	if err != nil {
+		// Missing manifests and virtual workspaces are left for cargo to report
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, errNoPackageVersion) {
+			return nil
+		}
+		return err
+	}
+	parsed, err := parseReleaseVersion(manifestVersion)
+	if err != nil || strings.HasPrefix(manifestVersion, "v") {
+		return fmt.Errorf("%s has version %q, which cargo does not accept as a SemVer version", cfg.ManifestPath, manifestVersion)
+	}
+	if parsed.equal(version) {
+		return nil
+	}
+
+	checkout, repo := p.describeCheckout(ctx, cfg.ManifestPath)
+	return &manifestVersionError{
+		message: fmt.Sprintf("%s has version %s but the release version is %s; the version bump was probably not committed or not checked out before publishing (%s). Make sure the publish step runs from the commit that contains the bump",
+			cfg.ManifestPath, manifestVersion, version, checkout),
+		repo: repo,
+	}
+}
+
+// describeCheckout summarizes the git HEAD commit and the manifest's dirty
+// state in the work tree that owns the manifest, which is a submodule's own
+// repository when the manifest lives in one. The repository is nil when git
+// could not determine it.
+func (p *CratesPlugin) describeCheckout(ctx context.Context, manifestPath string) (string, *gitRepository) {
+	executor := p.getExecutor()
+	name := filepath.Base(manifestPath)
+
+	repo, err := p.findGitRepository(ctx, filepath.Dir(manifestPath))
+	if err != nil {
+		return "git HEAD could not be determined", nil
+	}
+	pathspec := repo.relPath(manifestPath)
+	if pathspec == "" {
+		pathspec = name
+	}
+
+	head, err := executor.RunInDir(ctx, repo.root, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "git HEAD could not be determined", repo
+	}
+
+	state := "has no uncommitted changes"
+	status, err := executor.RunInDir(ctx, repo.root, "git", "status", "--porcelain", "--", pathspec)
+	switch {
+	case err != nil:
+		state = "has an unknown git status"
+	case strings.TrimSpace(string(status)) != "":
+		state = "has uncommitted changes"
+	}
+
+	return fmt.Sprintf("HEAD of %s is %s and %s %s", repo.describe(), strings.TrimSpace(string(head)), name, state), repo
+}
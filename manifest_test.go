@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestReadManifestVersion(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+		errMsg  string
+	}{
+		{name: "package version", path: "manifests/simple/Cargo.toml", want: "1.3.0"},
+		{name: "inherited workspace version", path: "manifests/workspace/crates/member/Cargo.toml", want: "2.1.0"},
+		{name: "virtual workspace", path: "manifests/workspace/Cargo.toml", wantErr: errNoPackageVersion},
+		{name: "missing manifest", path: filepath.Join(dir, "missing.toml"), wantErr: os.ErrNotExist},
+		{name: "invalid toml", path: write("invalid.toml", "[package\nname = 1"), errMsg: "failed to parse"},
+		{name: "unsupported version value", path: write("numeric.toml", "[package]\nname = \"x\"\nversion = 1\n"), errMsg: "unsupported package version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readManifestVersion(tt.path)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected error %v, got %v", tt.wantErr, err)
+				}
+			case tt.errMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("readManifestVersion() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteManifestVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name              string
+		manifestPath      string
+		version           string
+		gitStatus         string
+		gitErr            error
+		wantSuccess       bool
+		wantErrorContains []string
+	}{
+		{
+			name:         "matching version publishes",
+			manifestPath: "manifests/simple/Cargo.toml",
+			version:      "v1.3.0",
+			wantSuccess:  true,
+		},
+		{
+			name:         "matching inherited version publishes",
+			manifestPath: "manifests/workspace/crates/member/Cargo.toml",
+			version:      "v2.1.0",
+			wantSuccess:  true,
+		},
+		{
+			name:         "bump not checked out",
+			manifestPath: "manifests/simple/Cargo.toml",
+			version:      "v1.4.0",
+			wantErrorContains: []string{
+				"has version 1.3.0 but the release version is 1.4.0",
+				"not committed or not checked out",
+				"is 0123456789abcdef",
+				"Cargo.toml has no uncommitted changes",
+			},
+		},
+		{
+			name:         "bump not committed",
+			manifestPath: "manifests/simple/Cargo.toml",
+			version:      "v1.4.0",
+			gitStatus:    " M Cargo.toml\n",
+			wantErrorContains: []string{
+				"Cargo.toml has uncommitted changes",
+			},
+		},
+		{
+			name:         "outside a git repository",
+			manifestPath: "manifests/simple/Cargo.toml",
+			version:      "v1.4.0",
+			gitErr:       errors.New("exit status 128"),
+			wantErrorContains: []string{
+				"git HEAD could not be determined",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, _ := filepath.Abs(".")
+			mock := &MockCommandExecutor{RunInDirFunc: fakeGit(root, "", tt.gitStatus, tt.gitErr)}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook: plugin.HookPostPublish,
+				Config: map[string]any{
+					"token":         "test-token",
+					"manifest_path": tt.manifestPath,
+				},
+				Context: plugin.ReleaseContext{Version: tt.version},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			for _, want := range tt.wantErrorContains {
+				if !strings.Contains(resp.Error, want) {
+					t.Errorf("expected error to contain %q, got %q", want, resp.Error)
+				}
+			}
+
+			for _, call := range mock.GetCalls() {
+				if tt.wantSuccess && call.Name == "git" {
+					t.Errorf("git should only run to diagnose a mismatch, got %v", call.Args)
+				}
+				if !tt.wantSuccess && call.Name != "git" {
+					t.Errorf("cargo must not run on a version mismatch, got %s %v", call.Name, call.Args)
+				}
+			}
+		})
+	}
+}
+
+// fakeGit answers the git commands describeCheckout runs for a work tree at
+// root, inside superproject when that is set; other commands succeed.
+func fakeGit(root, superproject, status string, gitErr error) func(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+	return func(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+		if name != "git" {
+			return []byte("Uploaded successfully"), nil
+		}
+		if gitErr != nil {
+			return nil, gitErr
+		}
+		switch strings.Join(args, " ") {
+		case "rev-parse --show-toplevel":
+			return []byte(root + "\n"), nil
+		case "rev-parse --show-superproject-working-tree":
+			if superproject == "" {
+				return nil, nil
+			}
+			return []byte(superproject + "\n"), nil
+		case "rev-parse HEAD":
+			return []byte("0123456789abcdef\n"), nil
+		}
+		return []byte(status), nil
+	}
+}
+
+func TestManifestInSubmodule(t *testing.T) {
+	// The fixture project stands in for the superproject and the simple
+	// manifest's directory for a submodule checked out inside it
+	superproject, _ := filepath.Abs(".")
+	submodule, _ := filepath.Abs("manifests/simple")
+
+	mock := &MockCommandExecutor{RunInDirFunc: fakeGit(submodule, superproject, " M Cargo.toml\n", nil)}
+	p := &CratesPlugin{cmdExecutor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook: plugin.HookPostPublish,
+		Config: map[string]any{
+			"token":         "test-token",
+			"manifest_path": "manifests/simple/Cargo.toml",
+		},
+		Context: plugin.ReleaseContext{Version: "v1.4.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected a version mismatch")
+	}
+
+	want := "HEAD of submodule repository " + submodule + " (inside " + superproject + ") is 0123456789abcdef and Cargo.toml has uncommitted changes"
+	if !strings.Contains(resp.Error, want) {
+		t.Errorf("error should attribute the checkout to the submodule, got %q", resp.Error)
+	}
+	if resp.Outputs["git_repository"] != submodule || resp.Outputs["git_superproject"] != superproject {
+		t.Errorf("outputs should name the repositories, got %v", resp.Outputs)
+	}
+
+	// Every git command after discovery runs in the submodule's work tree
+	for _, call := range mock.GetCalls() {
+		args := strings.Join(call.Args, " ")
+		if strings.HasPrefix(args, "rev-parse --show") {
+			if call.Dir != "manifests/simple" {
+				t.Errorf("discovery should run from the manifest directory, got %s for %s", call.Dir, args)
+			}
+			continue
+		}
+		if call.Dir != submodule {
+			t.Errorf("git %s ran in %s, want the submodule root %s", args, call.Dir, submodule)
+		}
+		if call.Args[0] == "status" && call.Args[len(call.Args)-1] != "Cargo.toml" {
+			t.Errorf("status pathspec should be relative to the submodule, got %v", call.Args)
+		}
+	}
+}
+
+func TestManifestOutsideSubmodule(t *testing.T) {
+	root, _ := filepath.Abs(".")
+	mock := &MockCommandExecutor{RunInDirFunc: fakeGit(root, "", "", nil)}
+	p := &CratesPlugin{cmdExecutor: mock}
+
+	checkout, repo := p.describeCheckout(context.Background(), "manifests/simple/Cargo.toml")
+	if repo == nil || repo.root != root || repo.superproject != "" {
+		t.Fatalf("unexpected repository %+v", repo)
+	}
+	if !strings.HasPrefix(checkout, "HEAD of repository "+root+" is") {
+		t.Errorf("unexpected checkout description %q", checkout)
+	}
+	for _, call := range mock.GetCalls() {
+		if call.Args[0] == "status" && call.Args[len(call.Args)-1] != "manifests/simple/Cargo.toml" {
+			t.Errorf("status pathspec should be relative to the work tree, got %v", call.Args)
+		}
+	}
+}
+
+func TestExecuteCrateName(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]any
+		dryRun      bool
+		wantName    string
+		wantWarning bool
+	}{
+		{
+			name:     "derived from the manifest",
+			config:   map[string]any{},
+			wantName: "fixture",
+		},
+		{
+			name:     "override matching the manifest",
+			config:   map[string]any{"crate_name": "fixture"},
+			wantName: "fixture",
+		},
+		{
+			name:        "override differing from the manifest",
+			config:      map[string]any{"crate_name": "fixture-renamed"},
+			wantName:    "fixture-renamed",
+			wantWarning: true,
+		},
+		{
+			name:        "override on a dry run",
+			config:      map[string]any{"crate_name": "fixture-renamed"},
+			dryRun:      true,
+			wantName:    "fixture-renamed",
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"token": "test-token"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+
+			if got := resp.Outputs["crate_name"]; got != tt.wantName {
+				t.Errorf("crate_name = %v, want %s", got, tt.wantName)
+			}
+
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			found := false
+			for _, w := range warnings {
+				if strings.Contains(w, "differs from package.name") {
+					found = true
+				}
+			}
+			if found != tt.wantWarning {
+				t.Errorf("mismatch warning = %v, want %v (warnings: %v)", found, tt.wantWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestPublishCrateName(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "Cargo.toml")
+	if err := os.WriteFile(invalid, []byte("[package\nname = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantName    string
+		wantWarning string
+	}{
+		{name: "manifest", cfg: Config{ManifestPath: "Cargo.toml"}, wantName: "fixture"},
+		{name: "inherited fields", cfg: Config{ManifestPath: "manifests/workspace/crates/member/Cargo.toml"}, wantName: "member"},
+		{name: "crate_name", cfg: Config{ManifestPath: invalid, CrateName: "renamed"}, wantName: "renamed"},
+		{name: "virtual workspace", cfg: Config{ManifestPath: "manifests/workspace/Cargo.toml"}},
+		{name: "missing manifest", cfg: Config{ManifestPath: filepath.Join(dir, "missing.toml")}},
+		{name: "invalid manifest", cfg: Config{ManifestPath: invalid}, wantWarning: "crate name unavailable, omitted from messages and outputs: failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, warning := publishCrateName(&tt.cfg)
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if !strings.HasPrefix(warning, tt.wantWarning) || (tt.wantWarning == "") != (warning == "") {
+				t.Errorf("warning = %q, want it to start with %q", warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestExecuteCrateNameInMessages(t *testing.T) {
+	failing := &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return []byte("error: failed to upload"), errors.New("exit status 101")
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: failing}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.HasPrefix(resp.Error, "cargo publish failed for fixture 1.0.0: exit status 101") {
+		t.Errorf("expected the crate in the error, got success=%v error=%q", resp.Success, resp.Error)
+	}
+
+	// An unreadable manifest only drops the name; compat_level 2.0 skips
+	// the manifest version check, which would reject it
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package\nname = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+	p = &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token", "compat_level": "2.0"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if !strings.HasPrefix(resp.Message, "Published crate version 1.0.0 to crates.io") {
+		t.Errorf("unexpected message %q", resp.Message)
+	}
+	if _, ok := resp.Outputs["crate_name"]; ok {
+		t.Errorf("expected no crate_name, got %v", resp.Outputs["crate_name"])
+	}
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	if !slices.ContainsFunc(warnings, func(w string) bool { return strings.HasPrefix(w, "crate name unavailable") }) {
+		t.Errorf("expected a crate name warning, got %v", warnings)
+	}
+}
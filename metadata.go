@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// metadataCheckEnabled reports whether publishing requires the package
+// metadata crates.io enforces: on at the current compat_level unless
+// skip_metadata_check is set.
+func metadataCheckEnabled(cfg *Config) bool {
+	return !cfg.SkipMetadataCheck && compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatMetadataCheck)
+}
+
+// packageMetadata is the package metadata crates.io enforces, with fields
+// declared `workspace = true` taken from the workspace root.
+type packageMetadata struct {
+	description string
+	license     string
+	// licenseFile is resolved against the directory of the manifest that set
+	// it, so it can be checked from the working directory.
+	licenseFile string
+}
+
+// readPackageMetadata reads the description, license and license-file of a
+// manifest, looking fields declared with `workspace = true` up in the
+// workspace root's [workspace.package]. It returns nil for a missing
+// manifest or one without a [package] table, which are left for cargo to
+// report.
+func readPackageMetadata(path string) (*packageMetadata, error) {
+	manifest, err := loadManifest(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if manifest.Package == nil {
+		return nil, nil
+	}
+
+	// The workspace root is only read when a field inherits from it
+	var root string
+	var workspace *cargoManifest
+	resolve := func(value any, field func(*cargoManifest) string) (string, string, error) {
+		switch v := value.(type) {
+		case string:
+			return strings.TrimSpace(v), filepath.Dir(path), nil
+		case map[string]any:
+			if inherit, _ := v["workspace"].(bool); !inherit {
+				break
+			}
+			if workspace == nil {
+				var err error
+				if root, workspace, err = findWorkspaceRoot(path); err != nil {
+					return "", "", err
+				}
+			}
+			return strings.TrimSpace(field(workspace)), filepath.Dir(root), nil
+		}
+		return "", "", nil
+	}
+
+	pkg := manifest.Package
+	metadata := &packageMetadata{}
+	if metadata.description, _, err = resolve(pkg.Description, func(m *cargoManifest) string { return m.Workspace.Package.Description }); err != nil {
+		return nil, err
+	}
+	if metadata.license, _, err = resolve(pkg.License, func(m *cargoManifest) string { return m.Workspace.Package.License }); err != nil {
+		return nil, err
+	}
+	licenseFile, dir, err := resolve(pkg.LicenseFile, func(m *cargoManifest) string { return m.Workspace.Package.LicenseFile })
+	if err != nil {
+		return nil, err
+	}
+	if licenseFile != "" {
+		metadata.licenseFile = filepath.Join(dir, licenseFile)
+	}
+	return metadata, nil
+}
+
+// missing lists the fields crates.io requires that are not set:
+// description, and license or license-file.
+func (m *packageMetadata) missing() []string {
+	var missing []string
+	if m.description == "" {
+		missing = append(missing, "description")
+	}
+	if m.license == "" && m.licenseFile == "" {
+		missing = append(missing, "license or license-file")
+	}
+	return missing
+}
+
+// metadataCheck runs checkMetadata when it is enabled, recording why it was
+// skipped or why it blocked the release. It returns warnings about license
+// identifiers crates.io still accepts.
+func (p *CratesPlugin) metadataCheck(cfg *Config, subject string, decisions *decisionLog) ([]string, error) {
+	switch {
+	case cfg.SkipMetadataCheck:
+		decisions.add(subject, decisionSkip, "disabled by skip_metadata_check", "metadata_check", "skip_metadata_check")
+		return nil, nil
+	case !metadataCheckEnabled(cfg):
+		decisions.add(subject, decisionSkip, "compat_level "+cfg.CompatLevel+" predates the metadata check", "metadata_check", "compat_level")
+		return nil, nil
+	}
+	warnings, err := checkMetadata(cfg)
+	if err != nil {
+		decisions.add(subject, decisionBlock, "manifest package metadata would be rejected by crates.io", "metadata_check", "skip_metadata_check")
+	}
+	return warnings, err
+}
+
+// checkMetadata fails when the manifest lacks the metadata crates.io
+// rejects uploads without, sets a license that is not an SPDX expression, or
+// names a license-file that does not exist, before cargo spends minutes
+// building the crate. Deprecated and uncommon license identifiers are
+// returned as warnings.
+func checkMetadata(cfg *Config) ([]string, error) {
+	metadata, err := readPackageMetadata(cfg.ManifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("metadata check failed: %w", err)
+	}
+	if metadata == nil {
+		return nil, nil
+	}
+	if missing := metadata.missing(); len(missing) > 0 {
+		return nil, fmt.Errorf("%s is missing package metadata crates.io requires: %s; add it to [package] (or [workspace.package] with `workspace = true`), or set skip_metadata_check for a registry that does not require it",
+			cfg.ManifestPath, strings.Join(missing, ", "))
+	}
+
+	if metadata.license != "" {
+		expr, err := parseLicenseExpression(metadata.license)
+		if err != nil {
+			return nil, fmt.Errorf("%s sets license %q, which is not an SPDX license expression: %v; join SPDX identifiers with OR, AND and WITH, as in MIT OR Apache-2.0", cfg.ManifestPath, metadata.license, err)
+		}
+		return licenseWarnings(metadata.license, expr), nil
+	}
+	if info, err := os.Stat(metadata.licenseFile); err != nil || !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("license-file %s set by %s does not exist or is not a file", metadata.licenseFile, cfg.ManifestPath)
+	}
+	return nil, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestReadPackageMetadata(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	write("partial/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n\n[workspace.package]\nlicense = \"MIT\"\n")
+	orphan := write("orphan/crate/Cargo.toml", "[package]\nname = \"orphan\"\ndescription.workspace = true\nlicense = \"MIT\"\n")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr string
+	}{
+		{name: "complete", path: "manifests/simple/Cargo.toml"},
+		{name: "inherited from the workspace", path: "manifests/workspace/crates/member/Cargo.toml"},
+		{name: "virtual workspace", path: "manifests/workspace/Cargo.toml"},
+		{name: "missing manifest", path: filepath.Join(dir, "missing.toml")},
+		{name: "license-file", path: write("license-file.toml", "[package]\nname = \"x\"\ndescription = \"x\"\nlicense-file = \"LICENSE\"\n")},
+		{name: "nothing set", path: write("bare.toml", "[package]\nname = \"x\"\n"), want: []string{"description", "license or license-file"}},
+		{name: "blank description", path: write("blank.toml", "[package]\nname = \"x\"\ndescription = \" \"\nlicense = \"MIT\"\n"), want: []string{"description"}},
+		{name: "not set by the workspace", path: write("partial/member/Cargo.toml", "[package]\nname = \"member\"\ndescription.workspace = true\nlicense.workspace = true\n"), want: []string{"description"}},
+		{name: "no workspace root", path: orphan, wantErr: "no workspace root found"},
+		{name: "invalid toml", path: write("invalid.toml", "[package\nname = 1"), wantErr: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := readPackageMetadata(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			if metadata != nil {
+				got = metadata.missing()
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("missing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMetadataLicense(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	pkg := "[package]\nname = \"x\"\ndescription = \"x\"\n"
+	write("present/LICENSE", "MIT License\n")
+	write("workspace/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n\n[workspace.package]\nlicense-file = \"LICENSE\"\n")
+	write("workspace/LICENSE", "MIT License\n")
+
+	tests := []struct {
+		name         string
+		manifest     string
+		wantWarnings []string
+		wantErr      string
+	}{
+		{name: "dual license", manifest: write("dual/Cargo.toml", pkg+"license = \"MIT OR Apache-2.0\"\n")},
+		{name: "with exception", manifest: write("exception/Cargo.toml", pkg+"license = \"Apache-2.0 WITH LLVM-exception\"\n")},
+		{name: "deprecated identifier", manifest: write("deprecated/Cargo.toml", pkg+"license = \"GPL-3.0\"\n"), wantWarnings: []string{"license identifier GPL-3.0 is deprecated by SPDX; use GPL-3.0-only"}},
+		{name: "slash separator", manifest: write("slash/Cargo.toml", pkg+"license = \"MIT/Apache-2.0\"\n"), wantWarnings: []string{`license "MIT/Apache-2.0" uses the deprecated / separator; use OR`}},
+		{name: "uncommon identifier", manifest: write("uncommon/Cargo.toml", pkg+"license = \"Proprietary\"\n"), wantWarnings: []string{"license identifier Proprietary is not a common SPDX identifier; crates.io rejects identifiers outside the SPDX license list"}},
+		{name: "not an expression", manifest: write("invalid/Cargo.toml", pkg+"license = \"MIT OR\"\n"), wantErr: `sets license "MIT OR", which is not an SPDX license expression`},
+		{name: "license-file present", manifest: write("present/Cargo.toml", pkg+"license-file = \"LICENSE\"\n")},
+		{name: "license-file missing", manifest: write("missing/Cargo.toml", pkg+"license-file = \"LICENSE\"\n"), wantErr: "license-file " + filepath.Join(dir, "missing", "LICENSE") + " set by"},
+		{name: "license-file inherited", manifest: write("workspace/member/Cargo.toml", pkg+"license-file.workspace = true\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := checkMetadata(&Config{ManifestPath: tt.manifest})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestExecuteMetadataCheck(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte("[package]\nname = \"bare\"\nversion = \"1.0.0\"\nlicense = \"MIT\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	tests := []struct {
+		name        string
+		hook        plugin.Hook
+		config      map[string]any
+		dryRun      bool
+		wantSuccess bool
+	}{
+		{name: "publish", hook: plugin.HookPostPublish},
+		{name: "publish dry run", hook: plugin.HookPostPublish, dryRun: true},
+		{name: "pre-publish", hook: plugin.HookPrePublish},
+		{name: "pre-publish without verification", hook: plugin.HookPrePublish, config: map[string]any{"prepublish_verify": false}},
+		{name: "skipped", hook: plugin.HookPostPublish, config: map[string]any{"skip_metadata_check": true}, wantSuccess: true},
+		{name: "pinned to 2.0", hook: plugin.HookPostPublish, config: map[string]any{"compat_level": "2.0"}, wantSuccess: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"token": "test-token"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    tt.hook,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v, want %v (error: %s)", resp.Success, tt.wantSuccess, resp.Error)
+			}
+
+			decisions, _ := resp.Outputs["decisions"].([]decision)
+			i := slices.IndexFunc(decisions, func(d decision) bool { return d.Feature == "metadata_check" })
+			if tt.wantSuccess {
+				if i < 0 || decisions[i].Decision != decisionSkip {
+					t.Errorf("expected a skip decision, got %+v", decisions)
+				}
+				return
+			}
+			if !strings.HasPrefix(resp.Error, "Cargo.toml is missing package metadata crates.io requires: description;") {
+				t.Errorf("unexpected error: %q", resp.Error)
+			}
+			if i < 0 || decisions[i].Decision != decisionBlock {
+				t.Errorf("expected a block decision, got %+v", decisions)
+			}
+			if calls := mock.GetCalls(); len(calls) != 0 {
+				t.Errorf("expected no commands, got %+v", calls)
+			}
+		})
+	}
+}
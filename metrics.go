@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
+)
+
+const (
+	defaultMetricsPort     = 8125
+	defaultMetricsPrefix   = "relicta.crates"
+	metricsFormatStatsd    = "statsd"
+	metricsFormatDogstatsd = "dogstatsd"
+)
+
+// MetricsConfig configures fire-and-forget statsd metric emission.
+type MetricsConfig struct {
+	Host   string
+	Port   int
+	Prefix string
+	Format string
+	Tags   map[string]string
+}
+
+// parseMetricsConfig parses the optional metrics block.
+func parseMetricsConfig(raw map[string]any) MetricsConfig {
+	parser := helpers.NewConfigParser(raw)
+
+	tags := map[string]string{}
+	for k, v := range parser.GetMap("tags") {
+		tags[k] = fmt.Sprint(v)
+	}
+
+	return MetricsConfig{
+		Host:   parser.GetString("host", "", ""),
+		Port:   parser.GetInt("port", defaultMetricsPort),
+		Prefix: parser.GetString("prefix", "", defaultMetricsPrefix),
+		Format: parser.GetString("format", "", metricsFormatDogstatsd),
+		Tags:   tags,
+	}
+}
+
+// metricsClient sends statsd metrics over UDP. A nil client is a no-op, so
+// callers never need to check whether metrics are enabled.
+type metricsClient struct {
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool
+	tags      []string
+}
+
+// newMetricsClient returns a client for the configuration, or nil when
+// metrics are disabled or the socket cannot be opened. Every metric is tagged
+// with the registry and, when it is known, the crate name.
+func newMetricsClient(cfg MetricsConfig, registry, crate string) *metricsClient {
+	if cfg.Host == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil
+	}
+
+	tags := []string{"registry:" + registry}
+	if crate != "" {
+		tags = append(tags, "crate:"+crate)
+	}
+	keys := make([]string, 0, len(cfg.Tags))
+	for k := range cfg.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		tags = append(tags, k+":"+cfg.Tags[k])
+	}
+
+	return &metricsClient{
+		conn:      conn,
+		prefix:    strings.TrimSuffix(cfg.Prefix, "."),
+		dogstatsd: cfg.Format != metricsFormatStatsd,
+		tags:      tags,
+	}
+}
+
+// incr increments a counter.
+func (m *metricsClient) incr(name string, value int, tags ...string) {
+	m.send(name, strconv.Itoa(value)+"|c", tags)
+}
+
+// timing records a duration in milliseconds.
+func (m *metricsClient) timing(name string, d time.Duration, tags ...string) {
+	m.send(name, strconv.FormatInt(d.Milliseconds(), 10)+"|ms", tags)
+}
+
+// send writes a single metric, ignoring any delivery error.
+func (m *metricsClient) send(name, value string, tags []string) {
+	if m == nil {
+		return
+	}
+
+	line := m.prefix + "." + name + ":" + value
+	if m.dogstatsd {
+		line += "|#" + strings.Join(append(append([]string{}, m.tags...), tags...), ",")
+	}
+	_, _ = m.conn.Write([]byte(line))
+}
+
+// close releases the UDP socket.
+func (m *metricsClient) close() {
+	if m == nil {
+		return
+	}
+	_ = m.conn.Close()
+}
+
+// publishFailed records a failed publish with its error kind.
+func (m *metricsClient) publishFailed(kind string) {
+	m.incr("publish.failure", 1, "error_kind:"+kind)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// listenStatsd starts a local UDP listener and returns its port and a function
+// that collects every payload received until the read deadline passes.
+func listenStatsd(t *testing.T) (int, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	collect := func() []string {
+		var payloads []string
+		buf := make([]byte, 2048)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return payloads
+			}
+			payloads = append(payloads, string(buf[:n]))
+		}
+	}
+
+	return conn.LocalAddr().(*net.UDPAddr).Port, collect
+}
+
+func findMetric(payloads []string, prefix string) string {
+	for _, p := range payloads {
+		if strings.HasPrefix(p, prefix) {
+			return p
+		}
+	}
+	return ""
+}
+
+func TestPublishMetrics(t *testing.T) {
+	t.Run("success emits counter and phase timer", func(t *testing.T) {
+		port, collect := listenStatsd(t)
+		// The upload takes 3s on the injected clock
+		clock := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+		p := &CratesPlugin{
+			cmdExecutor: &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					if args[0] == "publish" {
+						clock = clock.Add(3 * time.Second)
+					}
+					return []byte("success"), nil
+				},
+			},
+			now: func() time.Time { return clock },
+		}
+
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"token": "test-token",
+				"metrics": map[string]any{
+					"host": "127.0.0.1",
+					"port": float64(port),
+					"tags": map[string]any{"team": "release"},
+				},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !resp.Success {
+			t.Fatalf("expected success, got error: %s", resp.Error)
+		}
+
+		payloads := collect()
+		if got := findMetric(payloads, "relicta.crates.publish.success:1|c|#"); got != "relicta.crates.publish.success:1|c|#registry:crates.io,crate:fixture,team:release" {
+			t.Errorf("unexpected success metric %q in %v", got, payloads)
+		}
+		for _, phase := range []string{"registry_preflight", "cargo_publish"} {
+			var timer string
+			for _, payload := range payloads {
+				if strings.HasPrefix(payload, "relicta.crates.phase.duration:") && strings.HasSuffix(payload, ",phase:"+phase) {
+					timer = payload
+				}
+			}
+			if !strings.Contains(timer, "|ms|#registry:crates.io,crate:fixture,team:release,") {
+				t.Errorf("missing %s phase timer with the crate tag in %v", phase, payloads)
+			}
+			if phase == "cargo_publish" && !strings.HasPrefix(timer, "relicta.crates.phase.duration:3000|ms") {
+				t.Errorf("cargo_publish timer = %q, want 3000ms from the injected clock", timer)
+			}
+		}
+	})
+
+	t.Run("failure tagged with error kind", func(t *testing.T) {
+		port, collect := listenStatsd(t)
+		p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{
+			RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				return []byte("error: crate already uploaded"), errors.New("exit status 101")
+			},
+		}}
+
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"token":    "test-token",
+				"registry": "internal",
+				"metrics": map[string]any{
+					"host":   "127.0.0.1",
+					"port":   float64(port),
+					"prefix": "ci",
+				},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success {
+			t.Fatal("expected failure")
+		}
+
+		payloads := collect()
+		if got := findMetric(payloads, "ci.publish.failure"); got != "ci.publish.failure:1|c|#registry:internal,crate:fixture,error_kind:cargo_failed" {
+			t.Errorf("unexpected failure metric %q in %v", got, payloads)
+		}
+	})
+
+	t.Run("rate limit retries are counted", func(t *testing.T) {
+		port, collect := listenStatsd(t)
+		calls := 0
+		p := &CratesPlugin{
+			cmdExecutor: &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					calls++
+					if calls == 1 {
+						return []byte("status 429 Too Many Requests: retry after 1 seconds"), errors.New("exit status 101")
+					}
+					return []byte("Uploaded successfully"), nil
+				},
+			},
+			sleep: func(ctx context.Context, d time.Duration) error { return nil },
+		}
+
+		_, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"token":            "test-token",
+				"max_package_size": 0,
+				"metrics":          map[string]any{"host": "127.0.0.1", "port": float64(port), "format": "statsd"},
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		payloads := collect()
+		if got := findMetric(payloads, "relicta.crates.publish.retries"); got != "relicta.crates.publish.retries:1|c" {
+			t.Errorf("unexpected retries metric %q in %v", got, payloads)
+		}
+	})
+}
+
+func TestMetricsClientDisabled(t *testing.T) {
+	m := newMetricsClient(MetricsConfig{}, "crates.io", "fixture")
+	if m != nil {
+		t.Fatal("expected nil client without host")
+	}
+
+	// A nil client must be safe to use.
+	m.incr("publish.success", 1)
+	m.timing("phase.duration", time.Second)
+	m.close()
+}
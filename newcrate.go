@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// crateLookupTimeout bounds the registry index lookup of allow_new_crate.
+const crateLookupTimeout = 5 * time.Second
+
+// sparseIndexPath returns the path of a crate's file in a sparse index, after
+// cargo's layout: 1/a, 2/ab, 3/a/abc, then ab/cd/abcd for longer names.
+func sparseIndexPath(name string) string {
+	name = strings.ToLower(name)
+	switch len(name) {
+	case 1:
+		return "1/" + name
+	case 2:
+		return "2/" + name
+	case 3:
+		return "3/" + name[:1] + "/" + name
+	default:
+		return name[:2] + "/" + name[2:4] + "/" + name
+	}
+}
+
+// newCrateCheck is the outcome of looking the crate up in the registry index.
+type newCrateCheck struct {
+	// err blocks the publish of a crate the registry does not know.
+	err error
+	// warning explains why the lookup could not be made.
+	warning string
+}
+
+// newCrateCheckEnabled reports whether publishing looks the crate up in the
+// registry index: on at the current compat_level unless allow_new_crate is
+// set.
+func newCrateCheckEnabled(cfg *Config) bool {
+	return !cfg.AllowNewCrate && compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatNewCrateCheck)
+}
+
+// checkNewCrate looks the crate up in the registry's sparse index before
+// publishing, so a typo in the crate name does not publish a new crate. A
+// missing crate fails unless allow_new_crate is set; a lookup that cannot be
+// made is only a warning.
+func (p *CratesPlugin) checkNewCrate(ctx context.Context, cfg *Config, subject string, decisions *decisionLog) newCrateCheck {
+	if cfg.AllowNewCrate {
+		return newCrateCheck{}
+	}
+	if !newCrateCheckEnabled(cfg) {
+		decisions.add(subject, decisionSkip, "compat_level "+cfg.CompatLevel+" predates the new crate check", "new_crate_check", "compat_level")
+		return newCrateCheck{}
+	}
+	if cfg.Offline || cfg.Frozen {
+		return newCrateCheck{warning: "allow_new_crate check skipped: cargo runs in offline mode"}
+	}
+	name := crateName(cfg)
+	if name == "" {
+		return newCrateCheck{warning: "allow_new_crate check skipped: the crate name is not known; set crate_name"}
+	}
+
+	indexURL, reason := registryIndexURL(cfg)
+	if reason != "" {
+		return newCrateCheck{warning: "allow_new_crate check skipped: " + reason}
+	}
+	base, ok := sparseIndexBase(indexURL)
+	if !ok {
+		return newCrateCheck{warning: fmt.Sprintf("allow_new_crate check skipped: index %s is not a sparse index", indexURL)}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, crateLookupTimeout)
+	defer cancel()
+	entry := base + sparseIndexPath(name)
+	status, err := p.getRegistryExecutor(cfg).FetchStatus(lookupCtx, entry)
+	switch {
+	case err != nil:
+		return newCrateCheck{warning: fmt.Sprintf("allow_new_crate check skipped: failed to query %s: %v", entry, err)}
+	case status == http.StatusOK:
+		return newCrateCheck{}
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return newCrateCheck{err: fmt.Errorf("crate '%s' does not exist on %s; set allow_new_crate to publish a new crate", name, p.getRegistryName(cfg))}
+	default:
+		return newCrateCheck{warning: fmt.Sprintf("allow_new_crate check skipped: %s responded with status %d", entry, status)}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestSparseIndexPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "a", want: "1/a"},
+		{name: "ab", want: "2/ab"},
+		{name: "abc", want: "3/a/abc"},
+		{name: "serde", want: "se/rd/serde"},
+		{name: "Foo-Cre", want: "fo/o-/foo-cre"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparseIndexPath(tt.name); got != tt.want {
+				t.Errorf("sparseIndexPath(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteAllowNewCrate(t *testing.T) {
+	t.Setenv("CARGO_REGISTRIES_INTERNAL_INDEX", "")
+
+	tests := []struct {
+		name        string
+		config      map[string]any
+		status      int
+		fetchErr    error
+		wantURL     string
+		wantError   string
+		wantWarning string
+		wantSkip    bool
+	}{
+		{
+			name:    "existing crate",
+			config:  map[string]any{"crate_name": "foo-core"},
+			status:  http.StatusOK,
+			wantURL: "https://index.crates.io/fo/o-/foo-core",
+		},
+		{
+			name:      "name typo",
+			config:    map[string]any{"crate_name": "foo-cre"},
+			status:    http.StatusNotFound,
+			wantURL:   "https://index.crates.io/fo/o-/foo-cre",
+			wantError: "crate 'foo-cre' does not exist on crates.io; set allow_new_crate to publish a new crate",
+		},
+		{
+			name:   "new crate allowed",
+			config: map[string]any{"crate_name": "foo-cre", "allow_new_crate": true},
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "pinned to 2.0",
+			config:   map[string]any{"crate_name": "foo-cre", "compat_level": "2.0"},
+			status:   http.StatusNotFound,
+			wantSkip: true,
+		},
+		{
+			name:      "pinned to 2.0 with new_crate_check opted in",
+			config:    map[string]any{"crate_name": "foo-cre", "compat_level": "2.0", "compat_features": []any{"new_crate_check"}},
+			status:    http.StatusNotFound,
+			wantURL:   "https://index.crates.io/fo/o-/foo-cre",
+			wantError: "crate 'foo-cre' does not exist on crates.io",
+		},
+		{
+			name:      "private sparse index",
+			config:    map[string]any{"index": "sparse+https://crates.example.com/index"},
+			status:    http.StatusNotFound,
+			wantURL:   "https://crates.example.com/index/fi/xt/fixture",
+			wantError: "crate 'fixture' does not exist on crates.example.com",
+		},
+		{
+			name:        "registry unreachable",
+			config:      map[string]any{},
+			fetchErr:    errors.New("dial tcp: i/o timeout"),
+			wantURL:     "https://index.crates.io/fi/xt/fixture",
+			wantWarning: "allow_new_crate check skipped: failed to query https://index.crates.io/fi/xt/fixture: dial tcp: i/o timeout",
+		},
+		{
+			name:        "unexpected status",
+			config:      map[string]any{},
+			status:      http.StatusForbidden,
+			wantURL:     "https://index.crates.io/fi/xt/fixture",
+			wantWarning: "responded with status 403",
+		},
+		{
+			name:        "git index",
+			config:      map[string]any{"index": "https://github.com/example/index"},
+			wantWarning: "is not a sparse index",
+		},
+		{
+			name:        "named registry without a known index",
+			config:      map[string]any{"registry": "internal"},
+			wantWarning: "the index of registry internal is not known to the plugin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"token": "secret", "skip_preflight": true}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+
+			var fetched []string
+			mock := &MockCommandExecutor{
+				FetchFunc: func(_ context.Context, url string) (int, error) {
+					fetched = append(fetched, url)
+					return tt.status, tt.fetchErr
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantURL == "" && len(fetched) > 0 {
+				t.Errorf("expected no index lookup, got %v", fetched)
+			}
+			if tt.wantURL != "" && (len(fetched) != 1 || fetched[0] != tt.wantURL) {
+				t.Errorf("index lookups = %v, want %s", fetched, tt.wantURL)
+			}
+			decisions, _ := resp.Outputs["decisions"].([]decision)
+			skipped := slices.ContainsFunc(decisions, func(d decision) bool {
+				return d.Feature == "new_crate_check" && d.Decision == decisionSkip
+			})
+			if skipped != tt.wantSkip {
+				t.Errorf("new_crate_check skipped = %v, want %v (decisions %+v)", skipped, tt.wantSkip, decisions)
+			}
+
+			ranCargo := false
+			for _, call := range mock.GetCalls() {
+				if len(call.Args) > 0 && call.Args[0] == "publish" {
+					ranCargo = true
+				}
+			}
+			if tt.wantError != "" {
+				if resp.Success || !strings.Contains(resp.Error, tt.wantError) {
+					t.Errorf("error = %q, want %q", resp.Error, tt.wantError)
+				}
+				if ranCargo {
+					t.Error("cargo publish must not run for a new crate")
+				}
+				return
+			}
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+			if !ranCargo {
+				t.Error("expected cargo publish to run")
+			}
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			if tt.wantWarning != "" && !strings.Contains(strings.Join(warnings, "\n"), tt.wantWarning) {
+				t.Errorf("warnings %v should contain %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestNewCrateLookupUserAgent(t *testing.T) {
+	var agent string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		if r.URL.Path != "/index/fi/xt/fixture" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The lookup goes through the real executor against the test index
+	real := &RealCommandExecutor{client: server.Client()}
+	mock := &MockCommandExecutor{FetchFunc: real.FetchStatus}
+	p := &CratesPlugin{cmdExecutor: mock}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:   plugin.HookPostPublish,
+		Config: map[string]any{"token": "secret", "skip_preflight": true, "index": "sparse+" + server.URL + "/index"},
+		Context: plugin.ReleaseContext{
+			Version:     "v1.0.0",
+			Environment: map[string]string{"RELICTA_CORRELATION_ID": "release-42"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if agent != "relicta-plugin-crates/2.0.0 (correlation_id=release-42)" {
+		t.Errorf("User-Agent = %q, want the correlation ID", agent)
+	}
+}
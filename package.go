@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	// packagingPattern matches cargo's "Packaging <name> v<version>" status line.
+	packagingPattern = regexp.MustCompile(`(?m)^\s*Packaging (\S+) v(\S+)`)
+	// packagedNamePattern and packagedVersionPattern bound the crate name and
+	// version read from cargo output before they become part of a file path.
+	packagedNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	packagedVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+([-+][0-9A-Za-z.+-]+)?$`)
+)
+
+// packagedCrate describes the .crate file produced by cargo package.
+type packagedCrate struct {
+	path string
+	size int64
+}
+
+// buildPackageArgs constructs the cargo package arguments for the two-phase
+// publish. The token is omitted since packaging never talks to the registry
+// with credentials, and quiet is dropped because the status output names the crate.
+func (p *CratesPlugin) buildPackageArgs(cfg *Config) []string {
+	packageCfg := *cfg
+	packageCfg.Token = ""
+	packageCfg.Quiet = false
+	return p.buildCargoArgs(&packageCfg, "package")
+}
+
+// runCargoPackage runs cargo package and locates the produced .crate file.
+func (p *CratesPlugin) runCargoPackage(ctx context.Context, cfg *Config, workDir string, args []string) (*packagedCrate, []byte, error) {
+	output, err := p.runCargo(ctx, cfg, workDir, args...)
+	if err != nil {
+		return nil, output, err
+	}
+
+	m := packagingPattern.FindStringSubmatch(string(output))
+	if m == nil {
+		return nil, output, fmt.Errorf("could not determine the packaged crate from cargo output")
+	}
+	if !packagedNamePattern.MatchString(m[1]) || !packagedVersionPattern.MatchString(m[2]) {
+		return nil, output, fmt.Errorf("cargo reported an unexpected packaged crate %q v%q", m[1], m[2])
+	}
+
+	targetDir := cfg.TargetDir
+	if targetDir == "" {
+		targetDir, err = p.cargoTargetDirectory(ctx, cfg, workDir)
+		if err != nil {
+			return nil, output, err
+		}
+	}
+
+	path := filepath.Join(targetDir, "package", m[1]+"-"+m[2]+".crate")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, output, fmt.Errorf("packaged crate not found: %w", err)
+	}
+
+	return &packagedCrate{path: path, size: info.Size()}, output, nil
+}
+
+// cargoTargetDirectory asks cargo metadata for the target directory of the manifest.
+func (p *CratesPlugin) cargoTargetDirectory(ctx context.Context, cfg *Config, workDir string) (string, error) {
+	var args []string
+	if cfg.Toolchain != "" {
+		args = append(args, "+"+cfg.Toolchain)
+	}
+	args = append(args, "metadata", "--no-deps", "--format-version", "1")
+	if cfg.ManifestPath != "" {
+		args = append(args, "--manifest-path", cfg.ManifestPath)
+	}
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
+	}
+
+	output, err := p.runCargo(ctx, cfg, workDir, args...)
+	if errors.Is(err, errCargoNotFound) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run cargo metadata: %v\nOutput: %s", err, string(output))
+	}
+
+	var metadata struct {
+		TargetDirectory string `json:"target_directory"`
+	}
+	if err := decodeMetadata(output, &metadata); err != nil || metadata.TargetDirectory == "" {
+		return "", fmt.Errorf("failed to read target directory from cargo metadata")
+	}
+	return metadata.TargetDirectory, nil
+}
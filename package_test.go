@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestBuildPackageArgs(t *testing.T) {
+	p := &CratesPlugin{}
+	cfg := &Config{
+		Token:     "secret-token",
+		Registry:  "my-registry",
+		Toolchain: "stable",
+		Quiet:     true,
+		Locked:    true,
+		Features:  []string{"serde"},
+	}
+
+	got := strings.Join(p.buildPackageArgs(cfg), " ")
+	want := "+stable package --registry my-registry --features serde --locked"
+	if got != want {
+		t.Errorf("buildPackageArgs() = %q, want %q", got, want)
+	}
+	if cfg.Token != "secret-token" || !cfg.Quiet {
+		t.Error("buildPackageArgs must not modify the config")
+	}
+}
+
+func TestExecutePackageThenPublish(t *testing.T) {
+	const packagingOutput = "   Packaging mycrate v1.0.0 (/src/mycrate)\n    Packaged 4 files, 1.1KiB (828.0B compressed)\n"
+
+	tests := []struct {
+		name              string
+		useTargetDir      bool
+		writeCrate        bool
+		packageErr        error
+		packageOutput     string
+		wantSuccess       bool
+		wantErrorContains string
+		wantCalls         []string
+	}{
+		{
+			name:        "target directory from cargo metadata",
+			writeCrate:  true,
+			wantSuccess: true,
+			wantCalls:   []string{"package", "metadata", "publish"},
+		},
+		{
+			name:         "configured target_dir",
+			useTargetDir: true,
+			writeCrate:   true,
+			wantSuccess:  true,
+			wantCalls:    []string{"package", "publish"},
+		},
+		{
+			name:              "packaging failure skips publish",
+			packageErr:        errors.New("exit status 101"),
+			wantErrorContains: "cargo package failed",
+			wantCalls:         []string{"package"},
+		},
+		{
+			name:              "crate name that looks like a flag",
+			useTargetDir:      true,
+			packageOutput:     "   Packaging --registry v1.0.0 (/src/mycrate)\n",
+			wantErrorContains: "unexpected packaged crate",
+			wantCalls:         []string{"package"},
+		},
+		{
+			name:              "crate name that escapes the target directory",
+			useTargetDir:      true,
+			packageOutput:     "   Packaging ../../mycrate v1.0.0 (/src/mycrate)\n",
+			wantErrorContains: "unexpected packaged crate",
+			wantCalls:         []string{"package"},
+		},
+		{
+			name:              "missing crate file skips publish",
+			wantErrorContains: "packaged crate not found",
+			wantCalls:         []string{"package", "metadata"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDir := t.TempDir()
+			cratePath := filepath.Join(targetDir, "package", "mycrate-1.0.0.crate")
+			if tt.writeCrate {
+				if err := os.MkdirAll(filepath.Dir(cratePath), 0o755); err != nil {
+					t.Fatalf("failed to create package dir: %v", err)
+				}
+				if err := os.WriteFile(cratePath, []byte("crate-bytes"), 0o644); err != nil {
+					t.Fatalf("failed to write crate: %v", err)
+				}
+			}
+
+			var publishArgs, packageArgs []string
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					switch args[0] {
+					case "package":
+						packageArgs = args
+						if tt.packageOutput != "" {
+							return []byte(tt.packageOutput), tt.packageErr
+						}
+						return []byte(packagingOutput), tt.packageErr
+					case "metadata":
+						return []byte(fmt.Sprintf("warning: unused key\n{\"packages\":[],\"target_directory\":%q}\n", targetDir)), nil
+					default:
+						publishArgs = args
+						return []byte("Uploaded mycrate v1.0.0"), nil
+					}
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			config := map[string]any{
+				"token":                "test-token",
+				"package_then_publish": true,
+			}
+			if tt.useTargetDir {
+				config["target_dir"] = targetDir
+				config["target_dir_root"] = filepath.Dir(targetDir)
+			}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain %q, got %q", tt.wantErrorContains, resp.Error)
+			}
+
+			var gotCalls []string
+			for _, call := range mock.GetCalls() {
+				gotCalls = append(gotCalls, call.Args[0])
+			}
+			if strings.Join(gotCalls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("calls = %v, want %v", gotCalls, tt.wantCalls)
+			}
+
+			if strings.Contains(strings.Join(packageArgs, " "), "--token") {
+				t.Errorf("package args must not contain the token: %v", packageArgs)
+			}
+			if strings.Contains(strings.Join(packageArgs, " "), "--no-verify") {
+				t.Errorf("package step should verify the build: %v", packageArgs)
+			}
+
+			if !tt.wantSuccess {
+				return
+			}
+			if !strings.Contains(strings.Join(publishArgs, " "), "--no-verify") {
+				t.Errorf("publish args should contain --no-verify: %v", publishArgs)
+			}
+			if resp.Outputs["crate_file"] != cratePath {
+				t.Errorf("crate_file = %v, want %s", resp.Outputs["crate_file"], cratePath)
+			}
+			if resp.Outputs["crate_size"] != int64(len("crate-bytes")) {
+				t.Errorf("crate_size = %v, want %d", resp.Outputs["crate_size"], len("crate-bytes"))
+			}
+		})
+	}
+}
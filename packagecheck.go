@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Package check levels select what a failed packaging sanity check does.
+const (
+	packageCheckOff   = "off"
+	packageCheckWarn  = "warn"
+	packageCheckError = "error"
+)
+
+// defaultRequiredPaths are the paths a packaged crate must contain when
+// required_paths is unset.
+var defaultRequiredPaths = []string{"src/"}
+
+// cargoGeneratedFiles are always listed by cargo package, so they do not count
+// towards min_package_files.
+var cargoGeneratedFiles = map[string]bool{
+	"Cargo.toml":           true,
+	"Cargo.toml.orig":      true,
+	"Cargo.lock":           true,
+	".cargo_vcs_info.json": true,
+}
+
+// packageCheckEnabled reports whether the pre-publish packaging check runs.
+func packageCheckEnabled(cfg *Config) bool {
+	return cfg.PackageCheck == packageCheckWarn || cfg.PackageCheck == packageCheckError
+}
+
+// packageListEnabled reports whether the pre-publish hook lists the packaged
+// files: for package_check, package_must_include or package_must_not_include.
+func packageListEnabled(cfg *Config) bool {
+	return packageCheckEnabled(cfg) || len(cfg.MustInclude) > 0 || len(cfg.MustNotInclude) > 0
+}
+
+// validatePackageCheck checks the package_check level, required_paths and
+// min_package_files.
+func validatePackageCheck(level string, requiredPaths []string, minFiles int) error {
+	switch level {
+	case "", packageCheckOff, packageCheckWarn, packageCheckError:
+	default:
+		return fmt.Errorf("package_check must be one of: off, warn, error")
+	}
+	for i, path := range requiredPaths {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("required_paths[%d] must not be empty", i)
+		}
+		// Required paths name files inside the package, not the working directory
+		if err := validateRelativePath(path); err != nil {
+			return fmt.Errorf("invalid required_paths[%d]: %w", i, err)
+		}
+	}
+	if minFiles < 0 {
+		return fmt.Errorf("min_package_files must be zero or a positive integer")
+	}
+	return nil
+}
+
+// buildPackageListArgs constructs the cargo package --list arguments. The
+// listing always allows a dirty tree: whether publishing a dirty tree is
+// allowed is enforced by the publish itself, not by this check.
+func buildPackageListArgs(cfg *Config) []string {
+	var args []string
+	if cfg.Toolchain != "" {
+		args = append(args, "+"+cfg.Toolchain)
+	}
+	args = append(args, "package", "--list", "-q", "--allow-dirty")
+	if cfg.ManifestPath != "" {
+		args = append(args, "--manifest-path", cfg.ManifestPath)
+	}
+	if cfg.Locked {
+		args = append(args, "--locked")
+	}
+	if cfg.Offline {
+		args = append(args, "--offline")
+	}
+	if cfg.Frozen {
+		args = append(args, "--frozen")
+	}
+	// Features and target select the same package as the publish
+	if len(cfg.Features) > 0 {
+		args = append(args, "--features", strings.Join(cfg.Features, ","))
+	}
+	if cfg.AllFeatures {
+		args = append(args, "--all-features")
+	}
+	if cfg.NoDefaultFeatures {
+		args = append(args, "--no-default-features")
+	}
+	if cfg.Target != "" {
+		args = append(args, "--target", cfg.Target)
+	}
+	return append(args, cargoConfigArgs(cfg)...)
+}
+
+// listPackageFiles runs cargo package --list and returns the files cargo
+// would package, with forward slashes.
+func (p *CratesPlugin) listPackageFiles(ctx context.Context, cfg *Config) ([]string, error) {
+	args := buildPackageListArgs(cfg)
+	workDir := manifestWorkDir(cfg)
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
+	}
+
+	output, err := p.runCargo(ctx, cfg, workDir, args...)
+	if errors.Is(err, errCargoNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run cargo package --list: %v\nOutput: %s", err, string(output))
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.ToSlash(line))
+		}
+	}
+	return files, nil
+}
+
+// checkPackageFiles returns the reasons the packaged file list looks broken:
+// required paths with no packaged file, and fewer than minFiles files besides
+// those cargo generates.
+func checkPackageFiles(files, requiredPaths []string, minFiles int) []string {
+	var problems []string
+	for _, required := range requiredPaths {
+		if !containsPackagePath(files, required) {
+			problems = append(problems, fmt.Sprintf("no packaged file under %s", required))
+		}
+	}
+
+	count := 0
+	for _, file := range files {
+		if !cargoGeneratedFiles[file] {
+			count++
+		}
+	}
+	if count < minFiles {
+		problems = append(problems, fmt.Sprintf("%d packaged file(s) besides the manifest and lockfile, at least %d required", count, minFiles))
+	}
+	return problems
+}
+
+// containsPackagePath reports whether files contains path itself or any file
+// below it when path names a directory.
+func containsPackagePath(files []string, path string) bool {
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	for _, file := range files {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePackageGlobs checks that every package_must_include or
+// package_must_not_include pattern is a valid glob.
+func validatePackageGlobs(key string, patterns []string) error {
+	for i, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("%s[%d] must not be empty", key, i)
+		}
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid %s[%d] %q: %w", key, i, pattern, err)
+		}
+	}
+	return nil
+}
+
+// checkPackageGlobs returns the package_must_include patterns no packaged
+// file matches and the packaged files a package_must_not_include pattern
+// matches, naming the pattern.
+func checkPackageGlobs(files, mustInclude, mustNotInclude []string) []string {
+	var problems []string
+	for _, pattern := range mustInclude {
+		if !slices.ContainsFunc(files, func(file string) bool { return matchPackageGlob(pattern, file) }) {
+			problems = append(problems, fmt.Sprintf("no packaged file matches package_must_include %q", pattern))
+		}
+	}
+	var unwanted []string
+	for _, file := range files {
+		for _, pattern := range mustNotInclude {
+			if matchPackageGlob(pattern, file) {
+				unwanted = append(unwanted, fmt.Sprintf("%s (%s)", file, pattern))
+				break
+			}
+		}
+	}
+	if len(unwanted) > 0 {
+		problems = append(problems, "packaged files match package_must_not_include: "+strings.Join(unwanted, ", "))
+	}
+	return problems
+}
+
+// matchPackageGlob reports whether a packaged file matches pattern. A pattern
+// without a slash matches the file name at any depth, as .env does; one with
+// a slash is matched from the crate root, where ** matches any number of
+// directories and a trailing slash matches everything below the directory.
+func matchPackageGlob(pattern, file string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// matchGlobSegments matches path segments against pattern segments.
+func matchGlobSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlobSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], segments[0])
+	return ok && err == nil && matchGlobSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCheckPackageFiles(t *testing.T) {
+	complete := []string{"Cargo.lock", "Cargo.toml", "Cargo.toml.orig", "README.md", "src/lib.rs"}
+	empty := []string{".cargo_vcs_info.json", "Cargo.lock", "Cargo.toml", "Cargo.toml.orig"}
+
+	tests := []struct {
+		name          string
+		files         []string
+		requiredPaths []string
+		minFiles      int
+		wantProblems  []string
+	}{
+		{
+			name:          "complete crate",
+			files:         complete,
+			requiredPaths: defaultRequiredPaths,
+			minFiles:      1,
+		},
+		{
+			name:          "src excluded",
+			files:         empty,
+			requiredPaths: defaultRequiredPaths,
+			minFiles:      1,
+			wantProblems: []string{
+				"no packaged file under src/",
+				"0 packaged file(s) besides the manifest and lockfile, at least 1 required",
+			},
+		},
+		{
+			name:          "required file and directory",
+			files:         complete,
+			requiredPaths: []string{"README.md", "./src", "LICENSE"},
+			wantProblems:  []string{"no packaged file under LICENSE"},
+		},
+		{
+			name:          "prefix is not a directory match",
+			files:         []string{"srcfile.rs"},
+			requiredPaths: []string{"src"},
+			wantProblems:  []string{"no packaged file under src"},
+		},
+		{
+			name:     "minimum file count",
+			files:    complete,
+			minFiles: 3,
+			wantProblems: []string{
+				"2 packaged file(s) besides the manifest and lockfile, at least 3 required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkPackageFiles(tt.files, tt.requiredPaths, tt.minFiles)
+			if strings.Join(got, "\n") != strings.Join(tt.wantProblems, "\n") {
+				t.Errorf("checkPackageFiles() = %q, want %q", got, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func TestBuildPackageListArgs(t *testing.T) {
+	cfg := &Config{
+		ManifestPath: "Cargo.toml",
+		Toolchain:    "stable",
+		Offline:      true,
+		CargoConfig:  map[string]string{"net.retry": "5"},
+	}
+	got := strings.Join(buildPackageListArgs(cfg), " ")
+	want := "+stable package --list -q --allow-dirty --manifest-path Cargo.toml --offline --config net.retry=5"
+	if got != want {
+		t.Errorf("buildPackageListArgs() = %q, want %q", got, want)
+	}
+
+	// The listing selects the same features and target as the publish
+	cfg = &Config{Features: []string{"a", "b"}, NoDefaultFeatures: true, Target: "x86_64-unknown-linux-gnu"}
+	got = strings.Join(buildPackageListArgs(cfg), " ")
+	want = "package --list -q --allow-dirty --features a,b --no-default-features --target x86_64-unknown-linux-gnu"
+	if got != want {
+		t.Errorf("buildPackageListArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckPackageGlobs(t *testing.T) {
+	files := []string{"Cargo.toml", "README.md", "src/lib.rs", "src/bin/.env", "tests/fixtures/huge.bin", "tests/it.rs"}
+
+	tests := []struct {
+		name           string
+		mustInclude    []string
+		mustNotInclude []string
+		wantProblems   []string
+	}{
+		{name: "no patterns"},
+		{name: "included", mustInclude: []string{"README.md", "src/**/*.rs", "src/"}},
+		{name: "missing", mustInclude: []string{"LICENSE*", "src/*.rs"}, wantProblems: []string{`no packaged file matches package_must_include "LICENSE*"`}},
+		{
+			name:           "excluded files named",
+			mustNotInclude: []string{".env", "tests/fixtures/**", "*.pem"},
+			wantProblems:   []string{"packaged files match package_must_not_include: src/bin/.env (.env), tests/fixtures/huge.bin (tests/fixtures/**)"},
+		},
+		{name: "anchored pattern", mustNotInclude: []string{"bin/.env", "fixtures/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkPackageGlobs(files, tt.mustInclude, tt.mustNotInclude)
+			if strings.Join(got, "\n") != strings.Join(tt.wantProblems, "\n") {
+				t.Errorf("checkPackageGlobs() = %q, want %q", got, tt.wantProblems)
+			}
+		})
+	}
+
+	if err := validatePackageGlobs("package_must_not_include", []string{"[a-"}); err == nil || !strings.Contains(err.Error(), `invalid package_must_not_include[0] "[a-"`) {
+		t.Errorf("expected an invalid pattern error, got %v", err)
+	}
+}
+
+func TestExecutePackageCheck(t *testing.T) {
+	const emptyListing = "Cargo.lock\nCargo.toml\nCargo.toml.orig\n"
+
+	tests := []struct {
+		name              string
+		config            map[string]any
+		listing           string
+		wantSuccess       bool
+		wantErrorContains []string
+		wantWarning       bool
+		wantList          bool
+	}{
+		{
+			name:        "complete crate passes",
+			config:      map[string]any{"package_check": "error"},
+			listing:     "Cargo.toml\nCargo.toml.orig\nsrc/lib.rs\n",
+			wantSuccess: true,
+			wantList:    true,
+		},
+		{
+			name:    "empty crate fails at error level",
+			config:  map[string]any{"package_check": "error"},
+			listing: emptyListing,
+			wantErrorContains: []string{
+				"packaging check failed for fixture 1.0.0: no packaged file under src/",
+				"cargo would package:\nCargo.lock\nCargo.toml\nCargo.toml.orig",
+			},
+			wantList: true,
+		},
+		{
+			name:        "empty crate warns at warn level",
+			config:      map[string]any{"package_check": "warn"},
+			listing:     emptyListing,
+			wantSuccess: true,
+			wantWarning: true,
+			wantList:    true,
+		},
+		{
+			name:        "runs with prepublish_verify disabled",
+			config:      map[string]any{"package_check": "warn", "prepublish_verify": false},
+			listing:     emptyListing,
+			wantSuccess: true,
+			wantWarning: true,
+			wantList:    true,
+		},
+		{
+			name:              "package_must_not_include fails without package_check",
+			config:            map[string]any{"package_must_not_include": []any{".env"}},
+			listing:           "Cargo.toml\n.env\nsrc/lib.rs\n",
+			wantErrorContains: []string{"packaged file check failed for fixture 1.0.0: packaged files match package_must_not_include: .env (.env)"},
+			wantList:          true,
+		},
+		{
+			name:        "package_must_include lists the files",
+			config:      map[string]any{"package_must_include": []any{"src/lib.rs"}},
+			listing:     "Cargo.toml\nsrc/lib.rs\n",
+			wantSuccess: true,
+			wantList:    true,
+		},
+		{
+			name:        "off by default",
+			config:      map[string]any{},
+			listing:     emptyListing,
+			wantSuccess: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					if len(args) > 1 && args[0] == "package" && args[1] == "--list" {
+						listed = true
+						return []byte(tt.listing), nil
+					}
+					return []byte("warning: aborting upload due to dry run"), nil
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPrePublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("success = %v, want %v (error: %s)", resp.Success, tt.wantSuccess, resp.Error)
+			}
+			for _, want := range tt.wantErrorContains {
+				if !strings.Contains(resp.Error, want) {
+					t.Errorf("error %q should contain %q", resp.Error, want)
+				}
+			}
+			if listed != tt.wantList {
+				t.Errorf("cargo package --list ran = %v, want %v", listed, tt.wantList)
+			}
+			if files, _ := resp.Outputs["package_files"].([]string); tt.wantList && len(files) == 0 {
+				t.Errorf("expected package_files in outputs, got %v", resp.Outputs["package_files"])
+			}
+
+			var warnings []string
+			all, _ := resp.Outputs["warnings"].([]string)
+			for _, w := range all {
+				if strings.HasPrefix(w, "packaging check:") {
+					warnings = append(warnings, w)
+				}
+			}
+			if got := len(warnings) > 0; got != tt.wantWarning {
+				t.Errorf("warnings = %v, want a warning: %v", warnings, tt.wantWarning)
+			}
+			if tt.wantWarning && !strings.Contains(warnings[0], "no packaged file under src/") {
+				t.Errorf("warning should explain the problem, got %q", warnings[0])
+			}
+		})
+	}
+}
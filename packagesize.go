@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultMaxPackageSize is the crates.io limit on the size of an uploaded
+// .crate file.
+const defaultMaxPackageSize = 10 << 20
+
+// packageSizeCheckEnabled reports whether publishing measures the packaged
+// crate against max_package_size: on at the current compat_level unless
+// max_package_size is 0.
+func packageSizeCheckEnabled(cfg *Config) bool {
+	return cfg.MaxPackageSize > 0 && compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatPackageSizeCheck)
+}
+
+// validateMaxPackageSize checks that max_package_size is not negative.
+func validateMaxPackageSize(size int) error {
+	if size < 0 {
+		return fmt.Errorf("max_package_size must be 0 or more bytes")
+	}
+	return nil
+}
+
+// measurePackage runs cargo package --no-verify to produce the .crate file
+// cargo publish would upload, without building it.
+func (p *CratesPlugin) measurePackage(ctx context.Context, cfg *Config, workDir string) (*packagedCrate, error) {
+	packageCfg := *cfg
+	packageCfg.NoVerify = true
+	args := p.buildPackageArgs(&packageCfg)
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
+	}
+	packaged, output, err := p.runCargoPackage(ctx, cfg, workDir, args)
+	if err != nil {
+		return nil, fmt.Errorf("%w\nOutput: %s", err, string(output))
+	}
+	return packaged, nil
+}
+
+// checkPackageSize fails when the packaged crate is larger than
+// max_package_size.
+func checkPackageSize(label string, size int64, limit int) error {
+	if size <= int64(limit) {
+		return nil
+	}
+	return fmt.Errorf("packaged %s is %s, over the max_package_size limit of %s; exclude large files such as test fixtures with package.exclude, or raise max_package_size",
+		label, formatSize(size), formatSize(int64(limit)))
+}
+
+// formatSize renders a byte count in MiB or KiB alongside the exact count.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB (%d bytes)", float64(size)/(1<<20), size)
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB (%d bytes)", float64(size)/(1<<10), size)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
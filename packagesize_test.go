@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCheckPackageSize(t *testing.T) {
+	if err := checkPackageSize("fixture 1.0.0", defaultMaxPackageSize, defaultMaxPackageSize); err != nil {
+		t.Errorf("a crate at the limit should pass, got %v", err)
+	}
+	err := checkPackageSize("fixture 1.0.0", 12<<20+512<<10, defaultMaxPackageSize)
+	want := "packaged fixture 1.0.0 is 12.5 MiB (13107200 bytes), over the max_package_size limit of 10.0 MiB (10485760 bytes)"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %v, want it to start with %q", err, want)
+	}
+	if got := formatSize(1536); got != "1.5 KiB (1536 bytes)" {
+		t.Errorf("formatSize(1536) = %q", got)
+	}
+}
+
+func TestExecutePackageSize(t *testing.T) {
+	const packagingOutput = "   Packaging fixture v1.0.0 (/src/fixture)\n"
+
+	tests := []struct {
+		name         string
+		hook         plugin.Hook
+		config       map[string]any
+		wantSuccess  bool
+		wantSize     bool
+		wantPackaged bool
+	}{
+		{name: "pre-publish under the limit", hook: plugin.HookPrePublish, config: map[string]any{}, wantSuccess: true, wantSize: true, wantPackaged: true},
+		{name: "pre-publish over the limit", hook: plugin.HookPrePublish, config: map[string]any{"max_package_size": 4}, wantSize: true, wantPackaged: true},
+		{name: "pre-publish without verification", hook: plugin.HookPrePublish, config: map[string]any{"max_package_size": 4, "prepublish_verify": false}, wantSize: true, wantPackaged: true},
+		{name: "disabled", hook: plugin.HookPrePublish, config: map[string]any{"max_package_size": 0}, wantSuccess: true},
+		{name: "pinned to 2.0", hook: plugin.HookPrePublish, config: map[string]any{"max_package_size": 4, "compat_level": "2.0", "prepublish_verify": true}, wantSuccess: true},
+		{name: "package_then_publish over the limit", hook: plugin.HookPostPublish, config: map[string]any{"max_package_size": 4, "package_then_publish": true}, wantSize: true, wantPackaged: true},
+		{name: "package_then_publish under the limit", hook: plugin.HookPostPublish, config: map[string]any{"package_then_publish": true}, wantSuccess: true, wantSize: true, wantPackaged: true},
+		{name: "publish over the limit", hook: plugin.HookPostPublish, config: map[string]any{"max_package_size": 4}, wantSize: true, wantPackaged: true},
+		{name: "publish under the limit", hook: plugin.HookPostPublish, config: map[string]any{}, wantSuccess: true, wantSize: true, wantPackaged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetDir := t.TempDir()
+			cratePath := filepath.Join(targetDir, "package", "fixture-1.0.0.crate")
+			if err := os.MkdirAll(filepath.Dir(cratePath), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(cratePath, []byte("crate-bytes"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			packaged, published := false, false
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					switch args[0] {
+					case "package":
+						packaged = true
+						return []byte(packagingOutput), nil
+					case "publish":
+						published = !strings.Contains(strings.Join(args, " "), "--dry-run")
+					}
+					return []byte("ok"), nil
+				},
+			}
+			config := map[string]any{
+				"token":           "test-token",
+				"target_dir":      targetDir,
+				"target_dir_root": filepath.Dir(targetDir),
+			}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    tt.hook,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v, want %v (error: %s)", resp.Success, tt.wantSuccess, resp.Error)
+			}
+			if !tt.wantSuccess {
+				if !strings.Contains(resp.Error, "packaged fixture 1.0.0 is 11 bytes, over the max_package_size limit of 4 bytes") {
+					t.Errorf("unexpected error: %q", resp.Error)
+				}
+				if published {
+					t.Error("expected the crate not to be uploaded")
+				}
+			}
+			if size, ok := resp.Outputs["package_size_bytes"]; ok != tt.wantSize || (ok && size != int64(len("crate-bytes"))) {
+				t.Errorf("package_size_bytes = %v, want recorded: %v", size, tt.wantSize)
+			}
+			if packaged != tt.wantPackaged {
+				t.Errorf("cargo package ran = %v, want %v", packaged, tt.wantPackaged)
+			}
+		})
+	}
+}
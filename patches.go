@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// patchCheckEnabled reports whether publishing refuses manifests with
+// [patch] or [replace] overrides: on at the current compat_level unless
+// allow_patched is set.
+func patchCheckEnabled(cfg *Config) bool {
+	return !cfg.AllowPatched && compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatPatchCheck)
+}
+
+// manifestOverrides lists the [patch] and [replace] entries of the manifest
+// and of its workspace root, each as "[patch.<source>] <crate> in <manifest>".
+// Manifests that cannot be read are skipped; cargo reports them.
+func manifestOverrides(path string) []string {
+	manifests := []string{path}
+	if root, _, err := findWorkspaceRoot(path); err == nil {
+		// Name the root relative to the working directory like the manifest
+		if wd, err := filepath.Abs("."); err == nil && !filepath.IsAbs(path) {
+			if rel, err := filepath.Rel(wd, root); err == nil {
+				root = rel
+			}
+		}
+		manifests = append(manifests, root)
+	}
+
+	var overrides []string
+	for _, manifestPath := range manifests {
+		manifest, err := loadManifest(manifestPath)
+		if err != nil {
+			continue
+		}
+		for _, source := range sortedKeys(manifest.Patch) {
+			for _, crate := range sortedKeys(manifest.Patch[source]) {
+				overrides = append(overrides, fmt.Sprintf("[patch.%s] %s in %s", source, crate, manifestPath))
+			}
+		}
+		for _, spec := range sortedKeys(manifest.Replace) {
+			overrides = append(overrides, fmt.Sprintf("[replace] %s in %s", spec, manifestPath))
+		}
+	}
+	return overrides
+}
+
+// checkPatches fails when the manifest or its workspace root overrides
+// dependencies with [patch] or [replace], which do not apply to the published
+// crate, before cargo spends minutes verifying it.
+func checkPatches(cfg *Config) error {
+	overrides := manifestOverrides(cfg.ManifestPath)
+	if len(overrides) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s is built with dependency overrides the published crate will not have: %s; remove them for the release or move them to .cargo/config.toml, which is not published, or set allow_patched for a registry that accepts them",
+		cfg.ManifestPath, strings.Join(overrides, ", "))
+}
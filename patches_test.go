@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestManifestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	pkg := "[package]\nname = \"x\"\n"
+	root := write("workspace/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n\n[patch.crates-io]\nserde = { path = \"../serde\" }\n\n[replace]\n\"log:0.4.0\" = { git = \"https://example.com/log\" }\n")
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{name: "no overrides", manifest: write("plain/Cargo.toml", pkg)},
+		{
+			name:     "patch sources in order",
+			manifest: write("patched/Cargo.toml", pkg+"\n[patch.\"https://github.com/org/repo\"]\nb = { path = \"b\" }\n\n[patch.crates-io]\nz = { path = \"z\" }\na = { path = \"a\" }\n"),
+			want: []string{
+				"[patch.crates-io] a in " + filepath.Join(dir, "patched", "Cargo.toml"),
+				"[patch.crates-io] z in " + filepath.Join(dir, "patched", "Cargo.toml"),
+				"[patch.https://github.com/org/repo] b in " + filepath.Join(dir, "patched", "Cargo.toml"),
+			},
+		},
+		{
+			name:     "inherited from the workspace root",
+			manifest: write("workspace/member/Cargo.toml", pkg),
+			want:     []string{"[patch.crates-io] serde in " + root, "[replace] log:0.4.0 in " + root},
+		},
+		{name: "unparseable manifest", manifest: write("invalid/Cargo.toml", "[package\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := manifestOverrides(tt.manifest); !slices.Equal(got, tt.want) {
+				t.Errorf("manifestOverrides() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecutePatchedManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := "[package]\nname = \"patched\"\nversion = \"1.0.0\"\ndescription = \"x\"\nlicense = \"MIT\"\n\n[patch.crates-io]\nserde = { path = \"../serde\" }\n"
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	tests := []struct {
+		name        string
+		config      map[string]any
+		dryRun      bool
+		wantSuccess bool
+	}{
+		{name: "publish", config: map[string]any{}},
+		{name: "dry run", config: map[string]any{}, dryRun: true},
+		{name: "allow_patched", config: map[string]any{"allow_patched": true}, dryRun: true, wantSuccess: true},
+		{name: "pinned to 2.0", config: map[string]any{"compat_level": "2.0"}, dryRun: true, wantSuccess: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := map[string]any{"token": "test-token"}
+			for k, v := range tt.config {
+				config[k] = v
+			}
+			mock := &MockCommandExecutor{}
+			p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v, want %v (error: %s)", resp.Success, tt.wantSuccess, resp.Error)
+			}
+			if tt.wantSuccess {
+				return
+			}
+			if !strings.Contains(resp.Error, "Cargo.toml is built with dependency overrides the published crate will not have: [patch.crates-io] serde in Cargo.toml;") {
+				t.Errorf("unexpected error: %q", resp.Error)
+			}
+			decisions, _ := resp.Outputs["decisions"].([]decision)
+			if !slices.ContainsFunc(decisions, func(d decision) bool { return d.Feature == "patch_check" && d.Decision == decisionBlock }) {
+				t.Errorf("expected a patch_check block decision, got %+v", decisions)
+			}
+			for _, call := range mock.GetCalls() {
+				if call.Name == "cargo" {
+					t.Errorf("expected cargo not to run, got %+v", call)
+				}
+			}
+		})
+	}
+}
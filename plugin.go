@@ -3,26 +3,69 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"net/netip"
 	"net/url"
+	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
 	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+	"golang.org/x/net/idna"
 )
 
 // CommandExecutor abstracts command execution for testability.
 type CommandExecutor interface {
 	Run(ctx context.Context, name string, args ...string) ([]byte, error)
 	RunInDir(ctx context.Context, dir string, name string, args ...string) ([]byte, error)
+	// RunWithEnv runs a command in dir (the current directory when empty)
+	// with env appended to the inherited environment.
+	RunWithEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error)
+	// RunInEnv runs a command in dir (the current directory when empty)
+	// with env as its entire environment, inheriting nothing.
+	RunInEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error)
+	// Output runs a command and returns its standard output only. A failed
+	// command returns an *exec.ExitError carrying its standard error.
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+	LookPath(name string) (string, error)
+	// Probe checks that a network endpoint is reachable: network "http"
+	// sends a GET to the address URL, "tcp" dials the host:port address.
+	Probe(ctx context.Context, network, address string) error
+	// FetchStatus sends a GET to url without credentials and returns the
+	// response status code.
+	FetchStatus(ctx context.Context, url string) (int, error)
 }
 
 // RealCommandExecutor executes actual system commands.
-type RealCommandExecutor struct{}
+type RealCommandExecutor struct {
+	// client sends Probe and FetchStatus requests. If nil, uses
+	// http.DefaultClient.
+	client *http.Client
+}
+
+// httpClient returns the client for Probe and FetchStatus.
+func (e *RealCommandExecutor) httpClient() *http.Client {
+	if e.client != nil {
+		return e.client
+	}
+	return http.DefaultClient
+}
 
 // Run executes a command and returns combined output.
 func (e *RealCommandExecutor) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
@@ -37,10 +80,94 @@ func (e *RealCommandExecutor) RunInDir(ctx context.Context, dir string, name str
 	return cmd.CombinedOutput()
 }
 
+// RunWithEnv executes a command with additional environment variables.
+func (e *RealCommandExecutor) RunWithEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.CombinedOutput()
+}
+
+// RunInEnv executes a command with exactly the given environment.
+func (e *RealCommandExecutor) RunInEnv(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	// A nil Env would inherit the parent environment
+	cmd.Env = append([]string{}, env...)
+	return cmd.CombinedOutput()
+}
+
+// Output executes a command and returns its standard output.
+func (e *RealCommandExecutor) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	return cmd.Output()
+}
+
+// LookPath resolves an executable the way Run would.
+func (e *RealCommandExecutor) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+// Probe connects to an endpoint without sending credentials. Any HTTP
+// response below 500 counts as reachable.
+func (e *RealCommandExecutor) Probe(ctx context.Context, network, address string) error {
+	if network == "tcp" {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent(ctx))
+	resp, err := e.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server responded %s", resp.Status)
+	}
+	return nil
+}
+
+// FetchStatus requests a URL and returns the status code, discarding the body.
+func (e *RealCommandExecutor) FetchStatus(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent(ctx))
+	resp, err := e.httpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
 // CratesPlugin implements the Publish crates to crates.io (Rust) plugin.
 type CratesPlugin struct {
 	// cmdExecutor is used for executing shell commands. If nil, uses RealCommandExecutor.
 	cmdExecutor CommandExecutor
+	// sleep waits between publish retries. If nil, uses sleepContext.
+	sleep func(ctx context.Context, d time.Duration) error
+	// now returns the current time. If nil, uses time.Now.
+	now func() time.Time
+	// keyring reads token_keyring entries. If nil, uses the OS keyring.
+	keyring Keyring
+	// httpClient sends trusted publishing requests. If nil, uses a client
+	// bounded by trustedPublishingTimeout.
+	httpClient *http.Client
+	// cratesIOAPI is the crates.io API base URL. If empty, uses cratesIOAPI.
+	cratesIOAPI string
+	// resolver resolves registry hosts. If nil, uses net.DefaultResolver.
+	resolver ipResolver
 }
 
 // getExecutor returns the command executor, defaulting to RealCommandExecutor.
@@ -51,343 +178,2222 @@ func (p *CratesPlugin) getExecutor() CommandExecutor {
 	return &RealCommandExecutor{}
 }
 
+// runCargo runs cargo with the given arguments, in workDir when set. When the
+// cargo binary does not exist the error is errCargoNotFound.
+func (p *CratesPlugin) runCargo(ctx context.Context, cfg *Config, workDir string, args ...string) ([]byte, error) {
+	return p.runCargoWithEnv(ctx, cfg, workDir, nil, args...)
+}
+
+// runCargoWithEnv is runCargo with extra environment variables for cargo.
+// They are layered over the ambient environment with the env config entries
+// first, then the proxy settings, then the plugin's own variables so those
+// win, and under env_mode clean or allowlist cargo gets only childEnv in place
+// of the ambient environment.
+func (p *CratesPlugin) runCargoWithEnv(ctx context.Context, cfg *Config, workDir string, env []string, args ...string) ([]byte, error) {
+	executor := p.getExecutor()
+	binary := cargoBinary(cfg)
+	env = append(append(envEntries(cfg.Env), proxyEnv(cfg)...), env...)
+
+	var output []byte
+	var err error
+	switch {
+	case cfg.EnvMode == envModeClean || cfg.EnvMode == envModeAllowlist:
+		output, err = executor.RunInEnv(ctx, workDir, append(childEnv(cfg, os.Environ(), runtime.GOOS == "windows"), env...), binary, args...)
+	case len(env) > 0:
+		output, err = executor.RunWithEnv(ctx, workDir, env, binary, args...)
+	case workDir != "":
+		output, err = executor.RunInDir(ctx, workDir, binary, args...)
+	default:
+		output, err = executor.Run(ctx, binary, args...)
+	}
+	if err != nil && isCargoNotFound(err, binary) {
+		return output, errCargoNotFound
+	}
+	return output, err
+}
+
 // Config represents the Crates plugin configuration.
 type Config struct {
-	Token             string
-	Registry          string
-	AllowDirty        bool
-	NoVerify          bool
-	ManifestPath      string
-	Features          []string
-	AllFeatures       bool
-	NoDefaultFeatures bool
-	Jobs              int
+	Token              string
+	TokenSource        string
+	Tokens             map[string]string
+	TokenEnv           string
+	Registry           string
+	Index              string
+	RegistryIndex      string
+	AllowDirty         bool
+	NoVerify           bool
+	ManifestPath       string
+	Features           []string
+	AllFeatures        bool
+	NoDefaultFeatures  bool
+	Jobs               int
+	RateLimitMaxWait   time.Duration
+	QuotaWarnThreshold int
+	QuotaStateFile     string
+	PublishTimeout     time.Duration
+	Metrics            MetricsConfig
+	Locked             bool
+	Offline            bool
+	Frozen             bool
+	Target             string
+	TargetDir          string
+	TargetDirRoot      string
+	CargoConfig        map[string]string
+	EnvMode            string
+	Env                map[string]string
+	EnvAllowlist       []string
+	AuditLog           string
+	ExtraArgs          []string
+	UnstableFlags      []string
+	Quiet              bool
+	Verbose            int
+	Toolchain          string
+	CargoPath          string
+	MinCargoVersion    string
+	PackageThenPublish bool
+	ExecuteDryRun      bool
+	PrePublishVerify   bool
+	SkipMetadataCheck  bool
+	AllowPatched       bool
+	DenyPrereleaseDeps bool
+	SkipPreflight      bool
+	ReportLicenses     bool
+	ForbiddenLicenses  []string
+	PackageCheck       string
+	RequiredPaths      []string
+	MinPackageFiles    int
+	MaxPackageSize     int
+	MustInclude        []string
+	MustNotInclude     []string
+	PostPublishWait    time.Duration
+	TokenViaEnv        bool
+	CredentialProvider string
+	TokenFile          string
+	TokenFileRoot      string
+	TokenCommand       []string
+	TokenKeyring       *keyringEntry
+	TokenCmdTimeout    time.Duration
+	CrateName          string
+	TempDir            string
+	FailurePolicy      string
+	CompatLevel        string
+	CompatFeatures     []string
+	StrictConfig       bool
+	AliasesUsed        []aliasUse
+	ExplicitKeys       map[string]bool
+	CodeArtifact       *codeArtifactConfig
+	TrustedPublishing  bool
+	ForbidInlineToken  bool
+	AllowNewCrate      bool
+	RegistryPorts      []int
+	InsecureRegistry   bool
+	InsecureHosts      []string
+	AllowedNetworks    []string
+	DNSTimeout         time.Duration
+	SkipDNSCheck       bool
+	CheckConnectivity  bool
+	RegistryCACert     string
+	ClientCert         string
+	ClientKey          string
+	Proxy              string
+	NoProxy            []string
+	NetRetry           *int
+	GitFetchWithCLI    bool
+	RegistryDefined    bool
+	Registries         []registryTarget
+	RegistriesPolicy   string
+	ChannelRegistries  map[string]string
+	// ChannelSelection is the registry channel_registries selected for the
+	// release; nil without channel_registries.
+	ChannelSelection *channelSelection
+	// TokenExpiresAt is when a CodeArtifact token expires; zero otherwise.
+	TokenExpiresAt time.Time
+	// RegistryConfigFile is the generated registry_index config file.
+	RegistryConfigFile string
+	// RegistryCAs trusts registry_ca_cert for the plugin's own requests.
+	RegistryCAs *x509.CertPool
+	// ClientCertificate is the client_cert key pair for the plugin's own
+	// requests.
+	ClientCertificate *tls.Certificate
 }
 
+// targetDirTemp is the target_dir sentinel requesting a per-run temporary directory.
+const targetDirTemp = "temp"
+
+// pluginVersion is the plugin version reported by GetInfo and in the
+// User-Agent of HTTP requests.
+const pluginVersion = "2.0.0"
+
 // GetInfo returns plugin metadata.
 func (p *CratesPlugin) GetInfo() plugin.Info {
 	return plugin.Info{
 		Name:        "crates",
-		Version:     "2.0.0",
+		Version:     pluginVersion,
 		Description: "Publish crates to crates.io (Rust)",
 		Author:      "Relicta Team",
 		Hooks: []plugin.Hook{
+			plugin.HookPrePublish,
 			plugin.HookPostPublish,
 		},
-		ConfigSchema: `{
-			"type": "object",
-			"properties": {
-				"token": {"type": "string", "description": "Crates.io API token (or use CARGO_REGISTRY_TOKEN env)"},
-				"registry": {"type": "string", "description": "Registry to publish to (optional, for private registries)"},
-				"allow_dirty": {"type": "boolean", "description": "Allow publishing with uncommitted changes", "default": false},
-				"no_verify": {"type": "boolean", "description": "Skip crate verification", "default": false},
-				"manifest_path": {"type": "string", "description": "Path to Cargo.toml", "default": "Cargo.toml"},
-				"features": {"type": "array", "items": {"type": "string"}, "description": "Features to activate"},
-				"all_features": {"type": "boolean", "description": "Activate all available features", "default": false},
-				"no_default_features": {"type": "boolean", "description": "Do not activate the default feature", "default": false},
-				"jobs": {"type": "integer", "description": "Number of parallel jobs"}
-			}
-		}`,
+		ConfigSchema: infoConfigSchema(),
 	}
 }
 
+// correlationIDEnvKeys lists the release context environment keys that may
+// carry a host-provided correlation ID, in order of preference.
+var correlationIDEnvKeys = []string{"RELICTA_CORRELATION_ID", "RELICTA_RELEASE_ID"}
+
 // Execute runs the plugin for a given hook.
 func (p *CratesPlugin) Execute(ctx context.Context, req plugin.ExecuteRequest) (*plugin.ExecuteResponse, error) {
 	cfg := p.parseConfig(req.Config)
+	cfg = p.applyChannelRegistry(req.Config, cfg, req.Context)
+	correlationID := resolveCorrelationID(req.Context)
+	ctx = withCorrelationID(ctx, correlationID)
+
+	// Record every command of this execution when audit_log is set
+	run := p
+	var audit *auditExecutor
+	var auditWarnings []string
+	if validateAuditLog(cfg.AuditLog) == nil {
+		var executor CommandExecutor
+		var err error
+		executor, audit, err = openAuditLog(p.getExecutor(), cfg, correlationID, p.getNow)
+		if err != nil {
+			auditWarnings = append(auditWarnings, err.Error())
+		}
+		if audit != nil {
+			audited := *p
+			audited.cmdExecutor = executor
+			run = &audited
+		}
+	}
 
+	var resp *plugin.ExecuteResponse
+	var err error
+	var decisions decisionLog
 	switch req.Hook {
+	case plugin.HookPrePublish:
+		resp, err = run.prePublish(ctx, cfg, req.Context, req.DryRun, &decisions)
 	case plugin.HookPostPublish:
-		return p.publish(ctx, cfg, req.Context, req.DryRun)
+		if len(cfg.Registries) > 0 {
+			resp, err = run.publishRegistries(ctx, req.Config, cfg, req.Context, req.DryRun, &decisions)
+		} else {
+			resp, err = run.publish(ctx, cfg, req.Context, req.DryRun, &decisions)
+		}
 	default:
-		return &plugin.ExecuteResponse{
+		decisions.add("hook "+string(req.Hook), decisionSkip, "the plugin does not act on this hook", "hooks", "")
+		resp = &plugin.ExecuteResponse{
 			Success: true,
 			Message: fmt.Sprintf("Hook %s not handled", req.Hook),
-		}, nil
+			Outputs: map[string]any{
+				"unhandled": true,
+			},
+		}
+	}
+
+	if audit != nil {
+		if w := audit.close(); w != "" {
+			auditWarnings = append(auditWarnings, w)
+		}
+	}
+
+	if resp != nil {
+		if resp.Outputs == nil {
+			resp.Outputs = map[string]any{}
+		}
+		resp.Outputs["correlation_id"] = correlationID
+		addAliasOutputs(cfg.AliasesUsed, resp.Outputs)
+		p.addChannelOutputs(cfg, resp.Outputs)
+		p.revokeTrustedPublishing(ctx, cfg, resp.Outputs)
+		if audit != nil {
+			resp.Outputs["audit_log"] = cfg.AuditLog
+		}
+		if len(auditWarnings) > 0 {
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			resp.Outputs["warnings"] = append(warnings, auditWarnings...)
+		}
+
+		if !resp.Success && cfg.FailurePolicy == failurePolicySoft && !strings.HasPrefix(resp.Error, errConfigValidation) {
+			decisions.add("hook "+string(req.Hook), decisionSkip, "failure reported as a warning instead of failing the release", "failure_policy", "failure_policy")
+			softenFailure(resp)
+		}
+		decisions.addOutputs(resp.Outputs)
+
+		// Nothing leaves the plugin with a token in it
+		newScrubber(knownSecrets(cfg)).scrubResponse(resp)
+	}
+
+	return resp, err
+}
+
+// Failure policies select whether an execution failure fails the release.
+const (
+	failurePolicyHard = "hard"
+	failurePolicySoft = "soft"
+)
+
+// errConfigValidation prefixes configuration errors found during execution.
+// They are reported even under failure_policy: soft, like Validate errors.
+const errConfigValidation = "configuration validation failed"
+
+// softenFailure turns a failed response into a successful one flagged with
+// soft_failed, keeping the original error in outputs and as a warning.
+func softenFailure(resp *plugin.ExecuteResponse) {
+	failure := resp.Error
+	summary, _, _ := strings.Cut(failure, "\n")
+
+	warnings, _ := resp.Outputs["warnings"].([]string)
+	resp.Outputs["warnings"] = append(warnings, "publish failed (failure_policy: soft): "+summary)
+	resp.Outputs["soft_failed"] = true
+	resp.Outputs["error"] = failure
+
+	resp.Success = true
+	resp.Message = "WARNING: crate publish failed, continuing because failure_policy is soft: " + summary
+	resp.Error = ""
+}
+
+// resolveCorrelationID returns the host-provided correlation ID from the
+// release context, generating a random UUID when none is present.
+func resolveCorrelationID(releaseCtx plugin.ReleaseContext) string {
+	for _, key := range correlationIDEnvKeys {
+		if id := strings.TrimSpace(releaseCtx.Environment[key]); id != "" {
+			return id
+		}
+	}
+	return newUUID()
+}
+
+// correlationIDKey is the context key carrying the execution's correlation ID.
+type correlationIDKey struct{}
+
+// withCorrelationID returns ctx carrying the correlation ID, so HTTP requests
+// made during the execution can send it to the registry.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// userAgent returns the User-Agent for HTTP requests made with ctx, naming
+// the correlation ID of the execution when ctx carries one.
+func userAgent(ctx context.Context) string {
+	agent := "relicta-plugin-crates/" + pluginVersion
+	if id, _ := ctx.Value(correlationIDKey{}).(string); id != "" {
+		agent += " (correlation_id=" + id + ")"
 	}
+	return agent
+}
+
+// newUUID generates a random RFC 4122 version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
 }
 
 // publish executes the cargo publish command.
-func (p *CratesPlugin) publish(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool) (*plugin.ExecuteResponse, error) {
-	// Validate configuration
+func (p *CratesPlugin) publish(ctx context.Context, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool, decisions *decisionLog) (*plugin.ExecuteResponse, error) {
+	metricsCrate, _ := publishCrateName(cfg)
+	metrics := newMetricsClient(cfg.Metrics, p.getRegistryName(cfg), metricsCrate)
+	defer metrics.close()
+
+	// Validate configuration, then the addresses the registry host resolves to
 	if err := p.validateConfig(cfg); err != nil {
+		metrics.publishFailed("config_invalid")
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("configuration validation failed: %v", err),
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
+		}, nil
+	}
+	if cfg.SkipDNSCheck {
+		decisions.add("registry DNS check", decisionSkip, "disabled by skip_dns_check", "dns_check", "skip_dns_check")
+	}
+	dnsWarnings, err := p.checkRegistryHosts(ctx, cfg)
+	if err != nil {
+		metrics.publishFailed("config_invalid")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
 		}, nil
 	}
 
-	// Build cargo publish command arguments
-	args := p.buildPublishArgs(cfg)
-
-	version := strings.TrimPrefix(releaseCtx.Version, "v")
+	// Trust registry_ca_cert and present client_cert in the plugin's own
+	// requests to the registry
+	if cfg.RegistryCAs, err = loadRegistryCACert(cfg.RegistryCACert); err == nil {
+		cfg.ClientCertificate, err = loadClientCert(cfg.ClientCert, cfg.ClientKey)
+	}
+	if err != nil {
+		metrics.publishFailed("config_invalid")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
+		}, nil
+	}
 
-	if dryRun {
+	// A named registry missing from cargo's configuration fails here rather
+	// than deep inside cargo
+	if err := checkRegistryDefined(cfg, manifestWorkDir(cfg)); err != nil {
+		metrics.publishFailed("config_invalid")
 		return &plugin.ExecuteResponse{
-			Success: true,
-			Message: fmt.Sprintf("Would publish crate version %s to %s", version, p.getRegistryName(cfg)),
-			Outputs: map[string]any{
-				"version":       version,
-				"registry":      cfg.Registry,
-				"manifest_path": cfg.ManifestPath,
-				"allow_dirty":   cfg.AllowDirty,
-				"no_verify":     cfg.NoVerify,
-				"command":       "cargo publish " + strings.Join(args, " "),
-			},
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
 		}, nil
 	}
 
-	// Check if token is available
-	if cfg.Token == "" {
+	// Read the token from token_file or token_command unless one was configured directly
+	if err := p.resolveToken(ctx, cfg); err != nil {
+		metrics.publishFailed("token_source")
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   "no API token provided: set token in config or CARGO_REGISTRY_TOKEN environment variable",
+			Error:   err.Error(),
 		}, nil
 	}
 
-	// Execute cargo publish
-	executor := p.getExecutor()
+	// Build cargo publish command arguments
+	args := p.buildPublishArgs(cfg)
 
-	// Determine working directory from manifest path
-	workDir := ""
-	if cfg.ManifestPath != "" && cfg.ManifestPath != "Cargo.toml" {
-		workDir = filepath.Dir(cfg.ManifestPath)
+	release, err := parseReleaseVersion(releaseCtx.Version)
+	if err != nil {
+		metrics.publishFailed("version_invalid")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("invalid release version: %v", err),
+		}, nil
+	}
+	version := release.String()
+	subject := releaseSubject(cfg, version)
+	crate, crateWarning := publishCrateName(cfg)
+	label := crateLabel(crate, version)
+
+	// Refuse to upload a manifest whose version differs from the release
+	if !compatEnabled(cfg.CompatLevel, cfg.CompatFeatures, compatManifestVersionCheck) {
+		decisions.add(subject, decisionSkip, "compat_level "+cfg.CompatLevel+" predates the manifest version check", "manifest_version_check", "compat_level")
+	} else if err := p.checkManifestVersion(ctx, cfg, release); err != nil {
+		metrics.publishFailed("version_mismatch")
+		decisions.add(subject, decisionBlock, "manifest version does not match the release version", "manifest_version_check", "manifest_path")
+		var outputs map[string]any
+		var mismatch *manifestVersionError
+		if errors.As(err, &mismatch) && mismatch.repo != nil {
+			outputs = map[string]any{}
+			mismatch.repo.addOutputs(outputs)
+		}
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+			Outputs: outputs,
+		}, nil
 	}
 
-	var output []byte
-	var err error
-	if workDir != "" {
-		output, err = executor.RunInDir(ctx, workDir, "cargo", args...)
-	} else {
-		output, err = executor.Run(ctx, "cargo", args...)
+	// Refuse to build a crate crates.io would reject for its metadata
+	manifestWarnings, err := p.metadataCheck(cfg, subject, decisions)
+	if err != nil {
+		metrics.publishFailed("metadata_missing")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
 	}
 
+	// Or for the version requirements of its dependencies
+	dependencies, err := p.dependencyCheck(cfg, release, subject, decisions)
 	if err != nil {
+		metrics.publishFailed("dependency_requirement")
+		outputs := map[string]any{}
+		dependencies.addOutputs(outputs)
 		return &plugin.ExecuteResponse{
 			Success: false,
-			Error:   fmt.Sprintf("cargo publish failed: %v\nOutput: %s", err, string(output)),
+			Error:   err.Error(),
+			Outputs: outputs,
 		}, nil
 	}
+	if dependencies != nil {
+		manifestWarnings = append(manifestWarnings, dependencies.warnings...)
+	}
 
-	return &plugin.ExecuteResponse{
-		Success: true,
-		Message: fmt.Sprintf("Published crate version %s to %s", version, p.getRegistryName(cfg)),
-		Outputs: map[string]any{
-			"version":  version,
-			"registry": cfg.Registry,
-			"output":   string(output),
-		},
-	}, nil
-}
+	// Declare registry_index in a temporary cargo config for this hook
+	cleanupRegistryConfig, err := writeRegistryConfig(cfg)
+	if err != nil {
+		metrics.publishFailed("registry_config")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	defer cleanupRegistryConfig()
+	if cfg.RegistryConfigFile != "" {
+		args = p.buildPublishArgs(cfg)
+	}
 
-// buildPublishArgs constructs the cargo publish command arguments.
-func (p *CratesPlugin) buildPublishArgs(cfg *Config) []string {
-	args := []string{"publish"}
+	if dryRun {
+		// Report a missing readme and overrides as the preflight would
+		readme, err := checkReadme(cfg)
+		if err != nil {
+			decisions.add(subject, decisionBlock, "readme set by the manifest does not exist", "preflight", "manifest_path")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("preflight check failed: %v", err),
+			}, nil
+		}
+		if patchCheckEnabled(cfg) {
+			if err := checkPatches(cfg); err != nil {
+				decisions.add(subject, decisionBlock, "manifest has [patch] or [replace] overrides", "patch_check", "allow_patched")
+				return &plugin.ExecuteResponse{
+					Success: false,
+					Error:   fmt.Sprintf("preflight check failed: %v", err),
+				}, nil
+			}
+		}
 
-	// Token is passed via argument (cargo handles it securely)
-	if cfg.Token != "" {
-		args = append(args, "--token", cfg.Token)
-	}
+		outputs := map[string]any{
+			"target_dir":       cfg.TargetDir,
+			"version":          version,
+			"registry":         cfg.Registry,
+			"index":            cfg.Index,
+			"manifest_path":    cfg.ManifestPath,
+			"allow_dirty":      cfg.AllowDirty,
+			"no_verify":        cfg.NoVerify,
+			"locked":           cfg.Locked,
+			"offline":          cfg.Offline,
+			"frozen":           cfg.Frozen,
+			"target":           cfg.Target,
+			"toolchain":        cfg.Toolchain,
+			"command":          formatCommand(cfg, args),
+			"dry_run_mode":     dryRunSimulated,
+			"auth":             authMechanism(cfg),
+			"effective_config": p.explainConfig(cfg),
+		}
+		if crate != "" {
+			outputs["crate_name"] = crate
+		}
+		dependencies.addOutputs(outputs)
+		if readme != "" {
+			outputs["readme"] = readme
+		}
+		if cfg.SkipDNSCheck {
+			outputs["dns_check"] = dnsCheckSkipped
+		}
+		addRegistryConfigOutputs(cfg, outputs)
+		if cfg.RegistryCACert != "" {
+			outputs["registry_ca_cert"] = registryCACertPath(cfg)
+		}
+		if cfg.ClientCert != "" {
+			outputs["client_cert"] = cfg.ClientCert
+		}
+		if cfg.Proxy != "" {
+			outputs["proxy"] = redactProxy(cfg.Proxy)
+			outputs["no_proxy"] = cfg.NoProxy
+		}
+		addCargoNetOutputs(cfg, outputs)
+		dryRunWarnings := manifestWarnings
+		if crateWarning != "" {
+			dryRunWarnings = append(dryRunWarnings, crateWarning)
+		}
+		if w := crateNameMismatch(cfg); w != "" {
+			dryRunWarnings = append(dryRunWarnings, w)
+		}
+		if len(dryRunWarnings) > 0 {
+			outputs["warnings"] = dryRunWarnings
+		}
+		if cfg.PackageThenPublish {
+			publishCfg := *cfg
+			publishCfg.NoVerify = true
+			outputs["package_command"] = formatCommand(cfg, p.buildPackageArgs(cfg))
+			outputs["command"] = formatCommand(cfg, p.buildPublishArgs(&publishCfg))
+		}
 
-	// Registry for private registries
-	if cfg.Registry != "" {
-		args = append(args, "--registry", cfg.Registry)
-	}
+		if !cfg.ExecuteDryRun {
+			decisions.add(subject, decisionSkip, "host dry run; the publish command was only rendered", "dry_run", "execute_dry_run")
+			return &plugin.ExecuteResponse{
+				Success: true,
+				Message: fmt.Sprintf("Would publish %s to %s (%s)", label, p.getRegistryName(cfg), authDescription(cfg)),
+				Outputs: outputs,
+			}, nil
+		}
 
-	// Allow dirty working directory
-	if cfg.AllowDirty {
-		args = append(args, "--allow-dirty")
+		// Let cargo package and verify the crate without uploading it
+		decisions.add(subject, decisionSkip, "host dry run; ran cargo publish --dry-run instead of uploading", "dry_run", "execute_dry_run")
+		dryRunArgs, output, err := p.runPublishDryRun(ctx, cfg)
+		outputs["dry_run_mode"] = dryRunVerified
+		outputs["command"] = formatCommand(cfg, dryRunArgs)
+		outputs["output"] = string(output)
+		if errors.Is(err, errCargoNotFound) {
+			return cargoNotFoundResponse(outputs), nil
+		}
+		if err != nil {
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s: %v\nOutput: %s", crateFailure("cargo publish --dry-run", crate, version), err, string(output)),
+				Outputs: outputs,
+			}, nil
+		}
+		return &plugin.ExecuteResponse{
+			Success: true,
+			Message: fmt.Sprintf("Verified %s for %s with cargo publish --dry-run", label, p.getRegistryName(cfg)),
+			Outputs: outputs,
+		}, nil
 	}
 
-	// Skip verification
-	if cfg.NoVerify {
-		args = append(args, "--no-verify")
+	// Exchange the OIDC token only now, so dry runs never create a token
+	if err := p.resolveTrustedPublishing(ctx, cfg); err != nil {
+		metrics.publishFailed("token_source")
+		decisions.add(subject, decisionBlock, "trusted publishing token exchange failed", "trusted_publishing", "trusted_publishing")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
 	}
-
-	// Manifest path
-	if cfg.ManifestPath != "" && cfg.ManifestPath != "Cargo.toml" {
-		args = append(args, "--manifest-path", cfg.ManifestPath)
+	if cfg.TokenSource == tokenSourceTrustedPublishing {
+		args = p.buildPublishArgs(cfg)
 	}
 
-	// Features
-	if len(cfg.Features) > 0 {
-		args = append(args, "--features", strings.Join(cfg.Features, ","))
+	// Check the environment before invoking cargo
+	checks := p.preflight(cfg)
+	if err := checks.err(); err != nil {
+		metrics.publishFailed(checks.failureKind())
+		checks.addDecisions(decisions, subject)
+		outputs := map[string]any{}
+		checks.addOutputs(outputs)
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+			Outputs: outputs,
+		}, nil
 	}
 
-	// All features
-	if cfg.AllFeatures {
-		args = append(args, "--all-features")
+	// Fail fast when the registry cannot be reached, before the verify build
+	reach := p.checkRegistryReachable(ctx, cfg)
+	if reach.endpoint != "" {
+		metrics.timing("phase.duration", reach.latency, "phase:registry_preflight")
 	}
-
-	// No default features
-	if cfg.NoDefaultFeatures {
-		args = append(args, "--no-default-features")
+	if reach.status == registrySkipped {
+		decisions.add("registry preflight", decisionSkip, reach.reason, "registry_preflight", "skip_preflight")
 	}
-
-	// Parallel jobs
-	if cfg.Jobs > 0 {
-		args = append(args, "--jobs", fmt.Sprintf("%d", cfg.Jobs))
+	if reach.err != nil {
+		metrics.publishFailed("network")
+		decisions.add(subject, decisionBlock, "registry endpoint is unreachable", "registry_preflight", "skip_preflight")
+		outputs := map[string]any{}
+		checks.addOutputs(outputs)
+		reach.addOutputs(outputs)
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   reach.err.Error(),
+			Outputs: outputs,
+		}, nil
 	}
 
-	return args
-}
-
-// getRegistryName returns a human-readable registry name.
-func (p *CratesPlugin) getRegistryName(cfg *Config) string {
-	if cfg.Registry != "" {
-		return cfg.Registry
+	// Resolve the build directory before building the final arguments
+	targetDir, cleanupTargetDir, err := p.resolveTargetDir(ctx, cfg)
+	if err != nil {
+		metrics.publishFailed("target_dir")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	defer cleanupTargetDir()
+	if targetDir != cfg.TargetDir {
+		cfg.TargetDir = targetDir
+		args = p.buildPublishArgs(cfg)
 	}
-	return "crates.io"
-}
 
-// validateConfig validates the plugin configuration for security issues.
-func (p *CratesPlugin) validateConfig(cfg *Config) error {
-	// Validate manifest path
-	if err := validatePath(cfg.ManifestPath); err != nil {
-		return fmt.Errorf("invalid manifest_path: %w", err)
+	// Determine working directory from manifest path. Cargo resolves
+	// --manifest-path against the working directory, so the argument is
+	// rebased onto it; workspace discovery then starts at the manifest itself.
+	workDir := manifestWorkDir(cfg)
+	if workDir != "" {
+		args = rebaseManifestPath(args, workDir)
 	}
 
-	// Validate registry URL if provided
-	if cfg.Registry != "" {
-		if err := validateRegistryURL(cfg.Registry); err != nil {
-			return fmt.Errorf("invalid registry: %w", err)
+	// Fail early when cargo is too old for the configured features
+	var cargoVer cargoVersion
+	if cfg.MinCargoVersion != "" {
+		cargoVer, err = p.checkCargoVersion(ctx, cfg, workDir)
+		if errors.Is(err, errCargoNotFound) {
+			metrics.publishFailed("cargo_not_found")
+			return cargoNotFoundResponse(nil), nil
+		}
+		if err != nil {
+			metrics.publishFailed("cargo_version")
+			decisions.add(subject, decisionBlock, err.Error(), "min_cargo_version", "min_cargo_version")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
 		}
 	}
 
-	return nil
-}
-
-// validatePath validates a file path to prevent path traversal.
-func validatePath(path string) error {
-	if path == "" {
-		return nil
+	warnings := append(dnsWarnings, manifestWarnings...)
+	if crateWarning != "" {
+		warnings = append(warnings, crateWarning)
+	}
+	if reach.warning != "" {
+		warnings = append(warnings, reach.warning)
+	}
+	if w := crateNameMismatch(cfg); w != "" {
+		warnings = append(warnings, w)
 	}
 
-	// Clean the path
-	cleaned := filepath.Clean(path)
+	// Refuse to publish a crate the registry does not know unless asked to
+	newCrate := p.checkNewCrate(ctx, cfg, subject, decisions)
+	if newCrate.err != nil {
+		metrics.publishFailed("new_crate")
+		decisions.add(subject, decisionBlock, "crate does not exist on the registry yet", "new_crate_check", "allow_new_crate")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   newCrate.err.Error(),
+		}, nil
+	}
+	if newCrate.warning != "" {
+		warnings = append(warnings, newCrate.warning)
+	}
 
-	// Check for absolute paths (potential escape from working directory)
-	if filepath.IsAbs(cleaned) {
-		return fmt.Errorf("absolute paths are not allowed")
+	// Track publishes per token to warn before registry quotas are exhausted
+	quota, err := p.newQuotaTracker(cfg)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("publish quota tracking disabled: %v", err))
+	}
+	if quota != nil {
+		if w := quota.warning(); w != "" {
+			warnings = append(warnings, w)
+		}
 	}
 
-	// Check for path traversal attempts
-	if strings.HasPrefix(cleaned, "..") || strings.Contains(cleaned, string(filepath.Separator)+"..") {
-		return fmt.Errorf("path traversal detected: cannot use '..' to escape working directory")
+	// Execute cargo publish, bounded by the optional publish timeout
+	publishCtx := ctx
+	if cfg.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		publishCtx, cancel = context.WithTimeout(ctx, cfg.PublishTimeout)
+		defer cancel()
 	}
 
-	return nil
-}
+	// Package once with verification, then upload without rebuilding
+	var packaged *packagedCrate
+	if cfg.PackageThenPublish {
+		packageArgs := p.buildPackageArgs(cfg)
+		if workDir != "" {
+			packageArgs = rebaseManifestPath(packageArgs, workDir)
+		}
+
+		var packageOutput []byte
+		packaged, packageOutput, err = p.runCargoPackage(publishCtx, cfg, workDir, packageArgs)
+		if errors.Is(err, errCargoNotFound) {
+			metrics.publishFailed("cargo_not_found")
+			return cargoNotFoundResponse(nil), nil
+		}
+		if err != nil {
+			metrics.publishFailed("package_failed")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s: %v\nOutput: %s", crateFailure("cargo package", crate, version), err, string(packageOutput)),
+			}, nil
+		}
+		if !cfg.NoVerify {
+			decisions.add("cargo publish verification", decisionSkip, "the crate was already verified by cargo package", "package_then_publish", "package_then_publish")
+		}
+		cfg.NoVerify = true
+		args = p.buildPublishArgs(cfg)
+		if workDir != "" {
+			args = rebaseManifestPath(args, workDir)
+		}
+	}
+
+	// Refuse to upload a crate over the registry's size limit, measuring it
+	// with cargo package --no-verify when it was not packaged above
+	measured := packaged
+	if measured == nil && packageSizeCheckEnabled(cfg) {
+		measured, err = p.measurePackage(publishCtx, cfg, workDir)
+		if errors.Is(err, errCargoNotFound) {
+			metrics.publishFailed("cargo_not_found")
+			return cargoNotFoundResponse(nil), nil
+		}
+		if err != nil {
+			// cargo publish reports the packaging failure itself
+			warnings = append(warnings, fmt.Sprintf("package size not checked: %v", err))
+		}
+	}
+	if measured != nil && packageSizeCheckEnabled(cfg) {
+		if err := checkPackageSize(label, measured.size, cfg.MaxPackageSize); err != nil {
+			metrics.publishFailed("package_too_large")
+			decisions.add(subject, decisionBlock, "packaged crate is over max_package_size", "package_size_check", "max_package_size")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   err.Error(),
+				Outputs: map[string]any{"package_size_bytes": measured.size},
+			}, nil
+		}
+	}
+
+	start := p.getNow()
+	output, stats, err := p.runCargoPublish(publishCtx, cfg, workDir, args)
+	metrics.timing("phase.duration", p.getNow().Sub(start)-stats.waited, "phase:cargo_publish")
+	if stats.retries > 0 {
+		metrics.incr("publish.retries", stats.retries)
+		metrics.timing("phase.duration", stats.waited, "phase:rate_limit_wait")
+		decisions.add(subject, decisionDefer, fmt.Sprintf("registry rate limited the upload; retried %d time(s) after waiting %s", stats.retries, stats.waited), "rate_limit_retry", "rate_limit_max_wait")
+	}
+
+	if err != nil && cfg.PublishTimeout > 0 && errors.Is(publishCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		metrics.publishFailed("timeout")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("cargo publish of %s timed out after %s\nPartial output: %s", label, cfg.PublishTimeout, string(output)),
+		}, nil
+	}
+	if errors.Is(err, errCargoNotFound) {
+		metrics.publishFailed("cargo_not_found")
+		return cargoNotFoundResponse(nil), nil
+	}
+	if err != nil {
+		var rlErr *rateLimitError
+		if errors.As(err, &rlErr) {
+			msg := err.Error()
+			if quota != nil {
+				msg += "; " + quota.quotaGuidance()
+			}
+			metrics.publishFailed("rate_limited")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%s\nOutput: %s", msg, string(output)),
+			}, nil
+		}
+		if expired := p.tokenExpiredError(cfg); expired != nil {
+			metrics.publishFailed("token_expired")
+			return &plugin.ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("%v\nOutput: %s", expired, string(output)),
+			}, nil
+		}
+		metrics.publishFailed("cargo_failed")
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v\nOutput: %s", crateFailure("cargo publish", crate, version), err, string(output)),
+		}, nil
+	}
+	metrics.incr("publish.success", 1)
+
+	outputs := map[string]any{
+		"version":  version,
+		"registry": cfg.Registry,
+		"output":   string(output),
+	}
+	if cfg.Quiet {
+		outputs["output"] = tailLines(string(output), quietOutputLines)
+	}
+
+	checks.addOutputs(outputs)
+	reach.addOutputs(outputs)
+	if cfg.SkipDNSCheck {
+		outputs["dns_check"] = dnsCheckSkipped
+	}
+	addRegistryConfigOutputs(cfg, outputs)
+
+	if crate != "" {
+		outputs["crate_name"] = crate
+	}
+	dependencies.addOutputs(outputs)
+
+	if cfg.TargetDir != "" {
+		outputs["target_dir"] = cfg.TargetDir
+	}
+
+	if cfg.Toolchain != "" {
+		outputs["toolchain"] = cfg.Toolchain
+	}
+
+	if cfg.MinCargoVersion != "" {
+		outputs["cargo_version"] = cargoVer.String()
+	}
+
+	if packaged != nil {
+		outputs["crate_file"] = packaged.path
+		outputs["crate_size"] = packaged.size
+	}
+	if measured != nil {
+		outputs["package_size_bytes"] = measured.size
+	}
+
+	// Record the linked dependency licenses for the release record
+	if cfg.ReportLicenses {
+		if report, err := p.auditLicenses(ctx, cfg); err != nil {
+			warnings = append(warnings, fmt.Sprintf("dependency license report unavailable: %v", err))
+		} else {
+			report.addOutputs(outputs)
+		}
+	}
+
+	if quota != nil {
+		if err := quota.record(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("failed to record publish for quota tracking: %v", err))
+		}
+		outputs["quota_publish_count"] = quota.count()
+	}
+
+	// Give the index a fixed buffer to propagate before later steps run
+	if cfg.PostPublishWait > 0 {
+		decisions.add(subject, decisionDefer, fmt.Sprintf("waiting %s after publishing before returning", cfg.PostPublishWait), "post_publish_wait", "post_publish_wait")
+		waitStart := p.getNow()
+		waited := cfg.PostPublishWait
+		if err := p.getSleeper()(ctx, cfg.PostPublishWait); err != nil {
+			waited = p.getNow().Sub(waitStart)
+			warnings = append(warnings, fmt.Sprintf("post_publish_wait interrupted after %s: %v", waited, err))
+		}
+		outputs["post_publish_waited"] = waited.String()
+	}
+
+	if len(warnings) > 0 {
+		outputs["warnings"] = warnings
+	}
+
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("Published %s to %s", label, p.getRegistryName(cfg)),
+		Outputs: outputs,
+	}, nil
+}
+
+// quietOutputLines is the number of trailing output lines kept on success when quiet is set.
+const quietOutputLines = 5
+
+// tailLines returns the last n non-empty lines of s.
+func tailLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// buildPublishArgs constructs the cargo publish command arguments.
+func (p *CratesPlugin) buildPublishArgs(cfg *Config) []string {
+	return p.buildCargoArgs(cfg, "publish")
+}
+
+// buildCargoArgs constructs the arguments for a cargo subcommand that shares
+// the publish flags, such as publish and package.
+func (p *CratesPlugin) buildCargoArgs(cfg *Config, subcommand string) []string {
+	var args []string
+
+	// Toolchain override must precede the subcommand
+	if cfg.Toolchain != "" {
+		args = append(args, "+"+cfg.Toolchain)
+	}
+	args = append(args, subcommand)
+
+	// Token is passed via argument unless token_via_env moves it to the environment
+	if cfg.Token != "" && !cfg.TokenViaEnv {
+		args = append(args, "--token", cfg.Token)
+	}
+
+	// Registry for private registries, declared by the registry_index
+	// config when the runner's cargo configuration lacks it
+	if cfg.Registry != "" {
+		args = append(args, "--registry", cfg.Registry)
+	}
+	if cfg.RegistryConfigFile != "" {
+		args = append(args, "--config", cfg.RegistryConfigFile)
+	}
+
+	// Registry addressed by index URL
+	if cfg.Index != "" {
+		args = append(args, "--index", cfg.Index)
+	}
+
+	// Allow dirty working directory
+	if cfg.AllowDirty {
+		args = append(args, "--allow-dirty")
+	}
+
+	// Skip verification
+	if cfg.NoVerify {
+		args = append(args, "--no-verify")
+	}
+
+	// Manifest path
+	if cfg.ManifestPath != "" && cfg.ManifestPath != "Cargo.toml" {
+		args = append(args, "--manifest-path", cfg.ManifestPath)
+	}
+
+	// Features
+	if len(cfg.Features) > 0 {
+		args = append(args, "--features", strings.Join(cfg.Features, ","))
+	}
+
+	// All features
+	if cfg.AllFeatures {
+		args = append(args, "--all-features")
+	}
+
+	// No default features
+	if cfg.NoDefaultFeatures {
+		args = append(args, "--no-default-features")
+	}
+
+	// Parallel jobs
+	if cfg.Jobs > 0 {
+		args = append(args, "--jobs", fmt.Sprintf("%d", cfg.Jobs))
+	}
+
+	// Require an up-to-date lockfile
+	if cfg.Locked {
+		args = append(args, "--locked")
+	}
+
+	// Build without network access
+	if cfg.Offline {
+		args = append(args, "--offline")
+	}
+
+	// Require an up-to-date lockfile and no network access
+	if cfg.Frozen {
+		args = append(args, "--frozen")
+	}
+
+	// Target triple for the verification build
+	if cfg.Target != "" {
+		args = append(args, "--target", cfg.Target)
+	}
+
+	// Isolated build directory
+	if cfg.TargetDir != "" {
+		args = append(args, "--target-dir", cfg.TargetDir)
+	}
+
+	// Output verbosity
+	if cfg.Quiet {
+		args = append(args, "-q")
+	}
+	if cfg.Verbose > 0 {
+		args = append(args, "-"+strings.Repeat("v", cfg.Verbose))
+	}
+
+	// Unstable flags, only accepted by nightly cargo
+	for _, flag := range cfg.UnstableFlags {
+		args = append(args, "-Z", flag)
+	}
+
+	// Cargo configuration overrides
+	args = append(args, cargoConfigArgs(cfg)...)
+
+	// Passthrough arguments always come last
+	args = append(args, cfg.ExtraArgs...)
+
+	return args
+}
+
+// cargoConfigArgs returns the --config arguments for cargo: http.cainfo for
+// registry_ca_cert, the network settings, then the cargo_config overrides,
+// sorted for a stable command line.
+func cargoConfigArgs(cfg *Config) []string {
+	var args []string
+	if cfg.RegistryCACert != "" {
+		args = append(args, "--config", cargoCAInfoKey+"='"+registryCACertPath(cfg)+"'")
+	}
+	args = append(args, cargoNetArgs(cfg)...)
+	for _, key := range sortedKeys(cfg.CargoConfig) {
+		args = append(args, "--config", key+"="+cfg.CargoConfig[key])
+	}
+	return args
+}
+
+// manifestWorkDir returns the directory cargo runs in for a non-default
+// manifest path, or "" to run in the current directory.
+func manifestWorkDir(cfg *Config) string {
+	if cfg.ManifestPath != "" && cfg.ManifestPath != "Cargo.toml" {
+		return manifestDir(cfg.ManifestPath, runtime.GOOS == "windows")
+	}
+	return ""
+}
+
+// manifestDir returns the directory of a manifest path. With windows set,
+// backslashes separate directories as well, as they do for filepath.Dir on
+// Windows; the result uses the host separator, like filepath.Dir.
+func manifestDir(manifestPath string, windows bool) string {
+	if windows {
+		manifestPath = strings.ReplaceAll(manifestPath, `\`, "/")
+	}
+	return filepath.FromSlash(path.Dir(filepath.ToSlash(manifestPath)))
+}
+
+// rebaseManifestPath rewrites the --manifest-path argument relative to dir.
+func rebaseManifestPath(args []string, dir string) []string {
+	rebased := make([]string, len(args))
+	copy(rebased, args)
+
+	for i := 0; i < len(rebased)-1; i++ {
+		if rebased[i] != "--manifest-path" {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, rebased[i+1]); err == nil {
+			rebased[i+1] = rel
+		}
+	}
+	return rebased
+}
+
+// getRegistryName returns a human-readable registry name.
+func (p *CratesPlugin) getRegistryName(cfg *Config) string {
+	if cfg.Registry != "" {
+		return cfg.Registry
+	}
+	if cfg.Index != "" {
+		if u, err := url.Parse(strings.TrimPrefix(cfg.Index, "sparse+")); err == nil && u.Host != "" {
+			return u.Host
+		}
+		return cfg.Index
+	}
+	return "crates.io"
+}
+
+// validateConfig validates the plugin configuration for security issues.
+func (p *CratesPlugin) validateConfig(cfg *Config) error {
+	// Validate manifest path
+	if err := validatePath(cfg.ManifestPath); err != nil {
+		return fmt.Errorf("invalid manifest_path: %w", err)
+	}
+
+	// Validate the port allowlist, insecure hosts and allowed networks, then
+	// registry URL if provided
+	if err := validatePorts(cfg.RegistryPorts); err != nil {
+		return err
+	}
+	if err := validateInsecureHosts(cfg.InsecureRegistry, cfg.InsecureHosts); err != nil {
+		return err
+	}
+	if _, err := parseNetworks(cfg.AllowedNetworks); err != nil {
+		return err
+	}
+	policy := configRegistryPolicy(cfg)
+	if cfg.Registry != "" {
+		if err := validateRegistryURL(cfg.Registry, policy); err != nil {
+			return fmt.Errorf("invalid registry: %w", err)
+		}
+	}
+
+	// Validate index URL if provided
+	if cfg.Index != "" {
+		if cfg.Registry != "" {
+			return fmt.Errorf("registry and index are mutually exclusive")
+		}
+		if err := validateIndexURL(cfg.Index, policy); err != nil {
+			return fmt.Errorf("invalid index: %w", err)
+		}
+	}
+	if err := validateRegistryIndex(cfg.Registry, cfg.Index, cfg.RegistryIndex, policy); err != nil {
+		return err
+	}
+	if err := validateRegistryCACertPath(cfg.RegistryCACert, cfg.CargoConfig); err != nil {
+		return err
+	}
+	if err := validateClientCertPair(cfg.ClientCert, cfg.ClientKey); err != nil {
+		return err
+	}
+	if err := validateProxy(cfg.Proxy, cfg.NoProxy); err != nil {
+		return err
+	}
+	if err := validateCargoNet(cfg.NetRetry, cfg.GitFetchWithCLI, cfg.CargoConfig); err != nil {
+		return err
+	}
+	if err := validateRegistryTargets(cfg.Registries, cfg.RegistriesPolicy, cfg.ExplicitKeys); err != nil {
+		return err
+	}
+	if err := validateChannelRegistries(cfg.ChannelRegistries, policy, cfg.ExplicitKeys); err != nil {
+		return err
+	}
+
+	// Validate failure policy
+	if err := validateFailurePolicy(cfg.FailurePolicy); err != nil {
+		return err
+	}
+
+	// Validate deprecated keys
+	if err := validateAliasUses(cfg.AliasesUsed, cfg.StrictConfig); err != nil {
+		return err
+	}
+
+	// Enforce forbid_inline_token even when Validate was skipped
+	if err := validateInlineToken(cfg.ExplicitKeys, cfg.ForbidInlineToken); err != nil {
+		return err
+	}
+
+	// Validate the compatibility level and opted-in features
+	if err := validateCompat(cfg.CompatLevel, cfg.CompatFeatures); err != nil {
+		return err
+	}
+
+	// Validate the credential provider and that no token is configured with it
+	if err := validateCredentialProvider(cfg); err != nil {
+		return err
+	}
+
+	// Validate the CodeArtifact domain and that no token is configured with it
+	if err := validateCodeArtifact(cfg); err != nil {
+		return err
+	}
+
+	// Validate that trusted publishing targets crates.io without another token source
+	if err := validateTrustedPublishing(cfg); err != nil {
+		return err
+	}
+
+	// Validate that the token can be moved to the environment
+	if cfg.TokenViaEnv {
+		if err := validateTokenViaEnv(cfg.Registry, cfg.Index); err != nil {
+			return err
+		}
+	}
+
+	// Validate token file location
+	if err := validateTokenFile(cfg.TokenFile, cfg.TokenFileRoot); err != nil {
+		return fmt.Errorf("invalid token_file: %w", err)
+	}
+
+	// Validate the packaging check
+	if err := validatePackageCheck(cfg.PackageCheck, cfg.RequiredPaths, cfg.MinPackageFiles); err != nil {
+		return err
+	}
+	if err := validateMaxPackageSize(cfg.MaxPackageSize); err != nil {
+		return err
+	}
+	if err := validatePackageGlobs("package_must_include", cfg.MustInclude); err != nil {
+		return err
+	}
+	if err := validatePackageGlobs("package_must_not_include", cfg.MustNotInclude); err != nil {
+		return err
+	}
+
+	// Validate the token value without revealing it
+	if err := validateToken(cfg.Token); err != nil {
+		return err
+	}
+
+	// Validate per-registry tokens
+	if err := validateTokens(cfg.Tokens); err != nil {
+		return fmt.Errorf("invalid tokens: %w", err)
+	}
+
+	// Validate the token variable name
+	if err := validateTokenEnv(cfg.TokenEnv); err != nil {
+		return fmt.Errorf("invalid token_env: %w", err)
+	}
+
+	// Validate the token command
+	if err := validateTokenCommand(cfg.TokenCommand, cfg.TokenFile); err != nil {
+		return fmt.Errorf("invalid token_command: %w", err)
+	}
+
+	// Validate the keyring entry
+	if err := validateKeyringEntry(cfg.TokenKeyring, cfg.TokenFile, cfg.TokenCommand); err != nil {
+		return err
+	}
+
+	// Validate crate name override
+	if err := validateCrateName(cfg.CrateName); err != nil {
+		return fmt.Errorf("invalid crate_name: %w", err)
+	}
+
+	// Validate feature names
+	for i, feature := range cfg.Features {
+		if err := validateArgValue(feature); err != nil {
+			return fmt.Errorf("invalid features[%d]: %w", i, err)
+		}
+	}
+
+	// Validate target triple if provided
+	if err := validateTargetTriple(cfg.Target); err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	// Validate build directory if provided
+	if err := validateTargetDir(cfg.TargetDir, cfg.TargetDirRoot); err != nil {
+		return fmt.Errorf("invalid target_dir: %w", err)
+	}
+
+	// Validate the temporary directory root
+	if err := validateTempDir(cfg.TempDir); err != nil {
+		return fmt.Errorf("invalid temp_dir: %w", err)
+	}
+
+	// Validate cargo configuration overrides
+	for _, key := range sortedKeys(cfg.CargoConfig) {
+		if err := validateCargoConfigEntry(key, cfg.CargoConfig[key]); err != nil {
+			return fmt.Errorf("invalid cargo_config: %w", err)
+		}
+	}
+
+	// Validate the cargo environment
+	if err := validateEnvConfig(cfg.EnvMode, cfg.Env, cfg.EnvAllowlist); err != nil {
+		return err
+	}
+
+	// Validate the audit log path
+	if err := validateAuditLog(cfg.AuditLog); err != nil {
+		return err
+	}
+
+	// Validate toolchain if provided
+	if err := validateToolchain(cfg.Toolchain); err != nil {
+		return fmt.Errorf("invalid toolchain: %w", err)
+	}
+
+	// Validate cargo executable override
+	if err := validateCargoPath(cfg.CargoPath); err != nil {
+		return fmt.Errorf("invalid cargo_path: %w", err)
+	}
+
+	// Validate minimum cargo version if provided
+	if cfg.MinCargoVersion != "" {
+		if _, err := parseMinCargoVersion(cfg.MinCargoVersion); err != nil {
+			return fmt.Errorf("invalid min_cargo_version: %w", err)
+		}
+	}
+
+	// Validate unstable flags
+	if err := validateUnstableFlags(cfg.UnstableFlags, configuredToolchain(cfg.Toolchain)); err != nil {
+		return err
+	}
+
+	// Validate forbidden license identifiers
+	for i, id := range cfg.ForbiddenLicenses {
+		if err := validateLicenseID(id); err != nil {
+			return fmt.Errorf("invalid forbidden_licenses[%d]: %w", i, err)
+		}
+	}
+
+	// Validate output verbosity
+	if cfg.Quiet && cfg.Verbose > 0 {
+		return fmt.Errorf("quiet and verbose are mutually exclusive")
+	}
+	if cfg.Verbose < 0 || cfg.Verbose > 2 {
+		return fmt.Errorf("verbose must be true, false, or a level from 0 to 2")
+	}
+
+	// Validate passthrough arguments
+	for i, arg := range cfg.ExtraArgs {
+		if err := validateExtraArg(arg); err != nil {
+			return fmt.Errorf("invalid extra_args[%d] %q: %w", i, arg, err)
+		}
+	}
+
+	return nil
+}
+
+// unstableFlagPattern matches a -Z flag name with an optional =value.
+var unstableFlagPattern = regexp.MustCompile(`^[a-z0-9-]+(=\S+)?$`)
+
+// toolchainPattern matches rustup toolchain names: a channel, an optional
+// archive date, and an optional host triple.
+var toolchainPattern = regexp.MustCompile(`^(stable|beta|nightly|\d+\.\d+(\.\d+)?)(-\d{4}-\d{2}-\d{2})?(-[a-zA-Z][a-zA-Z0-9_]*(-[a-zA-Z0-9_.]+){1,3})?$`)
+
+// validateToolchain validates a rustup toolchain name.
+func validateToolchain(toolchain string) error {
+	if toolchain == "" {
+		return nil
+	}
+	if !toolchainPattern.MatchString(toolchain) {
+		return fmt.Errorf("%q is not a rustup toolchain name (e.g. stable, nightly-2024-05-01, 1.74.0-x86_64-unknown-linux-gnu)", toolchain)
+	}
+	return nil
+}
+
+// defaultCargoPath is the cargo executable used when cargo_path is not set.
+const defaultCargoPath = "cargo"
+
+// commandNamePattern matches a bare executable name without path separators.
+var commandNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// cargoBinary returns the cargo executable to invoke.
+func cargoBinary(cfg *Config) string {
+	if cfg.CargoPath == "" {
+		return defaultCargoPath
+	}
+	return cfg.CargoPath
+}
+
+// validateCargoPath validates a cargo_path override: either a bare command name
+// resolved through PATH or an absolute path to an existing executable file.
+func validateCargoPath(path string) error {
+	if path == "" || commandNamePattern.MatchString(path) {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("must be a command name or an absolute path")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot access %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// configuredToolchain returns the rustup toolchain cargo will run with: the
+// toolchain option when set, otherwise RUSTUP_TOOLCHAIN from the environment.
+func configuredToolchain(toolchain string) string {
+	if toolchain != "" {
+		return toolchain
+	}
+	return os.Getenv("RUSTUP_TOOLCHAIN")
+}
+
+// isNightlyToolchain reports whether a rustup toolchain name is a nightly channel.
+func isNightlyToolchain(toolchain string) bool {
+	return toolchain == "nightly" || strings.HasPrefix(toolchain, "nightly-")
+}
+
+// validateUnstableFlags validates -Z flags and requires a nightly toolchain
+// when any are set, since stable cargo rejects them.
+func validateUnstableFlags(flags []string, toolchain string) error {
+	for i, flag := range flags {
+		if !unstableFlagPattern.MatchString(flag) {
+			return fmt.Errorf("invalid unstable_flags[%d] %q: must match [a-z0-9-]+(=value)", i, flag)
+		}
+	}
+	if len(flags) > 0 && !isNightlyToolchain(toolchain) {
+		return fmt.Errorf("unstable_flags require a nightly toolchain, got %q", toolchain)
+	}
+	return nil
+}
+
+// managedFlags are cargo publish flags the plugin sets itself and that
+// extra_args must not override.
+var managedFlags = map[string]bool{
+	"--token":         true,
+	"--registry":      true,
+	"--index":         true,
+	"--manifest-path": true,
+	"--target-dir":    true,
+	"--dry-run":       true,
+}
+
+// validateFailurePolicy validates the failure_policy option; empty means hard.
+func validateFailurePolicy(policy string) error {
+	if policy != "" && policy != failurePolicyHard && policy != failurePolicySoft {
+		return fmt.Errorf("failure_policy must be one of: hard, soft")
+	}
+	return nil
+}
+
+// crateNamePattern matches names crates.io accepts: an ASCII letter followed
+// by ASCII letters, digits, - or _.
+var crateNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// maxCrateNameLength is the longest crate name crates.io accepts.
+const maxCrateNameLength = 64
+
+// validateCrateName checks a crate_name override against the crates.io
+// naming rules.
+func validateCrateName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > maxCrateNameLength {
+		return fmt.Errorf("crate names are at most %d characters", maxCrateNameLength)
+	}
+	if !crateNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid crate name: use an ASCII letter followed by letters, digits, - or _", name)
+	}
+	return nil
+}
+
+// validateArgValue guards a value passed to a command as its own argument,
+// such as a feature name or path, so it cannot be read as a flag or split
+// into several arguments by tooling that re-parses the command line.
+func validateArgValue(value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("%q must not start with -", value)
+	}
+	for _, r := range value {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("%q must not contain whitespace or control characters", value)
+		}
+	}
+	return nil
+}
+
+// validateExtraArg validates a passthrough argument. Arguments must be long
+// flags, optionally with an =value, and free of whitespace and shell metacharacters.
+func validateExtraArg(arg string) error {
+	if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+		return fmt.Errorf("must start with -- followed by a flag name")
+	}
+	if strings.ContainsAny(arg, " \t\r\n;|&$<>`'\"\\(){}*?!#~") {
+		return fmt.Errorf("must not contain whitespace or shell metacharacters")
+	}
+	flag, _, _ := strings.Cut(arg, "=")
+	if managedFlags[flag] {
+		return fmt.Errorf("%s is managed by the plugin and cannot be passed through", flag)
+	}
+	return nil
+}
+
+// cargoConfigKeyPattern matches cargo's dotted configuration keys, where each
+// segment is a bare key or a double-quoted key without newlines.
+var cargoConfigKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.([A-Za-z0-9_-]+|"[^"\r\n]+"))*$`)
+
+// validateCargoConfigEntry validates a --config override so it cannot inject
+// additional configuration or arguments.
+func validateCargoConfigEntry(key, value string) error {
+	if !cargoConfigKeyPattern.MatchString(key) {
+		return fmt.Errorf("key %q is not a valid dotted cargo config key", key)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("value for %q must not contain newlines", key)
+	}
+	return nil
+}
+
+// validateTargetDir validates the build directory. Relative paths follow the
+// validatePath rules; absolute paths must live under the allowlisted root.
+func validateTargetDir(dir, root string) error {
+	if dir == "" || dir == targetDirTemp {
+		return nil
+	}
+
+	if err := validateArgValue(dir); err != nil {
+		return err
+	}
+
+	if root != "" && !filepath.IsAbs(root) {
+		return fmt.Errorf("target_dir_root must be an absolute path")
+	}
+
+	cleaned := filepath.Clean(dir)
+	if !filepath.IsAbs(cleaned) {
+		return validatePath(dir)
+	}
+
+	if root == "" {
+		return fmt.Errorf("absolute paths are only allowed under target_dir_root")
+	}
+
+	if !isUnderDir(cleaned, root) {
+		return fmt.Errorf("%s is not under target_dir_root %s", dir, root)
+	}
+
+	return nil
+}
+
+// isUnderDir reports whether the absolute path lies strictly below root.
+func isUnderDir(path, root string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveTargetDir returns the build directory to pass to cargo and a cleanup
+// function. The temp sentinel creates a fresh directory under temp_dir that
+// is removed on cleanup.
+func (p *CratesPlugin) resolveTargetDir(ctx context.Context, cfg *Config) (string, func(), error) {
+	dir := cfg.TargetDir
+	if dir == "" {
+		return "", func() {}, nil
+	}
+
+	if dir == targetDirTemp {
+		return p.makeBuildTempDir(ctx, cfg, "relicta-crates-target-", "the temporary target_dir")
+	}
+
+	// Cargo may run from the manifest directory, so relative paths are made absolute
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve target_dir: %w", err)
+	}
+	return abs, func() {}, nil
+}
+
+// targetTriplePattern matches target triples such as x86_64-unknown-linux-gnu
+// or thumbv7em-none-eabihf: two to five dash-separated components.
+var targetTriplePattern = regexp.MustCompile(`^[a-zA-Z0-9_.]+(-[a-zA-Z0-9_.]+){1,4}$`)
+
+// validateTargetTriple validates a target triple so it cannot smuggle extra arguments.
+func validateTargetTriple(target string) error {
+	if target == "" {
+		return nil
+	}
+	if !targetTriplePattern.MatchString(target) {
+		return fmt.Errorf("%q is not a valid target triple", target)
+	}
+	return nil
+}
+
+// validatePath validates a file path to prevent path traversal. A path that
+// exists is also resolved through symlinks, so a committed symlink cannot
+// point it outside the working directory.
+func validatePath(path string) error {
+	if err := validateRelativePath(path); err != nil {
+		return err
+	}
+	return validateResolvedPath(path)
+}
+
+// validateResolvedPath resolves the symlinks of an existing relative path and
+// checks that the result stays under the working directory. Paths that do not
+// exist are left to the lexical checks of validateRelativePath.
+func validateResolvedPath(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return nil
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("cannot resolve symlinks in %s: %v", path, err)
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %v", path, err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot determine the working directory: %v", err)
+	}
+	// The working directory may itself be reached through a symlink
+	root, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		return fmt.Errorf("cannot resolve the working directory %s: %v", wd, err)
+	}
+
+	if resolved != root && !isUnderDir(resolved, root) {
+		return fmt.Errorf("path %s resolves to %s, outside the working directory %s", path, resolved, root)
+	}
+	return nil
+}
+
+// validateRelativePath checks the path lexically: it must be relative and
+// must not use '..' to leave the directory it is resolved against. Release
+// configs are shared between POSIX and Windows runners, so the checks apply
+// the rules of both on every platform: backslashes separate directories, and
+// drive (C:\, C:) and UNC (\\server\share) paths count as absolute.
+func validateRelativePath(p string) error {
+	if p == "" {
+		return nil
+	}
+
+	if err := validateArgValue(p); err != nil {
+		return err
+	}
+
+	// Check for absolute paths (potential escape from working directory)
+	if filepath.IsAbs(p) || isAbsoluteAnyOS(p) {
+		return fmt.Errorf("absolute paths are not allowed")
+	}
+
+	// Check for path traversal attempts with either separator
+	cleaned := path.Clean(strings.ReplaceAll(p, `\`, "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("path traversal detected: cannot use '..' to escape working directory")
+	}
+
+	return nil
+}
+
+// isAbsoluteAnyOS reports whether p is rooted on POSIX or Windows: a leading
+// slash or backslash (including UNC paths) or a drive letter.
+func isAbsoluteAnyOS(p string) bool {
+	if p[0] == '/' || p[0] == '\\' {
+		return true
+	}
+	return len(p) >= 2 && p[1] == ':' && ('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z')
+}
+
+// defaultRegistryPorts are the ports a registry URL may name when
+// allowed_registry_ports is unset.
+var defaultRegistryPorts = []int{443}
+
+// registryPolicy is the configuration that relaxes validateRegistryURL.
+type registryPolicy struct {
+	// ports are the ports a non-localhost URL may name; nil means
+	// defaultRegistryPorts.
+	ports []int
+	// insecureHosts may use plain HTTP and resolve to private addresses.
+	// It is only set with allow_insecure_registry.
+	insecureHosts []string
+	// networks are the allowed_networks exempt from the private network
+	// check.
+	networks []*net.IPNet
+}
+
+// newRegistryPolicy builds the registryPolicy of the configuration. The
+// insecure_hosts list is ignored unless allow_insecure_registry is set.
+func newRegistryPolicy(ports []int, allowInsecure bool, insecureHosts []string) registryPolicy {
+	policy := registryPolicy{ports: ports}
+	if allowInsecure {
+		for _, host := range insecureHosts {
+			if ascii, err := asciiHost(host); err == nil {
+				host = ascii
+			}
+			policy.insecureHosts = append(policy.insecureHosts, host)
+		}
+	}
+	return policy
+}
+
+// configRegistryPolicy builds the registryPolicy of a validated
+// configuration.
+func configRegistryPolicy(cfg *Config) registryPolicy {
+	policy := newRegistryPolicy(cfg.RegistryPorts, cfg.InsecureRegistry, cfg.InsecureHosts)
+	policy.networks, _ = parseNetworks(cfg.AllowedNetworks)
+	return policy
+}
+
+// insecure reports whether host is exempt from the HTTPS and private
+// network checks.
+func (p registryPolicy) insecure(host string) bool {
+	return slices.ContainsFunc(p.insecureHosts, func(h string) bool { return strings.EqualFold(h, host) })
+}
+
+// hostProfile is the IDNA lookup profile without the STD3 rules, so the
+// underscores of internal host names stay accepted.
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.CheckHyphens(true), idna.CheckJoiners(true), idna.StrictDomainName(false))
+
+// asciiHost returns the punycode form of a host name, so internationalized
+// names are compared and resolved the way DNS sees them. Anything but
+// letters, digits, '-', '_' and '.' is rejected, including IPv6 zones.
+func asciiHost(host string) (string, error) {
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil || !asciiHostPattern.MatchString(ascii) {
+		return "", fmt.Errorf("URL host is not a valid host name")
+	}
+	return ascii, nil
+}
+
+// asciiHostPattern matches the characters of a punycode host name.
+var asciiHostPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// allowsNetwork reports whether ip is in one of the allowed_networks.
+func (p registryPolicy) allowsNetwork(ip net.IP) bool {
+	return slices.ContainsFunc(p.networks, func(n *net.IPNet) bool { return n.Contains(ip) })
+}
 
 // validateRegistryURL validates a registry URL for security (SSRF protection).
-func validateRegistryURL(registryURL string) error {
+// Credentials and fragments are rejected, and a non-localhost URL may only
+// name a port in policy.ports. Hosts in policy.insecureHosts may use plain
+// HTTP and private addresses, and addresses in policy.networks are exempt
+// from the private network check, which only covers IP literals here; host
+// names are resolved by checkRegistryDNS. Errors never repeat the URL, which
+// may carry a password.
+func validateRegistryURL(registryURL string, policy registryPolicy) error {
+	if err := validateArgValue(registryURL); err != nil {
+		return err
+	}
+
 	// If it's just a registry name (not a URL), allow it
 	if !strings.Contains(registryURL, "://") {
-		// Simple registry name validation (alphanumerics, dots, dashes)
-		validName := regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9.-]*$`)
-		if !validName.MatchString(registryURL) {
-			return fmt.Errorf("invalid registry name format")
-		}
-		return nil
+		return validateRegistryName(registryURL)
 	}
 
-	// Parse as URL
-	parsedURL, err := url.Parse(registryURL)
+	// Parse as URL. Cargo's sparse+ prefix selects the sparse protocol over
+	// the inner HTTP(S) URL, which the rules below apply to
+	inner, sparse := strings.CutPrefix(registryURL, "sparse+")
+	parsedURL, err := url.Parse(inner)
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return fmt.Errorf("invalid URL: it cannot be parsed")
+	}
+	scheme := parsedURL.Scheme
+	if sparse {
+		if scheme != "http" && scheme != "https" {
+			return fmt.Errorf("sparse+ must be followed by an http:// or https:// URL")
+		}
+		scheme = "sparse+" + scheme
+	}
+
+	// Credentials belong in token or token_env, not in a URL that ends up in logs
+	if parsedURL.User != nil {
+		return fmt.Errorf("URLs must not contain credentials (user:password@); pass the token with token, token_env or token_file instead")
+	}
+	if parsedURL.Fragment != "" || strings.Contains(registryURL, "#") {
+		return fmt.Errorf("URLs must not contain a fragment (#...)")
 	}
 
 	host := parsedURL.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL has no host")
+	}
+	// IPv6 literals are checked as addresses; internationalized host names
+	// are checked and resolved in their punycode form
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if host, err = asciiHost(host); err != nil {
+			return err
+		}
+	}
+	for _, segment := range strings.Split(parsedURL.Path, "/") {
+		if segment == ".." {
+			return fmt.Errorf("URL path must not contain '..'")
+		}
+	}
 
 	// Allow localhost for testing purposes
-	isLocalhost := host == "localhost" || host == "127.0.0.1" || host == "::1"
+	isLocalhost := host == "localhost" || ip != nil && ip.IsLoopback()
+
+	// Only allowlisted ports, so the URL cannot aim at arbitrary services
+	if port := parsedURL.Port(); port != "" && !isLocalhost {
+		allowedPorts := policy.ports
+		if allowedPorts == nil {
+			allowedPorts = defaultRegistryPorts
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || !slices.Contains(allowedPorts, n) {
+			return fmt.Errorf("port %s is not allowed; add it to allowed_registry_ports (allowed: %s)", port, formatPorts(allowedPorts))
+		}
+	}
 
-	// Require HTTPS for non-localhost URLs
+	// Require HTTPS for non-localhost URLs, sparse or not
 	if parsedURL.Scheme != "https" && !isLocalhost {
-		if parsedURL.Scheme != "sparse+https" { // Cargo supports sparse+https protocol
-			return fmt.Errorf("only HTTPS URLs are allowed (got %s)", parsedURL.Scheme)
+		if parsedURL.Scheme != "http" || !policy.insecure(host) {
+			return fmt.Errorf("only HTTPS URLs are allowed (got %s); list the host in insecure_hosts with allow_insecure_registry to allow plain HTTP", scheme)
 		}
 	}
 
-	// For localhost, skip the private IP check
+	// For localhost, skip the private IP check; literal addresses are checked
+	// without a lookup
 	if isLocalhost {
 		return nil
 	}
-
-	// Resolve hostname to check for private IPs
-	ips, err := net.LookupIP(host)
-	if err != nil {
-		// DNS resolution might fail in some environments, allow it but log
-		return nil
+	if ip != nil {
+		return checkRegistryIPs(host, []net.IP{ip}, policy)
 	}
 
+	// Host names are resolved by checkRegistryDNS, within dns_timeout
+	return nil
+}
+
+// checkRegistryIPs rejects a registry host resolving to a private address,
+// unless the host is in insecure_hosts or the address in allowed_networks.
+// Cloud metadata endpoints are rejected regardless of the policy.
+func checkRegistryIPs(host string, ips []net.IP, policy registryPolicy) error {
 	for _, ip := range ips {
-		if isPrivateIP(ip) {
-			return fmt.Errorf("URLs pointing to private networks are not allowed")
+		switch {
+		case isCloudMetadataIP(ip):
+			return fmt.Errorf("URLs pointing to cloud metadata endpoints are not allowed")
+		case policy.insecure(host), policy.allowsNetwork(ip):
+		case isPrivateIP(ip):
+			return fmt.Errorf("URLs pointing to private networks are not allowed; list the network in allowed_networks to allow it")
 		}
 	}
-
 	return nil
 }
 
-// isPrivateIP checks if an IP address is in a private/reserved range.
-func isPrivateIP(ip net.IP) bool {
-	// Private IPv4 ranges
-	privateRanges := []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-		"127.0.0.0/8",
-		"169.254.0.0/16", // Link-local
-		"0.0.0.0/8",
+// parseNetworks parses the allowed_networks CIDR ranges.
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("allowed_networks[%d] %q is not a CIDR range such as 10.20.0.0/16", i, cidr)
+		}
+		networks = append(networks, network)
 	}
+	return networks, nil
+}
 
-	// Cloud metadata endpoints
-	cloudMetadata := []string{
-		"169.254.169.254/32", // AWS/GCP/Azure metadata
-		"fd00:ec2::254/128",  // AWS IMDSv2 IPv6
+// registryNameFormat matches the registry names cargo accepts in
+// .cargo/config.toml: a letter or '_' followed by letters, digits, '-' and
+// '_'. Dots are also accepted for names that already use them.
+var registryNameFormat = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]*$`)
+
+// validateRegistryName validates a registry name (not a URL).
+func validateRegistryName(name string) error {
+	if !registryNameFormat.MatchString(name) {
+		return fmt.Errorf("invalid registry name format: use a letter or '_' followed by letters, digits, '-' or '_'")
 	}
+	return nil
+}
 
-	allRanges := append(privateRanges, cloudMetadata...)
+// validateIndexURL validates an index URL with the registry URL rules,
+// additionally requiring a URL rather than a registry name.
+func validateIndexURL(indexURL string, policy registryPolicy) error {
+	if !strings.Contains(indexURL, "://") {
+		return fmt.Errorf("index must be a URL")
+	}
+	return validateRegistryURL(indexURL, policy)
+}
 
-	for _, cidr := range allRanges {
-		_, block, err := net.ParseCIDR(cidr)
-		if err != nil {
-			continue
+// insecureHostPattern matches a host name, in punycode form, or an IPv4
+// address, without scheme, port or wildcards.
+var insecureHostPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// validateInsecureHosts checks allow_insecure_registry and insecure_hosts:
+// the opt-in and the host list only work together, so neither can turn into
+// a blanket exemption.
+func validateInsecureHosts(allow bool, hosts []string) error {
+	if len(hosts) > 0 && !allow {
+		return fmt.Errorf("insecure_hosts needs allow_insecure_registry")
+	}
+	if allow && len(hosts) == 0 {
+		return fmt.Errorf("allow_insecure_registry needs insecure_hosts listing the hosts to exempt")
+	}
+	for i, host := range hosts {
+		if ascii, err := asciiHost(host); err == nil {
+			host = ascii
 		}
-		if block.Contains(ip) {
-			return true
+		if !insecureHostPattern.MatchString(host) && net.ParseIP(host) == nil {
+			return fmt.Errorf("insecure_hosts[%d] %q must be a host name without scheme, port or wildcards", i, host)
 		}
 	}
+	return nil
+}
+
+// privatePrefixes are the private, reserved and special-purpose ranges a
+// registry host must not resolve to. IPv4-mapped IPv6 addresses are checked
+// as IPv4.
+var privatePrefixes = mustParsePrefixes(
+	"0.0.0.0/8",       // "This network"
+	"10.0.0.0/8",      // Private
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"127.0.0.0/8",     // Loopback
+	"169.254.0.0/16",  // Link-local, including cloud metadata
+	"172.16.0.0/12",   // Private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation (TEST-NET-1)
+	"192.168.0.0/16",  // Private
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation (TEST-NET-2)
+	"203.0.113.0/24",  // Documentation (TEST-NET-3)
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"::/128",          // Unspecified
+	"::1/128",         // Loopback
+	"2001:db8::/32",   // Documentation
+	"fc00::/7",        // Unique local, including fd00::/8
+	"fe80::/10",       // Link-local
+	"ff00::/8",        // Multicast
+)
+
+// cloudMetadataPrefixes are the cloud metadata endpoints, which no
+// configuration can allow.
+var cloudMetadataPrefixes = mustParsePrefixes(
+	"169.254.169.254/32", // AWS/GCP/Azure metadata
+	"fd00:ec2::254/128",  // AWS IMDSv2 IPv6
+	"100.100.100.200/32", // Alibaba Cloud metadata
+)
+
+// mustParsePrefixes parses the built-in CIDR ranges.
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
 
-	// Check for IPv6 private ranges
-	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() {
+// inPrefixes reports whether ip is in one of prefixes. An address that
+// cannot be converted counts as contained, so the checks fail closed.
+func inPrefixes(prefixes []netip.Prefix, ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
 		return true
 	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool { return prefix.Contains(addr) })
+}
+
+// isCloudMetadataIP checks if an IP address is a cloud metadata endpoint.
+func isCloudMetadataIP(ip net.IP) bool {
+	return inPrefixes(cloudMetadataPrefixes, ip)
+}
 
-	return false
+// isPrivateIP checks if an IP address is in a private/reserved range.
+func isPrivateIP(ip net.IP) bool {
+	return inPrefixes(privatePrefixes, ip) || isCloudMetadataIP(ip)
 }
 
 // parseConfig parses the raw configuration map into a Config struct.
 func (p *CratesPlugin) parseConfig(raw map[string]any) *Config {
+	raw, aliasUses := resolveConfigAliases(raw)
 	parser := helpers.NewConfigParser(raw)
 
+	// Defaults that changed since an older compat_level are looked up here
+	compatLevel := parser.GetString("compat_level", "", "")
+	compatOptIn := parser.GetStringSlice("compat_features", nil)
+
+	// Token precedence: token, tokens[registry], token_file, token_command,
+	// token_keyring, codeartifact, the token_env variable,
+	// CARGO_REGISTRIES_<NAME>_TOKEN and finally CARGO_REGISTRY_TOKEN. The
+	// environment is only consulted without a file, command, keyring entry,
+	// credential provider, CodeArtifact domain or trusted publishing;
+	// resolveToken reads the file, runs the command, queries the keyring or
+	// fetches the CodeArtifact token later, and publish exchanges the trusted
+	// publishing token just before the upload
+	tokenFile := parser.GetString("token_file", "", "")
+	tokenCommand := parseTokenCommand(raw["token_command"])
+	tokenKeyring := parseKeyringEntry(parser.GetMap("token_keyring"))
+	registry := parser.GetString("registry", "", "")
+	tokens := parseStringMap(parser.GetMap("tokens"))
+	tokenEnvName := parser.GetString("token_env", "", "")
+	credentialProvider := parser.GetString("credential_provider", "", "")
+	codeArtifact := parseCodeArtifactConfig(parser.GetMap("codeartifact"))
+	trustedPublishing := parser.GetBool("trusted_publishing", false)
+	var envNames []string
+	if tokenFile == "" && len(tokenCommand) == 0 && tokenKeyring == nil && credentialProvider == "" && codeArtifact == nil && !trustedPublishing {
+		envNames = tokenEnvNames(tokenEnvName, registry, compatEnabled(compatLevel, compatOptIn, compatRegistryTokenEnv))
+	}
+	token, tokenSource := configuredToken(parser.GetString("token", "", ""), registry, tokens, envNames)
+
 	return &Config{
-		Token:             parser.GetString("token", "CARGO_REGISTRY_TOKEN", ""),
-		Registry:          parser.GetString("registry", "", ""),
-		AllowDirty:        parser.GetBool("allow_dirty", false),
-		NoVerify:          parser.GetBool("no_verify", false),
-		ManifestPath:      parser.GetString("manifest_path", "", "Cargo.toml"),
-		Features:          parser.GetStringSlice("features", nil),
-		AllFeatures:       parser.GetBool("all_features", false),
-		NoDefaultFeatures: parser.GetBool("no_default_features", false),
-		Jobs:              parser.GetInt("jobs", 0),
+		Token:              token,
+		TokenSource:        tokenSource,
+		Tokens:             tokens,
+		TokenEnv:           tokenEnvName,
+		Registry:           registry,
+		Index:              parser.GetString("index", "", ""),
+		RegistryIndex:      parser.GetString("registry_index", "", ""),
+		RegistryPorts:      parsePorts(raw["allowed_registry_ports"]),
+		InsecureRegistry:   parser.GetBool("allow_insecure_registry", false),
+		InsecureHosts:      parser.GetStringSlice("insecure_hosts", nil),
+		AllowedNetworks:    parser.GetStringSlice("allowed_networks", nil),
+		DNSTimeout:         parseDuration(parser.GetString("dns_timeout", "", ""), defaultDNSTimeout),
+		SkipDNSCheck:       parser.GetBool("skip_dns_check", false),
+		CheckConnectivity:  parser.GetBool("check_registry_connectivity", false),
+		RegistryCACert:     parser.GetString("registry_ca_cert", "", ""),
+		ClientCert:         parser.GetString("client_cert", "", ""),
+		ClientKey:          parser.GetString("client_key", "", ""),
+		Proxy:              parser.GetString("proxy", "", ""),
+		NoProxy:            parser.GetStringSlice("no_proxy", nil),
+		NetRetry:           parseNetRetry(raw["net_retry"]),
+		GitFetchWithCLI:    parser.GetBool("git_fetch_with_cli", false),
+		RegistryDefined:    parser.GetBool("check_registry_defined", false),
+		Registries:         parseRegistryTargets(raw["registries"]),
+		RegistriesPolicy:   parser.GetString("registries_policy", "", ""),
+		ChannelRegistries:  parseStringMap(parser.GetMap("channel_registries")),
+		AllowDirty:         parser.GetBool("allow_dirty", false),
+		NoVerify:           parser.GetBool("no_verify", false),
+		ManifestPath:       parser.GetString("manifest_path", "", "Cargo.toml"),
+		Features:           parser.GetStringSlice("features", nil),
+		AllFeatures:        parser.GetBool("all_features", false),
+		NoDefaultFeatures:  parser.GetBool("no_default_features", false),
+		Jobs:               parser.GetInt("jobs", 0),
+		Locked:             parser.GetBool("locked", false),
+		Offline:            parser.GetBool("offline", false),
+		Frozen:             parser.GetBool("frozen", false),
+		Target:             parser.GetString("target", "", ""),
+		TargetDir:          parser.GetString("target_dir", "", ""),
+		TargetDirRoot:      parser.GetString("target_dir_root", "", ""),
+		CargoConfig:        parseStringMap(parser.GetMap("cargo_config")),
+		EnvMode:            parser.GetString("env_mode", "", envModeInherit),
+		Env:                parseStringMap(parser.GetMap("env")),
+		EnvAllowlist:       parser.GetStringSlice("env_allowlist", nil),
+		AuditLog:           parser.GetString("audit_log", "", ""),
+		ExtraArgs:          parser.GetStringSlice("extra_args", nil),
+		UnstableFlags:      parser.GetStringSlice("unstable_flags", nil),
+		Quiet:              parser.GetBool("quiet", false),
+		Verbose:            parseVerbosity(raw["verbose"]),
+		Toolchain:          parser.GetString("toolchain", "", ""),
+		CargoPath:          parser.GetString("cargo_path", "", defaultCargoPath),
+		MinCargoVersion:    parser.GetString("min_cargo_version", "", ""),
+		PackageThenPublish: parser.GetBool("package_then_publish", false),
+		ExecuteDryRun:      parser.GetBool("execute_dry_run", false),
+		PrePublishVerify:   parser.GetBool("prepublish_verify", compatEnabled(compatLevel, compatOptIn, compatPrePublishVerify)),
+		SkipMetadataCheck:  parser.GetBool("skip_metadata_check", false),
+		AllowPatched:       parser.GetBool("allow_patched", false),
+		DenyPrereleaseDeps: parser.GetBool("deny_prerelease_deps", false),
+		SkipPreflight:      parser.GetBool("skip_preflight", false),
+		ReportLicenses:     parser.GetBool("report_licenses", false),
+		ForbiddenLicenses:  parser.GetStringSlice("forbidden_licenses", nil),
+		PackageCheck:       parser.GetString("package_check", "", packageCheckOff),
+		RequiredPaths:      parser.GetStringSlice("required_paths", defaultRequiredPaths),
+		MinPackageFiles:    parser.GetInt("min_package_files", 1),
+		MaxPackageSize:     parser.GetInt("max_package_size", defaultMaxPackageSize),
+		MustInclude:        parser.GetStringSlice("package_must_include", nil),
+		MustNotInclude:     parser.GetStringSlice("package_must_not_include", nil),
+		RateLimitMaxWait:   parseDuration(parser.GetString("rate_limit_max_wait", "", ""), defaultRateLimitMaxWait),
+		QuotaWarnThreshold: parser.GetInt("quota_warn_threshold", 0),
+		QuotaStateFile:     parser.GetString("quota_state_file", "", ""),
+		PublishTimeout:     parseDuration(parser.GetString("publish_timeout", "", ""), 0),
+		PostPublishWait:    parseDuration(parser.GetString("post_publish_wait", "", ""), 0),
+		TokenViaEnv:        parser.GetBool("token_via_env", false),
+		CredentialProvider: credentialProvider,
+		CodeArtifact:       codeArtifact,
+		TrustedPublishing:  trustedPublishing,
+		ForbidInlineToken:  forbidInlineToken(parser.GetBool("forbid_inline_token", false)),
+		TokenFile:          tokenFile,
+		TokenFileRoot:      parser.GetString("token_file_root", "", ""),
+		TokenCommand:       tokenCommand,
+		TokenKeyring:       tokenKeyring,
+		TokenCmdTimeout:    parseDuration(parser.GetString("token_command_timeout", "", ""), defaultTokenCmdTimeout),
+		CrateName:          parser.GetString("crate_name", "", ""),
+		AllowNewCrate:      parser.GetBool("allow_new_crate", false),
+		TempDir:            parser.GetString("temp_dir", "", ""),
+		FailurePolicy:      parser.GetString("failure_policy", "", failurePolicyHard),
+		CompatLevel:        compatLevel,
+		CompatFeatures:     compatOptIn,
+		StrictConfig:       parser.GetBool("strict_config", false),
+		AliasesUsed:        aliasUses,
+		ExplicitKeys:       explicitKeys(raw),
+		Metrics:            parseMetricsConfig(parser.GetMap("metrics")),
+	}
+}
+
+// explicitKeys returns the keys set in a raw config, so defaults can be told
+// apart from configured values.
+func explicitKeys(raw map[string]any) map[string]bool {
+	keys := make(map[string]bool, len(raw))
+	for key := range raw {
+		keys[key] = true
+	}
+	return keys
+}
+
+// parseStringMap converts a raw config object into a string map, formatting
+// non-string values such as booleans and numbers with their default format.
+func parseStringMap(raw map[string]any) map[string]string {
+	if raw == nil {
+		return nil
+	}
+	result := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			result[k] = s
+		} else {
+			result[k] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+// parseVerbosity converts the verbose option into a level: true is 1 and
+// numbers are taken as the level. Invalid values yield -1.
+func parseVerbosity(raw any) int {
+	switch v := raw.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case float64:
+		if v != float64(int(v)) {
+			return -1
+		}
+		return int(v)
+	case int:
+		return v
+	default:
+		return -1
+	}
+}
+
+// parsePorts reads a list of port numbers. It is nil when unset; entries that
+// are not whole numbers become -1 so validatePorts rejects them.
+func parsePorts(raw any) []int {
+	items, ok := raw.([]any)
+	if !ok {
+		if ports, ok := raw.([]int); ok {
+			return ports
+		}
+		return nil
+	}
+	ports := make([]int, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				ports = append(ports, -1)
+				continue
+			}
+			ports = append(ports, int(v))
+		case int:
+			ports = append(ports, v)
+		default:
+			ports = append(ports, -1)
+		}
+	}
+	return ports
+}
+
+// validatePorts checks that every allowed_registry_ports entry is a port.
+func validatePorts(ports []int) error {
+	for i, port := range ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("allowed_registry_ports[%d] is not a port number between 1 and 65535", i)
+		}
 	}
+	return nil
+}
+
+// formatPorts renders ports for messages, as "none" when empty.
+func formatPorts(ports []int) string {
+	if len(ports) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseDuration parses a Go duration string, returning the fallback when empty or invalid.
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+// warningCode marks validation entries that are informational and do not
+// make the configuration invalid.
+const warningCode = "warning"
+
+// validationWarnings collects non-fatal findings reported alongside errors.
+type validationWarnings []plugin.ValidationError
+
+// add records a warning for the given field.
+func (w *validationWarnings) add(field, message string) {
+	*w = append(*w, plugin.ValidationError{Field: field, Message: message, Code: warningCode})
 }
 
 // Validate validates the plugin configuration.
-func (p *CratesPlugin) Validate(_ context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
+func (p *CratesPlugin) Validate(ctx context.Context, config map[string]any) (*plugin.ValidateResponse, error) {
 	vb := helpers.NewValidationBuilder()
+	config, aliasUses := resolveConfigAliases(config)
 	parser := helpers.NewConfigParser(config)
+	var warnings validationWarnings
+
+	// Deprecated keys still parse but warn, or fail under strict_config
+	strict := parser.GetBool("strict_config", false)
+	for _, use := range aliasUses {
+		if err := use.err(strict); err != nil {
+			vb.AddError(use.Old, err.Error())
+		} else {
+			warnings.add(use.Old, use.message())
+		}
+	}
+
+	// Reject tokens pasted into the config when the policy forbids them
+	if err := validateInlineToken(explicitKeys(config), forbidInlineToken(parser.GetBool("forbid_inline_token", false))); err != nil {
+		vb.AddError("token", err.Error())
+	}
 
 	// Validate manifest_path if provided
 	manifestPath := parser.GetString("manifest_path", "", "Cargo.toml")
@@ -395,14 +2401,418 @@ func (p *CratesPlugin) Validate(_ context.Context, config map[string]any) (*plug
 		vb.AddError("manifest_path", err.Error())
 	}
 
-	// Validate registry URL if provided
+	// Validate the port allowlist and insecure hosts, then registry URL if provided
+	registryPorts := parsePorts(config["allowed_registry_ports"])
+	if err := validatePorts(registryPorts); err != nil {
+		vb.AddError("allowed_registry_ports", err.Error())
+	}
+	allowInsecure := parser.GetBool("allow_insecure_registry", false)
+	insecureHosts := parser.GetStringSlice("insecure_hosts", nil)
+	if err := validateInsecureHosts(allowInsecure, insecureHosts); err != nil {
+		vb.AddError("insecure_hosts", err.Error())
+	} else if allowInsecure {
+		warnings.add("allow_insecure_registry", fmt.Sprintf("plain HTTP and private addresses are allowed for %s; tokens sent to these hosts are not encrypted in transit", strings.Join(insecureHosts, ", ")))
+	}
+	policy := newRegistryPolicy(registryPorts, allowInsecure, insecureHosts)
+	allowedNetworks := parser.GetStringSlice("allowed_networks", nil)
+	if networks, err := parseNetworks(allowedNetworks); err != nil {
+		vb.AddError("allowed_networks", err.Error())
+	} else if len(networks) > 0 {
+		policy.networks = networks
+		warnings.add("allowed_networks", fmt.Sprintf("registry addresses in %s are exempt from the private network check", strings.Join(allowedNetworks, ", ")))
+	}
+	dnsTimeout := defaultDNSTimeout
+	if raw := parser.GetString("dns_timeout", "", ""); raw != "" {
+		if d, err := time.ParseDuration(raw); err != nil || d <= 0 {
+			vb.AddError("dns_timeout", "dns_timeout must be a positive Go duration such as 3s")
+		} else {
+			dnsTimeout = d
+		}
+	}
+	skipDNS := parser.GetBool("skip_dns_check", false)
+	if skipDNS {
+		warnings.add("skip_dns_check", "registry and index host names are not resolved, so a host resolving to a private or cloud metadata address is not rejected")
+	}
+	checkDNS := func(key, registryURL string) {
+		if skipDNS {
+			return
+		}
+		warning, err := checkRegistryDNS(ctx, p.getResolver(), registryURL, policy, dnsTimeout)
+		if err != nil {
+			vb.AddError(key, err.Error())
+		} else if warning != "" {
+			warnings.add(key, warning)
+		}
+	}
+	registryOK := true
 	registry := parser.GetString("registry", "", "")
 	if registry != "" {
-		if err := validateRegistryURL(registry); err != nil {
+		if err := validateRegistryURL(registry, policy); err != nil {
+			vb.AddError("registry", err.Error())
+			registryOK = false
+		} else {
+			checkDNS("registry", registry)
+		}
+	}
+
+	// Validate index URL if provided; it replaces registry rather than combining with it
+	index := parser.GetString("index", "", "")
+	if index != "" {
+		if registry != "" {
+			vb.AddError("index", "index and registry are mutually exclusive")
+		}
+		if err := validateIndexURL(index, policy); err != nil {
+			vb.AddError("index", err.Error())
+			registryOK = false
+		} else {
+			checkDNS("index", index)
+		}
+	}
+
+	registryIndex := parser.GetString("registry_index", "", "")
+	if err := validateRegistryIndex(registry, index, registryIndex, policy); err != nil {
+		vb.AddError("registry_index", err.Error())
+		registryOK = false
+	} else if registryIndex != "" {
+		checkDNS("registry_index", registryIndex)
+	}
+
+	// Validate the registries entries, each like registry or index
+	targets := parseRegistryTargets(config["registries"])
+	if err := validateRegistryTargets(targets, parser.GetString("registries_policy", "", ""), explicitKeys(config)); err != nil {
+		field := "registries"
+		if strings.HasPrefix(err.Error(), "registries_policy") {
+			field = "registries_policy"
+		}
+		vb.AddError(field, err.Error())
+	}
+	for i, target := range targets {
+		if target.Registry != "" {
+			if err := validateRegistryURL(target.Registry, policy); err != nil {
+				vb.AddError("registries", fmt.Sprintf("registries[%d]: invalid registry: %v", i, err))
+			}
+		}
+		if target.Index != "" {
+			if err := validateIndexURL(target.Index, policy); err != nil {
+				vb.AddError("registries", fmt.Sprintf("registries[%d]: invalid index: %v", i, err))
+			}
+		}
+	}
+
+	// Validate the channel_registries mapping
+	if err := validateChannelRegistries(parseStringMap(parser.GetMap("channel_registries")), policy, explicitKeys(config)); err != nil {
+		vb.AddError("channel_registries", err.Error())
+	}
+
+	// Check that a named registry is defined in cargo's configuration
+	if registryOK && parser.GetBool("check_registry_defined", false) {
+		definedCfg := &Config{
+			Registry:        registry,
+			RegistryIndex:   registryIndex,
+			CargoConfig:     parseStringMap(parser.GetMap("cargo_config")),
+			ManifestPath:    parser.GetString("manifest_path", "", "Cargo.toml"),
+			RegistryDefined: true,
+		}
+		if err := checkRegistryDefined(definedCfg, manifestWorkDir(definedCfg)); err != nil {
 			vb.AddError("registry", err.Error())
 		}
 	}
 
+	// Validate the registry CA certificate file
+	registryCACert := parser.GetString("registry_ca_cert", "", "")
+	var registryCAs *x509.CertPool
+	if err := validateRegistryCACertPath(registryCACert, parseStringMap(parser.GetMap("cargo_config"))); err != nil {
+		vb.AddError("registry_ca_cert", err.Error())
+		registryOK = false
+	} else if registryCAs, err = loadRegistryCACert(registryCACert); err != nil {
+		vb.AddError("registry_ca_cert", err.Error())
+		registryOK = false
+	}
+
+	// Validate the client certificate pair; cargo cannot be given it
+	clientCert := parser.GetString("client_cert", "", "")
+	clientKey := parser.GetString("client_key", "", "")
+	var clientCertificate *tls.Certificate
+	if err := validateClientCertPair(clientCert, clientKey); err != nil {
+		field := "client_key"
+		if clientCert == "" {
+			field = "client_cert"
+		}
+		vb.AddError(field, err.Error())
+		registryOK = false
+	} else if clientCertificate, err = loadClientCert(clientCert, clientKey); err != nil {
+		vb.AddError("client_cert", err.Error())
+		registryOK = false
+	} else if clientCertificate != nil {
+		warnings.add("client_cert", clientCertCargoWarning)
+	}
+
+	// Validate the proxy and warn when it looks meant for another network
+	proxy := parser.GetString("proxy", "", "")
+	noProxy := parser.GetStringSlice("no_proxy", nil)
+	if err := validateProxy(proxy, noProxy); err != nil {
+		field := "proxy"
+		if proxy == "" {
+			field = "no_proxy"
+		}
+		vb.AddError(field, err.Error())
+		registryOK = false
+	} else if w := proxyWarning(&Config{Proxy: proxy, Registry: registry, Index: index, RegistryIndex: registryIndex, InsecureHosts: parser.GetStringSlice("insecure_hosts", nil)}); w != "" {
+		warnings.add("proxy", w)
+	}
+
+	// Request the registry index when asked to, so a wrong URL fails here
+	// rather than after the verify build
+	if parser.GetBool("check_registry_connectivity", false) && registryOK {
+		field := "registry"
+		switch {
+		case index != "":
+			field = "index"
+		case registryIndex != "":
+			field = "registry_index"
+		}
+		connCfg := &Config{Registry: registry, Index: index, RegistryIndex: registryIndex, RegistryCAs: registryCAs, ClientCertificate: clientCertificate, Proxy: proxy, NoProxy: noProxy}
+		if target, reason := connectivityTarget(connCfg); target == "" {
+			warnings.add("check_registry_connectivity", "registry connectivity not checked: "+reason)
+		} else if result := p.checkConnectivity(ctx, connCfg, target); result.err != nil {
+			vb.AddError(field, result.err.Error())
+		} else if result.warning != "" {
+			warnings.add(field, result.warning)
+		}
+	}
+
+	// Validate failure policy
+	if err := validateFailurePolicy(parser.GetString("failure_policy", "", failurePolicyHard)); err != nil {
+		vb.AddError("failure_policy", err.Error())
+	}
+
+	// Validate the compatibility level and warn when it turns off newer defaults
+	compatLevel := parser.GetString("compat_level", "", "")
+	compatOptIn := parser.GetStringSlice("compat_features", nil)
+	if err := validateCompat(compatLevel, compatOptIn); err != nil {
+		vb.AddError("compat_level", err.Error())
+	} else if w := compatWarning(compatLevel, compatOptIn); w != "" {
+		warnings.add("compat_level", w)
+	}
+
+	// Validate the credential provider and that no token is configured with it
+	if provider := parser.GetString("credential_provider", "", ""); provider != "" {
+		providerCfg := &Config{
+			CredentialProvider: provider,
+			Registry:           registry,
+			Index:              index,
+			Token:              parser.GetString("token", "", ""),
+			Tokens:             parseStringMap(parser.GetMap("tokens")),
+			TokenFile:          parser.GetString("token_file", "", ""),
+			TokenCommand:       parseTokenCommand(config["token_command"]),
+			TokenKeyring:       parseKeyringEntry(parser.GetMap("token_keyring")),
+			TokenViaEnv:        parser.GetBool("token_via_env", false),
+		}
+		if err := validateCredentialProvider(providerCfg); err != nil {
+			vb.AddError("credential_provider", err.Error())
+		}
+	}
+
+	// Validate the CodeArtifact domain and that no token is configured with it
+	if codeArtifact := parseCodeArtifactConfig(parser.GetMap("codeartifact")); codeArtifact != nil {
+		codeArtifactCfg := &Config{
+			CodeArtifact:       codeArtifact,
+			Registry:           registry,
+			Index:              index,
+			Token:              parser.GetString("token", "", ""),
+			Tokens:             parseStringMap(parser.GetMap("tokens")),
+			TokenFile:          parser.GetString("token_file", "", ""),
+			TokenCommand:       parseTokenCommand(config["token_command"]),
+			TokenKeyring:       parseKeyringEntry(parser.GetMap("token_keyring")),
+			CredentialProvider: parser.GetString("credential_provider", "", ""),
+		}
+		if err := validateCodeArtifact(codeArtifactCfg); err != nil {
+			vb.AddError("codeartifact", err.Error())
+		}
+	}
+
+	// Validate that trusted publishing targets crates.io without another token source
+	if parser.GetBool("trusted_publishing", false) {
+		trustedCfg := &Config{
+			TrustedPublishing:  true,
+			Registry:           registry,
+			Index:              index,
+			Token:              parser.GetString("token", "", ""),
+			Tokens:             parseStringMap(parser.GetMap("tokens")),
+			TokenFile:          parser.GetString("token_file", "", ""),
+			TokenCommand:       parseTokenCommand(config["token_command"]),
+			TokenKeyring:       parseKeyringEntry(parser.GetMap("token_keyring")),
+			CodeArtifact:       parseCodeArtifactConfig(parser.GetMap("codeartifact")),
+			CredentialProvider: parser.GetString("credential_provider", "", ""),
+		}
+		if err := validateTrustedPublishing(trustedCfg); err != nil {
+			vb.AddError("trusted_publishing", err.Error())
+		}
+	}
+
+	// Validate that the token can be moved to the environment
+	if parser.GetBool("token_via_env", false) {
+		if err := validateTokenViaEnv(registry, index); err != nil {
+			vb.AddError("token_via_env", err.Error())
+		}
+	}
+
+	// Validate feature names
+	for i, feature := range parser.GetStringSlice("features", nil) {
+		if err := validateArgValue(feature); err != nil {
+			vb.AddError("features", fmt.Sprintf("features[%d]: %v", i, err))
+		}
+	}
+
+	// Validate target triple if provided
+	if err := validateTargetTriple(parser.GetString("target", "", "")); err != nil {
+		vb.AddError("target", err.Error())
+	}
+
+	// Validate build directory if provided
+	if err := validateTargetDir(parser.GetString("target_dir", "", ""), parser.GetString("target_dir_root", "", "")); err != nil {
+		vb.AddError("target_dir", err.Error())
+	}
+
+	// Validate the temporary directory root if provided
+	if err := validateTempDir(parser.GetString("temp_dir", "", "")); err != nil {
+		vb.AddError("temp_dir", err.Error())
+	}
+
+	// Validate token file location if provided
+	if err := validateTokenFile(parser.GetString("token_file", "", ""), parser.GetString("token_file_root", "", "")); err != nil {
+		vb.AddError("token_file", err.Error())
+	}
+
+	// Validate the packaging check
+	if err := validatePackageCheck(parser.GetString("package_check", "", ""), parser.GetStringSlice("required_paths", nil), parser.GetInt("min_package_files", 1)); err != nil {
+		vb.AddError("package_check", err.Error())
+	}
+	if err := validateMaxPackageSize(parser.GetInt("max_package_size", defaultMaxPackageSize)); err != nil {
+		vb.AddError("max_package_size", err.Error())
+	}
+	for _, key := range []string{"package_must_include", "package_must_not_include"} {
+		if err := validatePackageGlobs(key, parser.GetStringSlice(key, nil)); err != nil {
+			vb.AddError(key, err.Error())
+		}
+	}
+
+	// Validate the token value without revealing it
+	if err := validateToken(strings.TrimSpace(parser.GetString("token", "", ""))); err != nil {
+		vb.AddError("token", err.Error())
+	}
+
+	// Validate per-registry tokens
+	if err := validateTokens(parseStringMap(parser.GetMap("tokens"))); err != nil {
+		vb.AddError("tokens", err.Error())
+	}
+
+	// Validate the token variable name; it may only be set at execution time
+	if name := parser.GetString("token_env", "", ""); name != "" {
+		if err := validateTokenEnv(name); err != nil {
+			vb.AddError("token_env", err.Error())
+		} else if os.Getenv(name) == "" {
+			warnings.add("token_env", fmt.Sprintf("environment variable %s is not set; it must be set when the plugin runs", name))
+		}
+	}
+
+	// Validate the token command if provided
+	if err := validateTokenCommand(parseTokenCommand(config["token_command"]), parser.GetString("token_file", "", "")); err != nil {
+		vb.AddError("token_command", err.Error())
+	}
+
+	// Validate the keyring entry if provided
+	if err := validateKeyringEntry(parseKeyringEntry(parser.GetMap("token_keyring")), parser.GetString("token_file", "", ""), parseTokenCommand(config["token_command"])); err != nil {
+		vb.AddError("token_keyring", err.Error())
+	}
+	if timeout := parser.GetString("token_command_timeout", "", ""); timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			vb.AddError("token_command_timeout", "token_command_timeout must be a positive Go duration such as 30s")
+		}
+	}
+
+	// Validate crate name override and flag a mismatch with the manifest
+	if crate := parser.GetString("crate_name", "", ""); crate != "" {
+		if err := validateCrateName(crate); err != nil {
+			vb.AddError("crate_name", err.Error())
+		} else if w := crateNameMismatch(&Config{CrateName: crate, ManifestPath: manifestPath}); w != "" {
+			warnings.add("crate_name", w)
+		}
+	}
+
+	// Validate cargo configuration overrides
+	cargoConfig := parseStringMap(parser.GetMap("cargo_config"))
+	for _, key := range sortedKeys(cargoConfig) {
+		if err := validateCargoConfigEntry(key, cargoConfig[key]); err != nil {
+			vb.AddError("cargo_config", err.Error())
+		}
+	}
+
+	// Validate the cargo environment
+	if err := validateEnvConfig(parser.GetString("env_mode", "", envModeInherit), parseStringMap(parser.GetMap("env")), parser.GetStringSlice("env_allowlist", nil)); err != nil {
+		vb.AddError("env_mode", err.Error())
+	}
+
+	// Validate the audit log path
+	if err := validateAuditLog(parser.GetString("audit_log", "", "")); err != nil {
+		vb.AddError("audit_log", err.Error())
+	}
+
+	// Validate cargo executable override
+	if err := validateCargoPath(parser.GetString("cargo_path", "", "")); err != nil {
+		vb.AddError("cargo_path", err.Error())
+	}
+
+	// Validate minimum cargo version if provided
+	if minVersion := parser.GetString("min_cargo_version", "", ""); minVersion != "" {
+		if _, err := parseMinCargoVersion(minVersion); err != nil {
+			vb.AddError("min_cargo_version", err.Error())
+		}
+	}
+
+	// Validate toolchain if provided
+	toolchain := parser.GetString("toolchain", "", "")
+	if err := validateToolchain(toolchain); err != nil {
+		vb.AddError("toolchain", err.Error())
+	}
+
+	// Validate unstable flags
+	if err := validateUnstableFlags(parser.GetStringSlice("unstable_flags", nil), configuredToolchain(toolchain)); err != nil {
+		vb.AddError("unstable_flags", err.Error())
+	}
+
+	// Validate forbidden license identifiers
+	for i, id := range parser.GetStringSlice("forbidden_licenses", nil) {
+		if err := validateLicenseID(id); err != nil {
+			vb.AddError("forbidden_licenses", fmt.Sprintf("forbidden_licenses[%d]: %v", i, err))
+		}
+	}
+
+	// Validate output verbosity
+	verbose := parseVerbosity(config["verbose"])
+	if verbose < 0 || verbose > 2 {
+		vb.AddError("verbose", "verbose must be true, false, or a level from 0 to 2")
+	}
+	if parser.GetBool("quiet", false) && verbose > 0 {
+		vb.AddError("quiet", "quiet and verbose are mutually exclusive")
+	}
+
+	// Validate passthrough arguments
+	for i, arg := range parser.GetStringSlice("extra_args", nil) {
+		if err := validateExtraArg(arg); err != nil {
+			vb.AddError("extra_args", fmt.Sprintf("extra_args[%d] %q: %v", i, arg, err))
+		}
+	}
+
+	// Cargo network settings must be in range and not repeat cargo_config
+	if err := validateCargoNet(parseNetRetry(config["net_retry"]), parser.GetBool("git_fetch_with_cli", false), parseStringMap(parser.GetMap("cargo_config"))); err != nil {
+		field := "net_retry"
+		if strings.HasPrefix(err.Error(), "git_fetch_with_cli") {
+			field = "git_fetch_with_cli"
+		}
+		vb.AddError(field, err.Error())
+	}
+
 	// Jobs must be positive if specified
 	if jobs, ok := config["jobs"].(float64); ok {
 		if jobs < 0 {
@@ -410,8 +2820,54 @@ func (p *CratesPlugin) Validate(_ context.Context, config map[string]any) (*plug
 		}
 	}
 
+	// Durations must be valid and non-negative
+	for _, key := range []string{"rate_limit_max_wait", "publish_timeout", "post_publish_wait"} {
+		if raw := parser.GetString(key, "", ""); raw != "" {
+			if d, err := time.ParseDuration(raw); err != nil || d < 0 {
+				vb.AddError(key, fmt.Sprintf("%s must be a non-negative duration (e.g. 10m)", key))
+			}
+		}
+	}
+
+	// Quota threshold must not be negative
+	if parser.GetInt("quota_warn_threshold", 0) < 0 {
+		vb.AddError("quota_warn_threshold", "quota_warn_threshold must be zero or a positive integer")
+	}
+
+	// Metrics block needs a host, a valid port, and a known format
+	if raw := parser.GetMap("metrics"); raw != nil {
+		metrics := parseMetricsConfig(raw)
+		if metrics.Host == "" {
+			vb.AddError("metrics.host", "metrics.host is required when metrics are configured")
+		}
+		if metrics.Port < 1 || metrics.Port > 65535 {
+			vb.AddError("metrics.port", "metrics.port must be between 1 and 65535")
+		}
+		if metrics.Format != metricsFormatStatsd && metrics.Format != metricsFormatDogstatsd {
+			vb.AddError("metrics.format", "metrics.format must be one of: statsd, dogstatsd")
+		}
+	}
+
+	// frozen already implies locked and offline; cargo tolerates the redundancy
+	if parser.GetBool("frozen", false) {
+		for _, key := range []string{"locked", "offline"} {
+			if parser.GetBool(key, false) {
+				warnings.add(key, fmt.Sprintf("%s is redundant when frozen is enabled", key))
+			}
+		}
+	}
+
 	// Token is optional during validation - it can be set via env at runtime
 	// No warning needed here since it's checked at execution time
 
-	return vb.Build(), nil
+	// A malformed built-in schema is a plugin bug; surface it instead of
+	// letting hosts silently skip schema validation
+	if configSchemaErr != nil {
+		vb.AddError("config_schema", configSchemaErr.Error())
+	}
+
+	// Warnings are reported after Build so they do not affect validity
+	resp := vb.Build()
+	resp.Errors = append(resp.Errors, warnings...)
+	return resp, nil
 }
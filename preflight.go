@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// errNoToken explains how to provide the API token.
+const errNoToken = "no API token provided: set token, tokens, token_file, token_command, token_keyring or credential_provider in config, or the CARGO_REGISTRY_TOKEN environment variable (CARGO_REGISTRIES_<NAME>_TOKEN for a named registry)"
+
+// errCargoNotFound replaces the executor's error when cargo cannot be started,
+// so the failure is not mistaken for a problem with the crate.
+var errCargoNotFound = errors.New("cargo was not found on this runner; install Rust (https://rustup.rs) or set cargo_path")
+
+// isCargoNotFound reports whether err means the cargo binary does not exist.
+// A bare name that is not on PATH fails with exec.ErrNotFound on every
+// platform; a missing absolute cargo_path fails to start with a PathError
+// naming the binary, unlike a missing working directory.
+func isCargoNotFound(err error, binary string) bool {
+	if errors.Is(err, exec.ErrNotFound) {
+		return true
+	}
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) && pathErr.Path == binary && errors.Is(pathErr.Err, fs.ErrNotExist)
+}
+
+// cargoNotFoundResponse reports a missing cargo binary, keeping any outputs
+// collected so far.
+func cargoNotFoundResponse(outputs map[string]any) *plugin.ExecuteResponse {
+	if outputs == nil {
+		outputs = map[string]any{}
+	}
+	outputs["cargo_found"] = false
+	return &plugin.ExecuteResponse{
+		Success: false,
+		Error:   errCargoNotFound.Error(),
+		Outputs: outputs,
+	}
+}
+
+// preflightResult records the environment checks run before publishing.
+type preflightResult struct {
+	cargoFound         bool
+	manifestFound      bool
+	tokenPresent       bool
+	credentialProvider bool
+	// readme is the readme cargo will package; readmeFailed is set when the
+	// manifest names one that does not exist.
+	readme       string
+	readmeFailed bool
+	// patched is set when the manifest or workspace root has [patch] or
+	// [replace] overrides.
+	patched  bool
+	problems []string
+}
+
+// preflight checks that cargo resolves, the manifest and the readme it names
+// exist, the manifest has no [patch] or [replace] overrides, and a token or credential provider is available, collecting every
+// problem instead of stopping at the first.
+func (p *CratesPlugin) preflight(cfg *Config) *preflightResult {
+	result := &preflightResult{}
+
+	if _, err := p.getExecutor().LookPath(cargoBinary(cfg)); err == nil {
+		result.cargoFound = true
+	} else {
+		result.problems = append(result.problems, fmt.Sprintf("%v (looked up %q: %v)", errCargoNotFound, cargoBinary(cfg), err))
+	}
+
+	if info, err := os.Stat(cfg.ManifestPath); err == nil && info.Mode().IsRegular() {
+		result.manifestFound = true
+	} else {
+		result.problems = append(result.problems, fmt.Sprintf("manifest %s does not exist or is not a file", cfg.ManifestPath))
+	}
+
+	// A readme the manifest names must exist, or cargo fails while packaging
+	if result.manifestFound {
+		readme, err := checkReadme(cfg)
+		result.readme = readme
+		if err != nil {
+			result.readmeFailed = true
+			result.problems = append(result.problems, err.Error())
+		}
+	}
+
+	// Overrides would make the published crate differ from the verified one
+	if result.manifestFound && patchCheckEnabled(cfg) {
+		if err := checkPatches(cfg); err != nil {
+			result.patched = true
+			result.problems = append(result.problems, err.Error())
+		}
+	}
+
+	// Cargo asks a credential provider for the token itself
+	switch {
+	case cfg.Token != "":
+		result.tokenPresent = true
+	case cfg.CredentialProvider != "":
+		result.credentialProvider = true
+	default:
+		result.problems = append(result.problems, errNoToken)
+	}
+
+	return result
+}
+
+// tokenMissing reports whether the token check failed.
+func (r *preflightResult) tokenMissing() bool {
+	return !r.tokenPresent && !r.credentialProvider
+}
+
+// failureKind returns the metrics error kind for a failed preflight.
+func (r *preflightResult) failureKind() string {
+	if len(r.problems) == 1 && r.tokenMissing() {
+		return "no_token"
+	}
+	return "preflight_failed"
+}
+
+// err returns a single error listing every failed check, or nil.
+func (r *preflightResult) err() error {
+	switch len(r.problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("preflight check failed: %s", r.problems[0])
+	default:
+		return fmt.Errorf("preflight checks failed:\n- %s", strings.Join(r.problems, "\n- "))
+	}
+}
+
+// addOutputs records the check results for dashboards.
+func (r *preflightResult) addOutputs(outputs map[string]any) {
+	outputs["cargo_found"] = r.cargoFound
+	outputs["manifest_found"] = r.manifestFound
+	outputs["token_present"] = r.tokenPresent
+	if r.readme != "" {
+		outputs["readme"] = r.readme
+	}
+}
+
+// addDecisions records a block decision for every failed check.
+func (r *preflightResult) addDecisions(decisions *decisionLog, subject string) {
+	if !r.cargoFound {
+		decisions.add(subject, decisionBlock, "cargo was not found", "preflight", "cargo_path")
+	}
+	if !r.manifestFound {
+		decisions.add(subject, decisionBlock, "manifest does not exist", "preflight", "manifest_path")
+	}
+	if r.readmeFailed {
+		decisions.add(subject, decisionBlock, "readme set by the manifest does not exist", "preflight", "manifest_path")
+	}
+	if r.patched {
+		decisions.add(subject, decisionBlock, "manifest has [patch] or [replace] overrides", "patch_check", "allow_patched")
+	}
+	if r.tokenMissing() {
+		decisions.add(subject, decisionBlock, "no API token provided", "preflight", "token")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestExecutePreflight(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            map[string]any
+		cargoMissing      bool
+		wantSuccess       bool
+		wantCargoFound    bool
+		wantManifestFound bool
+		wantTokenPresent  bool
+		wantErrorContains []string
+	}{
+		{
+			name:              "all checks pass",
+			config:            map[string]any{"token": "test-token"},
+			wantSuccess:       true,
+			wantCargoFound:    true,
+			wantManifestFound: true,
+			wantTokenPresent:  true,
+		},
+		{
+			name:              "cargo missing",
+			config:            map[string]any{"token": "test-token"},
+			cargoMissing:      true,
+			wantManifestFound: true,
+			wantTokenPresent:  true,
+			wantErrorContains: []string{"preflight check failed", `cargo was not found on this runner`, `looked up "cargo"`},
+		},
+		{
+			name:              "manifest missing",
+			config:            map[string]any{"token": "test-token", "manifest_path": "crates/missing/Cargo.toml"},
+			wantCargoFound:    true,
+			wantTokenPresent:  true,
+			wantErrorContains: []string{"manifest crates/missing/Cargo.toml does not exist"},
+		},
+		{
+			name:              "token missing",
+			config:            map[string]any{},
+			wantCargoFound:    true,
+			wantManifestFound: true,
+			wantErrorContains: []string{"no API token provided"},
+		},
+		{
+			name:         "all problems reported at once",
+			config:       map[string]any{"manifest_path": "crates/missing/Cargo.toml", "cargo_path": "cargo-custom"},
+			cargoMissing: true,
+			wantErrorContains: []string{
+				"preflight checks failed",
+				`looked up "cargo-custom"`,
+				"manifest crates/missing/Cargo.toml does not exist",
+				"no API token provided",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "")
+
+			mock := &MockCommandExecutor{}
+			if tt.cargoMissing {
+				mock.LookPathFunc = func(name string) (string, error) {
+					return "", errors.New("executable file not found in $PATH")
+				}
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("expected success=%v, got %v (error: %s)", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			for _, want := range tt.wantErrorContains {
+				if !strings.Contains(resp.Error, want) {
+					t.Errorf("expected error to contain %q, got %q", want, resp.Error)
+				}
+			}
+
+			if resp.Outputs["cargo_found"] != tt.wantCargoFound {
+				t.Errorf("cargo_found = %v, want %v", resp.Outputs["cargo_found"], tt.wantCargoFound)
+			}
+			if resp.Outputs["manifest_found"] != tt.wantManifestFound {
+				t.Errorf("manifest_found = %v, want %v", resp.Outputs["manifest_found"], tt.wantManifestFound)
+			}
+			if resp.Outputs["token_present"] != tt.wantTokenPresent {
+				t.Errorf("token_present = %v, want %v", resp.Outputs["token_present"], tt.wantTokenPresent)
+			}
+
+			if !tt.wantSuccess && len(mock.GetCalls()) != 0 {
+				t.Errorf("cargo must not run when preflight fails, got %d calls", len(mock.GetCalls()))
+			}
+		})
+	}
+}
+
+func TestIsCargoNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		binary string
+		want   bool
+	}{
+		{
+			name:   "not on PATH",
+			err:    &exec.Error{Name: "cargo", Err: exec.ErrNotFound},
+			binary: "cargo",
+			want:   true,
+		},
+		{
+			name:   "missing absolute cargo_path",
+			err:    &fs.PathError{Op: "fork/exec", Path: "/opt/rust/bin/cargo", Err: syscall.ENOENT},
+			binary: "/opt/rust/bin/cargo",
+			want:   true,
+		},
+		{
+			name:   "missing working directory",
+			err:    &fs.PathError{Op: "chdir", Path: "crates/missing", Err: syscall.ENOENT},
+			binary: "cargo",
+			want:   false,
+		},
+		{
+			name:   "cargo exit status",
+			err:    errors.New("exit status 101"),
+			binary: "cargo",
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCargoNotFound(tt.err, tt.binary); got != tt.want {
+				t.Errorf("isCargoNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteCargoNotFound(t *testing.T) {
+	notFound := func(_ context.Context, name string, _ ...string) ([]byte, error) {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+
+	tests := []struct {
+		name   string
+		hook   plugin.Hook
+		config map[string]any
+		dryRun bool
+	}{
+		{
+			name:   "publish",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token"},
+		},
+		{
+			name:   "min cargo version check",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token", "min_cargo_version": "1.74"},
+		},
+		{
+			name:   "package then publish",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"token": "test-token", "package_then_publish": true, "target_dir": "target"},
+		},
+		{
+			name:   "executed dry run",
+			hook:   plugin.HookPostPublish,
+			config: map[string]any{"execute_dry_run": true},
+			dryRun: true,
+		},
+		{
+			name:   "pre-publish verification",
+			hook:   plugin.HookPrePublish,
+			config: map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &MockCommandExecutor{RunFunc: notFound}
+			p := &CratesPlugin{cmdExecutor: mock}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    tt.hook,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+				DryRun:  tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success {
+				t.Fatal("expected failure")
+			}
+			if resp.Error != errCargoNotFound.Error() {
+				t.Errorf("error = %q, want %q", resp.Error, errCargoNotFound.Error())
+			}
+			if resp.Outputs["cargo_found"] != false {
+				t.Errorf("cargo_found = %v, want false", resp.Outputs["cargo_found"])
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// cargoProxyEnv is the environment form of cargo's http.proxy setting. The
+// proxy reaches cargo through the environment rather than --config, so
+// proxy credentials stay off the command line.
+const cargoProxyEnv = "CARGO_HTTP_PROXY"
+
+// validateProxy checks the proxy URL and the no_proxy entries.
+func validateProxy(proxy string, noProxy []string) error {
+	if proxy == "" {
+		if len(noProxy) > 0 {
+			return fmt.Errorf("no_proxy needs proxy")
+		}
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("proxy must be an http:// or https:// URL such as http://proxy.example.com:3128")
+	}
+	if u.Path != "" && u.Path != "/" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("proxy must not have a path, query or fragment")
+	}
+	for i, entry := range noProxy {
+		if entry == "" || strings.ContainsFunc(entry, func(r rune) bool { return r == ',' || r <= ' ' || r == 0x7f }) {
+			return fmt.Errorf("no_proxy[%d] %q must be a host, domain, IP or CIDR range without commas or whitespace", i, entry)
+		}
+	}
+	return nil
+}
+
+// redactProxy returns the proxy URL with its password masked, for outputs.
+func redactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return redactedValue
+	}
+	return u.Redacted()
+}
+
+// proxyPassword returns the password in the proxy URL, a secret to scrub.
+func proxyPassword(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// proxyEnv returns the environment entries that route cargo through proxy:
+// cargo's http.proxy, and no_proxy in the spellings libcurl and git read.
+func proxyEnv(cfg *Config) []string {
+	if cfg.Proxy == "" {
+		return nil
+	}
+	env := []string{cargoProxyEnv + "=" + cfg.Proxy}
+	if len(cfg.NoProxy) > 0 {
+		noProxy := strings.Join(cfg.NoProxy, ",")
+		env = append(env, "NO_PROXY="+noProxy, "no_proxy="+noProxy)
+	}
+	return env
+}
+
+// proxyFunc returns the proxy selection of the plugin's own registry
+// requests for proxy and no_proxy.
+func proxyFunc(cfg *Config) func(*http.Request) (*url.URL, error) {
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  cfg.Proxy,
+		HTTPSProxy: cfg.Proxy,
+		NoProxy:    strings.Join(cfg.NoProxy, ","),
+	}
+	selectProxy := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}
+}
+
+// proxyWarning warns when proxy is a private address while the registry is
+// public, which usually means the proxy was meant for another network.
+func proxyWarning(cfg *Config) string {
+	if cfg.Proxy == "" {
+		return ""
+	}
+	u, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return ""
+	}
+	proxyIP := net.ParseIP(u.Hostname())
+	if proxyIP == nil || !isPrivateIP(proxyIP) {
+		return ""
+	}
+	indexURL, reason := registryIndexURL(cfg)
+	if reason != "" {
+		return ""
+	}
+	registry, err := url.Parse(strings.TrimPrefix(indexURL, "sparse+"))
+	if err != nil {
+		return ""
+	}
+	host := registry.Hostname()
+	if host == "" || host == "localhost" || configRegistryPolicy(cfg).insecure(host) {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return ""
+	}
+	return fmt.Sprintf("proxy %s is a private address while registry host %s is public; check that the proxy is meant for this registry", u.Hostname(), host)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// quotaStateVersion is the on-disk format version of the quota state file.
+const quotaStateVersion = 1
+
+// quotaState is the persisted publish counter. Tokens are only ever stored as
+// salted fingerprints, so the file reveals nothing about the credentials.
+type quotaState struct {
+	Version int                       `json:"version"`
+	Salt    string                    `json:"salt"`
+	Counts  map[string]map[string]int `json:"counts"`
+}
+
+// defaultQuotaStateFile returns the default location of the quota state file.
+func defaultQuotaStateFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "relicta", "crates-publish-quota.json")
+}
+
+// loadQuotaState reads the state file, creating a fresh state with a new salt
+// when the file does not exist yet.
+func loadQuotaState(path string) (*quotaState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read quota state %s: %w", path, err)
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate quota salt: %w", err)
+		}
+		return &quotaState{
+			Version: quotaStateVersion,
+			Salt:    hex.EncodeToString(salt),
+			Counts:  map[string]map[string]int{},
+		}, nil
+	}
+
+	var state quotaState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse quota state %s: %w", path, err)
+	}
+	if state.Salt == "" {
+		return nil, fmt.Errorf("quota state %s has no salt", path)
+	}
+	if state.Counts == nil {
+		state.Counts = map[string]map[string]int{}
+	}
+	return &state, nil
+}
+
+// save writes the state atomically with owner-only permissions.
+func (s *quotaState) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create quota state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode quota state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write quota state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write quota state: %w", err)
+	}
+	return nil
+}
+
+// fingerprint returns the salted hash identifying a token in the state file.
+func (s *quotaState) fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(s.Salt + ":" + token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// count returns the number of publishes recorded for the token on the given day.
+func (s *quotaState) count(token string, day time.Time) int {
+	return s.Counts[s.fingerprint(token)][quotaDay(day)]
+}
+
+// record increments the publish count for the token on the given day and
+// drops entries older than the previous day.
+func (s *quotaState) record(token string, day time.Time) int {
+	fp := s.fingerprint(token)
+	days := s.Counts[fp]
+	if days == nil {
+		days = map[string]int{}
+		s.Counts[fp] = days
+	}
+
+	today := quotaDay(day)
+	yesterday := quotaDay(day.AddDate(0, 0, -1))
+	for d := range days {
+		if d != today && d != yesterday {
+			delete(days, d)
+		}
+	}
+
+	days[today]++
+	return days[today]
+}
+
+// quotaDay formats the UTC day used as the counter window key.
+func quotaDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// quotaTracker tracks publishes for a single token during one execution.
+type quotaTracker struct {
+	path      string
+	token     string
+	threshold int
+	now       time.Time
+	state     *quotaState
+}
+
+// newQuotaTracker loads the state for the configured token. It returns nil
+// when tracking is disabled or no token is available.
+func (p *CratesPlugin) newQuotaTracker(cfg *Config) (*quotaTracker, error) {
+	if cfg.QuotaWarnThreshold <= 0 || cfg.Token == "" {
+		return nil, nil
+	}
+
+	path := cfg.QuotaStateFile
+	if path == "" {
+		path = defaultQuotaStateFile()
+	}
+
+	state, err := loadQuotaState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &quotaTracker{
+		path:      path,
+		token:     cfg.Token,
+		threshold: cfg.QuotaWarnThreshold,
+		now:       p.getNow(),
+		state:     state,
+	}, nil
+}
+
+// count returns the publishes already recorded for today.
+func (q *quotaTracker) count() int {
+	return q.state.count(q.token, q.now)
+}
+
+// warning returns a message when the next publish brings the count to the threshold.
+func (q *quotaTracker) warning() string {
+	if next := q.count() + 1; next >= q.threshold {
+		return fmt.Sprintf("this token has %d publishes recorded today (UTC) including this one, reaching quota_warn_threshold of %d; the registry may start rejecting publishes", next, q.threshold)
+	}
+	return ""
+}
+
+// record persists a successful publish.
+func (q *quotaTracker) record() error {
+	q.state.record(q.token, q.now)
+	return q.state.save(q.path)
+}
+
+// quotaGuidance explains a quota rejection using the locally observed count.
+func (q *quotaTracker) quotaGuidance() string {
+	return fmt.Sprintf("%d publishes were recorded for this token today (UTC); crates.io publish limits are enforced over a rolling window, so wait for the advised retry time or ask help@crates.io to raise the limit", q.count())
+}
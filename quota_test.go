@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestQuotaStateFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	state, err := loadQuotaState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	day := time.Date(2024, 10, 1, 9, 0, 0, 0, time.UTC)
+	state.record("secret-token", day)
+	state.record("secret-token", day)
+	if err := state.save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), "secret-token") {
+		t.Error("state file must not contain the token")
+	}
+
+	reloaded, err := loadQuotaState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := reloaded.count("secret-token", day); got != 2 {
+		t.Errorf("expected count 2, got %d", got)
+	}
+	if got := reloaded.count("other-token", day); got != 0 {
+		t.Errorf("expected count 0 for other token, got %d", got)
+	}
+
+	other, err := loadQuotaState(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if other.fingerprint("secret-token") == reloaded.fingerprint("secret-token") {
+		t.Error("expected fingerprints to differ between salts")
+	}
+}
+
+func TestQuotaStateWindow(t *testing.T) {
+	state, err := loadQuotaState(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	day1 := time.Date(2024, 10, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	day4 := day1.AddDate(0, 0, 3)
+
+	state.record("token", day1)
+	if got := state.record("token", day2); got != 1 {
+		t.Errorf("expected new day to start at 1, got %d", got)
+	}
+
+	state.record("token", day4)
+	if days := state.Counts[state.fingerprint("token")]; len(days) != 1 {
+		t.Errorf("expected stale days to be pruned, got %v", days)
+	}
+}
+
+func TestPublishQuotaTracking(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("warns when threshold reached", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "quota.json")
+		p := &CratesPlugin{
+			cmdExecutor: &MockCommandExecutor{},
+			now:         func() time.Time { return now },
+		}
+		config := map[string]any{
+			"token":                "test-token",
+			"quota_warn_threshold": 2,
+			"quota_state_file":     statePath,
+			"max_package_size":     0,
+		}
+
+		for i := 1; i <= 2; i++ {
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resp.Success {
+				t.Fatalf("expected success, got error: %s", resp.Error)
+			}
+			if resp.Outputs["quota_publish_count"] != i {
+				t.Errorf("expected quota_publish_count %d, got %v", i, resp.Outputs["quota_publish_count"])
+			}
+
+			_, hasWarnings := resp.Outputs["warnings"]
+			if wantWarning := i == 2; hasWarnings != wantWarning {
+				t.Errorf("publish %d: expected warning=%v, got outputs %v", i, wantWarning, resp.Outputs)
+			}
+		}
+	})
+
+	t.Run("quota error includes observed count", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "quota.json")
+		state, err := loadQuotaState(statePath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		state.record("test-token", now)
+		state.record("test-token", now)
+		if err := state.save(statePath); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		p := &CratesPlugin{
+			cmdExecutor: &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					return []byte(rateLimitedOutput), errors.New("exit status 101")
+				},
+			},
+			now: func() time.Time { return now },
+		}
+
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook: plugin.HookPostPublish,
+			Config: map[string]any{
+				"token":                "test-token",
+				"quota_warn_threshold": 10,
+				"quota_state_file":     statePath,
+				"rate_limit_max_wait":  "0",
+			},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success {
+			t.Fatal("expected failure")
+		}
+		if !strings.Contains(resp.Error, "2 publishes were recorded for this token today") {
+			t.Errorf("expected observed count in error, got '%s'", resp.Error)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"token": "test-token"},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := resp.Outputs["quota_publish_count"]; ok {
+			t.Error("expected no quota tracking without a threshold")
+		}
+	})
+}
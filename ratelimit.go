@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRateLimitWait is used when a 429 response does not say how long to wait.
+	defaultRateLimitWait = time.Minute
+	// defaultRateLimitMaxWait bounds the total time spent waiting on rate limits.
+	defaultRateLimitMaxWait = 10 * time.Minute
+)
+
+var (
+	// retryAfterDatePattern matches crates.io's "Please try again after <HTTP date>" hint.
+	retryAfterDatePattern = regexp.MustCompile(`(?i)try again after ([A-Za-z]{3}, \d{1,2} [A-Za-z]{3} \d{4} \d{2}:\d{2}:\d{2} GMT)`)
+	// retryAfterSecondsPattern matches "retry after N seconds" and "Retry-After: N" hints.
+	retryAfterSecondsPattern = regexp.MustCompile(`(?i)retry[- ]after:? (\d+)( seconds?)?`)
+	// rateLimitedPattern matches cargo's report of a 429 response from the registry.
+	rateLimitedPattern = regexp.MustCompile(`(?i)status 429|too many requests`)
+)
+
+// rateLimitError is returned when a rate-limited publish cannot be retried in time.
+type rateLimitError struct {
+	registry string
+	retryAt  time.Time
+	maxWait  time.Duration
+	cause    error
+}
+
+func (e *rateLimitError) Error() string {
+	retryAt := e.retryAt.UTC().Format(time.RFC1123)
+	if e.cause != nil {
+		return fmt.Sprintf("cargo publish was rate limited by %s and waiting was interrupted: %v (retry after %s)", e.registry, e.cause, retryAt)
+	}
+	return fmt.Sprintf("cargo publish was rate limited by %s: retry after %s (exceeds rate_limit_max_wait of %s or the context deadline)", e.registry, retryAt, e.maxWait)
+}
+
+func (e *rateLimitError) Unwrap() error {
+	return e.cause
+}
+
+// publishStats describes the rate-limit retries performed during a publish.
+type publishStats struct {
+	retries int
+	waited  time.Duration
+}
+
+// runCargoPublish runs cargo with the given arguments, waiting for the
+// server-advised time and retrying when the registry responds with 429.
+func (p *CratesPlugin) runCargoPublish(ctx context.Context, cfg *Config, workDir string, args []string) ([]byte, publishStats, error) {
+	var stats publishStats
+	for {
+		output, err := p.runCargoWithEnv(ctx, cfg, workDir, append(tokenEnv(cfg), credentialProviderEnv(cfg)...), args...)
+
+		if err == nil || !isRateLimited(string(output)) {
+			return output, stats, err
+		}
+
+		now := p.getNow()
+		retryAt, ok := parseRetryAfter(string(output), now)
+		if !ok {
+			retryAt = now.Add(defaultRateLimitWait)
+		}
+		wait := retryAt.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+
+		rlErr := &rateLimitError{registry: p.getRegistryName(cfg), retryAt: retryAt, maxWait: cfg.RateLimitMaxWait}
+		if deadline, ok := ctx.Deadline(); ok && retryAt.After(deadline) {
+			return output, stats, rlErr
+		}
+		if stats.waited+wait > cfg.RateLimitMaxWait {
+			return output, stats, rlErr
+		}
+
+		if err := p.getSleeper()(ctx, wait); err != nil {
+			rlErr.cause = err
+			return output, stats, rlErr
+		}
+		stats.retries++
+		stats.waited += wait
+	}
+}
+
+// isRateLimited reports whether cargo output indicates an HTTP 429 response.
+func isRateLimited(output string) bool {
+	return rateLimitedPattern.MatchString(output)
+}
+
+// parseRetryAfter extracts the server-advised retry time from cargo output.
+// It returns the absolute time at which a retry is allowed and whether a hint was found.
+func parseRetryAfter(output string, now time.Time) (time.Time, bool) {
+	if m := retryAfterDatePattern.FindStringSubmatch(output); m != nil {
+		if t, err := time.Parse(time.RFC1123, m[1]); err == nil {
+			return t, true
+		}
+	}
+
+	if m := retryAfterSecondsPattern.FindStringSubmatch(output); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil {
+			return now.Add(time.Duration(secs) * time.Second), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// getSleeper returns the sleep function, defaulting to sleepContext.
+func (p *CratesPlugin) getSleeper() func(ctx context.Context, d time.Duration) error {
+	if p.sleep != nil {
+		return p.sleep
+	}
+	return sleepContext
+}
+
+// getNow returns the current time, using the injected clock when set.
+func (p *CratesPlugin) getNow() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
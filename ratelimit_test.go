@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+const rateLimitedOutput = `error: failed to publish to registry at https://crates.io
+
+Caused by:
+  the remote server responded with an error (status 429 Too Many Requests): You have published too many versions of this crate in the last 24 hours. Please try again after Tue, 01 Oct 2024 12:05:00 GMT or email help@crates.io to have your limit increased.`
+
+func TestIsRateLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{name: "crates.io 429 response", output: rateLimitedOutput, want: true},
+		{name: "lowercase too many requests", output: "error: too many requests", want: true},
+		{name: "unrelated failure", output: "error: crate version `1.0.0` is already uploaded", want: false},
+		{name: "version containing 429", output: "Uploading foo v1.429.0", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRateLimited(tt.output); got != tt.want {
+				t.Errorf("isRateLimited() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "HTTP date from crates.io",
+			output: rateLimitedOutput,
+			want:   time.Date(2024, 10, 1, 12, 5, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "retry after seconds",
+			output: "status 429 Too Many Requests: retry after 30 seconds",
+			want:   now.Add(30 * time.Second),
+			wantOK: true,
+		},
+		{
+			name:   "Retry-After header echo",
+			output: "status 429 Too Many Requests\nRetry-After: 90",
+			want:   now.Add(90 * time.Second),
+			wantOK: true,
+		},
+		{
+			name:   "no hint",
+			output: "status 429 Too Many Requests",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.output, now)
+			if ok != tt.wantOK {
+				t.Fatalf("parseRetryAfter() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !got.Equal(tt.want) {
+				t.Errorf("parseRetryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishRateLimitRetry(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		config            map[string]any
+		failures          int
+		wantSuccess       bool
+		wantCalls         int
+		wantSleeps        []time.Duration
+		wantErrorContains string
+	}{
+		{
+			name:        "retries after advised wait",
+			config:      map[string]any{"token": "test-token", "max_package_size": 0},
+			failures:    1,
+			wantSuccess: true,
+			wantCalls:   2,
+			wantSleeps:  []time.Duration{5 * time.Minute},
+		},
+		{
+			name:              "advised wait exceeds rate_limit_max_wait",
+			config:            map[string]any{"token": "test-token", "max_package_size": 0, "rate_limit_max_wait": "1m"},
+			failures:          1,
+			wantSuccess:       false,
+			wantCalls:         1,
+			wantErrorContains: "retry after Tue, 01 Oct 2024 12:05:00 UTC",
+		},
+		{
+			name:              "zero max wait disables retries",
+			config:            map[string]any{"token": "test-token", "max_package_size": 0, "rate_limit_max_wait": "0"},
+			failures:          1,
+			wantSuccess:       false,
+			wantCalls:         1,
+			wantErrorContains: "rate limited by crates.io",
+		},
+		{
+			name:              "cumulative waits are bounded",
+			config:            map[string]any{"token": "test-token", "max_package_size": 0, "rate_limit_max_wait": "8m"},
+			failures:          2,
+			wantSuccess:       false,
+			wantCalls:         2,
+			wantSleeps:        []time.Duration{5 * time.Minute},
+			wantErrorContains: "exceeds rate_limit_max_wait of 8m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					calls++
+					if calls <= tt.failures {
+						return []byte(rateLimitedOutput), errors.New("exit status 101")
+					}
+					return []byte("Uploaded successfully"), nil
+				},
+			}
+
+			var sleeps []time.Duration
+			p := &CratesPlugin{
+				cmdExecutor: mock,
+				now:         func() time.Time { return now },
+				sleep: func(ctx context.Context, d time.Duration) error {
+					sleeps = append(sleeps, d)
+					return nil
+				},
+			}
+
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.Success != tt.wantSuccess {
+				t.Errorf("expected success=%v, got success=%v, error=%s", tt.wantSuccess, resp.Success, resp.Error)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d cargo calls, got %d", tt.wantCalls, calls)
+			}
+			if len(sleeps) != len(tt.wantSleeps) {
+				t.Fatalf("expected sleeps %v, got %v", tt.wantSleeps, sleeps)
+			}
+			for i := range sleeps {
+				if sleeps[i] != tt.wantSleeps[i] {
+					t.Errorf("sleep %d: expected %v, got %v", i, tt.wantSleeps[i], sleeps[i])
+				}
+			}
+			if tt.wantErrorContains != "" && !strings.Contains(resp.Error, tt.wantErrorContains) {
+				t.Errorf("expected error to contain '%s', got '%s'", tt.wantErrorContains, resp.Error)
+			}
+		})
+	}
+}
+
+func TestPublishRateLimitContextDeadline(t *testing.T) {
+	now := time.Now()
+	mock := &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return []byte("status 429 Too Many Requests: retry after 600 seconds"), errors.New("exit status 101")
+		},
+	}
+	p := &CratesPlugin{
+		cmdExecutor: mock,
+		now:         func() time.Time { return now },
+		sleep: func(ctx context.Context, d time.Duration) error {
+			t.Fatal("did not expect to sleep past the context deadline")
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Minute))
+	defer cancel()
+
+	resp, err := p.Execute(ctx, plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success {
+		t.Fatal("expected failure when retry time is past the deadline")
+	}
+	if !strings.Contains(resp.Error, "retry after") {
+		t.Errorf("expected error to include retry time, got '%s'", resp.Error)
+	}
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// registryProbeTimeout bounds the registry reachability preflight.
+const registryProbeTimeout = 10 * time.Second
+
+// cratesIOIndex is the crates.io sparse index.
+const cratesIOIndex = "sparse+https://index.crates.io/"
+
+// cratesIOIndexConfig is the configuration file of the crates.io sparse index.
+const cratesIOIndexConfig = "https://index.crates.io/config.json"
+
+// Registry preflight results reported as registry_preflight.
+const (
+	registryReachable   = "reachable"
+	registryUnreachable = "unreachable"
+	registrySkipped     = "skipped"
+)
+
+// registryPreflight is the outcome of the registry reachability check.
+type registryPreflight struct {
+	status   string
+	endpoint string
+	latency  time.Duration
+	// reason explains a skipped check.
+	reason string
+	// httpStatus and warning are set by check_registry_connectivity.
+	httpStatus int
+	warning    string
+	err        error
+}
+
+// registryIndexURL returns the index URL of the configured registry, or the
+// reason it is not known to the plugin.
+func registryIndexURL(cfg *Config) (indexURL, reason string) {
+	if cfg.Index != "" {
+		return cfg.Index, ""
+	}
+	if cfg.RegistryIndex != "" {
+		return cfg.RegistryIndex, ""
+	}
+	if strings.Contains(cfg.Registry, "://") {
+		return cfg.Registry, ""
+	}
+	if cfg.Registry == "" || cfg.Registry == cratesIORegistry {
+		return cratesIOIndex, ""
+	}
+	// Cargo reads the index of a named registry from its configuration,
+	// which the plugin only sees when it is set in the environment
+	envName := registryEnvPrefix(cfg.Registry) + "INDEX"
+	if indexURL = os.Getenv(envName); indexURL == "" {
+		return "", fmt.Sprintf("the index of registry %s is not known to the plugin (set %s to check it)", cfg.Registry, envName)
+	}
+	return indexURL, ""
+}
+
+// sparseIndexBase returns the HTTP base URL of a sparse+ index, ending in a
+// slash, and whether indexURL is a sparse index at all.
+func sparseIndexBase(indexURL string) (string, bool) {
+	sparse, ok := strings.CutPrefix(indexURL, "sparse+")
+	if !ok {
+		return "", false
+	}
+	if !strings.HasSuffix(sparse, "/") {
+		sparse += "/"
+	}
+	return sparse, true
+}
+
+// registryProbeTarget returns the network and address to probe for the
+// configured registry: the config.json of a sparse index over HTTP, or a TCP
+// dial to the host of a git index. When the endpoint cannot be determined it
+// returns the reason instead.
+func registryProbeTarget(cfg *Config) (network, address, reason string) {
+	indexURL, reason := registryIndexURL(cfg)
+	if reason != "" {
+		return "", "", reason
+	}
+
+	if base, ok := sparseIndexBase(indexURL); ok {
+		return "http", base + "config.json", ""
+	}
+
+	u, err := url.Parse(indexURL)
+	if err != nil || u.Hostname() == "" {
+		return "", "", fmt.Sprintf("index %s has no host to check", indexURL)
+	}
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		case "ssh":
+			port = "22"
+		case "git":
+			port = "9418"
+		default:
+			return "", "", fmt.Sprintf("index %s uses an unsupported scheme", indexURL)
+		}
+	}
+	return "tcp", net.JoinHostPort(u.Hostname(), port), ""
+}
+
+// registryPreflightTarget returns the endpoint checkRegistryReachable probes,
+// or the reason the check is skipped.
+func registryPreflightTarget(cfg *Config) (network, address, skipReason string) {
+	if cfg.SkipPreflight {
+		return "", "", "disabled by skip_preflight"
+	}
+	if cfg.Offline || cfg.Frozen {
+		return "", "", "cargo runs in offline mode"
+	}
+	return registryProbeTarget(cfg)
+}
+
+// checkRegistryReachable probes the registry endpoint before anything is built,
+// so an unreachable registry fails in seconds instead of after the verify
+// build. With check_registry_connectivity an index served over HTTP is
+// requested with checkConnectivity instead. It is skipped with
+// skip_preflight and in offline mode.
+func (p *CratesPlugin) checkRegistryReachable(ctx context.Context, cfg *Config) *registryPreflight {
+	network, address, reason := registryPreflightTarget(cfg)
+	if reason != "" {
+		return &registryPreflight{status: registrySkipped, reason: reason}
+	}
+	if cfg.CheckConnectivity {
+		if target, _ := connectivityTarget(cfg); target != "" {
+			return p.checkConnectivity(ctx, cfg, target)
+		}
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, registryProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := p.getRegistryExecutor(cfg).Probe(probeCtx, network, address)
+	result := &registryPreflight{status: registryReachable, endpoint: address, latency: time.Since(start)}
+	if err != nil {
+		result.status = registryUnreachable
+		result.err = fmt.Errorf("network error: registry endpoint %s is unreachable: %v; check connectivity to the registry or set skip_preflight to bypass this check", address, err)
+	}
+	return result
+}
+
+// addOutputs records the preflight result and latency for debugging slow
+// registries.
+func (r *registryPreflight) addOutputs(outputs map[string]any) {
+	outputs["registry_preflight"] = r.status
+	if r.endpoint != "" {
+		outputs["registry_preflight_endpoint"] = r.endpoint
+		outputs["registry_preflight_latency_ms"] = r.latency.Milliseconds()
+	}
+	if r.httpStatus != 0 {
+		outputs["registry_preflight_status"] = r.httpStatus
+	}
+	if r.reason != "" {
+		outputs["registry_preflight_reason"] = r.reason
+	}
+}
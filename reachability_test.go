@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestRegistryProbeTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		env         map[string]string
+		wantNetwork string
+		wantAddress string
+		wantReason  string
+	}{
+		{
+			name:        "crates.io",
+			wantNetwork: "http",
+			wantAddress: cratesIOIndexConfig,
+		},
+		{
+			name:        "crates-io by name",
+			cfg:         Config{Registry: "crates-io"},
+			wantNetwork: "http",
+			wantAddress: cratesIOIndexConfig,
+		},
+		{
+			name:        "sparse index",
+			cfg:         Config{Index: "sparse+https://index.example.com/crates"},
+			wantNetwork: "http",
+			wantAddress: "https://index.example.com/crates/config.json",
+		},
+		{
+			name:        "git index over https",
+			cfg:         Config{Index: "https://github.com/rust-lang/crates.io-index"},
+			wantNetwork: "tcp",
+			wantAddress: "github.com:443",
+		},
+		{
+			name:        "git index over ssh with a port",
+			cfg:         Config{Index: "ssh://git@git.example.com:2222/index.git"},
+			wantNetwork: "tcp",
+			wantAddress: "git.example.com:2222",
+		},
+		{
+			name:        "registry URL",
+			cfg:         Config{Registry: "sparse+https://registry.example.com/index/"},
+			wantNetwork: "http",
+			wantAddress: "https://registry.example.com/index/config.json",
+		},
+		{
+			name:        "named registry with its index in the environment",
+			cfg:         Config{Registry: "my-registry"},
+			env:         map[string]string{"CARGO_REGISTRIES_MY_REGISTRY_INDEX": "sparse+https://registry.example.com/index/"},
+			wantNetwork: "http",
+			wantAddress: "https://registry.example.com/index/config.json",
+		},
+		{
+			name:       "named registry from cargo configuration",
+			cfg:        Config{Registry: "my-registry"},
+			wantReason: "CARGO_REGISTRIES_MY_REGISTRY_INDEX",
+		},
+		{
+			name:       "local index",
+			cfg:        Config{Index: "file:///srv/index"},
+			wantReason: "no host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRIES_MY_REGISTRY_INDEX", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			network, address, reason := registryProbeTarget(&tt.cfg)
+			if tt.wantReason != "" {
+				if !strings.Contains(reason, tt.wantReason) {
+					t.Errorf("reason = %q, want it to contain %q", reason, tt.wantReason)
+				}
+				return
+			}
+			if network != tt.wantNetwork || address != tt.wantAddress || reason != "" {
+				t.Errorf("registryProbeTarget() = %q, %q, %q; want %q, %q", network, address, reason, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestExecuteRegistryPreflight(t *testing.T) {
+	refused := errors.New("dial tcp 127.0.0.1:443: connect: connection refused")
+
+	tests := []struct {
+		name              string
+		config            map[string]any
+		probeErr          error
+		wantSuccess       bool
+		wantProbed        bool
+		wantStatus        string
+		wantErrorContains string
+	}{
+		{
+			name:        "reachable registry publishes",
+			config:      map[string]any{"token": "test-token"},
+			wantSuccess: true,
+			wantProbed:  true,
+			wantStatus:  registryReachable,
+		},
+		{
+			name:              "unreachable registry fails before cargo runs",
+			config:            map[string]any{"token": "test-token"},
+			probeErr:          refused,
+			wantProbed:        true,
+			wantStatus:        registryUnreachable,
+			wantErrorContains: "network error: registry endpoint https://index.crates.io/config.json is unreachable",
+		},
+		{
+			name:        "skipped with skip_preflight",
+			config:      map[string]any{"token": "test-token", "skip_preflight": true},
+			probeErr:    refused,
+			wantSuccess: true,
+			wantStatus:  registrySkipped,
+		},
+		{
+			name:        "skipped offline",
+			config:      map[string]any{"token": "test-token", "offline": true},
+			probeErr:    refused,
+			wantSuccess: true,
+			wantStatus:  registrySkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probed := false
+			mock := &MockCommandExecutor{
+				ProbeFunc: func(ctx context.Context, network, address string) error {
+					probed = true
+					return tt.probeErr
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  tt.config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v, want %v (error: %s)", resp.Success, tt.wantSuccess, resp.Error)
+			}
+			if probed != tt.wantProbed {
+				t.Errorf("probed = %v, want %v", probed, tt.wantProbed)
+			}
+			if got := resp.Outputs["registry_preflight"]; got != tt.wantStatus {
+				t.Errorf("registry_preflight = %v, want %s", got, tt.wantStatus)
+			}
+			if tt.wantProbed {
+				if _, ok := resp.Outputs["registry_preflight_latency_ms"].(int64); !ok {
+					t.Errorf("expected registry_preflight_latency_ms in outputs, got %v", resp.Outputs)
+				}
+			}
+			if tt.wantErrorContains != "" {
+				if !strings.Contains(resp.Error, tt.wantErrorContains) {
+					t.Errorf("error = %q, want it to contain %q", resp.Error, tt.wantErrorContains)
+				}
+				if calls := mock.GetCalls(); len(calls) != 0 {
+					t.Errorf("expected no commands after an unreachable registry, got %+v", calls)
+				}
+			}
+		})
+	}
+}
+
+func TestRealProbe(t *testing.T) {
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+		if r.URL.Path == "/down/config.json" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// A listener closed right away leaves a port that refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedAddr := listener.Addr().String()
+	_ = listener.Close()
+
+	executor := &RealCommandExecutor{}
+	ctx := withCorrelationID(context.Background(), "release-42")
+	if err := executor.Probe(ctx, "http", server.URL+"/index/config.json"); err != nil {
+		t.Errorf("any response below 500 should count as reachable, got %v", err)
+	}
+	if agent != "relicta-plugin-crates/2.0.0 (correlation_id=release-42)" {
+		t.Errorf("User-Agent = %q, want the correlation ID", agent)
+	}
+	if err := executor.Probe(ctx, "http", server.URL+"/down/config.json"); err == nil {
+		t.Error("expected a 503 response to count as unreachable")
+	}
+	if err := executor.Probe(ctx, "tcp", strings.TrimPrefix(server.URL, "http://")); err != nil {
+		t.Errorf("expected the TCP dial to succeed, got %v", err)
+	}
+	if err := executor.Probe(ctx, "tcp", closedAddr); err == nil {
+		t.Error("expected a closed port to be unreachable")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultReadmeFiles are the files cargo packages as the readme, in order,
+// when the manifest does not set package.readme.
+var defaultReadmeFiles = []string{"README.md", "README.txt", "README"}
+
+// crateReadme is the readme cargo packages for a manifest.
+type crateReadme struct {
+	// path is the readme file, relative to the working directory like the
+	// manifest path; empty when the crate has no readme.
+	path string
+	// source names where the readme was set, for error messages; empty when
+	// it was found by cargo's default lookup.
+	source string
+}
+
+// manifestReadme resolves package.readme as cargo does: a path relative to
+// the manifest directory, true for README.md, false for none, and when unset
+// the first default readme file that exists. `readme.workspace = true` takes
+// [workspace.package] readme, relative to the workspace root. A manifest
+// that is missing or does not parse is left for cargo to report, and one
+// without a [package] table has no readme.
+func manifestReadme(path string) (crateReadme, error) {
+	manifest, err := loadManifest(path)
+	if err != nil || manifest.Package == nil {
+		return crateReadme{}, nil
+	}
+
+	dir := filepath.Dir(path)
+	value, source := manifest.Package.Readme, path
+	if v, ok := value.(map[string]any); ok {
+		if inherit, _ := v["workspace"].(bool); !inherit {
+			return crateReadme{}, fmt.Errorf("unsupported package readme in %s", path)
+		}
+		root, workspace, err := findWorkspaceRoot(path)
+		if err != nil {
+			return crateReadme{}, err
+		}
+		if workspace.Workspace.Package.Readme == nil {
+			return crateReadme{}, fmt.Errorf("%s inherits readme, but workspace %s does not set [workspace.package] readme", path, root)
+		}
+		dir, value, source = filepath.Dir(root), workspace.Workspace.Package.Readme, "[workspace.package] in "+root
+	}
+
+	switch v := value.(type) {
+	case nil:
+		for _, name := range defaultReadmeFiles {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+				return crateReadme{path: candidate}, nil
+			}
+		}
+		return crateReadme{}, nil
+	case bool:
+		if !v {
+			return crateReadme{}, nil
+		}
+		return crateReadme{path: filepath.Join(dir, "README.md"), source: source}, nil
+	case string:
+		return crateReadme{path: filepath.Join(dir, v), source: source}, nil
+	}
+	return crateReadme{}, fmt.Errorf("unsupported package readme in %s", source)
+}
+
+// checkReadme returns the readme cargo will package, failing when the
+// manifest names one that does not exist so the release stops before cargo
+// fails at packaging time.
+func checkReadme(cfg *Config) (string, error) {
+	readme, err := manifestReadme(cfg.ManifestPath)
+	if err != nil {
+		return "", fmt.Errorf("readme check failed: %w", err)
+	}
+	if readme.path == "" || readme.source == "" {
+		return readme.path, nil
+	}
+	if info, err := os.Stat(readme.path); err != nil || !info.Mode().IsRegular() {
+		return readme.path, fmt.Errorf("readme %s set by %s does not exist or is not a file", readme.path, readme.source)
+	}
+	return readme.path, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestCheckReadme(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	pkg := "[package]\nname = \"x\"\n"
+	write("default/README.txt", "")
+	write("explicit/docs/README.adoc", "")
+	write("workspace/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n\n[workspace.package]\nreadme = \"docs/README.md\"\n")
+	write("workspace/docs/README.md", "")
+	write("bare-workspace/Cargo.toml", "[workspace]\nmembers = [\"member\"]\n")
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+		wantErr  string
+	}{
+		{name: "default lookup", manifest: write("default/Cargo.toml", pkg), want: filepath.Join(dir, "default", "README.txt")},
+		{name: "no default readme", manifest: write("none/Cargo.toml", pkg)},
+		{name: "disabled", manifest: write("disabled/Cargo.toml", pkg+"readme = false\n")},
+		{name: "explicit path", manifest: write("explicit/Cargo.toml", pkg+"readme = \"docs/README.adoc\"\n"), want: filepath.Join(dir, "explicit", "docs", "README.adoc")},
+		{name: "renamed readme", manifest: write("renamed/Cargo.toml", pkg+"readme = \"README.md\"\n"), wantErr: "readme " + filepath.Join(dir, "renamed", "README.md") + " set by " + filepath.Join(dir, "renamed", "Cargo.toml") + " does not exist"},
+		{name: "true means README.md", manifest: write("true/Cargo.toml", pkg+"readme = true\n"), wantErr: filepath.Join(dir, "true", "README.md") + " set by"},
+		{name: "inherited", manifest: write("workspace/member/Cargo.toml", pkg+"readme.workspace = true\n"), want: filepath.Join(dir, "workspace", "docs", "README.md")},
+		{name: "not set by the workspace", manifest: write("bare-workspace/member/Cargo.toml", pkg+"readme.workspace = true\n"), wantErr: "does not set [workspace.package] readme"},
+		{name: "unparseable manifest", manifest: write("invalid/Cargo.toml", "[package\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkReadme(&Config{ManifestPath: tt.manifest})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("checkReadme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteMissingReadme(t *testing.T) {
+	dir := t.TempDir()
+	manifest := "[package]\nname = \"renamed\"\nversion = \"1.0.0\"\ndescription = \"x\"\nlicense = \"MIT\"\nreadme = \"README.md\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	for _, dryRun := range []bool{false, true} {
+		mock := &MockCommandExecutor{}
+		p := &CratesPlugin{cmdExecutor: mock}
+		resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+			Hook:    plugin.HookPostPublish,
+			Config:  map[string]any{"token": "test-token"},
+			Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			DryRun:  dryRun,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Success || !strings.Contains(resp.Error, "preflight check failed: readme README.md set by Cargo.toml does not exist") {
+			t.Errorf("dry run %v: expected a missing readme error, got success=%v error=%q", dryRun, resp.Success, resp.Error)
+		}
+		for _, call := range mock.GetCalls() {
+			if call.Name == "cargo" {
+				t.Errorf("dry run %v: expected cargo not to run, got %+v", dryRun, call)
+			}
+		}
+	}
+
+	// Once the readme exists it is reported in outputs
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# renamed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token"},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success || resp.Outputs["readme"] != "README.md" {
+		t.Errorf("expected readme README.md in outputs, got success=%v error=%q readme=%v", resp.Success, resp.Error, resp.Outputs["readme"])
+	}
+}
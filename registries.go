@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/helpers"
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// Policies for registries, selected by registries_policy.
+const (
+	registriesRequireAll = "require_all"
+	registriesBestEffort = "best_effort"
+)
+
+// Per-registry publish statuses reported in the registries output.
+const (
+	targetPublished = "published"
+	targetPlanned   = "planned"
+	targetFailed    = "failed"
+	targetSkipped   = "skipped"
+)
+
+// registryTargetKeys are the keys a registries entry may set. They replace
+// the top-level keys of the same name for that registry.
+var registryTargetKeys = []string{"registry", "index", "token", "token_env", "token_file", "no_verify"}
+
+// registriesExclusiveKeys select the registry or a token source, which every
+// registries entry chooses for itself.
+var registriesExclusiveKeys = []string{
+	"registry", "index", "registry_index", "token", "token_env", "token_file", "token_command",
+	"token_keyring", "codeartifact", "credential_provider", "trusted_publishing", "channel_registries",
+}
+
+// registryTarget is one registries entry: a registry to publish to and its
+// token source.
+type registryTarget struct {
+	Registry  string
+	Index     string
+	Token     string
+	TokenEnv  string
+	TokenFile string
+	NoVerify  *bool
+	// unknown lists the keys the entry sets that are not registryTargetKeys;
+	// invalid is set when the entry is not an object at all.
+	unknown []string
+	invalid bool
+}
+
+// parseRegistryTargets reads the registries entries. Problems are recorded
+// on the entries for validateRegistryTargets to report.
+func parseRegistryTargets(raw any) []registryTarget {
+	items, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	targets := make([]registryTarget, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			targets = append(targets, registryTarget{invalid: true})
+			continue
+		}
+		parser := helpers.NewConfigParser(entry)
+		target := registryTarget{
+			Registry:  parser.GetString("registry", "", ""),
+			Index:     parser.GetString("index", "", ""),
+			Token:     parser.GetString("token", "", ""),
+			TokenEnv:  parser.GetString("token_env", "", ""),
+			TokenFile: parser.GetString("token_file", "", ""),
+		}
+		if _, ok := entry["no_verify"]; ok {
+			noVerify := parser.GetBool("no_verify", false)
+			target.NoVerify = &noVerify
+		}
+		for key := range entry {
+			if !slices.Contains(registryTargetKeys, key) {
+				target.unknown = append(target.unknown, key)
+			}
+		}
+		sort.Strings(target.unknown)
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// name identifies the target in outputs: the registry name or URL, the index
+// URL, or crates-io.
+func (t registryTarget) name() string {
+	switch {
+	case t.Registry != "":
+		return t.Registry
+	case t.Index != "":
+		return t.Index
+	}
+	return cratesIORegistry
+}
+
+// config returns the raw configuration for publishing to the target: the
+// top-level keys with the entry's keys in place of registries.
+func (t registryTarget) config(raw map[string]any) map[string]any {
+	config := make(map[string]any, len(raw)+len(registryTargetKeys))
+	for key, value := range raw {
+		if key != "registries" && key != "registries_policy" {
+			config[key] = value
+		}
+	}
+	for key, value := range map[string]string{"registry": t.Registry, "index": t.Index, "token": t.Token, "token_env": t.TokenEnv, "token_file": t.TokenFile} {
+		if value != "" {
+			config[key] = value
+		}
+	}
+	if t.NoVerify != nil {
+		config["no_verify"] = *t.NoVerify
+	}
+	return config
+}
+
+// validateRegistryTargets checks registries and registries_policy: every
+// entry is an object with known keys naming a distinct registry, and the
+// top-level configuration leaves the registry and token source to them.
+func validateRegistryTargets(targets []registryTarget, policy string, explicit map[string]bool) error {
+	switch policy {
+	case "", registriesRequireAll, registriesBestEffort:
+	default:
+		return fmt.Errorf("registries_policy must be one of: %s, %s", registriesRequireAll, registriesBestEffort)
+	}
+	if len(targets) == 0 {
+		if explicit["registries_policy"] {
+			return fmt.Errorf("registries_policy needs registries")
+		}
+		return nil
+	}
+	for _, key := range registriesExclusiveKeys {
+		if explicit[key] {
+			return fmt.Errorf("registries and %s are mutually exclusive; set the registry and its token source in each registries entry", key)
+		}
+	}
+	seen := map[string]bool{}
+	for i, target := range targets {
+		switch {
+		case target.invalid:
+			return fmt.Errorf("registries[%d] must be an object", i)
+		case len(target.unknown) > 0:
+			return fmt.Errorf("registries[%d] has unknown key %q; entries may set %s", i, target.unknown[0], strings.Join(registryTargetKeys, ", "))
+		case target.Registry != "" && target.Index != "":
+			return fmt.Errorf("registries[%d]: registry and index are mutually exclusive", i)
+		case seen[target.name()]:
+			return fmt.Errorf("registries[%d] repeats registry %s", i, target.name())
+		}
+		seen[target.name()] = true
+	}
+	return nil
+}
+
+// maskRegistryTargets renders the registries entries for the effective
+// configuration, with inline tokens masked.
+func maskRegistryTargets(targets []registryTarget) []map[string]any {
+	if targets == nil {
+		return nil
+	}
+	masked := make([]map[string]any, 0, len(targets))
+	for _, target := range targets {
+		entry := map[string]any{"name": target.name()}
+		for key, value := range map[string]string{"registry": target.Registry, "index": target.Index, "token": maskSecret(target.Token), "token_env": target.TokenEnv, "token_file": target.TokenFile} {
+			if value != "" {
+				entry[key] = value
+			}
+		}
+		if target.NoVerify != nil {
+			entry["no_verify"] = *target.NoVerify
+		}
+		masked = append(masked, entry)
+	}
+	return masked
+}
+
+// targetError names the registry a publish failed for, keeping the
+// configuration error prefix so failure_policy soft still reports it.
+func targetError(name, err string) string {
+	if rest, ok := strings.CutPrefix(err, errConfigValidation+": "); ok {
+		return fmt.Sprintf("%s: registry %s: %s", errConfigValidation, name, rest)
+	}
+	return fmt.Sprintf("registry %s: %s", name, err)
+}
+
+// publishRegistries publishes the crate to every registries entry in order,
+// each with the top-level configuration and the entry's registry and token
+// source. Uploads that succeeded stay published when a later one fails.
+// Under require_all the first failure skips the remaining registries and
+// fails the hook; under best_effort every registry is tried and the hook
+// succeeds when at least one published. Outputs report each registry under
+// registries, keyed by name.
+func (p *CratesPlugin) publishRegistries(ctx context.Context, raw map[string]any, cfg *Config, releaseCtx plugin.ReleaseContext, dryRun bool, decisions *decisionLog) (*plugin.ExecuteResponse, error) {
+	if err := validateRegistryTargets(cfg.Registries, cfg.RegistriesPolicy, cfg.ExplicitKeys); err != nil {
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("%s: %v", errConfigValidation, err),
+		}, nil
+	}
+	policy := cfg.RegistriesPolicy
+	if policy == "" {
+		policy = registriesRequireAll
+	}
+
+	results := map[string]any{}
+	var order, published, commands, warnings []string
+	firstErr := ""
+	for _, target := range cfg.Registries {
+		name := target.name()
+		order = append(order, name)
+		if firstErr != "" && policy == registriesRequireAll {
+			decisions.add("registry "+name, decisionSkip, "an earlier registry failed under registries_policy require_all", "registries", "registries_policy")
+			results[name] = map[string]any{"status": targetSkipped}
+			continue
+		}
+
+		targetCfg := p.parseConfig(target.config(raw))
+		resp, err := p.publish(ctx, targetCfg, releaseCtx, dryRun, decisions)
+		if err != nil {
+			return nil, err
+		}
+		newScrubber(knownSecrets(targetCfg)).scrubResponse(resp)
+
+		result := map[string]any{"outputs": resp.Outputs}
+		if command, ok := resp.Outputs["command"].(string); ok && dryRun {
+			commands = append(commands, command)
+		}
+		if targetWarnings, ok := resp.Outputs["warnings"].([]string); ok {
+			for _, w := range targetWarnings {
+				warnings = append(warnings, fmt.Sprintf("registry %s: %s", name, w))
+			}
+		}
+		if resp.Success {
+			result["status"] = targetPublished
+			if dryRun {
+				result["status"] = targetPlanned
+			}
+			result["message"] = resp.Message
+			published = append(published, name)
+		} else {
+			result["status"] = targetFailed
+			result["error"] = resp.Error
+			if firstErr == "" {
+				firstErr = targetError(name, resp.Error)
+			}
+			if policy == registriesBestEffort {
+				warnings = append(warnings, targetError(name, resp.Error))
+			}
+		}
+		results[name] = result
+	}
+
+	outputs := map[string]any{
+		"registries":        results,
+		"registries_order":  order,
+		"registries_policy": policy,
+		"published_to":      published,
+	}
+	if dryRun {
+		outputs["commands"] = commands
+		outputs["dry_run_mode"] = dryRunSimulated
+		if cfg.ExecuteDryRun {
+			outputs["dry_run_mode"] = dryRunVerified
+		}
+	}
+	if len(warnings) > 0 {
+		outputs["warnings"] = warnings
+	}
+
+	verb := "Published"
+	if dryRun {
+		verb = "Would publish"
+	}
+	label := "crate"
+	if release, err := parseReleaseVersion(releaseCtx.Version); err == nil {
+		name, _ := publishCrateName(cfg)
+		label = crateLabel(name, release.String())
+	}
+	if len(published) == 0 || firstErr != "" && policy == registriesRequireAll {
+		msg := firstErr
+		if len(published) > 0 && !dryRun {
+			msg += fmt.Sprintf("\nAlready published to %s; those uploads are not undone", strings.Join(published, ", "))
+		}
+		return &plugin.ExecuteResponse{
+			Success: false,
+			Error:   msg,
+			Outputs: outputs,
+		}, nil
+	}
+	return &plugin.ExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("%s %s to %s", verb, label, strings.Join(published, ", ")),
+		Outputs: outputs,
+	}, nil
+}
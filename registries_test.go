@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateRegistryTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      any
+		policy   string
+		explicit map[string]bool
+		wantErr  string
+	}{
+		{name: "unset"},
+		{name: "two registries", raw: []any{map[string]any{}, map[string]any{"registry": "internal", "token_env": "INTERNAL_TOKEN", "no_verify": true}}, policy: registriesBestEffort},
+		{name: "unknown policy", raw: []any{map[string]any{}}, policy: "some", wantErr: "registries_policy must be one of"},
+		{name: "policy without registries", explicit: map[string]bool{"registries_policy": true}, wantErr: "registries_policy needs registries"},
+		{name: "top-level token", raw: []any{map[string]any{}}, explicit: map[string]bool{"token": true}, wantErr: "registries and token are mutually exclusive"},
+		{name: "top-level registry", raw: []any{map[string]any{}}, explicit: map[string]bool{"registry": true}, wantErr: "registries and registry are mutually exclusive"},
+		{name: "not an object", raw: []any{"internal"}, wantErr: "registries[0] must be an object"},
+		{name: "unknown key", raw: []any{map[string]any{"registry": "internal", "token_command": []any{"pass"}}}, wantErr: `registries[0] has unknown key "token_command"`},
+		{name: "registry and index", raw: []any{map[string]any{"registry": "internal", "index": "sparse+https://registry.example.com/"}}, wantErr: "registries[0]: registry and index are mutually exclusive"},
+		{name: "repeated registry", raw: []any{map[string]any{}, map[string]any{"registry": "crates-io"}}, wantErr: "registries[1] repeats registry crates-io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegistryTargets(parseRegistryTargets(tt.raw), tt.policy, tt.explicit)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// registriesConfig publishes to crates.io, internal and mirror.
+func registriesConfig(policy string) map[string]any {
+	return map[string]any{
+		"registries": []any{
+			map[string]any{"token": "crates-io-secret"},
+			map[string]any{"registry": "internal", "token_env": "INTERNAL_TOKEN", "no_verify": true},
+			map[string]any{"registry": "mirror", "token": "mirror-secret"},
+		},
+		"registries_policy": policy,
+		"skip_preflight":    true,
+	}
+}
+
+// publishRegistry returns the registry a cargo publish call targets.
+func publishRegistry(args []string) string {
+	if i := slices.Index(args, "--registry"); i >= 0 && i+1 < len(args) {
+		return args[i+1]
+	}
+	return cratesIORegistry
+}
+
+func TestExecuteRegistriesDryRun(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	t.Setenv("INTERNAL_TOKEN", "internal-secret")
+	p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  registriesConfig(""),
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("dry run failed: %s", resp.Error)
+	}
+
+	commands, _ := resp.Outputs["commands"].([]string)
+	if len(commands) != 3 {
+		t.Fatalf("expected one command per registry, got %v", commands)
+	}
+	if !strings.Contains(commands[0], "publish --token "+redactedValue) || strings.Contains(commands[0], "--registry") {
+		t.Errorf("unexpected crates.io command %q", commands[0])
+	}
+	if !strings.Contains(commands[1], "--registry internal --no-verify") {
+		t.Errorf("unexpected internal command %q", commands[1])
+	}
+	if !strings.Contains(commands[2], "--token "+redactedValue+" --registry mirror") {
+		t.Errorf("unexpected mirror command %q", commands[2])
+	}
+
+	results, _ := resp.Outputs["registries"].(map[string]any)
+	for _, name := range []string{"crates-io", "internal", "mirror"} {
+		result, _ := results[name].(map[string]any)
+		if result["status"] != targetPlanned {
+			t.Errorf("registry %s status = %v, want %s", name, result["status"], targetPlanned)
+		}
+	}
+	if order, _ := resp.Outputs["registries_order"].([]string); !slices.Equal(order, []string{"crates-io", "internal", "mirror"}) {
+		t.Errorf("registries_order = %v", order)
+	}
+	for _, secret := range []string{"crates-io-secret", "internal-secret", "mirror-secret"} {
+		if strings.Contains(fmt.Sprint(resp.Outputs), secret) {
+			t.Errorf("outputs leak %s", secret)
+		}
+	}
+}
+
+func TestExecuteRegistriesFailure(t *testing.T) {
+	tests := []struct {
+		policy        string
+		wantSuccess   bool
+		wantStatus    map[string]string
+		wantPublishes []string
+	}{
+		{
+			policy:        registriesRequireAll,
+			wantStatus:    map[string]string{"crates-io": targetPublished, "internal": targetFailed, "mirror": targetSkipped},
+			wantPublishes: []string{"crates-io", "internal"},
+		},
+		{
+			policy:        registriesBestEffort,
+			wantSuccess:   true,
+			wantStatus:    map[string]string{"crates-io": targetPublished, "internal": targetFailed, "mirror": targetPublished},
+			wantPublishes: []string{"crates-io", "internal", "mirror"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "")
+			t.Setenv("INTERNAL_TOKEN", "internal-secret")
+			var publishes []string
+			run := func(args []string) ([]byte, error) {
+				if !slices.Contains(args, "publish") {
+					return nil, nil
+				}
+				registry := publishRegistry(args)
+				publishes = append(publishes, registry)
+				if registry == "internal" {
+					return []byte("error: failed to publish to internal"), errors.New("exit status 101")
+				}
+				return []byte("Uploaded"), nil
+			}
+			mock := &MockCommandExecutor{
+				RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+					return run(args)
+				},
+				RunWithEnvFunc: func(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+					return run(args)
+				},
+			}
+			config := registriesConfig(tt.policy)
+			config["allow_new_crate"] = true
+			p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  config,
+				Context: plugin.ReleaseContext{Version: "v1.0.0"},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Success != tt.wantSuccess {
+				t.Fatalf("Success = %v, want %v: %s", resp.Success, tt.wantSuccess, resp.Error)
+			}
+			if !slices.Equal(publishes, tt.wantPublishes) {
+				t.Errorf("published to %v, want %v", publishes, tt.wantPublishes)
+			}
+			results, _ := resp.Outputs["registries"].(map[string]any)
+			for name, want := range tt.wantStatus {
+				result, _ := results[name].(map[string]any)
+				if result["status"] != want {
+					t.Errorf("registry %s status = %v, want %s", name, result["status"], want)
+				}
+			}
+
+			if !tt.wantSuccess {
+				if !strings.HasPrefix(resp.Error, "registry internal: cargo publish failed") || !strings.Contains(resp.Error, "Already published to crates-io; those uploads are not undone") {
+					t.Errorf("unexpected error: %q", resp.Error)
+				}
+				return
+			}
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			if !slices.ContainsFunc(warnings, func(w string) bool { return strings.HasPrefix(w, "registry internal: cargo publish failed") }) {
+				t.Errorf("expected the internal failure as a warning, got %v", warnings)
+			}
+		})
+	}
+}
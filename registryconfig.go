@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// registryConfigName is the file registry_index is declared in, inside a
+// temporary directory passed to cargo with --config.
+const registryConfigName = "config.toml"
+
+// validateRegistryIndex checks registry_index: it declares the index of a
+// named registry, so it needs registry set to a name, and it is validated
+// like index.
+func validateRegistryIndex(registry, index, registryIndex string, policy registryPolicy) error {
+	if registryIndex == "" {
+		return nil
+	}
+	if registry == "" || strings.Contains(registry, "://") || registry == cratesIORegistry {
+		return fmt.Errorf("registry_index needs registry set to the name of the registry it declares")
+	}
+	if index != "" {
+		return fmt.Errorf("registry_index and index are mutually exclusive")
+	}
+	if err := validateIndexURL(registryIndex, policy); err != nil {
+		return fmt.Errorf("invalid registry_index: %w", err)
+	}
+	return nil
+}
+
+// registryConfig renders the cargo configuration declaring registry_index,
+// or "" without it. It never holds credentials; the token reaches cargo as
+// for any named registry.
+func registryConfig(cfg *Config) (string, error) {
+	if cfg.RegistryIndex == "" {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	config := map[string]any{"registries": map[string]any{cfg.Registry: map[string]string{"index": cfg.RegistryIndex}}}
+	if err := enc.Encode(config); err != nil {
+		return "", fmt.Errorf("failed to render the registry_index config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// writeRegistryConfig writes the registryConfig to a temporary directory
+// under temp_dir and records the file in cfg.RegistryConfigFile, so cargo
+// gets it with --config. The cleanup function removes it again.
+func writeRegistryConfig(cfg *Config) (func(), error) {
+	contents, err := registryConfig(cfg)
+	if err != nil || contents == "" {
+		return func() {}, err
+	}
+	dir, cleanup, err := makeTempDir(cfg, "relicta-crates-registry-", "the registry_index config")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, registryConfigName)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to write the registry_index config: %w", err)
+	}
+	cfg.RegistryConfigFile = path
+	return cleanup, nil
+}
+
+// addRegistryConfigOutputs reports the generated registry config file and
+// its contents.
+func addRegistryConfigOutputs(cfg *Config, outputs map[string]any) {
+	if cfg.RegistryConfigFile == "" {
+		return
+	}
+	contents, _ := registryConfig(cfg)
+	outputs["registry_config_file"] = cfg.RegistryConfigFile
+	outputs["registry_config"] = contents
+}
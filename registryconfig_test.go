@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateRegistryIndex(t *testing.T) {
+	tests := []struct {
+		name          string
+		registry      string
+		index         string
+		registryIndex string
+		wantErr       string
+	}{
+		{name: "unset"},
+		{name: "named registry", registry: "my-registry", registryIndex: "sparse+https://registry.example.com/index/"},
+		{name: "without registry", registryIndex: "sparse+https://registry.example.com/index/", wantErr: "needs registry set to the name"},
+		{name: "registry URL", registry: "sparse+https://registry.example.com/index/", registryIndex: "sparse+https://registry.example.com/index/", wantErr: "needs registry set to the name"},
+		{name: "crates.io", registry: "crates-io", registryIndex: "sparse+https://registry.example.com/index/", wantErr: "needs registry set to the name"},
+		{name: "with index", registry: "my-registry", index: "sparse+https://other.example.com/", registryIndex: "sparse+https://registry.example.com/index/", wantErr: "mutually exclusive"},
+		{name: "not a URL", registry: "my-registry", registryIndex: "my-registry", wantErr: "invalid registry_index: index must be a URL"},
+		{name: "plain HTTP", registry: "my-registry", registryIndex: "http://registry.example.com/index", wantErr: "only HTTPS URLs are allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRegistryIndex(tt.registry, tt.index, tt.registryIndex, registryPolicy{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistryConfig(t *testing.T) {
+	tests := []struct {
+		registry string
+		want     string
+	}{
+		{registry: "my-registry", want: "[registries]\n[registries.my-registry]\nindex = \"sparse+https://registry.example.com/index/\"\n"},
+		{registry: "my.registry", want: "[registries]\n[registries.\"my.registry\"]\nindex = \"sparse+https://registry.example.com/index/\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			got, err := registryConfig(&Config{Registry: tt.registry, RegistryIndex: "sparse+https://registry.example.com/index/"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("registryConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteRegistryIndex(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	const token = "registry-index-secret"
+	config := map[string]any{
+		"token":           token,
+		"registry":        "my-registry",
+		"registry_index":  "sparse+https://registry.example.com/index/",
+		"allow_new_crate": true,
+		"temp_dir":        t.TempDir(),
+	}
+
+	var configFile, contents string
+	mock := &MockCommandExecutor{
+		RunFunc: func(_ context.Context, name string, args ...string) ([]byte, error) {
+			for i, arg := range args {
+				if arg == "--config" && i+1 < len(args) {
+					configFile = args[i+1]
+					data, err := os.ReadFile(configFile)
+					if err != nil {
+						t.Errorf("registry config is not readable while cargo runs: %v", err)
+					}
+					contents = string(data)
+				}
+			}
+			return nil, nil
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if configFile == "" || !strings.HasPrefix(configFile, config["temp_dir"].(string)) {
+		t.Fatalf("expected --config with a file under temp_dir, got %q", configFile)
+	}
+	if !strings.Contains(contents, `index = "sparse+https://registry.example.com/index/"`) || strings.Contains(contents, token) {
+		t.Errorf("unexpected registry config:\n%s", contents)
+	}
+	if _, err := os.Stat(configFile); !os.IsNotExist(err) {
+		t.Errorf("registry config was not removed: %v", err)
+	}
+	if resp.Outputs["registry_config_file"] != configFile || resp.Outputs["registry_config"] != contents {
+		t.Errorf("unexpected outputs: %v", resp.Outputs)
+	}
+
+	// A dry run shows the generated file and its contents
+	resp, err = p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  config,
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+		DryRun:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file, _ := resp.Outputs["registry_config_file"].(string)
+	command, _ := resp.Outputs["command"].(string)
+	if file == "" || !strings.Contains(command, "--config "+file) {
+		t.Errorf("expected the registry config in the dry-run command, got %q and %q", file, command)
+	}
+	if resp.Outputs["registry_config"] != contents {
+		t.Errorf("registry_config = %v, want %q", resp.Outputs["registry_config"], contents)
+	}
+}
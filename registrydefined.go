@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// cargoRegistries is the part of a cargo configuration file that declares
+// registries.
+type cargoRegistries struct {
+	Registries map[string]struct {
+		Index string `toml:"index"`
+	} `toml:"registries"`
+}
+
+// cargoConfigFiles returns the configuration files cargo loads when run in
+// dir: .cargo/config.toml, or .cargo/config which cargo prefers when both
+// exist, in dir and each parent, then the one in cargoHome unless a parent
+// already covered it.
+func cargoConfigFiles(dir, cargoHome string) []string {
+	var files []string
+	seen := map[string]bool{}
+	add := func(cargoDir string) {
+		if seen[cargoDir] {
+			return
+		}
+		seen[cargoDir] = true
+		for _, name := range []string{"config", "config.toml"} {
+			path := filepath.Join(cargoDir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				files = append(files, path)
+				return
+			}
+		}
+	}
+	for current := dir; ; {
+		add(filepath.Join(current, ".cargo"))
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	if cargoHome != "" {
+		add(cargoHome)
+	}
+	return files
+}
+
+// cargoHomeDir returns CARGO_HOME, defaulting to ~/.cargo as cargo does.
+func cargoHomeDir() string {
+	if home := os.Getenv("CARGO_HOME"); home != "" {
+		return home
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".cargo")
+	}
+	return ""
+}
+
+// definedRegistries returns the registries with an index in the cargo
+// configuration files, the CARGO_REGISTRIES_<NAME>_INDEX variables of the
+// wanted registry and the cargo_config overrides.
+func definedRegistries(files []string, cfg *Config) (map[string]bool, error) {
+	defined := map[string]bool{}
+	for _, file := range files {
+		var config cargoRegistries
+		if _, err := toml.DecodeFile(file, &config); err != nil {
+			return nil, fmt.Errorf("failed to read cargo configuration %s: %w", file, err)
+		}
+		for name, registry := range config.Registries {
+			if registry.Index != "" {
+				defined[name] = true
+			}
+		}
+	}
+	if os.Getenv(registryEnvPrefix(cfg.Registry)+"INDEX") != "" {
+		defined[cfg.Registry] = true
+	}
+	for key, value := range cfg.CargoConfig {
+		name, ok := strings.CutPrefix(key, "registries.")
+		if name, ok = strings.CutSuffix(name, ".index"); ok && value != "" {
+			defined[strings.Trim(name, `"`)] = true
+		}
+	}
+	return defined, nil
+}
+
+// checkRegistryDefined fails when check_registry_defined is set and the
+// named registry has no index in the cargo configuration cargo will load in
+// dir, listing the registries that are defined. Registry URLs, crates.io and
+// a registry declared by registry_index need no definition.
+func checkRegistryDefined(cfg *Config, dir string) error {
+	if !cfg.RegistryDefined || cfg.Registry == "" || cfg.Registry == cratesIORegistry ||
+		strings.Contains(cfg.Registry, "://") || cfg.RegistryIndex != "" {
+		return nil
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	files := cargoConfigFiles(dir, cargoHomeDir())
+	defined, err := definedRegistries(files, cfg)
+	if err != nil {
+		return err
+	}
+	if defined[cfg.Registry] {
+		return nil
+	}
+
+	names := make([]string, 0, len(defined))
+	for name := range defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	known := "no registries are defined"
+	if len(names) > 0 {
+		known = "defined registries: " + strings.Join(names, ", ")
+	}
+	searched := "no cargo configuration files were found"
+	if len(files) > 0 {
+		searched = "searched " + strings.Join(files, ", ")
+	}
+	return fmt.Errorf("registry %s has no index in the cargo configuration (%s; %s); add [registries.%s] with an index to .cargo/config.toml or set registry_index", cfg.Registry, known, searched, cfg.Registry)
+}
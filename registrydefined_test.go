@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// writeCargoConfig writes a cargo configuration file, creating its directory.
+func writeCargoConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCargoConfigFiles(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "workspace", "crate")
+	home := filepath.Join(root, "home")
+	writeCargoConfig(t, filepath.Join(project, ".cargo", "config.toml"), "")
+	// cargo prefers the file without the extension when both exist
+	writeCargoConfig(t, filepath.Join(root, "workspace", ".cargo", "config"), "")
+	writeCargoConfig(t, filepath.Join(root, "workspace", ".cargo", "config.toml"), "")
+	writeCargoConfig(t, filepath.Join(home, "config.toml"), "")
+
+	got := cargoConfigFiles(project, home)
+	want := []string{
+		filepath.Join(project, ".cargo", "config.toml"),
+		filepath.Join(root, "workspace", ".cargo", "config"),
+		filepath.Join(home, "config.toml"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("cargoConfigFiles() = %v, want %v", got, want)
+	}
+
+	// CARGO_HOME is not read twice when it is a parent's .cargo directory
+	if got := cargoConfigFiles(project, filepath.Join(root, "workspace", ".cargo")); len(got) != 2 {
+		t.Errorf("cargoConfigFiles() = %v, want the two project files", got)
+	}
+}
+
+func TestCheckRegistryDefined(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	t.Setenv("CARGO_HOME", filepath.Join(root, "home"))
+	t.Setenv("CARGO_REGISTRIES_FROM_ENV_INDEX", "sparse+https://env.example.com/index/")
+	writeCargoConfig(t, filepath.Join(root, ".cargo", "config.toml"), `
+[registries.internal]
+index = "sparse+https://registry.example.com/index/"
+
+[registries.no-index]
+token = "unused"
+`)
+	writeCargoConfig(t, filepath.Join(root, "home", "config.toml"), `
+[registries.mirror]
+index = "sparse+https://mirror.example.com/index/"
+`)
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "check disabled", cfg: Config{Registry: "missing"}},
+		{name: "crates.io", cfg: Config{Registry: cratesIORegistry, RegistryDefined: true}},
+		{name: "registry URL", cfg: Config{Registry: "sparse+https://registry.example.com/index/", RegistryDefined: true}},
+		{name: "defined in a parent", cfg: Config{Registry: "internal", RegistryDefined: true}},
+		{name: "defined in CARGO_HOME", cfg: Config{Registry: "mirror", RegistryDefined: true}},
+		{name: "defined in the environment", cfg: Config{Registry: "from-env", RegistryDefined: true}},
+		{name: "defined in cargo_config", cfg: Config{Registry: "inline", RegistryDefined: true, CargoConfig: map[string]string{"registries.inline.index": `"sparse+https://inline.example.com/"`}}},
+		{name: "declared by registry_index", cfg: Config{Registry: "declared", RegistryIndex: "sparse+https://declared.example.com/", RegistryDefined: true}},
+		{name: "without an index", cfg: Config{Registry: "no-index", RegistryDefined: true}, wantErr: "registry no-index has no index in the cargo configuration (defined registries: internal, mirror;"},
+		{name: "missing", cfg: Config{Registry: "missing", RegistryDefined: true}, wantErr: "searched " + filepath.Join(root, ".cargo", "config.toml")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRegistryDefined(&tt.cfg, project)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRegistryDefinedInvalidConfig(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("CARGO_HOME", filepath.Join(root, "home"))
+	writeCargoConfig(t, filepath.Join(root, ".cargo", "config.toml"), "[registries\n")
+
+	err := checkRegistryDefined(&Config{Registry: "internal", RegistryDefined: true}, root)
+	if err == nil || !strings.Contains(err.Error(), "failed to read cargo configuration") {
+		t.Errorf("error = %v, want a configuration read error", err)
+	}
+}
+
+func TestExecuteRegistryNotDefined(t *testing.T) {
+	t.Setenv("CARGO_REGISTRY_TOKEN", "")
+	root := t.TempDir()
+	t.Setenv("CARGO_HOME", filepath.Join(root, "home"))
+	chdir(t, root)
+
+	mock := &MockCommandExecutor{}
+	p := &CratesPlugin{cmdExecutor: mock, resolver: &fakeResolver{}}
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "secret", "registry": "internal", "check_registry_defined": true},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Success || !strings.Contains(resp.Error, "registry internal has no index in the cargo configuration (no registries are defined") {
+		t.Fatalf("expected an undefined registry error, got success=%v error=%q", resp.Success, resp.Error)
+	}
+	if calls := mock.GetCalls(); len(calls) != 0 {
+		t.Errorf("expected no commands, got %+v", calls)
+	}
+
+	validation, err := p.Validate(context.Background(), map[string]any{"registry": "internal", "check_registry_defined": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if validation.Valid || len(validation.Errors) != 1 || validation.Errors[0].Field != "registry" {
+		t.Errorf("expected one registry error, got valid=%v %+v", validation.Valid, validation.Errors)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// releaseVersion is a crate version in the SemVer 2.0 form cargo accepts:
+// major.minor.patch with an optional pre-release (-rc.1) and build metadata
+// (+sha.abc). The release and manifest versions are both read through
+// parseReleaseVersion, so pre-release and metadata forms are handled the same
+// in messages, decisions and the manifest check.
+type releaseVersion struct {
+	major, minor, patch uint64
+	pre, build          string
+}
+
+// parseReleaseVersion parses a release version, accepting the leading v of
+// release tags such as v1.0.0-rc.1. Numeric identifiers with leading zeros
+// are rejected, as cargo rejects them.
+func parseReleaseVersion(s string) (releaseVersion, error) {
+	var v releaseVersion
+	core := strings.TrimPrefix(s, "v")
+	if core == "" {
+		return v, fmt.Errorf("version is empty")
+	}
+
+	var build, pre string
+	var hasBuild, hasPre bool
+	core, build, hasBuild = strings.Cut(core, "+")
+	core, pre, hasPre = strings.Cut(core, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("%q is not a major.minor.patch version", s)
+	}
+	numbers := [3]*uint64{&v.major, &v.minor, &v.patch}
+	for i, part := range parts {
+		n, err := parseNumericIdentifier(part)
+		if err != nil {
+			return v, fmt.Errorf("%q: %v", s, err)
+		}
+		*numbers[i] = n
+	}
+
+	if hasPre {
+		if err := checkIdentifiers(pre, true); err != nil {
+			return v, fmt.Errorf("%q: pre-release %v", s, err)
+		}
+		v.pre = pre
+	}
+	if hasBuild {
+		if err := checkIdentifiers(build, false); err != nil {
+			return v, fmt.Errorf("%q: build metadata %v", s, err)
+		}
+		v.build = build
+	}
+	return v, nil
+}
+
+// parseNumericIdentifier parses a version number without leading zeros.
+func parseNumericIdentifier(s string) (uint64, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("%q has a leading zero", s)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is out of range", s)
+	}
+	return n, nil
+}
+
+// checkIdentifiers checks dot-separated pre-release or build identifiers.
+// Numeric pre-release identifiers must not have leading zeros; build
+// metadata identifiers may.
+func checkIdentifiers(s string, pre bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return fmt.Errorf("%q has an empty identifier", s)
+		}
+		if strings.Trim(id, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-") != "" {
+			return fmt.Errorf("%q may only contain ASCII letters, digits and hyphens", s)
+		}
+		if pre && len(id) > 1 && id[0] == '0' && strings.Trim(id, "0123456789") == "" {
+			return fmt.Errorf("%q has a numeric identifier with a leading zero", s)
+		}
+	}
+	return nil
+}
+
+// String renders the version without the tag's v, including pre-release and
+// build metadata: the string cargo and the registry index use.
+func (v releaseVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	if v.build != "" {
+		s += "+" + v.build
+	}
+	return s
+}
+
+// equal reports whether v and other are the same version, including
+// pre-release and build metadata: cargo publishes the manifest version
+// verbatim, so 1.0.0 does not satisfy a 1.0.0-rc.1 release.
+func (v releaseVersion) equal(other releaseVersion) bool {
+	return v == other
+}
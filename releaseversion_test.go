@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// trickyVersions are release tags whose pre-release, build metadata or
+// numbering has tripped up string handling, with the version cargo sees, or
+// "" when cargo rejects the tag.
+var trickyVersions = []struct {
+	tag  string
+	want string
+}{
+	{tag: "v1.0.0", want: "1.0.0"},
+	{tag: "1.0.0", want: "1.0.0"},
+	{tag: "v1.0.0-rc.1", want: "1.0.0-rc.1"},
+	{tag: "v2.0.0-rc.1+sha.5114f85", want: "2.0.0-rc.1+sha.5114f85"},
+	{tag: "v1.0.0+build.007", want: "1.0.0+build.007"},
+	{tag: "v1.0.0-alpha-1.beta", want: "1.0.0-alpha-1.beta"},
+	{tag: "v1.0.0-0a", want: "1.0.0-0a"},
+	{tag: "v0.0.1", want: "0.0.1"},
+	{tag: "v0.0.0", want: "0.0.0"},
+	{tag: "v01.0.0"},
+	{tag: "v1.00.0"},
+	{tag: "v1.0.0-rc.01"},
+	{tag: "v1.0.0-"},
+	{tag: "v1.0.0+"},
+	{tag: "v1.0.0-rc..1"},
+	{tag: "v1.0.0-rc_1"},
+	{tag: "v1.0"},
+	{tag: "vv1.0.0"},
+	{tag: "V1.0.0"},
+	{tag: ""},
+}
+
+func TestParseReleaseVersion(t *testing.T) {
+	for _, tt := range trickyVersions {
+		t.Run(tt.tag, func(t *testing.T) {
+			v, err := parseReleaseVersion(tt.tag)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("expected %q to be rejected, got %s", tt.tag, v)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v.String() != tt.want {
+				t.Errorf("version = %s, want %s", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseVersionMessages(t *testing.T) {
+	for _, tt := range trickyVersions {
+		t.Run(tt.tag, func(t *testing.T) {
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+			// compat_level 2.0 skips the manifest check, which the fixture at 1.0.0 would fail
+			configs := map[plugin.Hook]map[string]any{
+				plugin.HookPrePublish:  {"token": "secret"},
+				plugin.HookPostPublish: {"token": "secret", "compat_level": "2.0"},
+			}
+			for _, hook := range []plugin.Hook{plugin.HookPrePublish, plugin.HookPostPublish} {
+				resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+					Hook:    hook,
+					Config:  configs[hook],
+					Context: plugin.ReleaseContext{Version: tt.tag},
+					DryRun:  true,
+				})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if tt.want == "" {
+					if resp.Success || !strings.Contains(resp.Error, "invalid release version") {
+						t.Errorf("%s: expected an invalid release version error, got %+v", hook, resp)
+					}
+					continue
+				}
+				if !resp.Success {
+					t.Fatalf("%s failed: %s", hook, resp.Error)
+				}
+				if !strings.Contains(resp.Message, "fixture "+tt.want+" ") {
+					t.Errorf("%s: message %q should name version %s", hook, resp.Message, tt.want)
+				}
+				if resp.Outputs["version"] != tt.want {
+					t.Errorf("%s: version output = %v, want %s", hook, resp.Outputs["version"], tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseVersionManifestCheck(t *testing.T) {
+	manifest := func(t *testing.T, version string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "Cargo.toml")
+		content := "[package]\nname = \"fixture\"\nversion = \"" + version + "\"\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write manifest: %v", err)
+		}
+		return path
+	}
+
+	// Cargo publishes the manifest version verbatim, so only an exact match passes
+	tests := []struct {
+		name     string
+		manifest string
+		release  string
+		wantErr  string
+	}{
+		{name: "plain", manifest: "1.0.0", release: "v1.0.0"},
+		{name: "pre-release", manifest: "2.0.0-rc.1", release: "v2.0.0-rc.1"},
+		{name: "build metadata", manifest: "1.0.0+build.007", release: "v1.0.0+build.007"},
+		{name: "0.0.x", manifest: "0.0.3", release: "v0.0.3"},
+		{name: "pre-release missing from the manifest", manifest: "2.0.0", release: "v2.0.0-rc.1", wantErr: "has version 2.0.0 but the release version is 2.0.0-rc.1"},
+		{name: "release candidate left in the manifest", manifest: "2.0.0-rc.1", release: "v2.0.0", wantErr: "has version 2.0.0-rc.1 but the release version is 2.0.0"},
+		{name: "different pre-release", manifest: "2.0.0-rc.1", release: "v2.0.0-rc.2", wantErr: "but the release version is 2.0.0-rc.2"},
+		{name: "build metadata missing from the manifest", manifest: "1.0.0", release: "v1.0.0+build.007", wantErr: "but the release version is 1.0.0+build.007"},
+		{name: "leading zero in the manifest", manifest: "1.0.01", release: "v1.0.1", wantErr: `has version "1.0.01", which cargo does not accept`},
+		{name: "tag prefix in the manifest", manifest: "v1.0.0", release: "v1.0.0", wantErr: `has version "v1.0.0", which cargo does not accept`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := parseReleaseVersion(tt.release)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{RunInDirFunc: fakeGit("/repo", "", "", nil)}}
+			err = p.checkManifestVersion(context.Background(), &Config{ManifestPath: manifest(t, tt.manifest)}, release)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReleaseVersionDecisionSubject(t *testing.T) {
+	for _, tt := range trickyVersions {
+		if tt.want == "" {
+			continue
+		}
+		t.Run(tt.tag, func(t *testing.T) {
+			p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:    plugin.HookPostPublish,
+				Config:  map[string]any{"token": "secret", "crate_name": "fixture", "compat_level": "2.0"},
+				Context: plugin.ReleaseContext{Version: tt.tag},
+				DryRun:  true,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			decisions, _ := resp.Outputs["decisions"].([]decision)
+			found := false
+			for _, d := range decisions {
+				if d.Subject == "fixture@"+tt.want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected a decision about fixture@%s, got %+v", tt.want, decisions)
+			}
+		})
+	}
+}
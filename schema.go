@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// baseConfigSchema is the JSON Schema hosts use to validate and document the
+// plugin configuration. Every key read by parseConfig must be listed here;
+// deprecated keys are added from configAliases.
+const baseConfigSchema = `{
+	"type": "object",
+	"properties": {
+		"token": {"type": "string", "description": "API token (or use CARGO_REGISTRY_TOKEN, or CARGO_REGISTRIES_<NAME>_TOKEN for a named registry)"},
+		"tokens": {"type": "object", "additionalProperties": {"type": "string"}, "description": "API tokens keyed by registry name (crates-io for crates.io); the entry for the configured registry is used when token is unset"},
+		"token_env": {"type": "string", "pattern": "^[A-Za-z_][A-Za-z0-9_]*$", "description": "Environment variable to read the API token from when token is unset, checked before CARGO_REGISTRIES_<NAME>_TOKEN and CARGO_REGISTRY_TOKEN"},
+		"token_file": {"type": "string", "description": "File whose trimmed contents are the API token; used when token is unset and takes precedence over CARGO_REGISTRY_TOKEN. Relative paths stay inside the working directory; absolute paths must be under token_file_root"},
+		"token_file_root": {"type": "string", "description": "Absolute secrets directory under which an absolute token_file is allowed, e.g. /run/secrets"},
+		"token_command": {"type": "array", "items": {"type": "string"}, "minItems": 1, "description": "Command run without a shell, as a program followed by its arguments, whose trimmed standard output is the API token; used when token is unset and takes precedence over CARGO_REGISTRY_TOKEN"},
+		"token_command_timeout": {"type": "string", "description": "Maximum duration for token_command and the codeartifact token request (Go duration)", "default": "30s"},
+		"token_keyring": {
+			"type": "object",
+			"description": "OS keyring entry (macOS Keychain, Secret Service, Windows Credential Manager) holding the API token; used when token is unset and takes precedence over environment variables",
+			"properties": {
+				"service": {"type": "string", "description": "Keyring service name"},
+				"account": {"type": "string", "description": "Keyring account (user) name"}
+			},
+			"required": ["service", "account"]
+		},
+		"token_via_env": {"type": "boolean", "description": "Pass the token to cargo as CARGO_REGISTRY_TOKEN (or CARGO_REGISTRIES_<NAME>_TOKEN for a named registry) instead of --token, keeping it out of process listings", "default": false},
+		"codeartifact": {
+			"type": "object",
+			"description": "AWS CodeArtifact domain whose authorization token, fetched with aws codeartifact get-authorization-token, is passed to cargo as CARGO_REGISTRIES_<NAME>_TOKEN; needs registry to name the CodeArtifact registry and no other token source",
+			"properties": {
+				"domain": {"type": "string", "description": "CodeArtifact domain name"},
+				"domain_owner": {"type": "string", "description": "AWS account ID that owns the domain; defaults to the caller's account"},
+				"region": {"type": "string", "description": "AWS region of the domain; defaults to the AWS CLI configuration"}
+			},
+			"required": ["domain"]
+		},
+		"trusted_publishing": {"type": "boolean", "description": "Publish to crates.io with trusted publishing: exchange the CI job's OIDC token (GitHub Actions with id-token: write) for a temporary publish token, revoked once the hook finishes; no other token source may be configured with it", "default": false},
+		"credential_provider": {"type": "string", "description": "Cargo credential provider (cargo 1.74+) such as cargo:token-from-stdout <command>, exported as CARGO_REGISTRY_CREDENTIAL_PROVIDER or CARGO_REGISTRIES_<NAME>_CREDENTIAL_PROVIDER; replaces the API token, so no token source may be configured with it"},
+		"registry": {"type": "string", "description": "Registry to publish to (optional, for private registries)"},
+		"index": {"type": "string", "description": "Registry index URL to publish to (--index); mutually exclusive with registry"},
+		"registry_index": {"type": "string", "description": "Index URL of the registry named by registry, for runners whose .cargo/config.toml does not declare it: written to a temporary config file passed with --config and removed after the hook; validated like index and mutually exclusive with it"},
+		"registries": {
+			"type": "array",
+			"description": "Registries to publish the same crate to, in order, instead of registry and index: each entry names the registry and its token source and is otherwise published with the top-level configuration. Uploads that succeeded are never undone when a later registry fails; outputs report each registry under registries",
+			"items": {
+				"type": "object",
+				"properties": {
+					"registry": {"type": "string", "description": "Registry name or URL; crates.io when neither registry nor index is set"},
+					"index": {"type": "string", "description": "Registry index URL; mutually exclusive with registry"},
+					"token": {"type": "string", "description": "API token for this registry"},
+					"token_env": {"type": "string", "description": "Environment variable holding the token for this registry"},
+					"token_file": {"type": "string", "description": "File holding the token for this registry"},
+					"no_verify": {"type": "boolean", "description": "Skip crate verification for this registry"}
+				}
+			}
+		},
+		"registries_policy": {"type": "string", "enum": ["require_all", "best_effort"], "description": "With registries, require_all stops at the first failed registry and fails the hook; best_effort tries every registry and succeeds when at least one published, reporting the failures as warnings", "default": "require_all"},
+		"channel_registries": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Registry name or URL per release channel, overriding registry: the channel is RELICTA_RELEASE_CHANNEL or RELICTA_CHANNEL from the release context, else the first pre-release identifier of the version without trailing digits (rc for 1.0.0-rc.1), else stable. Unmapped channels use registry; outputs report channel, channel_registry and registry_selection"},
+		"allowed_registry_ports": {"type": "array", "items": {"type": "integer", "minimum": 1, "maximum": 65535}, "description": "Ports a registry or index URL may name explicitly; localhost URLs may use any port", "default": [443]},
+		"allow_insecure_registry": {"type": "boolean", "description": "Allow the insecure_hosts to use plain HTTP (http:// or sparse+http://) and private network addresses, for internal registries such as an on-prem Kellnr; reported as a validation warning", "default": false},
+		"insecure_hosts": {"type": "array", "items": {"type": "string"}, "description": "Registry or index hosts exempt from the HTTPS and private network checks; only honored with allow_insecure_registry, and ports still need allowed_registry_ports"},
+		"allowed_networks": {"type": "array", "items": {"type": "string"}, "description": "CIDR ranges (such as 10.20.0.0/16) a registry or index host may resolve to despite being private, reported as a validation warning; cloud metadata endpoints stay blocked"},
+		"dns_timeout": {"type": "string", "description": "Maximum duration of the DNS lookup that checks a registry or index host for private addresses (Go duration); a lookup that takes longer is reported as a warning instead of blocking", "default": "3s"},
+		"skip_dns_check": {"type": "boolean", "description": "Do not resolve registry and index host names, for build machines without DNS; this weakens SSRF protection, since a host name resolving to a private or cloud metadata address is no longer rejected and only the scheme, port and IP literal checks remain. Reported as dns_check: skipped in outputs", "default": false},
+		"check_registry_connectivity": {"type": "boolean", "description": "Request the registry's sparse index config.json, or the root of an index served over HTTP, without the token during Validate and the publish preflight, failing on TLS errors, timeouts, unreachable endpoints, 404 and 5xx responses; 401 and 403 only warn", "default": false},
+		"check_registry_defined": {"type": "boolean", "description": "Fail Validate and publish when the named registry has no index in the cargo configuration files cargo loads (.cargo/config.toml in the manifest directory and its parents, then CARGO_HOME), CARGO_REGISTRIES_<NAME>_INDEX, cargo_config or registry_index, listing the registries that are defined", "default": false},
+		"registry_ca_cert": {"type": "string", "description": "PEM file of the CA certificates a private registry's TLS certificate chains to; passed to cargo as http.cainfo and trusted, next to the system roots, by the plugin's own registry requests. It must hold at least one certificate"},
+		"client_cert": {"type": "string", "description": "PEM client certificate the plugin's own registry requests present to a registry requiring mutual TLS; needs client_key. Cargo has no client certificate setting, which Validate reports as a warning"},
+		"client_key": {"type": "string", "description": "PEM private key of client_cert; it must match the certificate and its contents are never output"},
+		"proxy": {"type": "string", "description": "HTTP proxy URL (http:// or https://) for cargo, passed as CARGO_HTTP_PROXY, and for the plugin's own registry requests instead of the ambient HTTPS_PROXY; a password in it is redacted from outputs. Validate warns when it is a private address while the registry is public"},
+		"no_proxy": {"type": "array", "items": {"type": "string"}, "description": "Hosts, domains (.example.com), IPs and CIDR ranges reached without proxy, by the plugin and, through NO_PROXY, by cargo; needs proxy"},
+		"allow_dirty": {"type": "boolean", "description": "Allow publishing with uncommitted changes", "default": false},
+		"no_verify": {"type": "boolean", "description": "Skip crate verification", "default": false},
+		"allow_new_crate": {"type": "boolean", "description": "Allow publishing a crate that does not exist on the registry yet; otherwise the crate is looked up in the registry's sparse index first, so a typo in the name fails instead of publishing a new crate", "default": false},
+		"crate_name": {"type": "string", "pattern": "^[A-Za-z][A-Za-z0-9_-]*$", "maxLength": 64, "description": "Crate name for registry-facing lookups and outputs, overriding the manifest's package.name (cargo still publishes package.name)"},
+		"manifest_path": {"type": "string", "description": "Path to Cargo.toml", "default": "Cargo.toml"},
+		"features": {"type": "array", "items": {"type": "string"}, "description": "Features to activate"},
+		"all_features": {"type": "boolean", "description": "Activate all available features", "default": false},
+		"no_default_features": {"type": "boolean", "description": "Do not activate the default feature", "default": false},
+		"jobs": {"type": "integer", "description": "Number of parallel jobs"},
+		"net_retry": {"type": "integer", "description": "Times cargo retries a failed network request, 0 to 20, passed as --config net.retry; unset leaves it to cargo's configuration"},
+		"git_fetch_with_cli": {"type": "boolean", "description": "Have cargo fetch git indexes and dependencies with the git command line, which honors git's own credentials and proxy settings; passed as --config net.git-fetch-with-cli", "default": false},
+		"locked": {"type": "boolean", "description": "Require Cargo.lock to be up to date (--locked)", "default": false},
+		"offline": {"type": "boolean", "description": "Run the verification build without network access (--offline)", "default": false},
+		"frozen": {"type": "boolean", "description": "Require an up-to-date Cargo.lock and no network access (--frozen)", "default": false},
+		"target": {"type": "string", "description": "Target triple for the verification build (--target), e.g. thumbv7em-none-eabihf"},
+		"target_dir": {"type": "string", "description": "Build directory for the verification build (--target-dir); \"temp\" creates and removes a per-run temporary directory"},
+		"target_dir_root": {"type": "string", "description": "Absolute directory under which an absolute target_dir is allowed"},
+		"temp_dir": {"type": "string", "description": "Absolute directory for temporary allocations such as target_dir: temp (defaults to the system temp directory); build directories are probed for executable support"},
+		"env_mode": {"type": "string", "enum": ["inherit", "clean", "allowlist"], "description": "Environment of the cargo process: inherit passes the plugin's environment; clean passes only PATH, HOME and CARGO_*/RUSTUP_* variables (plus SYSTEMROOT, USERPROFILE, TEMP and TMP on Windows); allowlist also passes the env_allowlist variables", "default": "inherit"},
+		"env": {"type": "object", "additionalProperties": {"type": ["string", "number", "boolean"]}, "description": "Environment variables set for cargo in every env_mode"},
+		"env_allowlist": {"type": "array", "items": {"type": "string"}, "description": "Names of further variables passed to cargo with env_mode allowlist"},
+		"cargo_config": {"type": "object", "additionalProperties": {"type": ["string", "number", "boolean"]}, "description": "Cargo configuration overrides passed as repeated --config key=value arguments"},
+		"extra_args": {"type": "array", "items": {"type": "string"}, "description": "Additional --flag arguments appended to cargo publish; flags managed by the plugin are rejected"},
+		"unstable_flags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z0-9-]+(=\\S+)?$"}, "description": "Unstable cargo flags rendered as -Z <flag>; requires a nightly toolchain"},
+		"quiet": {"type": "boolean", "description": "Pass -q to cargo and trim the success output (errors always include the full output)", "default": false},
+		"verbose": {"type": ["boolean", "integer"], "minimum": 0, "maximum": 2, "description": "Pass -v to cargo; 2 passes -vv", "default": false},
+		"toolchain": {"type": "string", "description": "Rustup toolchain to publish with, invoked as cargo +<toolchain> (stable, beta, nightly, nightly-YYYY-MM-DD, a version, or a full toolchain name with host triple)"},
+		"cargo_path": {"type": "string", "description": "Cargo executable to run: a command name looked up on PATH or an absolute path to an executable file", "default": "cargo"},
+		"min_cargo_version": {"type": "string", "pattern": "^\\d+\\.\\d+(\\.\\d+)?$", "description": "Fail before publishing when cargo --version is older than this version, e.g. 1.74.0"},
+		"package_then_publish": {"type": "boolean", "description": "Run cargo package first, then upload with cargo publish --no-verify so the verification build runs once", "default": false},
+		"execute_dry_run": {"type": "boolean", "description": "On host dry runs, run cargo publish --dry-run (without the token) instead of only rendering the command", "default": false},
+		"prepublish_verify": {"type": "boolean", "description": "Run cargo publish --dry-run in the pre-publish hook so packaging and verification problems abort the release before it is published", "default": true},
+		"skip_metadata_check": {"type": "boolean", "description": "Skip the check, run before cargo in the pre-publish hook and before publishing, that the manifest sets the description and license or license-file crates.io requires (following workspace = true to [workspace.package]), that license is an SPDX expression and that license-file exists; for private registries that do not require them", "default": false},
+		"allow_patched": {"type": "boolean", "description": "Publish even when the manifest or its workspace root has [patch] or [replace] overrides, which the published crate is built without; for registries that accept them", "default": false},
+		"deny_prerelease_deps": {"type": "boolean", "description": "Fail a stable release whose normal dependencies require a pre-release version, such as foo = \"2.0.0-beta.3\", instead of warning; the dependencies are reported in prerelease_dependencies either way", "default": false},
+		"skip_preflight": {"type": "boolean", "description": "Skip the check that the registry endpoint is reachable before publishing; it is also skipped with offline or frozen", "default": false},
+		"report_licenses": {"type": "boolean", "description": "Record the deduplicated license expressions of the crate's linked dependencies in outputs as dependency_licenses", "default": false},
+		"forbidden_licenses": {"type": "array", "items": {"type": "string"}, "description": "SPDX license identifiers that fail the pre-publish hook when a linked dependency can only be used under one of them"},
+		"package_check": {"type": "string", "enum": ["off", "warn", "error"], "description": "Run cargo package --list in the pre-publish hook and warn or fail when the crate would be missing required_paths or have fewer than min_package_files files", "default": "off"},
+		"required_paths": {"type": "array", "items": {"type": "string"}, "description": "Relative files or directories the packaged crate must contain for package_check", "default": ["src/"]},
+		"min_package_files": {"type": "integer", "minimum": 0, "description": "Minimum number of packaged files, not counting Cargo.toml, Cargo.toml.orig, Cargo.lock and .cargo_vcs_info.json, for package_check", "default": 1},
+		"package_must_include": {"type": "array", "items": {"type": "string"}, "description": "Globs the packaged file list must match; the pre-publish hook lists the crate with cargo package --list, reports it in package_files and fails when a pattern matches no file. A pattern without a slash matches file names at any depth and ** matches any number of directories"},
+		"package_must_not_include": {"type": "array", "items": {"type": "string"}, "description": "Globs no packaged file may match, such as .env or tests/fixtures/**; the pre-publish hook fails naming each offending file"},
+		"max_package_size": {"type": "integer", "minimum": 0, "description": "Largest .crate file, in bytes, to upload; the pre-publish hook and publishing measure the crate with cargo package --no-verify before building it (package_then_publish checks its own package) and fail above the limit. 0 disables the check", "default": 10485760},
+		"rate_limit_max_wait": {"type": "string", "description": "Maximum time to wait and retry when the registry responds with 429 Too Many Requests (Go duration, 0 disables retries)", "default": "10m"},
+		"quota_warn_threshold": {"type": "integer", "description": "Warn when the number of publishes made with this token today reaches this count (0 disables local tracking)", "default": 0},
+		"quota_state_file": {"type": "string", "description": "Path of the local publish counter state file (stores salted token fingerprints only)"},
+		"publish_timeout": {"type": "string", "description": "Maximum duration for the cargo publish invocation (Go duration, e.g. 30m); unset means no extra timeout"},
+		"post_publish_wait": {"type": "string", "description": "Fixed pause after a successful publish before the hook returns, giving the index time to propagate for later steps (Go duration, e.g. 30s)"},
+		"failure_policy": {"type": "string", "enum": ["hard", "soft"], "description": "hard fails the release when publishing fails; soft reports the failure as a warning with soft_failed in outputs and lets the release continue (configuration errors still fail)", "default": "hard"},
+		"compat_level": {"type": "string", "pattern": "^[0-9]+\\.[0-9]+$", "description": "Pin the plugin's default behaviors to an older level such as 2.0; defaults introduced later stay off unless listed in compat_features. Unset means the current level (2.1)"},
+		"compat_features": {"type": "array", "items": {"type": "string", "enum": ["manifest_version_check", "prepublish_verify", "registry_token_env", "metadata_check", "package_size_check", "patch_check", "dependency_check", "new_crate_check"]}, "description": "Features newer than compat_level to turn on anyway"},
+		"forbid_inline_token": {"type": "boolean", "description": "Reject configs that set token or tokens inline, so tokens come from the environment, token_file, token_command or token_keyring instead; also enabled by RELICTA_CRATES_FORBID_INLINE_TOKEN", "default": false},
+		"strict_config": {"type": "boolean", "description": "Reject deprecated config keys instead of warning about them", "default": false},
+		"audit_log": {"type": "string", "description": "File the plugin appends a JSON line to for every command it runs (time, directory, command, redacted arguments, exit status, duration), each line chained to the previous one by its SHA-256 hash"},
+		"metrics": {
+			"type": "object",
+			"description": "Optional statsd/dogstatsd UDP metrics emission",
+			"properties": {
+				"host": {"type": "string", "description": "Statsd host; metrics are disabled when unset"},
+				"port": {"type": "integer", "description": "Statsd UDP port", "default": 8125},
+				"prefix": {"type": "string", "description": "Metric name prefix", "default": "relicta.crates"},
+				"format": {"type": "string", "enum": ["statsd", "dogstatsd"], "description": "Wire format; tags are only sent with dogstatsd", "default": "dogstatsd"},
+				"tags": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Extra tags added to every metric, after the registry and crate tags"}
+			}
+		}
+	}
+}`
+
+// configSchema is the advertised schema: baseConfigSchema plus the deprecated
+// aliases.
+var configSchema = withDeprecatedAliases(baseConfigSchema)
+
+// configSchemaErr records a malformed configSchema. It is checked once at
+// startup so a bad schema is reported instead of being shipped to hosts.
+var configSchemaErr = checkConfigSchema(configSchema)
+
+// schemaNode is the subset of a JSON Schema object the plugin checks.
+type schemaNode struct {
+	Type       json.RawMessage        `json:"type"`
+	Pattern    *string                `json:"pattern"`
+	Properties map[string]*schemaNode `json:"properties"`
+	Items      *schemaNode            `json:"items"`
+}
+
+// checkConfigSchema parses schema and requires an object schema whose
+// properties all declare a type and whose patterns compile.
+func checkConfigSchema(schema string) error {
+	var root schemaNode
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return fmt.Errorf("config schema is not valid JSON: %w", err)
+	}
+	if string(root.Type) != `"object"` {
+		return errors.New(`config schema must have type "object"`)
+	}
+	if len(root.Properties) == 0 {
+		return errors.New("config schema declares no properties")
+	}
+	return checkSchemaProperties("", root.Properties)
+}
+
+// checkSchemaProperties checks each property of an object schema, naming
+// nested properties by their dotted path.
+func checkSchemaProperties(prefix string, properties map[string]*schemaNode) error {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := prefix + name
+		node := properties[name]
+		if node == nil || len(node.Type) == 0 {
+			return fmt.Errorf("config schema property %s has no type", path)
+		}
+		if err := checkSchemaPattern(path, node); err != nil {
+			return err
+		}
+		if node.Items != nil {
+			if err := checkSchemaPattern(path+"[]", node.Items); err != nil {
+				return err
+			}
+		}
+		if err := checkSchemaProperties(path+".", node.Properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSchemaPattern requires a property's pattern, if any, to compile.
+func checkSchemaPattern(path string, node *schemaNode) error {
+	if node.Pattern == nil {
+		return nil
+	}
+	if _, err := regexp.Compile(*node.Pattern); err != nil {
+		return fmt.Errorf("config schema property %s has an invalid pattern: %w", path, err)
+	}
+	return nil
+}
+
+// infoConfigSchema returns the schema advertised in GetInfo. A malformed
+// schema is withheld so hosts fall back to the plugin's own validation
+// rather than failing to load it.
+func infoConfigSchema() string {
+	if configSchemaErr != nil {
+		return ""
+	}
+	return configSchema
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// compileConfigSchema compiles configSchema as a draft-07 JSON Schema, which
+// also validates it against the draft-07 meta-schema.
+func compileConfigSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	if err := compiler.AddResource("config.json", strings.NewReader(configSchema)); err != nil {
+		t.Fatalf("config schema is not valid JSON: %v", err)
+	}
+	schema, err := compiler.Compile("config.json")
+	if err != nil {
+		t.Fatalf("config schema is not a valid JSON Schema: %v", err)
+	}
+	return schema
+}
+
+func TestConfigSchemaIsValidJSONSchema(t *testing.T) {
+	if configSchemaErr != nil {
+		t.Fatalf("startup schema check failed: %v", configSchemaErr)
+	}
+	schema := compileConfigSchema(t)
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name: "typical configuration",
+			config: `{
+				"token": "secret",
+				"registry": "my-registry",
+				"features": ["a", "b"],
+				"jobs": 4,
+				"verbose": 2,
+				"cargo_config": {"net.retry": 5, "http.debug": true},
+				"failure_policy": "soft",
+				"metrics": {"host": "localhost", "port": 8125, "format": "statsd", "tags": {"crate": "fixture"}}
+			}`,
+		},
+		{
+			name:    "empty configuration",
+			config:  `{}`,
+			wantErr: false,
+		},
+		{
+			name:    "wrong type",
+			config:  `{"allow_dirty": "yes"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown enum value",
+			config:  `{"failure_policy": "lenient"}`,
+			wantErr: true,
+		},
+		{
+			name:    "pattern mismatch",
+			config:  `{"min_cargo_version": "latest"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config any
+			if err := json.Unmarshal([]byte(tt.config), &config); err != nil {
+				t.Fatalf("bad test config: %v", err)
+			}
+			err := schema.Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckConfigSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			schema: `{"type": "object", "properties": {"a": {"type": "string", "pattern": "^x$"}}}`,
+		},
+		{
+			name:    "trailing comma",
+			schema:  `{"type": "object", "properties": {"a": {"type": "string"},}}`,
+			wantErr: "not valid JSON",
+		},
+		{
+			name:    "not an object schema",
+			schema:  `{"type": "array", "properties": {"a": {"type": "string"}}}`,
+			wantErr: `type "object"`,
+		},
+		{
+			name:    "no properties",
+			schema:  `{"type": "object"}`,
+			wantErr: "no properties",
+		},
+		{
+			name:    "property without type",
+			schema:  `{"type": "object", "properties": {"a": {"description": "untyped"}}}`,
+			wantErr: "property a has no type",
+		},
+		{
+			name:    "nested property without type",
+			schema:  `{"type": "object", "properties": {"m": {"type": "object", "properties": {"host": {}}}}}`,
+			wantErr: "property m.host has no type",
+		},
+		{
+			name:    "invalid item pattern",
+			schema:  `{"type": "object", "properties": {"a": {"type": "array", "items": {"type": "string", "pattern": "("}}}}`,
+			wantErr: "property a[] has an invalid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConfigSchema(tt.schema)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvalidConfigSchemaIsReported(t *testing.T) {
+	saved := configSchemaErr
+	t.Cleanup(func() { configSchemaErr = saved })
+	configSchemaErr = checkConfigSchema(`{"type": "object",}`)
+
+	p := &CratesPlugin{}
+	if schema := p.GetInfo().ConfigSchema; schema != "" {
+		t.Errorf("GetInfo should withhold a malformed schema, got %q", schema)
+	}
+
+	resp, err := p.Validate(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Valid {
+		t.Error("expected Validate to report the malformed schema")
+	}
+	found := false
+	for _, e := range resp.Errors {
+		if e.Field == "config_schema" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a config_schema error, got %+v", resp.Errors)
+	}
+}
+
+// configKeysRead returns the string literal keys read in the named functions
+// of file, through parser.Get* calls or direct map indexing.
+func configKeysRead(t *testing.T, file string, funcs ...string) map[string]bool {
+	t.Helper()
+
+	f, err := parser.ParseFile(token.NewFileSet(), "../../"+file, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", file, err)
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range funcs {
+		wanted[name] = true
+	}
+
+	keys := map[string]bool{}
+	literal := func(expr ast.Expr) {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if key, err := strconv.Unquote(lit.Value); err == nil {
+				keys[key] = true
+			}
+		}
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !wanted[fn.Name.Name] {
+			continue
+		}
+		delete(wanted, fn.Name.Name)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if sel, ok := n.Fun.(*ast.SelectorExpr); ok && strings.HasPrefix(sel.Sel.Name, "Get") && len(n.Args) > 0 {
+					literal(n.Args[0])
+				}
+			case *ast.IndexExpr:
+				if ident, ok := n.X.(*ast.Ident); ok && (ident.Name == "raw" || ident.Name == "config") {
+					literal(n.Index)
+				}
+			}
+			return true
+		})
+	}
+	if len(wanted) > 0 {
+		t.Fatalf("functions not found in %s: %v", file, wanted)
+	}
+	return keys
+}
+
+// diffKeys returns the keys of a that are missing from b, sorted.
+func diffKeys(a, b map[string]bool) []string {
+	var missing []string
+	for key := range a {
+		if !b[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func TestConfigSchemaMatchesParsedKeys(t *testing.T) {
+	var root struct {
+		Properties map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(configSchema), &root); err != nil {
+		t.Fatalf("config schema is not valid JSON: %v", err)
+	}
+
+	// Deprecated aliases are resolved before parseConfig reads anything
+	schemaKeys := map[string]bool{}
+	aliases := map[string]bool{}
+	for _, alias := range configAliases {
+		aliases[alias.Old] = true
+	}
+	for key := range root.Properties {
+		if !aliases[key] {
+			schemaKeys[key] = true
+		}
+	}
+	metricsKeys := map[string]bool{}
+	for key := range root.Properties["metrics"].Properties {
+		metricsKeys[key] = true
+	}
+
+	parsed := configKeysRead(t, "plugin.go", "parseConfig")
+	validated := configKeysRead(t, "plugin.go", "Validate")
+	metrics := configKeysRead(t, "metrics.go", "parseMetricsConfig")
+
+	if missing := diffKeys(parsed, schemaKeys); len(missing) > 0 {
+		t.Errorf("keys read by parseConfig but missing from the schema: %v", missing)
+	}
+	if missing := diffKeys(validated, schemaKeys); len(missing) > 0 {
+		t.Errorf("keys read by Validate but missing from the schema: %v", missing)
+	}
+	if missing := diffKeys(schemaKeys, parsed); len(missing) > 0 {
+		t.Errorf("schema keys never read by parseConfig: %v", missing)
+	}
+	if missing := diffKeys(metrics, metricsKeys); len(missing) > 0 {
+		t.Errorf("metrics keys read by parseMetricsConfig but missing from the schema: %v", missing)
+	}
+	if missing := diffKeys(metricsKeys, metrics); len(missing) > 0 {
+		t.Errorf("metrics schema keys never read by parseMetricsConfig: %v", missing)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// execProbeScript is written to a temporary build directory and run to
+// confirm that files there can be executed, as cargo's build scripts must be.
+const execProbeScript = "#!/bin/sh\nexit 0\n"
+
+// tempRoot returns the directory temporary allocations are made in: temp_dir
+// when configured, otherwise the system default.
+func tempRoot(cfg *Config) string {
+	if cfg.TempDir != "" {
+		return cfg.TempDir
+	}
+	return os.TempDir()
+}
+
+// validateTempDir checks the temp_dir setting. The directory itself is only
+// checked when something is allocated in it.
+func validateTempDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := validateArgValue(dir); err != nil {
+		return err
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("temp_dir must be an absolute path")
+	}
+	return nil
+}
+
+// makeTempDir creates a directory under tempRoot for purpose and returns it
+// with a cleanup function that removes it.
+func makeTempDir(cfg *Config, pattern, purpose string) (string, func(), error) {
+	root := tempRoot(cfg)
+	dir, err := os.MkdirTemp(root, pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("temp_dir %s: failed to create %s: %w", root, purpose, err)
+	}
+	return dir, func() { _ = os.RemoveAll(dir) }, nil
+}
+
+// makeBuildTempDir is makeTempDir for directories cargo builds in. It fails
+// early when the directory does not accept executables, as on runners whose
+// /tmp is mounted noexec; the probe is skipped where scripts cannot run
+// directly, such as on Windows.
+func (p *CratesPlugin) makeBuildTempDir(ctx context.Context, cfg *Config, pattern, purpose string) (string, func(), error) {
+	dir, cleanup, err := makeTempDir(cfg, pattern, purpose)
+	if err != nil || runtime.GOOS == "windows" {
+		return dir, cleanup, err
+	}
+
+	root := tempRoot(cfg)
+	probe := filepath.Join(dir, "relicta-exec-probe")
+	if err := os.WriteFile(probe, []byte(execProbeScript), 0o700); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("temp_dir %s: failed to write an executable probe for %s: %w", root, purpose, err)
+	}
+	if output, err := p.getExecutor().RunInDir(ctx, dir, probe); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("temp_dir %s: cannot execute files in %s, so cargo build scripts would fail there (is it mounted noexec?): %v %s", root, purpose, err, output)
+	}
+	if err := os.Remove(probe); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("temp_dir %s: failed to remove the executable probe from %s: %w", root, purpose, err)
+	}
+	return dir, cleanup, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateTempDir(t *testing.T) {
+	tests := []struct {
+		dir     string
+		wantErr bool
+	}{
+		{dir: "", wantErr: false},
+		{dir: "/var/tmp/ci", wantErr: false},
+		{dir: "tmp", wantErr: true},
+		{dir: "/var/tmp/with space", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			err := validateTempDir(tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateTempDir(%q) error = %v, wantErr %v", tt.dir, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMakeBuildTempDir(t *testing.T) {
+	t.Run("created under temp_dir and executable", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("the executable probe does not run on Windows")
+		}
+		root := t.TempDir()
+		p := &CratesPlugin{cmdExecutor: &RealCommandExecutor{}}
+
+		dir, cleanup, err := p.makeBuildTempDir(context.Background(), &Config{TempDir: root}, "probe-", "the test directory")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if filepath.Dir(dir) != root {
+			t.Errorf("directory %s should be created under %s", dir, root)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("the probe should be removed, found %d entries", len(entries))
+		}
+		cleanup()
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("cleanup should remove %s, stat err = %v", dir, err)
+		}
+	})
+
+	t.Run("noexec directory fails and is removed", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("the executable probe does not run on Windows")
+		}
+		root := t.TempDir()
+		var probed string
+		mock := &MockCommandExecutor{
+			RunInDirFunc: func(ctx context.Context, dir string, name string, args ...string) ([]byte, error) {
+				probed = dir
+				return nil, errors.New("permission denied")
+			},
+		}
+		p := &CratesPlugin{cmdExecutor: mock}
+
+		_, _, err := p.makeBuildTempDir(context.Background(), &Config{TempDir: root}, "probe-", "the temporary target_dir")
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, want := range []string{"temp_dir " + root, "cannot execute files in the temporary target_dir", "noexec"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q should contain %q", err, want)
+			}
+		}
+		if _, statErr := os.Stat(probed); !os.IsNotExist(statErr) {
+			t.Errorf("the probed directory %s should be removed, stat err = %v", probed, statErr)
+		}
+	})
+
+	t.Run("missing temp_dir names the directory", func(t *testing.T) {
+		root := filepath.Join(t.TempDir(), "missing")
+		p := &CratesPlugin{cmdExecutor: &MockCommandExecutor{}}
+
+		_, _, err := p.makeBuildTempDir(context.Background(), &Config{TempDir: root}, "probe-", "the temporary target_dir")
+		if err == nil || !strings.Contains(err.Error(), "temp_dir "+root+": failed to create the temporary target_dir") {
+			t.Errorf("error = %v, want it to name %s and the operation", err, root)
+		}
+	})
+}
+
+func TestExecuteTempDir(t *testing.T) {
+	root := t.TempDir()
+
+	var usedDir string
+	mock := &MockCommandExecutor{
+		RunFunc: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			for i, arg := range args {
+				if arg == "--target-dir" && i+1 < len(args) {
+					usedDir = args[i+1]
+				}
+			}
+			return []byte("Uploaded successfully"), nil
+		},
+	}
+	p := &CratesPlugin{cmdExecutor: mock}
+
+	resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+		Hook:    plugin.HookPostPublish,
+		Config:  map[string]any{"token": "test-token", "target_dir": "temp", "temp_dir": root},
+		Context: plugin.ReleaseContext{Version: "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resp.Success {
+		t.Fatalf("publish failed: %s", resp.Error)
+	}
+	if filepath.Dir(usedDir) != root {
+		t.Errorf("target dir %q should be created under temp_dir %s", usedDir, root)
+	}
+	if _, err := os.Stat(usedDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed after publishing, stat err = %v", usedDir, err)
+	}
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+// tokenEnvVar returns the environment variable cargo reads the publish token
+// from: CARGO_REGISTRIES_<NAME>_TOKEN for a named registry, otherwise
+// CARGO_REGISTRY_TOKEN.
+func tokenEnvVar(cfg *Config) string {
+	return registryTokenEnvVar(cfg.Registry)
+}
+
+// registryTokenEnvVar maps a registry name to its token variable the way
+// cargo does. Registry URLs have no variable of their own and yield "".
+func registryTokenEnvVar(registry string) string {
+	if prefix := registryEnvPrefix(registry); prefix != "" {
+		return prefix + "TOKEN"
+	}
+	return ""
+}
+
+// registryEnvPrefix returns the prefix of cargo's per-registry environment
+// variables: the name is uppercased and dashes become underscores, giving
+// CARGO_REGISTRIES_<NAME>_. crates.io, named or not, uses CARGO_REGISTRY_.
+// Registry URLs yield "".
+func registryEnvPrefix(registry string) string {
+	if registry == "" || registry == cratesIORegistry {
+		return "CARGO_REGISTRY_"
+	}
+	if strings.Contains(registry, "://") {
+		return ""
+	}
+	name := strings.ToUpper(strings.ReplaceAll(registry, "-", "_"))
+	return "CARGO_REGISTRIES_" + name + "_"
+}
+
+// cratesIORegistry is cargo's name for the default registry.
+const cratesIORegistry = "crates-io"
+
+// registryNamePattern matches cargo registry names, the keys of tokens.
+var registryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// validateTokens checks that every tokens key is a registry name and every
+// value passes validateToken.
+func validateTokens(tokens map[string]string) error {
+	for _, name := range sortedKeys(tokens) {
+		if !registryNamePattern.MatchString(name) {
+			return fmt.Errorf("tokens key %q is not a registry name", name)
+		}
+		if err := validateToken(strings.TrimSpace(tokens[name])); err != nil {
+			return fmt.Errorf("tokens[%s]: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// forbidInlineTokenEnv enables forbid_inline_token for every release config,
+// for organizations enforcing the policy from the CI environment.
+const forbidInlineTokenEnv = "RELICTA_CRATES_FORBID_INLINE_TOKEN"
+
+// inlineTokenKeys are the config keys that carry the token itself.
+var inlineTokenKeys = []string{"token", "tokens"}
+
+// forbidInlineToken reports whether inline tokens are forbidden, by the
+// forbid_inline_token option or by forbidInlineTokenEnv. The environment can
+// only enable the policy, so a release config cannot opt out of it.
+func forbidInlineToken(configured bool) bool {
+	if configured {
+		return true
+	}
+	enforced, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv(forbidInlineTokenEnv)))
+	return enforced
+}
+
+// validateInlineToken rejects a config that sets token or tokens while
+// inline tokens are forbidden. Only the key is named, never its value.
+func validateInlineToken(keys map[string]bool, forbid bool) error {
+	if !forbid {
+		return nil
+	}
+	for _, key := range inlineTokenKeys {
+		if keys[key] {
+			return fmt.Errorf("%s must not be set in the config while forbid_inline_token is enabled; provide the token through CARGO_REGISTRY_TOKEN (or the variable named by token_env), token_file, token_command or token_keyring instead", key)
+		}
+	}
+	return nil
+}
+
+// configuredToken returns the trimmed token found without reading files or
+// running commands, in order of precedence: token, the tokens entry for the
+// registry (crates-io when none is configured), then the first of envNames
+// that is set. The source names where the token came from, or is "" without
+// one.
+func configuredToken(explicit, registry string, tokens map[string]string, envNames []string) (token, source string) {
+	if token := strings.TrimSpace(explicit); token != "" {
+		return token, "token"
+	}
+	key := registry
+	if key == "" {
+		key = cratesIORegistry
+	}
+	if token := strings.TrimSpace(tokens[key]); token != "" {
+		return token, "tokens[" + key + "]"
+	}
+	for _, name := range envNames {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, "env " + name
+		}
+	}
+	return "", ""
+}
+
+// tokenEnvNames returns the variables the token is read from, in order: the
+// variable named by token_env, the registry's CARGO_REGISTRIES_<NAME>_TOKEN
+// when registryEnv is set, then CARGO_REGISTRY_TOKEN.
+func tokenEnvNames(tokenEnvName, registry string, registryEnv bool) []string {
+	var names []string
+	if tokenEnvName != "" {
+		names = append(names, tokenEnvName)
+	}
+	if name := registryTokenEnvVar(registry); registryEnv && name != "" && name != "CARGO_REGISTRY_TOKEN" {
+		names = append(names, name)
+	}
+	return append(names, "CARGO_REGISTRY_TOKEN")
+}
+
+// templateMarkers are the openings of CI and shell substitutions that were
+// not expanded before the value reached the plugin.
+var templateMarkers = []string{"${{", "${", "$(", "%{"}
+
+// validateToken rejects token values that cannot be real tokens. Errors never
+// include any part of the token.
+func validateToken(token string) error {
+	for _, marker := range templateMarkers {
+		if strings.Contains(token, marker) {
+			return fmt.Errorf("token looks like an unexpanded CI template (contains %q); check that the secret is defined and expanded", marker)
+		}
+	}
+	for _, r := range token {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("token contains whitespace or control characters; check for a stray newline or a pasted command")
+		}
+	}
+	return nil
+}
+
+// envNamePattern matches portable environment variable names for token_env.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateTokenEnv checks the token_env variable name.
+func validateTokenEnv(name string) error {
+	if name != "" && !envNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not an environment variable name", name)
+	}
+	return nil
+}
+
+// tokenEnv returns the environment entries that pass the token to cargo when
+// token_via_env is set, keeping it out of the command line.
+func tokenEnv(cfg *Config) []string {
+	if !cfg.TokenViaEnv || cfg.Token == "" {
+		return nil
+	}
+	return []string{tokenEnvVar(cfg) + "=" + cfg.Token}
+}
+
+// validateTokenViaEnv checks that the token can be passed through the
+// environment for the configured registry.
+func validateTokenViaEnv(registry, index string) error {
+	if strings.Contains(registry, "://") {
+		return fmt.Errorf("token_via_env needs a registry name, not a URL, to derive CARGO_REGISTRIES_<NAME>_TOKEN")
+	}
+	if index != "" {
+		return fmt.Errorf("token_via_env cannot be combined with index: cargo only reads --index tokens from --token")
+	}
+	return nil
+}
+
+// validateTokenFile checks the token_file location. Relative paths follow
+// validatePath; absolute paths are only allowed under token_file_root, the
+// directory where a secrets manager mounts credentials.
+func validateTokenFile(path, root string) error {
+	if path == "" {
+		return nil
+	}
+	if root != "" && !filepath.IsAbs(root) {
+		return fmt.Errorf("token_file_root must be an absolute path")
+	}
+
+	cleaned := filepath.Clean(path)
+	if !filepath.IsAbs(cleaned) {
+		return validatePath(path)
+	}
+	if root == "" {
+		return fmt.Errorf("absolute paths are only allowed under token_file_root")
+	}
+	if !isUnderDir(cleaned, root) {
+		return fmt.Errorf("%s is not under token_file_root %s", path, root)
+	}
+	return nil
+}
+
+// resolveToken fills in the token from token_file, token_command,
+// token_keyring or codeartifact when no token was configured directly, then
+// sanity-checks the result.
+func (p *CratesPlugin) resolveToken(ctx context.Context, cfg *Config) error {
+	if err := resolveTokenFile(cfg); err != nil {
+		return err
+	}
+	if err := p.resolveTokenCommand(ctx, cfg); err != nil {
+		return err
+	}
+	if err := p.resolveTokenKeyring(cfg); err != nil {
+		return err
+	}
+	if err := p.resolveTokenCodeArtifact(ctx, cfg); err != nil {
+		return err
+	}
+	return validateToken(cfg.Token)
+}
+
+// resolveTokenFile sets the token from token_file when no token was
+// configured directly. The file contents are trimmed of surrounding
+// whitespace, and errors name the path but never the contents.
+func resolveTokenFile(cfg *Config) error {
+	if cfg.TokenFile == "" || cfg.Token != "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return fmt.Errorf("failed to read token_file %s: %w", cfg.TokenFile, err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("token_file %s is empty", cfg.TokenFile)
+	}
+
+	cfg.Token = token
+	cfg.TokenSource = "token_file"
+	return nil
+}
+
+// defaultTokenCmdTimeout bounds token_command when token_command_timeout is unset.
+const defaultTokenCmdTimeout = 30 * time.Second
+
+// shellMetacharacters are characters that only mean something to a shell.
+// token_command runs without one, so a lone string containing them was
+// almost certainly written for sh -c.
+const shellMetacharacters = "|&;<>()$`\\\"'*?[]#~ \t\n"
+
+// parseTokenCommand reads token_command as an argv list. A plain string is
+// taken as a one-element command so validateTokenCommand can reject it when
+// it looks like a shell command line.
+func parseTokenCommand(raw any) []string {
+	switch v := raw.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		command := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				command = append(command, s)
+			}
+		}
+		return command
+	}
+	return nil
+}
+
+// validateTokenCommand checks the token_command argv.
+func validateTokenCommand(command []string, tokenFile string) error {
+	if len(command) == 0 {
+		return nil
+	}
+	if tokenFile != "" {
+		return fmt.Errorf("token_command and token_file are mutually exclusive")
+	}
+	if strings.TrimSpace(command[0]) == "" {
+		return fmt.Errorf("the command name must not be empty")
+	}
+	if len(command) == 1 && strings.ContainsAny(command[0], shellMetacharacters) {
+		return fmt.Errorf("%q looks like a shell command line, but token_command runs without a shell; list the program and its arguments separately, e.g. [\"op\", \"read\", \"op://vault/crates/token\"]", command[0])
+	}
+	return nil
+}
+
+// resolveTokenCommand runs token_command, bounded by token_command_timeout,
+// and uses its trimmed standard output as the token. Failures include the
+// command's standard error, which the response scrubber cleans of known
+// secrets; standard output is never reported.
+func (p *CratesPlugin) resolveTokenCommand(ctx context.Context, cfg *Config) error {
+	if len(cfg.TokenCommand) == 0 || cfg.Token != "" {
+		return nil
+	}
+
+	timeout := cfg.TokenCmdTimeout
+	if timeout <= 0 {
+		timeout = defaultTokenCmdTimeout
+	}
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	name := cfg.TokenCommand[0]
+	output, err := p.getExecutor().Output(cmdCtx, name, cfg.TokenCommand[1:]...)
+	if err != nil {
+		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return fmt.Errorf("token_command %s timed out after %s", name, timeout)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return fmt.Errorf("token_command %s failed: %v\nStderr: %s", name, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("token_command %s failed: %v", name, err)
+	}
+
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return fmt.Errorf("token_command %s printed no token", name)
+	}
+
+	cfg.Token = token
+	cfg.TokenSource = "token_command"
+	return nil
+}
+
+// redactedValue replaces secret argument values in rendered commands.
+const redactedValue = "***"
+
+// secretFlags lists the cargo flags whose value is a credential.
+var secretFlags = map[string]bool{
+	"--token": true,
+}
+
+// redactArgs returns a copy of args with the values of secretFlags replaced by
+// redactedValue, in both the "--flag value" and "--flag=value" forms. The
+// arguments used for execution are left untouched.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	copy(redacted, args)
+	for i, arg := range redacted {
+		if secretFlags[arg] && i+1 < len(redacted) {
+			redacted[i+1] = redactedValue
+			continue
+		}
+		if flag, _, ok := strings.Cut(arg, "="); ok && secretFlags[flag] {
+			redacted[i] = flag + "=" + redactedValue
+		}
+	}
+	return redacted
+}
+
+// formatCommand renders a cargo invocation for outputs with secrets redacted.
+func formatCommand(cfg *Config, args []string) string {
+	return cargoBinary(cfg) + " " + strings.Join(redactArgs(args), " ")
+}
+
+// knownSecrets returns the token values that must not leave the plugin: the
+// configured token, every tokens entry, CARGO_REGISTRY_TOKEN, the token_env
+// variable, the registry's own token variable and, with trusted publishing,
+// the OIDC request token, plus their URL-escaped forms.
+func knownSecrets(cfg *Config) []string {
+	candidates := []string{cfg.Token, os.Getenv("CARGO_REGISTRY_TOKEN")}
+	if cfg.TokenEnv != "" {
+		candidates = append(candidates, os.Getenv(cfg.TokenEnv))
+	}
+	if cfg.TrustedPublishing {
+		candidates = append(candidates, os.Getenv(githubOIDCRequestTokenEnv))
+	}
+	if name := tokenEnvVar(cfg); name != "" && name != "CARGO_REGISTRY_TOKEN" {
+		candidates = append(candidates, os.Getenv(name))
+	}
+	for _, name := range sortedKeys(cfg.Tokens) {
+		candidates = append(candidates, cfg.Tokens[name])
+	}
+	candidates = append(candidates, proxyPassword(cfg.Proxy))
+	for _, target := range cfg.Registries {
+		candidates = append(candidates, target.Token)
+		if target.TokenEnv != "" {
+			candidates = append(candidates, os.Getenv(target.TokenEnv))
+		}
+	}
+
+	var secrets []string
+	for _, secret := range candidates {
+		if strings.TrimSpace(secret) == "" {
+			continue
+		}
+		secrets = append(secrets, secret)
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			secrets = append(secrets, escaped)
+		}
+	}
+	return secrets
+}
+
+// scrubber replaces every occurrence of the secrets with redactedValue.
+type scrubber struct {
+	replacer *strings.Replacer
+}
+
+// newScrubber returns a scrubber for the secrets; it is a no-op without any.
+func newScrubber(secrets []string) *scrubber {
+	if len(secrets) == 0 {
+		return &scrubber{}
+	}
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		pairs = append(pairs, secret, redactedValue)
+	}
+	return &scrubber{replacer: strings.NewReplacer(pairs...)}
+}
+
+// scrub returns s with all secrets replaced.
+func (s *scrubber) scrub(value string) string {
+	if s.replacer == nil {
+		return value
+	}
+	return s.replacer.Replace(value)
+}
+
+// scrubResponse removes secrets from the message, the error, and every string
+// output, including string lists and decision reasons. Cargo can echo its
+// command line or authenticated URLs in error output.
+func (s *scrubber) scrubResponse(resp *plugin.ExecuteResponse) {
+	if s.replacer == nil || resp == nil {
+		return
+	}
+	resp.Message = s.scrub(resp.Message)
+	resp.Error = s.scrub(resp.Error)
+	for key, value := range resp.Outputs {
+		switch v := value.(type) {
+		case string:
+			resp.Outputs[key] = s.scrub(v)
+		case []string:
+			scrubbed := make([]string, len(v))
+			for i, item := range v {
+				scrubbed[i] = s.scrub(item)
+			}
+			resp.Outputs[key] = scrubbed
+		case []decision:
+			scrubbed := make([]decision, len(v))
+			for i, d := range v {
+				d.Subject = s.scrub(d.Subject)
+				d.Reason = s.scrub(d.Reason)
+				scrubbed[i] = d
+			}
+			resp.Outputs[key] = scrubbed
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// cratesIOAPI is the crates.io API that issues trusted publishing tokens.
+	cratesIOAPI = "https://crates.io"
+	// trustedPublishingPath exchanges OIDC tokens (POST) and revokes the
+	// temporary publish token (DELETE).
+	trustedPublishingPath = "/api/v1/trusted_publishing/tokens"
+	// trustedPublishingAudience is the OIDC audience crates.io accepts.
+	trustedPublishingAudience = "crates.io"
+	// trustedPublishingTimeout bounds each OIDC and crates.io request.
+	trustedPublishingTimeout = 30 * time.Second
+	// tokenSourceTrustedPublishing marks a token exchanged by trusted publishing.
+	tokenSourceTrustedPublishing = "trusted_publishing"
+	// maxTrustedPublishingResponse bounds response bodies read from either side.
+	maxTrustedPublishingResponse = 1 << 20
+)
+
+// GitHub Actions exposes the OIDC token endpoint to jobs with
+// `permissions: id-token: write` through these variables.
+const (
+	githubOIDCRequestURLEnv   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	githubOIDCRequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// getHTTPClient returns the HTTP client for trusted publishing.
+func (p *CratesPlugin) getHTTPClient() *http.Client {
+	if p.httpClient != nil {
+		return p.httpClient
+	}
+	return &http.Client{Timeout: trustedPublishingTimeout}
+}
+
+// getCratesIOAPI returns the crates.io API base URL.
+func (p *CratesPlugin) getCratesIOAPI() string {
+	if p.cratesIOAPI != "" {
+		return p.cratesIOAPI
+	}
+	return cratesIOAPI
+}
+
+// validateTrustedPublishing checks that trusted publishing targets crates.io
+// and that no other token source is configured next to it.
+func validateTrustedPublishing(cfg *Config) error {
+	if !cfg.TrustedPublishing {
+		return nil
+	}
+	if (cfg.Registry != "" && cfg.Registry != cratesIORegistry) || cfg.Index != "" {
+		return fmt.Errorf("trusted_publishing is only available for crates.io, not registry or index")
+	}
+
+	var conflicts []string
+	if cfg.Token != "" {
+		conflicts = append(conflicts, "token")
+	}
+	if len(cfg.Tokens) > 0 {
+		conflicts = append(conflicts, "tokens")
+	}
+	if cfg.TokenFile != "" {
+		conflicts = append(conflicts, "token_file")
+	}
+	if len(cfg.TokenCommand) > 0 {
+		conflicts = append(conflicts, "token_command")
+	}
+	if cfg.TokenKeyring != nil {
+		conflicts = append(conflicts, "token_keyring")
+	}
+	if cfg.CodeArtifact != nil {
+		conflicts = append(conflicts, "codeartifact")
+	}
+	if cfg.CredentialProvider != "" {
+		conflicts = append(conflicts, "credential_provider")
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("trusted_publishing obtains a temporary token and cannot be combined with %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// resolveTrustedPublishing exchanges the CI job's OIDC token for a temporary
+// crates.io publish token when trusted_publishing is set. It runs only when
+// cargo will upload, so dry runs never create tokens; revokeTrustedPublishing
+// revokes the token once the hook finishes.
+func (p *CratesPlugin) resolveTrustedPublishing(ctx context.Context, cfg *Config) error {
+	if !cfg.TrustedPublishing || cfg.Token != "" {
+		return nil
+	}
+
+	jwt, err := p.requestOIDCToken(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := p.exchangeOIDCToken(ctx, jwt)
+	if err != nil {
+		return err
+	}
+	cfg.Token = token
+	cfg.TokenSource = tokenSourceTrustedPublishing
+	cfg.TokenViaEnv = true
+	return nil
+}
+
+// requestOIDCToken asks the CI provider for an OIDC token with the crates.io
+// audience. Only GitHub Actions is supported.
+func (p *CratesPlugin) requestOIDCToken(ctx context.Context) (string, error) {
+	requestURL := os.Getenv(githubOIDCRequestURLEnv)
+	requestToken := os.Getenv(githubOIDCRequestTokenEnv)
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("trusted_publishing needs an OIDC token from CI, but %s and %s are not set; run on GitHub Actions with `permissions: id-token: write`, or configure a token instead", githubOIDCRequestURLEnv, githubOIDCRequestTokenEnv)
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil || u.Scheme != "https" {
+		return "", fmt.Errorf("trusted_publishing: %s must be an https URL", githubOIDCRequestURLEnv)
+	}
+	query := u.Query()
+	query.Set("audience", trustedPublishingAudience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("trusted_publishing: failed to build the OIDC token request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := p.doTrustedPublishingRequest(req, http.StatusOK, &result); err != nil {
+		return "", fmt.Errorf("trusted_publishing: failed to request an OIDC token from GitHub Actions: %w", err)
+	}
+	if result.Value == "" {
+		return "", fmt.Errorf("trusted_publishing: GitHub Actions returned an empty OIDC token")
+	}
+	return result.Value, nil
+}
+
+// exchangeOIDCToken trades the OIDC token for a temporary publish token.
+func (p *CratesPlugin) exchangeOIDCToken(ctx context.Context, jwt string) (string, error) {
+	body, err := json.Marshal(map[string]string{"jwt": jwt})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.getCratesIOAPI()+trustedPublishingPath, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("trusted_publishing: failed to build the token exchange request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := p.doTrustedPublishingRequest(req, http.StatusOK, &result); err != nil {
+		return "", fmt.Errorf("trusted_publishing: crates.io rejected the OIDC token exchange: %w; check that a trusted publisher is configured for this crate, repository and workflow", err)
+	}
+	if strings.TrimSpace(result.Token) == "" {
+		return "", fmt.Errorf("trusted_publishing: crates.io returned an empty publish token")
+	}
+	return strings.TrimSpace(result.Token), nil
+}
+
+// revokeTrustedPublishing revokes a token obtained by trusted publishing and
+// records the outcome; a failed revocation is a warning, since the token
+// expires on its own shortly after.
+func (p *CratesPlugin) revokeTrustedPublishing(ctx context.Context, cfg *Config, outputs map[string]any) {
+	if cfg.TokenSource != tokenSourceTrustedPublishing {
+		return
+	}
+
+	// Revoke even when the hook was cancelled
+	revokeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), trustedPublishingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(revokeCtx, http.MethodDelete, p.getCratesIOAPI()+trustedPublishingPath, nil)
+	if err == nil {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		err = p.doTrustedPublishingRequest(req, http.StatusNoContent, nil)
+	}
+	outputs["trusted_publishing_token_revoked"] = err == nil
+	if err != nil {
+		warnings, _ := outputs["warnings"].([]string)
+		outputs["warnings"] = append(warnings, fmt.Sprintf("failed to revoke the trusted publishing token: %v; it expires on its own", err))
+	}
+}
+
+// doTrustedPublishingRequest sends req with the plugin's User-Agent and
+// decodes a JSON response into result when the status is want. Other statuses
+// are reported with the error detail crates.io or the CI provider returned.
+func (p *CratesPlugin) doTrustedPublishingRequest(req *http.Request, want int, result any) error {
+	req.Header.Set("User-Agent", userAgent(req.Context()))
+	resp, err := p.getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTrustedPublishingResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != want && !(want == http.StatusNoContent && resp.StatusCode == http.StatusOK) {
+		return fmt.Errorf("%s %s returned %s%s", req.Method, req.URL.Redacted(), resp.Status, responseDetail(body))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("%s %s returned invalid JSON: %v", req.Method, req.URL.Redacted(), err)
+	}
+	return nil
+}
+
+// responseDetail extracts the error details from a crates.io style error body
+// ({"errors": [{"detail": "..."}]}), or "" when there are none.
+func responseDetail(body []byte) string {
+	var parsed struct {
+		Errors []struct {
+			Detail string `json:"detail"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return ""
+	}
+	var details []string
+	for _, e := range parsed.Errors {
+		if e.Detail != "" {
+			details = append(details, e.Detail)
+		}
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(details, "; ")
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/relicta-tech/relicta-plugin-sdk/plugin"
+)
+
+func TestValidateTrustedPublishing(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{name: "unset", cfg: Config{Registry: "internal", Token: "secret"}},
+		{name: "crates.io", cfg: Config{TrustedPublishing: true}},
+		{name: "explicit crates-io", cfg: Config{TrustedPublishing: true, Registry: "crates-io"}},
+		{name: "named registry", cfg: Config{TrustedPublishing: true, Registry: "internal"}, wantErr: "only available for crates.io"},
+		{name: "index", cfg: Config{TrustedPublishing: true, Index: "sparse+https://example.com/"}, wantErr: "only available for crates.io"},
+		{
+			name:    "token sources",
+			cfg:     Config{TrustedPublishing: true, Token: "secret", TokenFile: "token.txt", CodeArtifact: &codeArtifactConfig{Domain: "acme"}},
+			wantErr: "cannot be combined with token, token_file, codeartifact",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTrustedPublishing(&tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// trustedPublishingServer fakes both the GitHub Actions OIDC endpoint
+// (/oidc) and the crates.io trusted publishing API.
+type trustedPublishingServer struct {
+	*httptest.Server
+
+	exchangeStatus int
+	exchangeBody   string
+	revokeStatus   int
+
+	mu       sync.Mutex
+	requests []string
+	agents   []string
+}
+
+const (
+	testOIDCRequestToken = "gha-request-token-4f1c"
+	testOIDCToken        = "eyJhbGciOiJSUzI1NiJ9.oidc-claims.signature"
+	testPublishToken     = "cio-trusted-8b3e2a"
+)
+
+func newTrustedPublishingServer(t *testing.T) *trustedPublishingServer {
+	t.Helper()
+	s := &trustedPublishingServer{
+		exchangeStatus: http.StatusOK,
+		exchangeBody:   fmt.Sprintf(`{"token": %q}`, testPublishToken),
+		revokeStatus:   http.StatusNoContent,
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.agents = append(s.agents, r.UserAgent())
+		s.mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/oidc":
+			if r.Header.Get("Authorization") != "Bearer "+testOIDCRequestToken {
+				http.Error(w, "bad request token", http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("audience") != trustedPublishingAudience || r.URL.Query().Get("api-version") != "2.0" {
+				http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, `{"count": 1, "value": %q}`, testOIDCToken)
+		case r.Method == http.MethodPost && r.URL.Path == trustedPublishingPath:
+			var body struct {
+				JWT string `json:"jwt"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.JWT != testOIDCToken {
+				http.Error(w, `{"errors": [{"detail": "invalid jwt"}]}`, http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(s.exchangeStatus)
+			fmt.Fprint(w, s.exchangeBody)
+		case r.Method == http.MethodDelete && r.URL.Path == trustedPublishingPath:
+			if r.Header.Get("Authorization") != "Bearer "+testPublishToken {
+				http.Error(w, `{"errors": [{"detail": "unknown token"}]}`, http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(s.revokeStatus)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *trustedPublishingServer) calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *trustedPublishingServer) userAgents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.agents...)
+}
+
+func TestExecuteTrustedPublishing(t *testing.T) {
+	tests := []struct {
+		name         string
+		noOIDC       bool
+		dryRun       bool
+		exchange     func(s *trustedPublishingServer)
+		revokeStatus int
+		publishErr   error
+		wantRequests []string
+		wantErrorHas []string
+		wantWarning  string
+	}{
+		{
+			name:         "token exchanged, used and revoked",
+			wantRequests: []string{"GET /oidc", "POST " + trustedPublishingPath, "DELETE " + trustedPublishingPath},
+		},
+		{
+			name:         "no OIDC environment",
+			noOIDC:       true,
+			wantErrorHas: []string{"trusted_publishing needs an OIDC token from CI", "permissions: id-token: write"},
+		},
+		{
+			name: "exchange rejected",
+			exchange: func(s *trustedPublishingServer) {
+				s.exchangeStatus = http.StatusBadRequest
+				s.exchangeBody = `{"errors": [{"detail": "No matching Trusted Publishing config found"}]}`
+			},
+			wantRequests: []string{"GET /oidc", "POST " + trustedPublishingPath},
+			wantErrorHas: []string{"crates.io rejected the OIDC token exchange", "400 Bad Request: No matching Trusted Publishing config found"},
+		},
+		{
+			name:         "revoked after a failed publish",
+			publishErr:   errors.New("exit status 101"),
+			wantRequests: []string{"GET /oidc", "POST " + trustedPublishingPath, "DELETE " + trustedPublishingPath},
+			wantErrorHas: []string{"cargo publish failed"},
+		},
+		{
+			name:         "revocation failure is a warning",
+			revokeStatus: http.StatusInternalServerError,
+			wantRequests: []string{"GET /oidc", "POST " + trustedPublishingPath, "DELETE " + trustedPublishingPath},
+			wantWarning:  "failed to revoke the trusted publishing token",
+		},
+		{
+			name:   "dry run creates no token",
+			dryRun: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CARGO_REGISTRY_TOKEN", "")
+			server := newTrustedPublishingServer(t)
+			if tt.exchange != nil {
+				tt.exchange(server)
+			}
+			if tt.revokeStatus != 0 {
+				server.revokeStatus = tt.revokeStatus
+			}
+			if tt.noOIDC {
+				t.Setenv(githubOIDCRequestURLEnv, "")
+				t.Setenv(githubOIDCRequestTokenEnv, "")
+			} else {
+				t.Setenv(githubOIDCRequestURLEnv, server.URL+"/oidc?api-version=2.0")
+				t.Setenv(githubOIDCRequestTokenEnv, testOIDCRequestToken)
+			}
+
+			mock := &MockCommandExecutor{
+				RunWithEnvFunc: func(context.Context, string, []string, string, ...string) ([]byte, error) {
+					// Cargo may echo the token, which must not reach the outputs
+					return []byte("Uploading fixture with " + testPublishToken), tt.publishErr
+				},
+			}
+			p := &CratesPlugin{cmdExecutor: mock, httpClient: server.Client(), cratesIOAPI: server.URL}
+			resp, err := p.Execute(context.Background(), plugin.ExecuteRequest{
+				Hook:   plugin.HookPostPublish,
+				Config: map[string]any{"trusted_publishing": true},
+				Context: plugin.ReleaseContext{
+					Version:     "v1.0.0",
+					Environment: map[string]string{"RELICTA_CORRELATION_ID": "release-42"},
+				},
+				DryRun: tt.dryRun,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if leaked := fmt.Sprint(resp); strings.Contains(leaked, testPublishToken) || strings.Contains(leaked, testOIDCRequestToken) {
+				t.Errorf("token leaked into the response: %+v", resp)
+			}
+			if got := server.calls(); strings.Join(got, ", ") != strings.Join(tt.wantRequests, ", ") {
+				t.Errorf("requests = %v, want %v", got, tt.wantRequests)
+			}
+			for _, agent := range server.userAgents() {
+				if agent != "relicta-plugin-crates/2.0.0 (correlation_id=release-42)" {
+					t.Errorf("User-Agent = %q, want the correlation ID", agent)
+				}
+			}
+
+			if len(tt.wantErrorHas) > 0 {
+				if resp.Success {
+					t.Fatal("expected failure")
+				}
+				for _, want := range tt.wantErrorHas {
+					if !strings.Contains(resp.Error, want) {
+						t.Errorf("error %q should contain %q", resp.Error, want)
+					}
+				}
+				return
+			}
+			if !resp.Success {
+				t.Fatalf("publish failed: %s", resp.Error)
+			}
+			if tt.dryRun {
+				if resp.Outputs["auth"] != authTrustedPublishing {
+					t.Errorf("auth = %v, want %s", resp.Outputs["auth"], authTrustedPublishing)
+				}
+				return
+			}
+
+			warnings, _ := resp.Outputs["warnings"].([]string)
+			if tt.wantWarning != "" {
+				if resp.Outputs["trusted_publishing_token_revoked"] != false {
+					t.Errorf("expected trusted_publishing_token_revoked false, got %v", resp.Outputs["trusted_publishing_token_revoked"])
+				}
+				if len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1], tt.wantWarning) {
+					t.Errorf("warnings %v should contain %q", warnings, tt.wantWarning)
+				}
+				return
+			}
+			if resp.Outputs["trusted_publishing_token_revoked"] != true {
+				t.Errorf("expected trusted_publishing_token_revoked, got %v", resp.Outputs["trusted_publishing_token_revoked"])
+			}
+
+			var publish *ExecutorCall
+			for _, call := range mock.GetCalls() {
+				if call.Method == "RunWithEnv" {
+					publish = &call
+				}
+			}
+			if publish == nil {
+				t.Fatal("expected cargo publish to run")
+			}
+			if strings.Contains(strings.Join(publish.Args, " "), "--token") {
+				t.Errorf("expected no --token, got %v", publish.Args)
+			}
+			found := false
+			for _, entry := range publish.Env {
+				if entry == "CARGO_REGISTRY_TOKEN="+testPublishToken {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected CARGO_REGISTRY_TOKEN in the environment, got %v", publish.Env)
+			}
+		})
+	}
+}
+
+func TestRequestOIDCTokenRequiresHTTPS(t *testing.T) {
+	t.Setenv(githubOIDCRequestURLEnv, "http://actions.example.com/oidc")
+	t.Setenv(githubOIDCRequestTokenEnv, testOIDCRequestToken)
+
+	p := &CratesPlugin{}
+	_, err := p.requestOIDCToken(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "must be an https URL") {
+		t.Errorf("error = %v, want an https error", err)
+	}
+}